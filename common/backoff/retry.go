@@ -21,8 +21,11 @@
 package backoff
 
 import (
+	"context"
 	"sync"
 	"time"
+
+	"github.com/uber/cadence/common/clock"
 )
 
 type (
@@ -113,6 +116,42 @@ func Retry(operation Operation, policy RetryPolicy, isRetryable IsRetryable) err
 	}
 }
 
+// RetryContext is the context aware version of Retry, it stops retrying once the context is done
+// or the next backoff would exceed the context deadline, and returns the last error of the operation.
+// the backoffs are measured by the time source, so the retries can be driven by a fake time source in tests
+func RetryContext(ctx context.Context, timeSource clock.TimeSource, operation Operation, policy RetryPolicy, isRetryable IsRetryable) error {
+	var err error
+	var next time.Duration
+
+	r := NewRetrier(policy, timeSource)
+	for {
+		// operation completed successfully.  No need to retry.
+		if err = operation(); err == nil {
+			return nil
+		}
+
+		if next = r.NextBackOff(); next == done {
+			return err
+		}
+
+		// Check if the error is retryable
+		if isRetryable != nil && !isRetryable(err) {
+			return err
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && timeSource.Now().Add(next).After(deadline) {
+			return err
+		}
+
+		backoffCtx, cancel := clock.ContextWithTimeout(ctx, timeSource, next)
+		<-backoffCtx.Done()
+		cancel()
+		if ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
 // IgnoreErrors can be used as IsRetryable handler for Retry function to exclude certain errors from the retry list
 func IgnoreErrors(errorsToExclude []error) func(error) bool {
 	return func(err error) bool {
@@ -21,12 +21,15 @@
 package backoff
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+
+	"github.com/uber/cadence/common/clock"
 )
 
 type (
@@ -137,6 +140,110 @@ func (s *RetrySuite) TestIsRetryableFailure() {
 	s.Equal(1, i)
 }
 
+func (s *RetrySuite) TestRetryContextSuccess() {
+	i := 0
+	op := func() error {
+		i++
+
+		if i == 5 {
+			return nil
+		}
+
+		return &someError{}
+	}
+
+	policy := NewExponentialRetryPolicy(1 * time.Millisecond)
+	policy.SetMaximumInterval(5 * time.Millisecond)
+	policy.SetMaximumAttempts(10)
+
+	err := RetryContext(context.Background(), clock.NewRealTimeSource(), op, policy, nil)
+	s.NoError(err)
+	s.Equal(5, i)
+}
+
+func (s *RetrySuite) TestRetryContextCancelled() {
+	i := 0
+	op := func() error {
+		i++
+		return &someError{}
+	}
+
+	policy := NewExponentialRetryPolicy(1 * time.Millisecond)
+	policy.SetMaximumInterval(5 * time.Millisecond)
+	policy.SetMaximumAttempts(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := RetryContext(ctx, clock.NewRealTimeSource(), op, policy, nil)
+	s.Error(err)
+	s.Equal(1, i)
+}
+
+func (s *RetrySuite) TestRetryContextDeadlineExceedsBackoff() {
+	i := 0
+	op := func() error {
+		i++
+		return &someError{}
+	}
+
+	policy := NewExponentialRetryPolicy(time.Second)
+	policy.SetMaximumAttempts(10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := RetryContext(ctx, clock.NewRealTimeSource(), op, policy, nil)
+	s.Error(err)
+	s.Equal(1, i)
+}
+
+func (s *RetrySuite) TestRetryContextTimeSource() {
+	timeSource := clock.NewEventTimeSource().Update(time.Now())
+	attemptCh := make(chan int, 10)
+	i := 0
+	op := func() error {
+		i++
+		attemptCh <- i
+		if i == 3 {
+			return nil
+		}
+		return &someError{}
+	}
+
+	policy := NewExponentialRetryPolicy(time.Minute)
+	policy.SetBackoffCoefficient(1)
+	policy.SetMaximumInterval(time.Minute)
+	policy.SetMaximumAttempts(10)
+	policy.SetExpirationInterval(NoInterval)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- RetryContext(context.Background(), timeSource, op, policy, nil)
+	}()
+
+	// the retries only happen once the time source is moved past the backoffs
+	s.Equal(1, <-attemptCh)
+	for attempt := 2; attempt <= 3; attempt++ {
+		select {
+		case <-attemptCh:
+			s.Fail("operation is retried before the backoff elapses")
+		case <-time.After(10 * time.Millisecond):
+		}
+		s.Equal(attempt, s.advanceUntilAttempt(timeSource, attemptCh))
+	}
+	s.NoError(<-errCh)
+}
+
+func (s *RetrySuite) advanceUntilAttempt(timeSource *clock.EventTimeSource, attemptCh <-chan int) int {
+	for {
+		timeSource.Update(timeSource.Now().Add(2 * time.Minute))
+		select {
+		case attempt := <-attemptCh:
+			return attempt
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
 func (s *RetrySuite) TestConcurrentRetrier() {
 	policy := NewExponentialRetryPolicy(1 * time.Millisecond)
 	policy.SetMaximumInterval(10 * time.Millisecond)
@@ -0,0 +1,103 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package clock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type (
+	// timeSourceContext is the context which times out according to a time source
+	timeSourceContext struct {
+		context.Context
+		deadline time.Time
+		done     chan struct{}
+
+		sync.Mutex
+		err error
+	}
+)
+
+// ContextWithTimeout is the same as context.WithTimeout, except the timeout is measured by the time source,
+// so the timeout can be triggered by updating a fake time source in tests.
+// context.WithTimeout is used for the real time source, and the time sources unable to call a func after a duration
+func ContextWithTimeout(
+	ctx context.Context,
+	timeSource TimeSource,
+	timeout time.Duration,
+) (context.Context, context.CancelFunc) {
+
+	timerTimeSource, ok := timeSource.(TimerTimeSource)
+	if _, isRealTimeSource := timeSource.(*RealTimeSource); isRealTimeSource || !ok {
+		return context.WithTimeout(ctx, timeout)
+	}
+
+	timeoutCtx := &timeSourceContext{
+		Context:  ctx,
+		deadline: timeSource.Now().Add(timeout),
+		done:     make(chan struct{}),
+	}
+	stop := timerTimeSource.AfterFunc(timeout, func() {
+		timeoutCtx.cancel(context.DeadlineExceeded)
+	})
+	go func() {
+		select {
+		case <-ctx.Done():
+			timeoutCtx.cancel(ctx.Err())
+		case <-timeoutCtx.done:
+		}
+	}()
+	return timeoutCtx, func() {
+		stop()
+		timeoutCtx.cancel(context.Canceled)
+	}
+}
+
+// Deadline returns the deadline according to the time source
+func (c *timeSourceContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+// Done returns the channel closed once the context is cancelled or times out
+func (c *timeSourceContext) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the context is done, nil if it is not done yet
+func (c *timeSourceContext) Err() error {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.err
+}
+
+func (c *timeSourceContext) cancel(err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+}
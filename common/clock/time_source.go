@@ -21,6 +21,7 @@
 package clock
 
 import (
+	"sync"
 	"time"
 
 	// clockwork is not currently used but it is useful to have the option to use this in testing code
@@ -36,15 +37,34 @@ type (
 	TimeSource interface {
 		Now() time.Time
 	}
+	// TimerTimeSource is a TimeSource which is
+	// also able to call a func once a duration
+	// elapses according to its time
+	TimerTimeSource interface {
+		TimeSource
+		// AfterFunc calls f in its own goroutine after the duration,
+		// the returned func stops the call and returns whether the call is stopped
+		AfterFunc(d time.Duration, f func()) (stop func() bool)
+	}
 	// RealTimeSource serves real wall-clock time
 	RealTimeSource struct{}
 
 	// EventTimeSource serves fake controlled time
 	EventTimeSource struct {
-		now time.Time
+		sync.Mutex
+		now    time.Time
+		timers map[*eventTimer]struct{}
+	}
+
+	eventTimer struct {
+		fireTime time.Time
+		f        func()
 	}
 )
 
+var _ TimerTimeSource = (*RealTimeSource)(nil)
+var _ TimerTimeSource = (*EventTimeSource)(nil)
+
 // NewRealTimeSource returns a time source that servers
 // real wall clock time
 func NewRealTimeSource() *RealTimeSource {
@@ -56,6 +76,11 @@ func (ts *RealTimeSource) Now() time.Time {
 	return time.Now()
 }
 
+// AfterFunc calls f after the duration of the real time
+func (ts *RealTimeSource) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
 // NewEventTimeSource returns a time source that servers
 // fake controlled time
 func NewEventTimeSource() *EventTimeSource {
@@ -64,11 +89,50 @@ func NewEventTimeSource() *EventTimeSource {
 
 // Now return the fake current time
 func (ts *EventTimeSource) Now() time.Time {
+	ts.Lock()
+	defer ts.Unlock()
+
 	return ts.now
 }
 
-// Update update the fake current time
+// Update update the fake current time, the funcs due by the new time are called
 func (ts *EventTimeSource) Update(now time.Time) *EventTimeSource {
+	ts.Lock()
+	defer ts.Unlock()
+
 	ts.now = now
+	for timer := range ts.timers {
+		if !timer.fireTime.After(now) {
+			delete(ts.timers, timer)
+			go timer.f()
+		}
+	}
 	return ts
 }
+
+// AfterFunc calls f once the fake current time is updated to or past the duration from the current one
+func (ts *EventTimeSource) AfterFunc(d time.Duration, f func()) func() bool {
+	ts.Lock()
+	defer ts.Unlock()
+
+	timer := &eventTimer{
+		fireTime: ts.now.Add(d),
+		f:        f,
+	}
+	if d <= 0 {
+		go f()
+		return func() bool { return false }
+	}
+	if ts.timers == nil {
+		ts.timers = make(map[*eventTimer]struct{})
+	}
+	ts.timers[timer] = struct{}{}
+	return func() bool {
+		ts.Lock()
+		defer ts.Unlock()
+
+		_, ok := ts.timers[timer]
+		delete(ts.timers, timer)
+		return ok
+	}
+}
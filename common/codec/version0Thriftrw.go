@@ -65,20 +65,26 @@ func (t *ThriftRWEncoder) Encode(obj ThriftObject) ([]byte, error) {
 
 // Decode decode the object
 func (t *ThriftRWEncoder) Decode(binary []byte, val ThriftObject) error {
+	wireVal, err := DecodeWire(binary)
+	if err != nil {
+		return err
+	}
+
+	return val.FromWire(wireVal)
+}
+
+// DecodeWire decodes the binary into the Thrift-level representation of the struct,
+// so part of the struct can be read without building the whole object
+func DecodeWire(binary []byte) (wire.Value, error) {
 	if len(binary) < 1 {
-		return MissingBinaryEncodingVersion
+		return wire.Value{}, MissingBinaryEncodingVersion
 	}
 
 	version := binary[0]
 	if version != preambleVersion0 {
-		return InvalidBinaryEncodingVersion
+		return wire.Value{}, InvalidBinaryEncodingVersion
 	}
 
 	reader := bytes.NewReader(binary[1:])
-	wireVal, err := protocol.Binary.Decode(reader, wire.TStruct)
-	if err != nil {
-		return err
-	}
-
-	return val.FromWire(wireVal)
+	return protocol.Binary.Decode(reader, wire.TStruct)
 }
@@ -0,0 +1,156 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package collection
+
+import (
+	"context"
+)
+
+type (
+	// BufferedPagingIteratorImpl is the implementation of PagingIterator which fetches
+	// the subsequent pages in the background while the current page is being consumed
+	BufferedPagingIteratorImpl struct {
+		ctx               context.Context
+		pageCh            chan []interface{}
+		prefetchErr       error
+		exhausted         bool
+		pageErr           error
+		pageItems         []interface{}
+		nextPageItemIndex int
+	}
+)
+
+// NewBufferedPagingIterator create a new paging iterator which prefetches up to prefetchSize pages
+// ahead of the page being consumed, pages are still fetched one after another and returned in order.
+// the first pagination error stops the prefetching and is returned by Next after all the pages fetched before it,
+// the prefetching also stops once the context is done, so the context must be cancelled if the iterator is abandoned
+func NewBufferedPagingIterator(ctx context.Context, paginationFn PaginationFn, prefetchSize int) Iterator {
+	if prefetchSize < 1 {
+		prefetchSize = 1
+	}
+
+	iter := &BufferedPagingIteratorImpl{
+		ctx: ctx,
+		// the prefetching goroutine holds one more page while blocked on sending
+		pageCh:            make(chan []interface{}, prefetchSize-1),
+		prefetchErr:       nil,
+		exhausted:         false,
+		pageErr:           nil,
+		pageItems:         nil,
+		nextPageItemIndex: 0,
+	}
+	go iter.prefetch(paginationFn)
+	return iter
+}
+
+// HasNext return whether has next item or err
+func (iter *BufferedPagingIteratorImpl) HasNext() bool {
+	for {
+		// pagination encounters error
+		if iter.pageErr != nil {
+			return true
+		}
+
+		// still have local cached item to return
+		if iter.nextPageItemIndex < len(iter.pageItems) {
+			return true
+		}
+
+		if iter.exhausted {
+			return false
+		}
+
+		iter.getNextPage()
+	}
+}
+
+// Next return next item or err
+func (iter *BufferedPagingIteratorImpl) Next() (interface{}, error) {
+	if !iter.HasNext() {
+		panic("BufferedPagingIterator Next() called without checking HasNext()")
+	}
+
+	if iter.pageErr != nil {
+		err := iter.pageErr
+		iter.pageErr = nil
+		return nil, err
+	}
+
+	if err := iter.ctx.Err(); err != nil {
+		iter.pageItems = nil
+		iter.nextPageItemIndex = 0
+		iter.exhausted = true
+		return nil, err
+	}
+
+	// we have cached events
+	if iter.nextPageItemIndex < len(iter.pageItems) {
+		index := iter.nextPageItemIndex
+		iter.nextPageItemIndex++
+		return iter.pageItems[index], nil
+	}
+
+	panic("BufferedPagingIterator Next() should return either an item or a err")
+}
+
+func (iter *BufferedPagingIteratorImpl) getNextPage() {
+	items, ok := <-iter.pageCh
+	iter.pageItems = items
+	iter.nextPageItemIndex = 0
+	if !ok {
+		// prefetchErr is written before the channel is closed
+		iter.pageErr = iter.prefetchErr
+		iter.exhausted = true
+	}
+}
+
+func (iter *BufferedPagingIteratorImpl) prefetch(paginationFn PaginationFn) {
+	defer close(iter.pageCh)
+	defer recoverPaginationPanic(func(err error) {
+		iter.prefetchErr = err
+	})
+
+	var pageToken []byte
+	for {
+		if err := iter.ctx.Err(); err != nil {
+			iter.prefetchErr = err
+			return
+		}
+
+		items, token, err := paginationFn(pageToken)
+		if err != nil {
+			iter.prefetchErr = err
+			return
+		}
+
+		select {
+		case iter.pageCh <- items:
+		case <-iter.ctx.Done():
+			iter.prefetchErr = iter.ctx.Err()
+			return
+		}
+
+		if len(token) == 0 {
+			return
+		}
+		pageToken = token
+	}
+}
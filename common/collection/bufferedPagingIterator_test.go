@@ -0,0 +1,198 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package collection
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type (
+	bufferedPagingIteratorSuite struct {
+		suite.Suite
+	}
+)
+
+func TestBufferedPagingIteratorSuite(t *testing.T) {
+	s := new(bufferedPagingIteratorSuite)
+	suite.Run(t, s)
+}
+
+func (s *bufferedPagingIteratorSuite) TestIteration_NoErr() {
+	outputs := [][]interface{}{
+		{1, 2, 3, 4, 5},
+		{},
+		{6},
+		{},
+	}
+	tokens := [][]byte{
+		[]byte("some random token 1"),
+		[]byte("some random token 2"),
+		[]byte("some random token 3"),
+		[]byte(nil),
+	}
+	phase := 0
+	pagingFn := func(token []byte) ([]interface{}, []byte, error) {
+		if phase == 0 {
+			s.Equal(0, len(token))
+		} else {
+			s.Equal(tokens[phase-1], token)
+		}
+		defer func() { phase++ }()
+		return outputs[phase], tokens[phase], nil
+	}
+
+	for _, prefetchSize := range []int{0, 1, 2, 10} {
+		phase = 0
+		result := []int{}
+		ite := NewBufferedPagingIterator(context.Background(), pagingFn, prefetchSize)
+		for ite.HasNext() {
+			item, err := ite.Next()
+			s.Nil(err)
+			num, ok := item.(int)
+			s.True(ok)
+			result = append(result, num)
+		}
+		s.Equal([]int{1, 2, 3, 4, 5, 6}, result)
+		s.Equal(len(outputs), phase)
+	}
+}
+
+func (s *bufferedPagingIteratorSuite) TestIteration_Prefetch() {
+	secondPageFetched := make(chan struct{})
+	pagingFn := func(token []byte) ([]interface{}, []byte, error) {
+		if len(token) == 0 {
+			return []interface{}{1}, []byte("some random token"), nil
+		}
+		close(secondPageFetched)
+		return []interface{}{2}, nil, nil
+	}
+
+	ite := NewBufferedPagingIterator(context.Background(), pagingFn, 1)
+	s.True(ite.HasNext())
+	item, err := ite.Next()
+	s.Nil(err)
+	s.Equal(1, item)
+
+	// the second page is fetched while the first page is still being consumed
+	select {
+	case <-secondPageFetched:
+	case <-time.After(time.Second):
+		s.Fail("second page is not prefetched")
+	}
+
+	s.True(ite.HasNext())
+	item, err = ite.Next()
+	s.Nil(err)
+	s.Equal(2, item)
+	s.False(ite.HasNext())
+}
+
+func (s *bufferedPagingIteratorSuite) TestIteration_Err_NotBegining() {
+	phase := 0
+	pagingFn := func(token []byte) ([]interface{}, []byte, error) {
+		switch phase {
+		case 0:
+			s.Equal(0, len(token))
+			defer func() { phase++ }()
+			return []interface{}{1, 2, 3, 4, 5}, []byte("some random token 1"), nil
+		case 1:
+			defer func() { phase++ }()
+			return nil, nil, errors.New("some random error")
+		default:
+			panic("should not reach here during test")
+		}
+	}
+
+	result := []int{}
+	var err error
+	ite := NewBufferedPagingIterator(context.Background(), pagingFn, 2)
+	for ite.HasNext() {
+		var item interface{}
+		item, err = ite.Next()
+		if err != nil {
+			break
+		}
+		num, ok := item.(int)
+		s.True(ok)
+		result = append(result, num)
+	}
+	s.Equal([]int{1, 2, 3, 4, 5}, result)
+	s.EqualError(err, "some random error")
+	s.False(ite.HasNext())
+}
+
+func (s *bufferedPagingIteratorSuite) TestIteration_ContextCancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fetched := make(chan struct{}, 10)
+	pagingFn := func(token []byte) ([]interface{}, []byte, error) {
+		fetched <- struct{}{}
+		return []interface{}{1}, []byte("some random token"), nil
+	}
+
+	ite := NewBufferedPagingIterator(ctx, pagingFn, 1)
+	s.True(ite.HasNext())
+	item, err := ite.Next()
+	s.Nil(err)
+	s.Equal(1, item)
+	cancel()
+
+	for ite.HasNext() {
+		_, err = ite.Next()
+		if err != nil {
+			break
+		}
+	}
+	s.Equal(context.Canceled, err)
+	s.False(ite.HasNext())
+}
+
+func (s *bufferedPagingIteratorSuite) TestIteration_Panic() {
+	pagingFn := func(token []byte) ([]interface{}, []byte, error) {
+		if len(token) == 0 {
+			return []interface{}{1}, []byte("some random token"), nil
+		}
+		panic("some random panic")
+	}
+
+	result := []int{}
+	var err error
+	ite := NewBufferedPagingIterator(context.Background(), pagingFn, 2)
+	for ite.HasNext() {
+		var item interface{}
+		item, err = ite.Next()
+		if err != nil {
+			break
+		}
+		result = append(result, item.(int))
+	}
+	s.Equal([]int{1}, result)
+	panicErr, ok := err.(*PaginationPanicError)
+	s.True(ok)
+	s.Equal("some random panic", panicErr.Value)
+	s.False(ite.HasNext())
+}
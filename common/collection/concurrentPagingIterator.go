@@ -0,0 +1,221 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package collection
+
+import (
+	"context"
+	"sync"
+)
+
+type (
+	// ConcurrentPagingIteratorImpl is the implementation of PagingIterator which fetches
+	// multiple paginated segments concurrently while returning their items strictly in segment order
+	ConcurrentPagingIteratorImpl struct {
+		ctx               context.Context
+		cancel            context.CancelFunc
+		pageChs           []chan []interface{}
+		slotCh            chan struct{}
+		errLock           sync.Mutex
+		firstErr          error
+		segmentIndex      int
+		exhausted         bool
+		pageErr           error
+		pageItems         []interface{}
+		nextPageItemIndex int
+	}
+)
+
+// NewConcurrentPagingIterator create a new paging iterator which fetches up to concurrency segments at the same time,
+// each segment is paginated by the pagination fn from its provider one page after another, and the items are returned
+// in the order of the segments, no matter which segment is fetched first.
+// the pagination fns are bound to a child context, which is cancelled by the first pagination error,
+// so all the outstanding fetches are cancelled and the error is returned by Next.
+// the fetching also stops once the context is done, so the context must be cancelled if the iterator is abandoned
+func NewConcurrentPagingIterator(ctx context.Context, paginationFnProviders []PaginationFnProvider, concurrency int) Iterator {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	iter := &ConcurrentPagingIteratorImpl{
+		ctx:               ctx,
+		cancel:            cancel,
+		pageChs:           make([]chan []interface{}, len(paginationFnProviders)),
+		slotCh:            make(chan struct{}, concurrency),
+		firstErr:          nil,
+		segmentIndex:      0,
+		exhausted:         false,
+		pageErr:           nil,
+		pageItems:         nil,
+		nextPageItemIndex: 0,
+	}
+	for i := range iter.pageChs {
+		// the fetching goroutine holds one more page while blocked on sending
+		iter.pageChs[i] = make(chan []interface{}, 1)
+	}
+	go iter.dispatch(paginationFnProviders)
+	return iter
+}
+
+// HasNext return whether has next item or err
+func (iter *ConcurrentPagingIteratorImpl) HasNext() bool {
+	for {
+		// pagination encounters error
+		if iter.pageErr != nil {
+			return true
+		}
+
+		// still have local cached item to return
+		if iter.nextPageItemIndex < len(iter.pageItems) {
+			return true
+		}
+
+		if iter.exhausted {
+			return false
+		}
+
+		iter.getNextPage()
+	}
+}
+
+// Next return next item or err
+func (iter *ConcurrentPagingIteratorImpl) Next() (interface{}, error) {
+	if !iter.HasNext() {
+		panic("ConcurrentPagingIterator Next() called without checking HasNext()")
+	}
+
+	if iter.pageErr != nil {
+		err := iter.pageErr
+		iter.pageErr = nil
+		return nil, err
+	}
+
+	if err := iter.getFirstErr(); err != nil {
+		iter.stop()
+		return nil, err
+	}
+
+	// we have cached events
+	if iter.nextPageItemIndex < len(iter.pageItems) {
+		index := iter.nextPageItemIndex
+		iter.nextPageItemIndex++
+		return iter.pageItems[index], nil
+	}
+
+	panic("ConcurrentPagingIterator Next() should return either an item or a err")
+}
+
+func (iter *ConcurrentPagingIteratorImpl) getNextPage() {
+	if iter.segmentIndex >= len(iter.pageChs) {
+		iter.stop()
+		return
+	}
+
+	items, ok := <-iter.pageChs[iter.segmentIndex]
+	iter.pageItems = items
+	iter.nextPageItemIndex = 0
+	if ok {
+		return
+	}
+
+	// firstErr is written before the channel is closed
+	if err := iter.getFirstErr(); err != nil {
+		iter.stop()
+		iter.pageErr = err
+		return
+	}
+	// the segment is consumed, so one more segment can be fetched
+	iter.segmentIndex++
+	<-iter.slotCh
+}
+
+func (iter *ConcurrentPagingIteratorImpl) stop() {
+	iter.pageItems = nil
+	iter.nextPageItemIndex = 0
+	iter.exhausted = true
+	iter.cancel()
+}
+
+func (iter *ConcurrentPagingIteratorImpl) dispatch(paginationFnProviders []PaginationFnProvider) {
+	for i, paginationFnProvider := range paginationFnProviders {
+		// the segments are dispatched in order, so the segment being consumed is always fetched
+		select {
+		case iter.slotCh <- struct{}{}:
+			go iter.fetch(paginationFnProvider, iter.pageChs[i])
+		case <-iter.ctx.Done():
+			iter.setFirstErr(iter.ctx.Err())
+			for _, pageCh := range iter.pageChs[i:] {
+				close(pageCh)
+			}
+			return
+		}
+	}
+}
+
+func (iter *ConcurrentPagingIteratorImpl) fetch(paginationFnProvider PaginationFnProvider, pageCh chan []interface{}) {
+	defer close(pageCh)
+	defer recoverPaginationPanic(iter.setFirstErr)
+
+	paginationFn := paginationFnProvider(iter.ctx)
+	var pageToken []byte
+	for {
+		if err := iter.ctx.Err(); err != nil {
+			iter.setFirstErr(err)
+			return
+		}
+
+		items, token, err := paginationFn(pageToken)
+		if err != nil {
+			iter.setFirstErr(err)
+			return
+		}
+
+		select {
+		case pageCh <- items:
+		case <-iter.ctx.Done():
+			iter.setFirstErr(iter.ctx.Err())
+			return
+		}
+
+		if len(token) == 0 {
+			return
+		}
+		pageToken = token
+	}
+}
+
+func (iter *ConcurrentPagingIteratorImpl) getFirstErr() error {
+	iter.errLock.Lock()
+	defer iter.errLock.Unlock()
+
+	return iter.firstErr
+}
+
+func (iter *ConcurrentPagingIteratorImpl) setFirstErr(err error) {
+	iter.errLock.Lock()
+	defer iter.errLock.Unlock()
+
+	if iter.firstErr == nil {
+		iter.firstErr = err
+		// the outstanding fetches are no longer needed
+		iter.cancel()
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package collection
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type (
+	concurrentPagingIteratorSuite struct {
+		suite.Suite
+	}
+)
+
+func TestConcurrentPagingIteratorSuite(t *testing.T) {
+	s := new(concurrentPagingIteratorSuite)
+	suite.Run(t, s)
+}
+
+func (s *concurrentPagingIteratorSuite) TestIteration_NoErr() {
+	outputs := [][][]interface{}{
+		{{1, 2}, {3}},
+		{},
+		{{4}, {}, {5, 6}},
+	}
+	providers := make([]PaginationFnProvider, len(outputs))
+	for i := range outputs {
+		pages := outputs[i]
+		providers[i] = func(ctx context.Context) PaginationFn {
+			phase := 0
+			return func(token []byte) ([]interface{}, []byte, error) {
+				if len(pages) == 0 {
+					return nil, nil, nil
+				}
+				defer func() { phase++ }()
+				if phase == len(pages)-1 {
+					return pages[phase], nil, nil
+				}
+				return pages[phase], []byte("some random token"), nil
+			}
+		}
+	}
+
+	for _, concurrency := range []int{0, 1, 2, 10} {
+		result := []int{}
+		ite := NewConcurrentPagingIterator(context.Background(), providers, concurrency)
+		for ite.HasNext() {
+			item, err := ite.Next()
+			s.Nil(err)
+			num, ok := item.(int)
+			s.True(ok)
+			result = append(result, num)
+		}
+		s.Equal([]int{1, 2, 3, 4, 5, 6}, result)
+	}
+}
+
+func (s *concurrentPagingIteratorSuite) TestIteration_OutOfOrder() {
+	lastSegmentFetched := make(chan struct{})
+	providers := []PaginationFnProvider{
+		func(ctx context.Context) PaginationFn {
+			return func(token []byte) ([]interface{}, []byte, error) {
+				// the first segment is fetched after the last one
+				select {
+				case <-lastSegmentFetched:
+				case <-time.After(time.Second):
+					s.Fail("segments are not fetched concurrently")
+				}
+				return []interface{}{1}, nil, nil
+			}
+		},
+		func(ctx context.Context) PaginationFn {
+			return func(token []byte) ([]interface{}, []byte, error) {
+				return []interface{}{2}, nil, nil
+			}
+		},
+		func(ctx context.Context) PaginationFn {
+			return func(token []byte) ([]interface{}, []byte, error) {
+				defer close(lastSegmentFetched)
+				return []interface{}{3}, nil, nil
+			}
+		},
+	}
+
+	result := []int{}
+	ite := NewConcurrentPagingIterator(context.Background(), providers, 3)
+	for ite.HasNext() {
+		item, err := ite.Next()
+		s.Nil(err)
+		result = append(result, item.(int))
+	}
+	s.Equal([]int{1, 2, 3}, result)
+}
+
+func (s *concurrentPagingIteratorSuite) TestIteration_Err_CancelOutstandingFetches() {
+	firstSegmentStarted := make(chan struct{})
+	firstSegmentCancelled := make(chan struct{})
+	providers := []PaginationFnProvider{
+		func(ctx context.Context) PaginationFn {
+			return func(token []byte) ([]interface{}, []byte, error) {
+				close(firstSegmentStarted)
+				<-ctx.Done()
+				close(firstSegmentCancelled)
+				return nil, nil, ctx.Err()
+			}
+		},
+		func(ctx context.Context) PaginationFn {
+			return func(token []byte) ([]interface{}, []byte, error) {
+				// the second segment fails while the first one is still being fetched
+				<-firstSegmentStarted
+				return nil, nil, errors.New("some random error")
+			}
+		},
+		func(ctx context.Context) PaginationFn {
+			return func(token []byte) ([]interface{}, []byte, error) {
+				return []interface{}{3}, nil, nil
+			}
+		},
+	}
+
+	var err error
+	ite := NewConcurrentPagingIterator(context.Background(), providers, 2)
+	for ite.HasNext() {
+		_, err = ite.Next()
+		if err != nil {
+			break
+		}
+	}
+	s.EqualError(err, "some random error")
+	s.False(ite.HasNext())
+
+	select {
+	case <-firstSegmentCancelled:
+	case <-time.After(time.Second):
+		s.Fail("outstanding fetch is not cancelled")
+	}
+}
+
+func (s *concurrentPagingIteratorSuite) TestIteration_ContextCancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	providers := []PaginationFnProvider{
+		func(ctx context.Context) PaginationFn {
+			return func(token []byte) ([]interface{}, []byte, error) {
+				return []interface{}{1}, nil, nil
+			}
+		},
+		func(ctx context.Context) PaginationFn {
+			return func(token []byte) ([]interface{}, []byte, error) {
+				return []interface{}{2}, []byte("some random token"), nil
+			}
+		},
+	}
+
+	ite := NewConcurrentPagingIterator(ctx, providers, 1)
+	s.True(ite.HasNext())
+	item, err := ite.Next()
+	s.Nil(err)
+	s.Equal(1, item)
+	cancel()
+
+	for ite.HasNext() {
+		_, err = ite.Next()
+		if err != nil {
+			break
+		}
+	}
+	s.Equal(context.Canceled, err)
+	s.False(ite.HasNext())
+}
+
+func (s *concurrentPagingIteratorSuite) TestIteration_Panic() {
+	providers := []PaginationFnProvider{
+		func(ctx context.Context) PaginationFn {
+			return func(token []byte) ([]interface{}, []byte, error) {
+				return []interface{}{1}, nil, nil
+			}
+		},
+		func(ctx context.Context) PaginationFn {
+			return func(token []byte) ([]interface{}, []byte, error) {
+				panic("some random panic")
+			}
+		},
+		func(ctx context.Context) PaginationFn {
+			panic("should not reach here during test")
+		},
+	}
+
+	result := []int{}
+	var err error
+	ite := NewConcurrentPagingIterator(context.Background(), providers, 1)
+	for ite.HasNext() {
+		var item interface{}
+		item, err = ite.Next()
+		if err != nil {
+			break
+		}
+		result = append(result, item.(int))
+	}
+	s.Equal([]int{1}, result)
+	panicErr, ok := err.(*PaginationPanicError)
+	s.True(ok)
+	s.Equal("some random panic", panicErr.Value)
+	s.False(ite.HasNext())
+}
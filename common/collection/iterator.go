@@ -20,6 +20,11 @@
 
 package collection
 
+import (
+	"fmt"
+	"runtime/debug"
+)
+
 type (
 	// Iterator represents the interface for iterator
 	Iterator interface {
@@ -28,4 +33,26 @@ type (
 		// Next returns the next item and error
 		Next() (interface{}, error)
 	}
+
+	// PaginationPanicError is the error returned by the iterators fetching the pages in background
+	// if the pagination fn panics, instead of crashing the process from the background goroutine
+	PaginationPanicError struct {
+		Value interface{}
+		Stack string
+	}
 )
+
+func (e *PaginationPanicError) Error() string {
+	return fmt.Sprintf("pagination panics: %v", e.Value)
+}
+
+// recoverPaginationPanic turns the panic of the pagination fn into an error,
+// it must be deferred directly by the background goroutine calling the pagination fn
+func recoverPaginationPanic(setErr func(error)) {
+	if rec := recover(); rec != nil {
+		setErr(&PaginationPanicError{
+			Value: rec,
+			Stack: string(debug.Stack()),
+		})
+	}
+}
@@ -20,12 +20,20 @@
 
 package collection
 
+import (
+	"context"
+)
+
 type (
 	// PaginationFn is the function which get a page of results
 	PaginationFn func(paginationToken []byte) ([]interface{}, []byte, error)
 
+	// PaginationFnProvider is the function which creates a PaginationFn bound to the context
+	PaginationFnProvider func(ctx context.Context) PaginationFn
+
 	// PagingIteratorImpl is the implementation of PagingIterator
 	PagingIteratorImpl struct {
+		ctx               context.Context
 		paginationFn      PaginationFn
 		pageToken         []byte
 		pageErr           error
@@ -48,6 +56,21 @@ func NewPagingIterator(paginationFn PaginationFn) Iterator {
 	return iter
 }
 
+// NewPagingIteratorWithContext create a new paging iterator which stops paginating once the context is done,
+// the context error is returned by Next
+func NewPagingIteratorWithContext(ctx context.Context, paginationFn PaginationFn) Iterator {
+	iter := &PagingIteratorImpl{
+		ctx:               ctx,
+		paginationFn:      paginationFn,
+		pageToken:         nil,
+		pageErr:           nil,
+		pageItems:         nil,
+		nextPageItemIndex: 0,
+	}
+	iter.getNextPage() // this will initialize the paging iterator
+	return iter
+}
+
 // HasNext return whether has next item or err
 func (iter *PagingIteratorImpl) HasNext() bool {
 	// pagination encounters error
@@ -80,6 +103,13 @@ func (iter *PagingIteratorImpl) Next() (interface{}, error) {
 		return nil, err
 	}
 
+	if err := iter.contextErr(); err != nil {
+		iter.pageItems = nil
+		iter.pageToken = nil
+		iter.nextPageItemIndex = 0
+		return nil, err
+	}
+
 	// we have cached events
 	if iter.nextPageItemIndex < len(iter.pageItems) {
 		index := iter.nextPageItemIndex
@@ -91,6 +121,14 @@ func (iter *PagingIteratorImpl) Next() (interface{}, error) {
 }
 
 func (iter *PagingIteratorImpl) getNextPage() {
+	if err := iter.contextErr(); err != nil {
+		iter.pageItems = nil
+		iter.pageToken = nil
+		iter.pageErr = err
+		iter.nextPageItemIndex = 0
+		return
+	}
+
 	items, token, err := iter.paginationFn(iter.pageToken)
 	if err == nil {
 		iter.pageItems = items
@@ -103,3 +141,10 @@ func (iter *PagingIteratorImpl) getNextPage() {
 	}
 	iter.nextPageItemIndex = 0
 }
+
+func (iter *PagingIteratorImpl) contextErr() error {
+	if iter.ctx == nil {
+		return nil
+	}
+	return iter.ctx.Err()
+}
@@ -21,6 +21,7 @@
 package collection
 
 import (
+	"context"
 	"errors"
 	"log"
 	"os"
@@ -164,3 +165,55 @@ func (s *pagingIteratorSuite) TestIteration_Err_NotBegining() {
 	}
 	s.Equal([]int{1, 2, 3, 4, 5}, result)
 }
+
+func (s *pagingIteratorSuite) TestIterationWithContext_CancelledBeforeBegining() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ite := NewPagingIteratorWithContext(ctx, func(token []byte) ([]interface{}, []byte, error) {
+		panic("should not reach here during test")
+	})
+
+	s.True(ite.HasNext())
+	item, err := ite.Next()
+	s.Nil(item)
+	s.Equal(context.Canceled, err)
+	s.False(ite.HasNext())
+}
+
+func (s *pagingIteratorSuite) TestIterationWithContext_CancelledNotBegining() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	phase := 0
+	pagingFn := func(token []byte) ([]interface{}, []byte, error) {
+		switch phase {
+		case 0:
+			s.Equal(0, len(token))
+			defer func() { phase++ }()
+			return []interface{}{1, 2, 3}, []byte("some random token 1"), nil
+		default:
+			panic("should not reach here during test")
+		}
+	}
+
+	result := []int{}
+	ite := NewPagingIteratorWithContext(ctx, pagingFn)
+	var err error
+	for ite.HasNext() {
+		var item interface{}
+		item, err = ite.Next()
+		if err != nil {
+			break
+		}
+		num, ok := item.(int)
+		s.True(ok)
+		result = append(result, num)
+		if num == 2 {
+			cancel()
+		}
+	}
+	s.Equal([]int{1, 2}, result)
+	s.Equal(context.Canceled, err)
+	s.False(ite.HasNext())
+}
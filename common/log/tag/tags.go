@@ -276,6 +276,11 @@ func WorkflowHistorySizeBytes(historySizeBytes int) Tag {
 	return newInt("wf-history-size-bytes", historySizeBytes)
 }
 
+// WorkflowEventBatchSize returns tag for EventBatchSize
+func WorkflowEventBatchSize(eventBatchSize int) Tag {
+	return newInt("wf-event-batch-size", eventBatchSize)
+}
+
 // WorkflowEventCount returns tag for EventCount
 func WorkflowEventCount(eventCount int) Tag {
 	return newInt("wf-event-count", eventCount)
@@ -677,6 +682,16 @@ func TokenLastEventVersion(version int64) Tag {
 	return newInt64("xdc-token-last-event-version", version)
 }
 
+// ResendCorrelationID returns tag for ResendCorrelationID
+func ResendCorrelationID(correlationID string) Tag {
+	return newStringTag("xdc-resend-correlation-id", correlationID)
+}
+
+// ResendPageSize returns tag for ResendPageSize
+func ResendPageSize(pageSize int32) Tag {
+	return newInt32("xdc-resend-page-size", pageSize)
+}
+
 ///////////////////  Archival tags defined here: archival- ///////////////////
 // archival request tags
 
@@ -54,6 +54,7 @@ const (
 	Counter MetricType = iota
 	Timer
 	Gauge
+	Histogram
 )
 
 // Service names for all services that emit metrics.
@@ -586,6 +587,8 @@ const (
 	HistoryRereplicationByHistoryMetadataReplicationScope
 	// HistoryRereplicationByActivityReplicationScope tracks history replication calls made by activity replication
 	HistoryRereplicationByActivityReplicationScope
+	// NDCHistoryResenderScope tracks history resend operations made by NDC history resender
+	NDCHistoryResenderScope
 
 	// PersistenceAppendHistoryNodesScope tracks AppendHistoryNodes calls made by service to persistence layer
 	PersistenceAppendHistoryNodesScope
@@ -1332,6 +1335,7 @@ var ScopeDefs = map[ServiceIdx]map[int]scopeDefinition{
 		HistoryRereplicationByHistoryReplicationScope:         {operation: "HistoryRereplicationByHistoryReplication"},
 		HistoryRereplicationByHistoryMetadataReplicationScope: {operation: "HistoryRereplicationByHistoryMetadataReplication"},
 		HistoryRereplicationByActivityReplicationScope:        {operation: "HistoryRereplicationByActivityReplication"},
+		NDCHistoryResenderScope:                               {operation: "NDCHistoryResender"},
 
 		ElasticsearchRecordWorkflowExecutionStartedScope:           {operation: "RecordWorkflowExecutionStarted"},
 		ElasticsearchRecordWorkflowExecutionClosedScope:            {operation: "RecordWorkflowExecutionClosed"},
@@ -1670,6 +1674,40 @@ const (
 
 	HistoryFailoverMarkerInsertFailure
 
+	HistoryResendRequests
+	HistoryResendSuccess
+	HistoryResendSkipTaskCounter
+	HistoryResendEntityNotExistsCounter
+	HistoryResendLatency
+	HistoryResendGetHistoryLatency
+	HistoryResendBatchCount
+	HistoryResendBytes
+	HistoryResendFetchedBytes
+	HistoryResendSentBytes
+	HistoryResendConcurrencyLimitedCounter
+	HistoryResendInvalidEventBatchCounter
+	HistoryResendCircuitOpenCounter
+	HistoryResendDedupedCounter
+	HistoryResendSkippedRunCacheHitCounter
+	HistoryResendCurrentExecutionFixQueuedCounter
+	HistoryResendCurrentExecutionFixDroppedCounter
+	HistoryResendLargeBatchCounter
+	HistoryResendChecksumMismatchCounter
+	HistoryResendHistoryGapCounter
+	HistoryResendPageSizeReducedCounter
+	HistoryResendSourceThrottledCounter
+	HistoryResendAuditFailedCounter
+	HistoryResendDeadlineExceededCounter
+	HistoryResendCancelledCounter
+	HistoryResendPanicCounter
+	HistoryResendRetryBudgetExhaustedCounter
+	HistoryResendBatchSplitCounter
+	HistoryResendQueueDepthGauge
+	HistoryResendQueueInFlightGauge
+	ReplicationTaskSkippedRetentionExpiredCounter
+	ReplicationTaskSkippedCorruptedCounter
+	ReplicationTaskSkippedUpToDateCounter
+
 	VisibilityArchiverArchiveNonRetryableErrorCount
 	VisibilityArchiverArchiveTransientErrorCount
 	VisibilityArchiveSuccessCount
@@ -2012,6 +2050,9 @@ const (
 	NumWorkerMetrics
 )
 
+// HistoryResendBatchCountBuckets are the histogram buckets for the number of history batches resent per run
+var HistoryResendBatchCountBuckets = tally.ValueBuckets{0, 1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000}
+
 // MetricDefs record the metrics for all services
 var MetricDefs = map[ServiceIdx]map[int]metricDefinition{
 	Common: {
@@ -2096,6 +2137,39 @@ var MetricDefs = map[ServiceIdx]map[int]metricDefinition{
 		HistoryArchiverBlobIntegrityCheckFailedCount:              {metricName: "history_archiver_blob_integrity_check_failed", metricType: Counter},
 		HistoryArchiverDuplicateArchivalsCount:                    {metricName: "history_archiver_duplicate_archivals", metricType: Counter},
 		HistoryFailoverMarkerInsertFailure:                        {metricName: "history_failover_marker_insert_failures", metricType: Counter},
+		HistoryResendRequests:                                     {metricName: "history_resend_requests", metricType: Counter},
+		HistoryResendSuccess:                                      {metricName: "history_resend_success", metricType: Counter},
+		HistoryResendSkipTaskCounter:                              {metricName: "history_resend_skip_task", metricType: Counter},
+		HistoryResendEntityNotExistsCounter:                       {metricName: "history_resend_entity_not_exists", metricType: Counter},
+		HistoryResendLatency:                                      {metricName: "history_resend_latency", metricType: Timer},
+		HistoryResendGetHistoryLatency:                            {metricName: "history_resend_get_history_latency", metricType: Timer},
+		HistoryResendBatchCount:                                   {metricName: "history_resend_batch_count", metricType: Histogram, buckets: HistoryResendBatchCountBuckets},
+		HistoryResendBytes:                                        {metricName: "history_resend_bytes", metricType: Counter},
+		HistoryResendFetchedBytes:                                 {metricName: "history_resend_fetched_bytes", metricType: Counter},
+		HistoryResendSentBytes:                                    {metricName: "history_resend_sent_bytes", metricType: Counter},
+		HistoryResendConcurrencyLimitedCounter:                    {metricName: "history_resend_concurrency_limited", metricType: Counter},
+		HistoryResendInvalidEventBatchCounter:                     {metricName: "history_resend_invalid_event_batch", metricType: Counter},
+		HistoryResendCircuitOpenCounter:                           {metricName: "history_resend_circuit_open", metricType: Counter},
+		HistoryResendDedupedCounter:                               {metricName: "history_resend_deduped", metricType: Counter},
+		HistoryResendSkippedRunCacheHitCounter:                    {metricName: "history_resend_skipped_run_cache_hit", metricType: Counter},
+		HistoryResendCurrentExecutionFixQueuedCounter:             {metricName: "history_resend_current_execution_fix_queued", metricType: Counter},
+		HistoryResendCurrentExecutionFixDroppedCounter:            {metricName: "history_resend_current_execution_fix_dropped", metricType: Counter},
+		HistoryResendLargeBatchCounter:                            {metricName: "history_resend_large_batch", metricType: Counter},
+		HistoryResendChecksumMismatchCounter:                      {metricName: "history_resend_checksum_mismatch", metricType: Counter},
+		HistoryResendHistoryGapCounter:                            {metricName: "history_resend_history_gap", metricType: Counter},
+		HistoryResendPageSizeReducedCounter:                       {metricName: "history_resend_page_size_reduced", metricType: Counter},
+		HistoryResendSourceThrottledCounter:                       {metricName: "history_resend_source_throttled", metricType: Counter},
+		HistoryResendAuditFailedCounter:                           {metricName: "history_resend_audit_failed", metricType: Counter},
+		HistoryResendDeadlineExceededCounter:                      {metricName: "history_resend_deadline_exceeded", metricType: Counter},
+		HistoryResendCancelledCounter:                             {metricName: "history_resend_cancelled", metricType: Counter},
+		HistoryResendPanicCounter:                                 {metricName: "history_resend_panic", metricType: Counter},
+		HistoryResendRetryBudgetExhaustedCounter:                  {metricName: "history_resend_retry_budget_exhausted", metricType: Counter},
+		HistoryResendBatchSplitCounter:                            {metricName: "history_resend_batch_split", metricType: Counter},
+		HistoryResendQueueDepthGauge:                              {metricName: "history_resend_queue_depth", metricType: Gauge},
+		HistoryResendQueueInFlightGauge:                           {metricName: "history_resend_queue_in_flight", metricType: Gauge},
+		ReplicationTaskSkippedRetentionExpiredCounter:             {metricName: "replication_task_skipped_retention_expired", metricType: Counter},
+		ReplicationTaskSkippedCorruptedCounter:                    {metricName: "replication_task_skipped_corrupted", metricType: Counter},
+		ReplicationTaskSkippedUpToDateCounter:                     {metricName: "replication_task_skipped_up_to_date", metricType: Counter},
 		VisibilityArchiverArchiveNonRetryableErrorCount:           {metricName: "visibility_archiver_archive_non_retryable_error", metricType: Counter},
 		VisibilityArchiverArchiveTransientErrorCount:              {metricName: "visibility_archiver_archive_transient_error", metricType: Counter},
 		VisibilityArchiveSuccessCount:                             {metricName: "visibility_archiver_archive_success", metricType: Counter},
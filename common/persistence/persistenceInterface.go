@@ -737,18 +737,28 @@ func (d *DataBlob) ToThrift() *workflow.DataBlob {
 
 // NewDataBlobFromThrift convert data blob from thrift representation
 func NewDataBlobFromThrift(blob *workflow.DataBlob) *DataBlob {
+	dataBlob, err := NewDataBlobFromThriftWithError(blob)
+	if err != nil {
+		panic(fmt.Sprintf("NewDataBlobFromThrift seeing unsupported enconding type: %v", blob.GetEncodingType()))
+	}
+	return dataBlob
+}
+
+// NewDataBlobFromThriftWithError convert data blob from thrift representation,
+// returns UnknownEncodingTypeError instead of panicking if the encoding type is unsupported
+func NewDataBlobFromThriftWithError(blob *workflow.DataBlob) (*DataBlob, error) {
 	switch blob.GetEncodingType() {
 	case workflow.EncodingTypeJSON:
 		return &DataBlob{
 			Encoding: common.EncodingTypeJSON,
 			Data:     blob.Data,
-		}
+		}, nil
 	case workflow.EncodingTypeThriftRW:
 		return &DataBlob{
 			Encoding: common.EncodingTypeThriftRW,
 			Data:     blob.Data,
-		}
+		}, nil
 	default:
-		panic(fmt.Sprintf("NewDataBlobFromThrift seeing unsupported enconding type: %v", blob.GetEncodingType()))
+		return nil, NewUnknownEncodingTypeError(common.EncodingType(blob.GetEncodingType().String()))
 	}
 }
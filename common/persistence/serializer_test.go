@@ -481,3 +481,18 @@ func (s *cadenceSerializerSuite) TestSerializer() {
 	succ := common.AwaitWaitGroup(&doneWG, 10*time.Second)
 	s.True(succ, "test timed out")
 }
+
+func (s *cadenceSerializerSuite) TestNewDataBlobFromThriftWithError() {
+	blob, err := NewDataBlobFromThriftWithError(&workflow.DataBlob{
+		EncodingType: workflow.EncodingTypeThriftRW.Ptr(),
+		Data:         []byte("some random data"),
+	})
+	s.NoError(err)
+	s.Equal(NewDataBlob([]byte("some random data"), common.EncodingTypeThriftRW), blob)
+
+	_, err = NewDataBlobFromThriftWithError(&workflow.DataBlob{
+		EncodingType: workflow.EncodingType(len(workflow.EncodingType_Values())).Ptr(),
+		Data:         []byte("some random data"),
+	})
+	s.IsType(&UnknownEncodingTypeError{}, err)
+}
@@ -83,6 +83,9 @@ type IntPropertyFn func(opts ...FilterOption) int
 // IntPropertyFnWithDomainFilter is a wrapper to get int property from dynamic config with domain as filter
 type IntPropertyFnWithDomainFilter func(domain string) int
 
+// IntPropertyFnWithDomainIDFilter is a wrapper to get int property from dynamic config with domainID as filter
+type IntPropertyFnWithDomainIDFilter func(domainID string) int
+
 // IntPropertyFnWithTaskListInfoFilters is a wrapper to get int property from dynamic config with three filters: domain, taskList, taskType
 type IntPropertyFnWithTaskListInfoFilters func(domain string, taskList string, taskType int) int
 
@@ -95,6 +98,9 @@ type FloatPropertyFn func(opts ...FilterOption) float64
 // FloatPropertyFnWithShardIDFilter is a wrapper to get float property from dynamic config with shardID as filter
 type FloatPropertyFnWithShardIDFilter func(shardID int) float64
 
+// FloatPropertyFnWithDomainIDFilter is a wrapper to get float property from dynamic config with domainID as filter
+type FloatPropertyFnWithDomainIDFilter func(domainID string) float64
+
 // DurationPropertyFn is a wrapper to get duration property from dynamic config
 type DurationPropertyFn func(opts ...FilterOption) time.Duration
 
@@ -176,6 +182,18 @@ func (c *Collection) GetIntPropertyFilteredByDomain(key Key, defaultValue int) I
 	}
 }
 
+// GetIntPropertyFilteredByDomainID gets property with domainID filter and asserts that it's an integer
+func (c *Collection) GetIntPropertyFilteredByDomainID(key Key, defaultValue int) IntPropertyFnWithDomainIDFilter {
+	return func(domainID string) int {
+		val, err := c.client.GetIntValue(key, getFilterMap(DomainIDFilter(domainID)), defaultValue)
+		if err != nil {
+			c.logError(key, err)
+		}
+		c.logValue(key, val, defaultValue, intCompareEquals)
+		return val
+	}
+}
+
 // GetIntPropertyFilteredByTaskListInfo gets property with taskListInfo as filters and asserts that it's an integer
 func (c *Collection) GetIntPropertyFilteredByTaskListInfo(key Key, defaultValue int) IntPropertyFnWithTaskListInfoFilters {
 	return func(domain string, taskList string, taskType int) int {
@@ -220,6 +238,18 @@ func (c *Collection) GetFloat64Property(key Key, defaultValue float64) FloatProp
 	}
 }
 
+// GetFloat64PropertyFilteredByDomainID gets property with domainID filter and asserts that it's a float64
+func (c *Collection) GetFloat64PropertyFilteredByDomainID(key Key, defaultValue float64) FloatPropertyFnWithDomainIDFilter {
+	return func(domainID string) float64 {
+		val, err := c.client.GetFloatValue(key, getFilterMap(DomainIDFilter(domainID)), defaultValue)
+		if err != nil {
+			c.logError(key, err)
+		}
+		c.logValue(key, val, defaultValue, float64CompareEquals)
+		return val
+	}
+}
+
 // GetFloat64PropertyFilteredByShardID gets property with shardID filter and asserts that it's a float64
 func (c *Collection) GetFloat64PropertyFilteredByShardID(key Key, defaultValue float64) FloatPropertyFnWithShardIDFilter {
 	return func(shardID int) float64 {
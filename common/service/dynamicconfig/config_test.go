@@ -161,6 +161,15 @@ func (s *configSuite) TestGetIntPropertyFilteredByDomain() {
 	s.Equal(50, value(domain))
 }
 
+func (s *configSuite) TestGetIntPropertyFilteredByDomainID() {
+	key := testGetIntPropertyFilteredByDomainIDKey
+	domainID := "testDomainID"
+	value := s.cln.GetIntPropertyFilteredByDomainID(key, 10)
+	s.Equal(10, value(domainID))
+	s.client.SetValue(key, 50)
+	s.Equal(50, value(domainID))
+}
+
 func (s *configSuite) TestGetStringPropertyFnWithDomainFilter() {
 	key := DefaultEventEncoding
 	domain := "testDomain"
@@ -189,6 +198,15 @@ func (s *configSuite) TestGetFloat64Property() {
 	s.Equal(0.01, value())
 }
 
+func (s *configSuite) TestGetFloat64PropertyFilteredByDomainID() {
+	key := testGetFloat64PropertyFilteredByDomainIDKey
+	domainID := "testDomainID"
+	value := s.cln.GetFloat64PropertyFilteredByDomainID(key, 0.1)
+	s.Equal(0.1, value(domainID))
+	s.client.SetValue(key, 0.01)
+	s.Equal(0.01, value(domainID))
+}
+
 func (s *configSuite) TestGetBoolProperty() {
 	key := testGetBoolPropertyKey
 	value := s.cln.GetBoolProperty(key, true)
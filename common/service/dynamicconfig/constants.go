@@ -39,11 +39,13 @@ var keys = map[Key]string{
 	testGetPropertyKey:                               "testGetPropertyKey",
 	testGetIntPropertyKey:                            "testGetIntPropertyKey",
 	testGetFloat64PropertyKey:                        "testGetFloat64PropertyKey",
+	testGetFloat64PropertyFilteredByDomainIDKey:      "testGetFloat64PropertyFilteredByDomainIDKey",
 	testGetDurationPropertyKey:                       "testGetDurationPropertyKey",
 	testGetBoolPropertyKey:                           "testGetBoolPropertyKey",
 	testGetStringPropertyKey:                         "testGetStringPropertyKey",
 	testGetMapPropertyKey:                            "testGetMapPropertyKey",
 	testGetIntPropertyFilteredByDomainKey:            "testGetIntPropertyFilteredByDomainKey",
+	testGetIntPropertyFilteredByDomainIDKey:          "testGetIntPropertyFilteredByDomainIDKey",
 	testGetDurationPropertyFilteredByDomainKey:       "testGetDurationPropertyFilteredByDomainKey",
 	testGetIntPropertyFilteredByTaskListInfoKey:      "testGetIntPropertyFilteredByTaskListInfoKey",
 	testGetDurationPropertyFilteredByTaskListInfoKey: "testGetDurationPropertyFilteredByTaskListInfoKey",
@@ -170,6 +172,45 @@ var keys = map[Key]string{
 	StandbyTaskRedispatchInterval:                         "history.standbyTaskRedispatchInterval",
 	TaskRedispatchIntervalJitterCoefficient:               "history.taskRedispatchIntervalJitterCoefficient",
 	StandbyTaskReReplicationContextTimeout:                "history.standbyTaskReReplicationContextTimeout",
+	ReReplicationPageSize:                                 "history.reReplicationPageSize",
+	ReReplicationGetHistoryRPS:                            "history.reReplicationGetHistoryRPS",
+	ReReplicationStrictValidation:                         "history.reReplicationStrictValidation",
+	ReReplicationTimeoutJitterCoefficient:                 "history.reReplicationTimeoutJitterCoefficient",
+	ReReplicationMaxBytes:                                 "history.reReplicationMaxBytes",
+	ReReplicationGetHistoryTimeout:                        "history.reReplicationGetHistoryTimeout",
+	ReReplicationReplicateEventsTimeout:                   "history.reReplicationReplicateEventsTimeout",
+	ReReplicationPageBufferSize:                           "history.reReplicationPageBufferSize",
+	ReReplicationFetchConcurrency:                         "history.reReplicationFetchConcurrency",
+	ReReplicationMaxDomainConcurrency:                     "history.reReplicationMaxDomainConcurrency",
+	ReReplicationConcurrencyWaitTimeout:                   "history.reReplicationConcurrencyWaitTimeout",
+	ReReplicationValidateEventBatch:                       "history.reReplicationValidateEventBatch",
+	ReReplicationSkipInvalidEventBatch:                    "history.reReplicationSkipInvalidEventBatch",
+	ReReplicationSkipEmptyVersionHistory:                  "history.reReplicationSkipEmptyVersionHistory",
+	ReReplicationCircuitBreakerThreshold:                  "history.reReplicationCircuitBreakerThreshold",
+	ReReplicationCircuitBreakerCooldown:                   "history.reReplicationCircuitBreakerCooldown",
+	ReReplicationLargeBatchThreshold:                      "history.reReplicationLargeBatchThreshold",
+	ReReplicationMaxBatchSize:                             "history.reReplicationMaxBatchSize",
+	ReReplicationAsyncFixWorkerCount:                      "history.reReplicationAsyncFixWorkerCount",
+	ReReplicationAsyncFixQueueSize:                        "history.reReplicationAsyncFixQueueSize",
+	ReReplicationArchivalFallback:                         "history.reReplicationArchivalFallback",
+	ReReplicationSkippedRunCacheSize:                      "history.reReplicationSkippedRunCacheSize",
+	ReReplicationSkippedRunCacheTTL:                       "history.reReplicationSkippedRunCacheTTL",
+	ReReplicationEventBlobEncoding:                        "history.reReplicationEventBlobEncoding",
+	ReReplicationBatchDelay:                               "history.reReplicationBatchDelay",
+	ReReplicationTrimBatchToEndEvent:                      "history.reReplicationTrimBatchToEndEvent",
+	ReReplicationPriority:                                 "history.reReplicationPriority",
+	ReReplicationMaxTimeoutOverride:                       "history.reReplicationMaxTimeoutOverride",
+	ReReplicationFailOnHistoryGap:                         "history.reReplicationFailOnHistoryGap",
+	ReReplicationDisableCurrentExecutionFix:               "history.reReplicationDisableCurrentExecutionFix",
+	ReReplicationMaxPages:                                 "history.reReplicationMaxPages",
+	ReReplicationMultiResendRetryBudget:                   "history.reReplicationMultiResendRetryBudget",
+	ReReplicationQuietExpectedErrors:                      "history.reReplicationQuietExpectedErrors",
+	ReReplicationTargetPageBytes:                          "history.reReplicationTargetPageBytes",
+	ReReplicationMinAdaptivePageSize:                      "history.reReplicationMinAdaptivePageSize",
+	ReReplicationMaxAdaptivePageSize:                      "history.reReplicationMaxAdaptivePageSize",
+	ReReplicationSplitBatchSize:                           "history.reReplicationSplitBatchSize",
+	ReReplicationGlobalBytesPerSecond:                     "history.reReplicationGlobalBytesPerSecond",
+	ReReplicationGlobalOpsPerSecond:                       "history.reReplicationGlobalOpsPerSecond",
 	QueueProcessorEnableSplit:                             "history.queueProcessorEnableSplit",
 	QueueProcessorSplitMaxLevel:                           "history.queueProcessorSplitMaxLevel",
 	QueueProcessorEnableRandomSplitByDomainID:             "history.queueProcessorEnableRandomSplitByDomainID",
@@ -330,11 +371,13 @@ const (
 	testGetPropertyKey
 	testGetIntPropertyKey
 	testGetFloat64PropertyKey
+	testGetFloat64PropertyFilteredByDomainIDKey
 	testGetDurationPropertyKey
 	testGetBoolPropertyKey
 	testGetStringPropertyKey
 	testGetMapPropertyKey
 	testGetIntPropertyFilteredByDomainKey
+	testGetIntPropertyFilteredByDomainIDKey
 	testGetDurationPropertyFilteredByDomainKey
 	testGetIntPropertyFilteredByTaskListInfoKey
 	testGetDurationPropertyFilteredByTaskListInfoKey
@@ -574,6 +617,97 @@ const (
 	TaskRedispatchIntervalJitterCoefficient
 	// StandbyTaskReReplicationContextTimeout is the context timeout for standby task re-replication
 	StandbyTaskReReplicationContextTimeout
+	// ReReplicationPageSize is the page size used when fetching history events from remote for re-replication
+	ReReplicationPageSize
+	// ReReplicationGetHistoryRPS is the max rps of fetching history events of a domain from remote by all the
+	// re-replications on a host, 0 means unlimited
+	ReReplicationGetHistoryRPS
+	// ReReplicationStrictValidation is whether the version history of re-replicated events is validated before being applied
+	ReReplicationStrictValidation
+	// ReReplicationTimeoutJitterCoefficient is the jitter coefficient applied to the re-replication context timeout
+	ReReplicationTimeoutJitterCoefficient
+	// ReReplicationMaxBytes is the max total size of history events moved by a single re-replication, 0 means unlimited
+	ReReplicationMaxBytes
+	// ReReplicationGetHistoryTimeout is the timeout of each call fetching history events from remote for re-replication
+	ReReplicationGetHistoryTimeout
+	// ReReplicationReplicateEventsTimeout is the timeout of each call applying re-replicated history events
+	ReReplicationReplicateEventsTimeout
+	// ReReplicationPageBufferSize is the max number of history pages held in memory by a single re-replication,
+	// values larger than 1 allow fetching subsequent pages from remote while the current page is being applied
+	ReReplicationPageBufferSize
+	// ReReplicationFetchConcurrency is the max number of history pages fetched concurrently by a single re-replication,
+	// the pages are still applied in order, the page buffer size is not used if the value is larger than 1
+	ReReplicationFetchConcurrency
+	// ReReplicationMaxDomainConcurrency is the max number of concurrent re-replications of a domain on a host, 0 means unlimited
+	ReReplicationMaxDomainConcurrency
+	// ReReplicationConcurrencyWaitTimeout is the max time a re-replication waits for its domain concurrency slot
+	ReReplicationConcurrencyWaitTimeout
+	// ReReplicationValidateEventBatch is whether re-replicated event batches are verified to deserialize before being applied
+	ReReplicationValidateEventBatch
+	// ReReplicationSkipInvalidEventBatch is whether invalid re-replicated event batches are skipped instead of failing the re-replication
+	ReReplicationSkipInvalidEventBatch
+	// ReReplicationSkipEmptyVersionHistory is whether re-replicated events without version history are skipped instead of failing the re-replication
+	ReReplicationSkipEmptyVersionHistory
+	// ReReplicationCircuitBreakerThreshold is the number of consecutive failed re-replications of a domain
+	// after which the re-replications of the domain fail fast, 0 means disabled
+	ReReplicationCircuitBreakerThreshold
+	// ReReplicationCircuitBreakerCooldown is the duration re-replications of a domain fail fast once the threshold is reached
+	ReReplicationCircuitBreakerCooldown
+	// ReReplicationLargeBatchThreshold is the size in bytes from which re-replicated event batches are reported as large, 0 means disabled
+	ReReplicationLargeBatchThreshold
+	// ReReplicationMaxBatchSize is the max size in bytes of a re-replicated event batch, larger batches fail the re-replication,
+	// 0 means unlimited
+	ReReplicationMaxBatchSize
+	// ReReplicationAsyncFixWorkerCount is the number of workers fixing current executions in background for re-replication,
+	// 0 means the fix is done synchronously
+	ReReplicationAsyncFixWorkerCount
+	// ReReplicationAsyncFixQueueSize is the size of the queue of current execution fixes done in background for re-replication
+	ReReplicationAsyncFixQueueSize
+	// ReReplicationArchivalFallback indicates whether to re-replicate the archived history if the workflow is deleted in the source cluster
+	ReReplicationArchivalFallback
+	// ReReplicationSkippedRunCacheSize is the max number of runs skipped by re-replication remembered, 0 means disabled
+	ReReplicationSkippedRunCacheSize
+	// ReReplicationSkippedRunCacheTTL is how long the runs skipped by re-replication are remembered
+	ReReplicationSkippedRunCacheTTL
+	// ReReplicationEventBlobEncoding is the encoding of the re-replicated event batches, empty means the source encoding
+	ReReplicationEventBlobEncoding
+	// ReReplicationBatchDelay is the delay between the event batches sent by re-replication, 0 means no delay
+	ReReplicationBatchDelay
+	// ReReplicationTrimBatchToEndEvent indicates whether re-replication trims the event batch containing the end event
+	ReReplicationTrimBatchToEndEvent
+	// ReReplicationPriority is the priority of the re-replications of a domain, which is high, normal or low
+	ReReplicationPriority
+	// ReReplicationMaxTimeoutOverride is the max timeout a single re-replication can be given to override the re-replication timeout
+	ReReplicationMaxTimeoutOverride
+	// ReReplicationFailOnHistoryGap is whether a gap in the re-replicated event IDs fails the re-replication instead of being logged only
+	ReReplicationFailOnHistoryGap
+	// ReReplicationDisableCurrentExecutionFix is whether re-replication leaves the current execution unchecked
+	// when the workflow does not exist in the target, returning the not exists error as is
+	ReReplicationDisableCurrentExecutionFix
+	// ReReplicationMaxPages is the max number of history pages fetched by a single re-replication, 0 means unlimited
+	ReReplicationMaxPages
+	// ReReplicationMultiResendRetryBudget is the max number of retries shared by all the workflows of a
+	// multi-workflow re-replication, 0 means unlimited
+	ReReplicationMultiResendRetryBudget
+	// ReReplicationQuietExpectedErrors is whether re-replication logs the expected errors, i.e. the workflows
+	// missing in either cluster and the skipped tasks, at Debug level, e.g. during a planned cleanup
+	ReReplicationQuietExpectedErrors
+	// ReReplicationTargetPageBytes is the target size in bytes of each history page fetched by re-replication,
+	// the page size is adjusted to it by the observed size of the event batches, 0 means the page size is fixed
+	ReReplicationTargetPageBytes
+	// ReReplicationMinAdaptivePageSize is the min page size adjusted to ReReplicationTargetPageBytes
+	ReReplicationMinAdaptivePageSize
+	// ReReplicationMaxAdaptivePageSize is the max page size adjusted to ReReplicationTargetPageBytes
+	ReReplicationMaxAdaptivePageSize
+	// ReReplicationSplitBatchSize is the size in bytes from which re-replication splits the event batches into
+	// multiple requests at the event boundaries, 0 means the batches are not split
+	ReReplicationSplitBatchSize
+	// ReReplicationGlobalBytesPerSecond is the max bytes per second of the history events fetched and sent by all the
+	// re-replications on a host, 0 means no limit
+	ReReplicationGlobalBytesPerSecond
+	// ReReplicationGlobalOpsPerSecond is the max calls per second fetching and sending the history events by all the
+	// re-replications on a host, 0 means no limit
+	ReReplicationGlobalOpsPerSecond
 	// QueueProcessorEnableSplit indicates whether processing queue split policy should be enabled
 	QueueProcessorEnableSplit
 	// QueueProcessorSplitMaxLevel is the max processing queue level
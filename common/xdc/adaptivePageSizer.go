@@ -0,0 +1,70 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"github.com/uber/cadence/.gen/go/shared"
+)
+
+type (
+	// adaptivePageSizer tracks the event batches fetched by a pagination, to size the next page
+	adaptivePageSizer struct {
+		batchCount int64
+		batchBytes int64
+	}
+)
+
+// observe records the event batches of a page fetched
+func (s *adaptivePageSizer) observe(
+	historyBatches []*shared.DataBlob,
+) {
+
+	for _, historyBatch := range historyBatches {
+		s.batchCount++
+		s.batchBytes += int64(len(historyBatch.GetData()))
+	}
+}
+
+// pageSize returns the number of event batches of the average size observed so far fitting in the target page bytes,
+// within the min and max page size, or the configured page size if nothing is observed yet
+func (s *adaptivePageSizer) pageSize(
+	configuredPageSize int32,
+	targetPageBytes int64,
+	minPageSize int32,
+	maxPageSize int32,
+) int32 {
+
+	pageSize := int64(configuredPageSize)
+	if s.batchCount > 0 && s.batchBytes > 0 {
+		averageBatchBytes := s.batchBytes / s.batchCount
+		if averageBatchBytes == 0 {
+			averageBatchBytes = 1
+		}
+		pageSize = targetPageBytes / averageBatchBytes
+	}
+	if pageSize < int64(minPageSize) {
+		pageSize = int64(minPageSize)
+	}
+	if pageSize > int64(maxPageSize) {
+		pageSize = int64(maxPageSize)
+	}
+	return int32(pageSize)
+}
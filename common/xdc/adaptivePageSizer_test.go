@@ -0,0 +1,203 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/yarpcerrors"
+
+	"github.com/uber/cadence/.gen/go/admin"
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+)
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_AdaptivePageSize() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	WithAdaptivePageSize(
+		func(domainID string) int { return 10 * len(blob.Data) },
+		nil,
+		nil,
+	)(s.rereplicator)
+
+	var pageSizes []int32
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			pageSizes = append(pageSizes, request.GetMaximumPageSize())
+			var nextPageToken []byte
+			if len(pageSizes) == 1 {
+				nextPageToken = []byte("some random next page token")
+			}
+			return &admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob, blob},
+				NextPageToken:  nextPageToken,
+				VersionHistory: &shared.VersionHistory{
+					Items: []*shared.VersionHistoryItem{
+						{
+							EventID: common.Int64Ptr(2),
+							Version: common.Int64Ptr(123),
+						},
+					},
+				},
+			}, nil
+		}).Times(2)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(4)
+
+	err := s.resender.SendSingleWorkflowHistory(context.Background(), s.domainID, workflowID, runID, nil, nil, nil, nil)
+	s.NoError(err)
+	// the first page is fetched with the configured page size
+	s.Equal([]int32{defaultPageSize, 10}, pageSizes)
+}
+
+func (s *nDCHistoryResenderSuite) TestAdaptivePageSizer() {
+	testCases := []struct {
+		name            string
+		batchSizes      []int
+		targetPageBytes int64
+		expected        int32
+	}{
+		{
+			name:            "nothing observed",
+			targetPageBytes: 1000,
+			expected:        100,
+		},
+		{
+			name:            "small batches",
+			batchSizes:      []int{10, 30},
+			targetPageBytes: 1000,
+			expected:        50,
+		},
+		{
+			name:            "large batches",
+			batchSizes:      []int{900, 1100},
+			targetPageBytes: 3000,
+			expected:        3,
+		},
+		{
+			name:            "clamped to min page size",
+			batchSizes:      []int{5000},
+			targetPageBytes: 1000,
+			expected:        2,
+		},
+		{
+			name:            "clamped to max page size",
+			batchSizes:      []int{1},
+			targetPageBytes: 1000,
+			expected:        500,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			pageSizer := &adaptivePageSizer{}
+			var batches []*shared.DataBlob
+			for _, size := range tc.batchSizes {
+				batches = append(batches, &shared.DataBlob{Data: make([]byte, size)})
+			}
+			pageSizer.observe(batches)
+			s.Equal(tc.expected, pageSizer.pageSize(100, tc.targetPageBytes, 2, 500))
+		})
+	}
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_OversizedResponse() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	WithResendPageSize(func(domainID string) int { return 8 })(s.rereplicator)
+
+	var pageSizes []int32
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			pageSizes = append(pageSizes, request.GetMaximumPageSize())
+			if request.GetMaximumPageSize() > 2 {
+				return nil, yarpcerrors.ResourceExhaustedErrorf("grpc: received message larger than max (8388608 vs. 4194304)")
+			}
+			return &admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob},
+				VersionHistory: &shared.VersionHistory{
+					Items: []*shared.VersionHistoryItem{
+						{
+							EventID: common.Int64Ptr(2),
+							Version: common.Int64Ptr(123),
+						},
+					},
+				},
+			}, nil
+		}).Times(3)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.NoError(err)
+	s.Equal([]int32{8, 4, 2}, pageSizes)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_OversizedResponse_MinPageSize() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	WithResendPageSize(func(domainID string) int { return 2 })(s.rereplicator)
+
+	oversizedErr := yarpcerrors.ResourceExhaustedErrorf("grpc: received message larger than max (8388608 vs. 4194304)")
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(nil, oversizedErr).Times(2)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.Equal(oversizedErr, err)
+}
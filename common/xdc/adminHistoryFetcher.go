@@ -0,0 +1,80 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"sort"
+
+	"go.uber.org/yarpc"
+
+	"github.com/uber/cadence/.gen/go/admin"
+	adminClient "github.com/uber/cadence/client/admin"
+)
+
+type (
+	// adminHistoryFetcher fetches the history events from the admin service of the source cluster,
+	// along with the admin headers carried by the context
+	adminHistoryFetcher struct {
+		adminClient adminClient.Client
+	}
+)
+
+var _ HistoryFetcher = (*adminHistoryFetcher)(nil)
+
+// NewAdminHistoryFetcher creates a HistoryFetcher fetching history events from the source cluster via the admin client
+func NewAdminHistoryFetcher(
+	adminClient adminClient.Client,
+) HistoryFetcher {
+
+	return &adminHistoryFetcher{
+		adminClient: adminClient,
+	}
+}
+
+// GetRawHistory fetches a page of raw history events from the source cluster
+func (f *adminHistoryFetcher) GetRawHistory(
+	ctx context.Context,
+	request *admin.GetWorkflowExecutionRawHistoryV2Request,
+) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+
+	return f.adminClient.GetWorkflowExecutionRawHistoryV2(ctx, request, getAdminCallOptions(ctx)...)
+}
+
+// getAdminCallOptions returns the call options attaching the admin headers carried by the context,
+// every call to the admin service of the source cluster is made with them
+func getAdminCallOptions(
+	ctx context.Context,
+) []yarpc.CallOption {
+
+	headers := GetAdminHeaders(ctx)
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	// the headers are attached in a deterministic order
+	sort.Strings(keys)
+	opts := make([]yarpc.CallOption, 0, len(keys))
+	for _, key := range keys {
+		opts = append(opts, yarpc.WithHeader(key, headers[key]))
+	}
+	return opts
+}
@@ -0,0 +1,253 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"go.uber.org/yarpc"
+
+	"github.com/uber/cadence/.gen/go/admin"
+	"github.com/uber/cadence/.gen/go/admin/adminservicetest"
+	"github.com/uber/cadence/.gen/go/history"
+	"github.com/uber/cadence/.gen/go/shared"
+	adminClient "github.com/uber/cadence/client/admin"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/cache"
+	"github.com/uber/cadence/common/cluster"
+)
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_AdminHeaders() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	headers := map[string]string{
+		"some random header":  "some random value",
+		"other random header": "other random value",
+	}
+
+	providerCalls := 0
+	WithAdminHeadersProvider(func(ctx context.Context, domainID string) (map[string]string, error) {
+		s.Equal(s.domainID, domainID)
+		providerCalls++
+		return headers, nil
+	})(s.rereplicator)
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			s.Equal(headers, GetAdminHeaders(ctx))
+			s.Len(opts, len(headers))
+			return &admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob},
+				VersionHistory: &shared.VersionHistory{
+					Items: []*shared.VersionHistoryItem{
+						{
+							EventID: common.Int64Ptr(2),
+							Version: common.Int64Ptr(123),
+						},
+					},
+				},
+			}, nil
+		}).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.NoError(err)
+	s.Equal(1, providerCalls)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_AdminHeadersError() {
+	providerErr := errors.New("some random error")
+	WithAdminHeadersProvider(func(ctx context.Context, domainID string) (map[string]string, error) {
+		return nil, providerErr
+	})(s.rereplicator)
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).Times(0)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		"some random workflow ID",
+		uuid.New(),
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.Equal(providerErr, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_SourceAdminClients() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+
+	sourceAdminClient := adminservicetest.NewMockClient(s.controller)
+	WithSourceAdminClients(map[string]adminClient.Client{
+		cluster.TestCurrentClusterName: sourceAdminClient,
+	})(s.rereplicator)
+	// the source cluster is picked once per resend, not by the domain name of each page
+	mockDomainCache := cache.NewMockDomainCache(s.controller)
+	mockDomainCache.EXPECT().GetDomainByID(s.domainID).Return(s.domainEntry, nil).AnyTimes()
+	mockDomainCache.EXPECT().GetDomain(gomock.Any()).Times(0)
+	s.rereplicator.domainCache = mockDomainCache
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).Times(0)
+	for _, token := range [][]byte{{1}, nil} {
+		sourceAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob},
+				NextPageToken:  token,
+				VersionHistory: &shared.VersionHistory{
+					Items: []*shared.VersionHistoryItem{
+						{
+							EventID: common.Int64Ptr(2),
+							Version: common.Int64Ptr(123),
+						},
+					},
+				},
+			}, nil).Times(1)
+	}
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.NoError(err)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_SourceAdminClientNotFound() {
+	sourceAdminClient := adminservicetest.NewMockClient(s.controller)
+	WithSourceAdminClients(map[string]adminClient.Client{
+		cluster.TestAlternativeClusterName: sourceAdminClient,
+	})(s.rereplicator)
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).Times(0)
+	sourceAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).Times(0)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		"some random workflow ID",
+		uuid.New(),
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.Equal(&SourceAdminClientNotFoundError{
+		DomainID:      s.domainID,
+		SourceCluster: cluster.TestCurrentClusterName,
+	}, err)
+	s.Equal(ErrSourceAdminClientNotFound, errors.Unwrap(err))
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_HistoryFetcher() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	versionHistoryItems := []*shared.VersionHistoryItem{
+		{
+			EventID: common.Int64Ptr(2),
+			Version: common.Int64Ptr(123),
+		},
+	}
+
+	historyFetcher := NewMockHistoryFetcher(s.controller)
+	WithHistoryFetcher(historyFetcher)(s.rereplicator)
+	historyFetcher.EXPECT().GetRawHistory(
+		gomock.Any(),
+		&admin.GetWorkflowExecutionRawHistoryV2Request{
+			Domain: common.StringPtr(s.domainName),
+			Execution: &shared.WorkflowExecution{
+				WorkflowId: common.StringPtr(workflowID),
+				RunId:      common.StringPtr(runID),
+			},
+			MaximumPageSize: common.Int32Ptr(defaultPageSize),
+		}).Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+		HistoryBatches: []*shared.DataBlob{blob},
+		VersionHistory: &shared.VersionHistory{
+			Items: versionHistoryItems,
+		},
+	}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(
+		gomock.Any(),
+		&history.ReplicateEventsV2Request{
+			DomainUUID: common.StringPtr(s.domainID),
+			WorkflowExecution: &shared.WorkflowExecution{
+				WorkflowId: common.StringPtr(workflowID),
+				RunId:      common.StringPtr(runID),
+			},
+			VersionHistoryItems: versionHistoryItems,
+			Events:              blob,
+		}).Return(nil).Times(1)
+
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.NoError(err)
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+
+	"github.com/uber/cadence/.gen/go/admin"
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/archiver"
+	"github.com/uber/cadence/common/archiver/provider"
+	"github.com/uber/cadence/common/cache"
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/persistence"
+)
+
+type (
+	// archivalHistoryFetcher fetches the history events of a run from the archival storage of its domain,
+	// the archived history is the current branch of the run, so the versions of the event range are not checked.
+	// the version history is accumulated from the pages fetched, so the fetcher is used by a single resend only
+	archivalHistoryFetcher struct {
+		historyArchiver archiver.HistoryArchiver
+		uri             archiver.URI
+		domainID        string
+		serializer      persistence.PayloadSerializer
+		versionHistory  *persistence.VersionHistory
+	}
+)
+
+var _ HistoryFetcher = (*archivalHistoryFetcher)(nil)
+
+// newArchivalHistoryFetcher creates a HistoryFetcher reading the archived history of the domain,
+// an error is returned if the history archival of the domain is not available
+func newArchivalHistoryFetcher(
+	archiverProvider provider.ArchiverProvider,
+	domainEntry *cache.DomainCacheEntry,
+	serializer persistence.PayloadSerializer,
+) (*archivalHistoryFetcher, error) {
+
+	uriString := domainEntry.GetConfig().HistoryArchivalURI
+	if uriString == "" {
+		return nil, &shared.BadRequestError{Message: "History archival is not enabled for the domain."}
+	}
+	uri, err := archiver.NewURI(uriString)
+	if err != nil {
+		return nil, err
+	}
+	historyArchiver, err := archiverProvider.GetHistoryArchiver(uri.Scheme(), common.HistoryServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &archivalHistoryFetcher{
+		historyArchiver: historyArchiver,
+		uri:             uri,
+		domainID:        domainEntry.GetInfo().ID,
+		serializer:      serializer,
+		versionHistory:  persistence.NewVersionHistory(nil, nil),
+	}, nil
+}
+
+// GetRawHistory reads a page of the archived history and encodes the events in the requested range
+func (f *archivalHistoryFetcher) GetRawHistory(
+	ctx context.Context,
+	request *admin.GetWorkflowExecutionRawHistoryV2Request,
+) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+
+	response, err := f.historyArchiver.Get(ctx, f.uri, &archiver.GetHistoryRequest{
+		DomainID:      f.domainID,
+		WorkflowID:    request.GetExecution().GetWorkflowId(),
+		RunID:         request.GetExecution().GetRunId(),
+		NextPageToken: request.GetNextPageToken(),
+		PageSize:      int(request.GetMaximumPageSize()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rawResponse := &admin.GetWorkflowExecutionRawHistoryV2Response{
+		NextPageToken: response.NextPageToken,
+	}
+	for _, batch := range response.HistoryBatches {
+		var events []*shared.HistoryEvent
+		for _, event := range batch.GetEvents() {
+			// the version history covers the events before the range as well
+			if err := f.versionHistory.AddOrUpdateItem(
+				persistence.NewVersionHistoryItem(event.GetEventId(), event.GetVersion()),
+			); err != nil {
+				return nil, err
+			}
+			if isEventInRange(event.GetEventId(), request.StartEventId, request.EndEventId) {
+				events = append(events, event)
+			}
+		}
+		if len(events) == 0 {
+			continue
+		}
+
+		blob, err := f.serializer.SerializeBatchEvents(events, common.EncodingTypeThriftRW)
+		if err != nil {
+			return nil, err
+		}
+		rawResponse.HistoryBatches = append(rawResponse.HistoryBatches, blob.ToThrift())
+	}
+	rawResponse.VersionHistory = f.versionHistory.ToThrift()
+	return rawResponse, nil
+}
+
+// isEventInRange checks whether the event ID is within the exclusive range, as the raw history API does
+func isEventInRange(
+	eventID int64,
+	startEventID *int64,
+	endEventID *int64,
+) bool {
+
+	return (startEventID == nil || eventID > *startEventID) &&
+		(endEventID == nil || eventID < *endEventID)
+}
+
+// getArchivedHistory returns the archival history fetcher and the first page of the archived history,
+// nil response is returned if the archival fallback is disabled or the archived history is not available
+func (n *NDCHistoryResenderImpl) getArchivedHistory(
+	ctx context.Context,
+	domainEntry *cache.DomainCacheEntry,
+	workflowID string,
+	runID string,
+	startEventID *int64,
+	startEventVersion *int64,
+	endEventID *int64,
+	endEventVersion *int64,
+) (HistoryFetcher, *admin.GetWorkflowExecutionRawHistoryV2Response) {
+
+	domainID := domainEntry.GetInfo().ID
+	if n.archiverProvider == nil || n.archivalFallback == nil || !n.archivalFallback(domainID) {
+		return nil, nil
+	}
+	logger := n.getLogger(ctx).WithTags(
+		tag.WorkflowDomainID(domainID),
+		tag.WorkflowID(workflowID),
+		tag.WorkflowRunID(runID),
+	)
+
+	archivalFetcher, err := newArchivalHistoryFetcher(n.archiverProvider, domainEntry, n.serializer)
+	if err != nil {
+		logger.Warn("history archival is not available for resend", tag.Error(err))
+		return nil, nil
+	}
+	response, err := n.getHistoryFrom(
+		ctx,
+		archivalFetcher,
+		domainEntry,
+		workflowID,
+		runID,
+		startEventID,
+		startEventVersion,
+		endEventID,
+		endEventVersion,
+		nil,
+		n.getResendPageSize(domainID),
+	)
+	if err != nil {
+		logger.Warn("failed to read archived history for resend", tag.Error(err))
+		return nil, nil
+	}
+	logger.Info("resending archived history since the workflow does not exist in the source cluster")
+	return archivalFetcher, response
+}
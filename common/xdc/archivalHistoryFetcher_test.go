@@ -0,0 +1,243 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/uber/cadence/.gen/go/admin"
+	"github.com/uber/cadence/.gen/go/history"
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/archiver"
+	"github.com/uber/cadence/common/archiver/provider"
+	"github.com/uber/cadence/common/cache"
+	"github.com/uber/cadence/common/persistence"
+)
+
+type (
+	archivalHistoryFetcherSuite struct {
+		suite.Suite
+		*require.Assertions
+
+		domainID        string
+		historyArchiver *archiver.HistoryArchiverMock
+		fetcher         *archivalHistoryFetcher
+	}
+)
+
+func TestArchivalHistoryFetcherSuite(t *testing.T) {
+	s := new(archivalHistoryFetcherSuite)
+	suite.Run(t, s)
+}
+
+func (s *archivalHistoryFetcherSuite) SetupTest() {
+	s.Assertions = require.New(s.T())
+
+	s.domainID = "some random domain ID"
+	s.historyArchiver = &archiver.HistoryArchiverMock{}
+	archiverProvider := &provider.MockArchiverProvider{}
+	archiverProvider.On("GetHistoryArchiver", "test", common.HistoryServiceName).Return(s.historyArchiver, nil)
+
+	var err error
+	s.fetcher, err = newArchivalHistoryFetcher(
+		archiverProvider,
+		cache.NewLocalDomainCacheEntryForTest(
+			&persistence.DomainInfo{ID: s.domainID},
+			&persistence.DomainConfig{HistoryArchivalURI: "test:///archival"},
+			"",
+			nil,
+		),
+		persistence.NewPayloadSerializer(),
+	)
+	s.NoError(err)
+}
+
+func (s *archivalHistoryFetcherSuite) TearDownTest() {
+	s.historyArchiver.AssertExpectations(s.T())
+}
+
+func (s *archivalHistoryFetcherSuite) TestNewArchivalHistoryFetcher_ArchivalNotEnabled() {
+	_, err := newArchivalHistoryFetcher(
+		&provider.MockArchiverProvider{},
+		cache.NewLocalDomainCacheEntryForTest(
+			&persistence.DomainInfo{ID: s.domainID},
+			&persistence.DomainConfig{},
+			"",
+			nil,
+		),
+		persistence.NewPayloadSerializer(),
+	)
+	s.IsType(&shared.BadRequestError{}, err)
+}
+
+func (s *archivalHistoryFetcherSuite) TestGetRawHistory() {
+	workflowID := "some random workflow ID"
+	runID := "some random run ID"
+	newEvent := func(eventID int64, version int64) *shared.HistoryEvent {
+		return &shared.HistoryEvent{
+			EventId:   common.Int64Ptr(eventID),
+			Version:   common.Int64Ptr(version),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		}
+	}
+	newRequest := func(token []byte) *admin.GetWorkflowExecutionRawHistoryV2Request {
+		return &admin.GetWorkflowExecutionRawHistoryV2Request{
+			Execution: &shared.WorkflowExecution{
+				WorkflowId: common.StringPtr(workflowID),
+				RunId:      common.StringPtr(runID),
+			},
+			StartEventId:    common.Int64Ptr(1),
+			EndEventId:      common.Int64Ptr(4),
+			MaximumPageSize: common.Int32Ptr(2),
+			NextPageToken:   token,
+		}
+	}
+	newArchiverRequest := func(token []byte) *archiver.GetHistoryRequest {
+		return &archiver.GetHistoryRequest{
+			DomainID:      s.domainID,
+			WorkflowID:    workflowID,
+			RunID:         runID,
+			NextPageToken: token,
+			PageSize:      2,
+		}
+	}
+	s.historyArchiver.On("Get", mock.Anything, mock.Anything, newArchiverRequest(nil)).Return(&archiver.GetHistoryResponse{
+		HistoryBatches: []*shared.History{
+			{Events: []*shared.HistoryEvent{newEvent(1, 1)}},
+			{Events: []*shared.HistoryEvent{newEvent(2, 1)}},
+		},
+		NextPageToken: []byte{1},
+	}, nil).Once()
+	s.historyArchiver.On("Get", mock.Anything, mock.Anything, newArchiverRequest([]byte{1})).Return(&archiver.GetHistoryResponse{
+		HistoryBatches: []*shared.History{
+			{Events: []*shared.HistoryEvent{newEvent(3, 2), newEvent(4, 2)}},
+		},
+	}, nil).Once()
+
+	// the first batch is before the range
+	response, err := s.fetcher.GetRawHistory(context.Background(), newRequest(nil))
+	s.NoError(err)
+	s.Len(response.HistoryBatches, 1)
+	s.Equal([]byte{1}, response.NextPageToken)
+	s.Equal([]*shared.VersionHistoryItem{
+		{EventID: common.Int64Ptr(2), Version: common.Int64Ptr(1)},
+	}, response.VersionHistory.Items)
+
+	// the last event is after the range, the version history covers all the events read
+	response, err = s.fetcher.GetRawHistory(context.Background(), newRequest([]byte{1}))
+	s.NoError(err)
+	s.Len(response.HistoryBatches, 1)
+	events, err := persistence.NewPayloadSerializer().DeserializeBatchEvents(persistence.NewDataBlobFromThrift(response.HistoryBatches[0]))
+	s.NoError(err)
+	s.Equal([]*shared.HistoryEvent{newEvent(3, 2)}, events)
+	s.Equal([]*shared.VersionHistoryItem{
+		{EventID: common.Int64Ptr(2), Version: common.Int64Ptr(1)},
+		{EventID: common.Int64Ptr(4), Version: common.Int64Ptr(2)},
+	}, response.VersionHistory.Items)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_ArchivalFallback() {
+	domainID := uuid.New()
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	domainEntry := cache.NewGlobalDomainCacheEntryForTest(
+		&persistence.DomainInfo{ID: domainID, Name: s.domainName},
+		&persistence.DomainConfig{Retention: 1, HistoryArchivalURI: "test:///archival"},
+		s.domainEntry.GetReplicationConfig(),
+		1234,
+		nil,
+	)
+	s.mockDomainCache.EXPECT().GetDomainByID(domainID).Return(domainEntry, nil).AnyTimes()
+	events := []*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+		{
+			EventId:   common.Int64Ptr(3),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskStarted.Ptr(),
+		},
+	}
+
+	historyArchiver := &archiver.HistoryArchiverMock{}
+	defer historyArchiver.AssertExpectations(s.T())
+	historyArchiver.On("Get", mock.Anything, mock.Anything, &archiver.GetHistoryRequest{
+		DomainID:   domainID,
+		WorkflowID: workflowID,
+		RunID:      runID,
+		PageSize:   int(defaultPageSize),
+	}).Return(&archiver.GetHistoryResponse{
+		HistoryBatches: []*shared.History{{Events: events}},
+	}, nil).Once()
+	archiverProvider := &provider.MockArchiverProvider{}
+	archiverProvider.On("GetHistoryArchiver", "test", common.HistoryServiceName).Return(historyArchiver, nil)
+	archivalFallback := false
+	WithArchivalFallback(archiverProvider, func(domainID string) bool { return archivalFallback })(s.rereplicator)
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(nil, &shared.EntityNotExistsError{}).Times(2)
+	sendHistory := func() error {
+		return s.resender.SendSingleWorkflowHistory(
+			context.Background(),
+			domainID,
+			workflowID,
+			runID,
+			common.Int64Ptr(2),
+			common.Int64Ptr(123),
+			nil,
+			nil,
+		)
+	}
+	s.IsType(&shared.EntityNotExistsError{}, sendHistory())
+
+	// only the events after the start event are sent
+	archivalFallback = true
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(
+		gomock.Any(),
+		&history.ReplicateEventsV2Request{
+			DomainUUID: common.StringPtr(domainID),
+			WorkflowExecution: &shared.WorkflowExecution{
+				WorkflowId: common.StringPtr(workflowID),
+				RunId:      common.StringPtr(runID),
+			},
+			VersionHistoryItems: []*shared.VersionHistoryItem{
+				{
+					EventID: common.Int64Ptr(3),
+					Version: common.Int64Ptr(123),
+				},
+			},
+			Events: s.serializeEvents(events[1:]),
+		}).Return(nil).Times(1)
+	s.NoError(sendHistory())
+}
@@ -0,0 +1,249 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common/cache"
+	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/metrics"
+	"github.com/uber/cadence/common/persistence"
+	checks "github.com/uber/cadence/common/reconciliation/common"
+	"github.com/uber/cadence/common/service/dynamicconfig"
+)
+
+const (
+	// currentExecutionFixResultTTL is for how long the result of a fix done in background is kept for the retried task
+	currentExecutionFixResultTTL = 10 * time.Minute
+	// currentExecutionFixResultMaxCount is the max number of the results of the fixes done in background kept
+	currentExecutionFixResultMaxCount = 10000
+)
+
+// ErrCurrentExecutionFixPending is the error returned when the current execution of the workflow is being checked
+// and fixed in background, the task is expected to be retried later to get the result of the fix
+var ErrCurrentExecutionFixPending = &shared.ServiceBusyError{Message: "Current execution of the workflow is being checked in background."}
+
+type (
+	// currentExecutionFixer checks and fixes the current execution records in the target cluster
+	// when the run does not exist in the target, either synchronously or by the workers in background
+	currentExecutionFixer struct {
+		check    checks.Invariant
+		states   []int
+		disabled dynamicconfig.BoolPropertyFnWithDomainIDFilter
+
+		// the fixes are done in background if both are positive, they are read on each use
+		workerCount dynamicconfig.IntPropertyFn
+		queueSize   dynamicconfig.IntPropertyFn
+
+		// onFixed is called once the current execution of a run is checked and fixed
+		onFixed func(domainID string, workflowID string, runID string)
+
+		ctx context.Context
+
+		sync.Mutex
+		queue   []*currentExecutionFixRequest
+		pending map[string]struct{}
+		results cache.Cache
+		workers int
+	}
+
+	currentExecutionFixRequest struct {
+		domainID       string
+		targetDomainID string
+		workflowID     string
+		runID          string
+		logger         log.Logger
+	}
+
+	currentExecutionFixResult struct {
+		reason   SkipTaskReason
+		skipTask bool
+	}
+)
+
+func newCurrentExecutionFixer(
+	ctx context.Context,
+	check checks.Invariant,
+	onFixed func(domainID string, workflowID string, runID string),
+) *currentExecutionFixer {
+
+	return &currentExecutionFixer{
+		check:   check,
+		states:  []int{persistence.WorkflowStateRunning},
+		onFixed: onFixed,
+		ctx:     ctx,
+		pending: make(map[string]struct{}),
+		results: cache.New(&cache.Options{
+			TTL:      currentExecutionFixResultTTL,
+			MaxCount: currentExecutionFixResultMaxCount,
+		}),
+	}
+}
+
+// checkAndFix returns the reason and whether the task is skipped once the current execution is checked and fixed.
+// if the fixes are done in background, the fix is queued and ErrCurrentExecutionFixPending is returned until
+// the fix is done, and its result is returned to the retried task. the fix is done synchronously if the queue is full.
+// the current execution is checked in the target domain, while the config is read by the source domain
+func (f *currentExecutionFixer) checkAndFix(
+	scope metrics.Scope,
+	logger log.Logger,
+	domainID string,
+	targetDomainID string,
+	workflowID string,
+	runID string,
+) (SkipTaskReason, bool, error) {
+
+	if f.disabled != nil && f.disabled(domainID) {
+		return SkipTaskReasonRetentionExpired, false, nil
+	}
+	if !f.isAsync() {
+		reason, skipTask := f.fix(logger, domainID, targetDomainID, workflowID, runID)
+		return reason, skipTask, nil
+	}
+
+	key := getRunKey(domainID, workflowID, runID)
+	f.Lock()
+	if result, ok := f.results.Get(key).(*currentExecutionFixResult); ok {
+		f.results.Delete(key)
+		f.Unlock()
+		return result.reason, result.skipTask, nil
+	}
+	if _, ok := f.pending[key]; ok {
+		f.Unlock()
+		return SkipTaskReasonRetentionExpired, false, ErrCurrentExecutionFixPending
+	}
+	if len(f.queue) < f.queueSize() {
+		f.queue = append(f.queue, &currentExecutionFixRequest{
+			domainID:       domainID,
+			targetDomainID: targetDomainID,
+			workflowID:     workflowID,
+			runID:          runID,
+			logger:         logger,
+		})
+		f.pending[key] = struct{}{}
+		if f.workers < f.workerCount() {
+			f.workers++
+			go f.fixLoop()
+		}
+		f.Unlock()
+		scope.IncCounter(metrics.HistoryResendCurrentExecutionFixQueuedCounter)
+		return SkipTaskReasonRetentionExpired, false, ErrCurrentExecutionFixPending
+	}
+	f.Unlock()
+
+	scope.IncCounter(metrics.HistoryResendCurrentExecutionFixDroppedCounter)
+	reason, skipTask := f.fix(logger, domainID, targetDomainID, workflowID, runID)
+	return reason, skipTask, nil
+}
+
+func (f *currentExecutionFixer) isAsync() bool {
+	return f.check != nil && len(f.states) != 0 &&
+		f.workerCount != nil && f.workerCount() > 0 &&
+		f.queueSize != nil && f.queueSize() > 0
+}
+
+// fixLoop does the queued fixes until the queue is empty, the worker exits early
+// if there are more workers than configured or the fixer is closed
+func (f *currentExecutionFixer) fixLoop() {
+	for {
+		f.Lock()
+		if f.ctx.Err() != nil {
+			for _, request := range f.queue {
+				delete(f.pending, getRunKey(request.domainID, request.workflowID, request.runID))
+			}
+			f.queue = nil
+		}
+		if len(f.queue) == 0 || f.workers > f.workerCount() {
+			f.workers--
+			f.Unlock()
+			return
+		}
+		request := f.queue[0]
+		f.queue[0] = nil
+		f.queue = f.queue[1:]
+		f.Unlock()
+
+		reason, skipTask := f.fix(request.logger, request.domainID, request.targetDomainID, request.workflowID, request.runID)
+		request.logger.Info("current execution is checked in background",
+			tag.WorkflowDomainID(request.targetDomainID),
+			tag.WorkflowID(request.workflowID),
+			tag.WorkflowRunID(request.runID),
+			tag.Value(reason.String()),
+			tag.Bool(skipTask))
+
+		key := getRunKey(request.domainID, request.workflowID, request.runID)
+		f.Lock()
+		delete(f.pending, key)
+		f.results.Put(key, &currentExecutionFixResult{
+			reason:   reason,
+			skipTask: skipTask,
+		})
+		f.Unlock()
+	}
+}
+
+// fix checks the current execution in all the states, the task is skipped if it is healthy in all of them,
+// the task is retried after the corrupted current execution is fixed
+func (f *currentExecutionFixer) fix(
+	logger log.Logger,
+	domainID string,
+	targetDomainID string,
+	workflowID string,
+	runID string,
+) (SkipTaskReason, bool) {
+
+	if f.check == nil || len(f.states) == 0 {
+		return SkipTaskReasonRetentionExpired, false
+	}
+	if f.onFixed != nil {
+		defer f.onFixed(domainID, workflowID, runID)
+	}
+	for _, state := range f.states {
+		execution := &checks.CurrentExecution{
+			Execution: checks.Execution{
+				DomainID:   targetDomainID,
+				WorkflowID: workflowID,
+				State:      state,
+			},
+		}
+		res := f.check.Check(execution)
+		switch res.CheckResultType {
+		case checks.CheckResultTypeCorrupted:
+			logger.Error(
+				"Encounter corrupted workflow",
+				tag.WorkflowDomainID(targetDomainID),
+				tag.WorkflowID(workflowID),
+				tag.WorkflowRunID(runID),
+				tag.WorkflowState(state),
+			)
+			f.check.Fix(execution)
+			return SkipTaskReasonCorrupted, false
+		case checks.CheckResultTypeFailed:
+			return SkipTaskReasonRetentionExpired, false
+		}
+	}
+	return SkipTaskReasonRetentionExpired, true
+}
@@ -0,0 +1,441 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+
+	"github.com/uber/cadence/.gen/go/admin"
+	"github.com/uber/cadence/.gen/go/history"
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/metrics"
+	"github.com/uber/cadence/common/persistence"
+	checks "github.com/uber/cadence/common/reconciliation/common"
+	"github.com/uber/cadence/common/service/dynamicconfig"
+)
+
+func (s *nDCHistoryResenderSuite) TestCurrentExecutionCheck() {
+	domainID := uuid.New()
+	workflowID1 := uuid.New()
+	workflowID2 := uuid.New()
+	runID := uuid.New()
+	invariantMock := checks.NewMockInvariant(s.controller)
+	rereplicator, err := NewNDCHistoryResender(
+		s.mockDomainCache,
+		s.mockAdminClient,
+		func(ctx context.Context, request *history.ReplicateEventsV2Request) error {
+			return s.mockHistoryClient.ReplicateEventsV2(ctx, request)
+		},
+		persistence.NewPayloadSerializer(),
+		nil,
+		invariantMock,
+		s.metricsClient,
+		s.logger,
+	)
+	s.NoError(err)
+	s.useRereplicator(rereplicator)
+	execution1 := &checks.CurrentExecution{
+		Execution: checks.Execution{
+			DomainID:   domainID,
+			WorkflowID: workflowID1,
+			State:      persistence.WorkflowStateRunning,
+		},
+	}
+	execution2 := &checks.CurrentExecution{
+		Execution: checks.Execution{
+			DomainID:   domainID,
+			WorkflowID: workflowID2,
+			State:      persistence.WorkflowStateRunning,
+		},
+	}
+	invariantMock.EXPECT().Check(execution1).Return(checks.CheckResult{
+		CheckResultType: checks.CheckResultTypeCorrupted,
+	}).Times(1)
+	invariantMock.EXPECT().Check(execution2).Return(checks.CheckResult{
+		CheckResultType: checks.CheckResultTypeHealthy,
+	}).Times(1)
+	invariantMock.EXPECT().Fix(gomock.Any()).Return(checks.FixResult{}).Times(1)
+
+	_, skipTask := s.rereplicator.currentExecutionFixer.fix(s.logger, domainID, domainID, workflowID1, runID)
+	s.False(skipTask)
+	reason, skipTask := s.rereplicator.currentExecutionFixer.fix(s.logger, domainID, domainID, workflowID2, runID)
+	s.True(skipTask)
+	s.Equal(SkipTaskReasonRetentionExpired, reason)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_TargetEntityNotExists() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	invariantMock := checks.NewMockInvariant(s.controller)
+	s.rereplicator.currentExecutionFixer.check = invariantMock
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).
+		Return(&shared.EntityNotExistsError{}).Times(1)
+	// the run does not exist in the target, the current execution in the target is checked
+	invariantMock.EXPECT().Check(gomock.Any()).Return(checks.CheckResult{
+		CheckResultType: checks.CheckResultTypeHealthy,
+	}).Times(1)
+
+	result, err := s.resender.SendSingleWorkflowHistoryWithResult(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.True(errors.Is(err, ErrSkipTask))
+	s.Equal(SkipTaskReasonRetentionExpired, GetSkipTaskReason(err))
+	s.True(result.Skipped)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_TargetEntityNotExists_CurrentExecutionFixDisabled() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	invariantMock := checks.NewMockInvariant(s.controller)
+	s.rereplicator.currentExecutionFixer.check = invariantMock
+	WithCurrentExecutionFixDisabled(func(domainID string) bool { return true })(s.rereplicator)
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+	notExistsErr := &shared.EntityNotExistsError{}
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).
+		Return(notExistsErr).Times(1)
+	// the current execution in the target is left for the external repair
+	invariantMock.EXPECT().Check(gomock.Any()).Times(0)
+	invariantMock.EXPECT().Fix(gomock.Any()).Times(0)
+
+	result, err := s.resender.SendSingleWorkflowHistoryWithResult(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.Equal(notExistsErr, err)
+	s.False(result.Skipped)
+}
+
+func (s *nDCHistoryResenderSuite) TestCurrentExecutionCheck_Fixed() {
+	domainID := uuid.New()
+	workflowID := uuid.New()
+	runID := uuid.New()
+	invariantMock := checks.NewMockInvariant(s.controller)
+	WithCurrentExecutionStates(persistence.WorkflowStateRunning)(s.rereplicator)
+	s.rereplicator.currentExecutionFixer.check = invariantMock
+
+	invariantMock.EXPECT().Check(gomock.Any()).Return(checks.CheckResult{
+		CheckResultType: checks.CheckResultTypeCorrupted,
+	}).Times(1)
+	invariantMock.EXPECT().Fix(gomock.Any()).Return(checks.FixResult{
+		FixResultType: checks.FixResultTypeFixed,
+	}).Times(1)
+
+	// the task is retried after the current execution is fixed
+	reason, skipTask := s.rereplicator.currentExecutionFixer.fix(s.logger, domainID, domainID, workflowID, runID)
+	s.False(skipTask)
+	s.Equal(SkipTaskReasonCorrupted, reason)
+}
+
+func (s *nDCHistoryResenderSuite) TestSkipTaskError() {
+	err := error(&SkipTaskError{
+		Reason:     SkipTaskReasonCorrupted,
+		DomainID:   s.domainID,
+		WorkflowID: "some random workflow ID",
+		RunID:      uuid.New(),
+	})
+	s.True(errors.Is(err, ErrSkipTask))
+	s.Equal(SkipTaskReasonCorrupted, GetSkipTaskReason(err))
+	s.Equal(metrics.ReplicationTaskSkippedCorruptedCounter, GetSkipTaskReason(err).MetricCounter())
+	s.Contains(err.Error(), "Corrupted")
+	s.Equal(SkipTaskReasonRetentionExpired, GetSkipTaskReason(ErrSkipTask))
+	s.Equal(metrics.ReplicationTaskSkippedUpToDateCounter, SkipTaskReasonUpToDate.MetricCounter())
+	s.Equal("UpToDate", SkipTaskReasonUpToDate.String())
+}
+
+func (s *nDCHistoryResenderSuite) TestCurrentExecutionCheck_MultipleStates() {
+	domainID := uuid.New()
+	workflowID := uuid.New()
+	runID := uuid.New()
+	invariantMock := checks.NewMockInvariant(s.controller)
+	rereplicator, err := NewNDCHistoryResender(
+		s.mockDomainCache,
+		s.mockAdminClient,
+		func(ctx context.Context, request *history.ReplicateEventsV2Request) error {
+			return s.mockHistoryClient.ReplicateEventsV2(ctx, request)
+		},
+		persistence.NewPayloadSerializer(),
+		nil,
+		invariantMock,
+		s.metricsClient,
+		s.logger,
+		WithCurrentExecutionStates(persistence.WorkflowStateRunning, persistence.WorkflowStateZombie),
+	)
+	s.NoError(err)
+	s.useRereplicator(rereplicator)
+	newExecution := func(state int) *checks.CurrentExecution {
+		return &checks.CurrentExecution{
+			Execution: checks.Execution{
+				DomainID:   domainID,
+				WorkflowID: workflowID,
+				State:      state,
+			},
+		}
+	}
+
+	gomock.InOrder(
+		invariantMock.EXPECT().Check(newExecution(persistence.WorkflowStateRunning)).Return(checks.CheckResult{
+			CheckResultType: checks.CheckResultTypeHealthy,
+		}).Times(1),
+		invariantMock.EXPECT().Check(newExecution(persistence.WorkflowStateZombie)).Return(checks.CheckResult{
+			CheckResultType: checks.CheckResultTypeCorrupted,
+		}).Times(1),
+		invariantMock.EXPECT().Fix(newExecution(persistence.WorkflowStateZombie)).Return(checks.FixResult{}).Times(1),
+	)
+	_, skipTask := s.rereplicator.currentExecutionFixer.fix(s.logger, domainID, domainID, workflowID, runID)
+	s.False(skipTask)
+
+	invariantMock.EXPECT().Check(gomock.Any()).Return(checks.CheckResult{
+		CheckResultType: checks.CheckResultTypeHealthy,
+	}).Times(2)
+	reason, skipTask := s.rereplicator.currentExecutionFixer.fix(s.logger, domainID, domainID, workflowID, runID)
+	s.True(skipTask)
+	s.Equal(SkipTaskReasonRetentionExpired, reason)
+}
+
+func (s *nDCHistoryResenderSuite) TestCurrentExecutionCheck_Async() {
+	domainID := uuid.New()
+	runID := uuid.New()
+	invariantMock := checks.NewMockInvariant(s.controller)
+	rereplicator, err := NewNDCHistoryResender(
+		s.mockDomainCache,
+		s.mockAdminClient,
+		func(ctx context.Context, request *history.ReplicateEventsV2Request) error {
+			return s.mockHistoryClient.ReplicateEventsV2(ctx, request)
+		},
+		persistence.NewPayloadSerializer(),
+		nil,
+		invariantMock,
+		s.metricsClient,
+		s.logger,
+		WithAsyncCurrentExecutionFix(dynamicconfig.GetIntPropertyFn(1), dynamicconfig.GetIntPropertyFn(1)),
+	)
+	s.NoError(err)
+	s.useRereplicator(rereplicator)
+	defer s.resender.Close()
+	fixer := s.rereplicator.currentExecutionFixer
+	scope := s.metricsClient.Scope(metrics.NDCHistoryResenderScope)
+	newExecution := func(workflowID string) *checks.CurrentExecution {
+		return &checks.CurrentExecution{
+			Execution: checks.Execution{
+				DomainID:   domainID,
+				WorkflowID: workflowID,
+				State:      persistence.WorkflowStateRunning,
+			},
+		}
+	}
+	healthy := checks.CheckResult{CheckResultType: checks.CheckResultTypeHealthy}
+
+	blockedCheckStarted := make(chan struct{})
+	releaseBlockedCheck := make(chan struct{})
+	invariantMock.EXPECT().Check(newExecution("workflow1")).DoAndReturn(
+		func(execution interface{}) checks.CheckResult {
+			close(blockedCheckStarted)
+			<-releaseBlockedCheck
+			return healthy
+		}).Times(1)
+	_, skipTask, err := fixer.checkAndFix(scope, s.logger, domainID, domainID, "workflow1", runID)
+	s.Equal(ErrCurrentExecutionFixPending, err)
+	s.False(skipTask)
+	<-blockedCheckStarted
+	// the fix of the run is not queued again while pending
+	_, _, err = fixer.checkAndFix(scope, s.logger, domainID, domainID, "workflow1", runID)
+	s.Equal(ErrCurrentExecutionFixPending, err)
+
+	invariantMock.EXPECT().Check(newExecution("workflow2")).Return(healthy).Times(1)
+	_, _, err = fixer.checkAndFix(scope, s.logger, domainID, domainID, "workflow2", runID)
+	s.Equal(ErrCurrentExecutionFixPending, err)
+
+	// the queue is full, so the current execution is checked synchronously
+	invariantMock.EXPECT().Check(newExecution("workflow3")).Return(healthy).Times(1)
+	reason, skipTask, err := fixer.checkAndFix(scope, s.logger, domainID, domainID, "workflow3", runID)
+	s.NoError(err)
+	s.True(skipTask)
+	s.Equal(SkipTaskReasonRetentionExpired, reason)
+
+	// the task is skipped only once the fix done in background confirms it
+	close(releaseBlockedCheck)
+	for _, workflowID := range []string{"workflow1", "workflow2"} {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			if reason, skipTask, err = fixer.checkAndFix(scope, s.logger, domainID, domainID, workflowID, runID); err != ErrCurrentExecutionFixPending {
+				break
+			}
+		}
+		s.NoError(err)
+		s.True(skipTask)
+		s.Equal(SkipTaskReasonRetentionExpired, reason)
+	}
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_TargetEntityNotExists_AsyncFix() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	invariantMock := checks.NewMockInvariant(s.controller)
+	s.rereplicator.currentExecutionFixer.check = invariantMock
+	WithAsyncCurrentExecutionFix(dynamicconfig.GetIntPropertyFn(1), dynamicconfig.GetIntPropertyFn(1))(s.rereplicator)
+	WithSkippedRunCache(dynamicconfig.GetIntPropertyFn(10), dynamicconfig.GetDurationPropertyFn(time.Minute))(s.rereplicator)
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).MinTimes(2)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).
+		Return(&shared.EntityNotExistsError{}).MinTimes(2)
+	fixed := make(chan struct{})
+	invariantMock.EXPECT().Check(gomock.Any()).DoAndReturn(
+		func(execution interface{}) checks.CheckResult {
+			defer close(fixed)
+			return checks.CheckResult{CheckResultType: checks.CheckResultTypeHealthy}
+		}).Times(1)
+	sendHistory := func() (*ResendResult, error) {
+		return s.resender.SendSingleWorkflowHistoryWithResult(
+			context.Background(),
+			s.domainID,
+			workflowID,
+			runID,
+			nil,
+			nil,
+			nil,
+			nil,
+		)
+	}
+
+	// the task is retried while the fix is pending
+	result, err := sendHistory()
+	s.Equal(ErrCurrentExecutionFixPending, err)
+	s.True(NewDefaultErrorClassifier().IsRetryable(err))
+	s.False(result.Skipped)
+	<-fixed
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if result, err = sendHistory(); err != ErrCurrentExecutionFixPending {
+			break
+		}
+	}
+	s.True(errors.Is(err, ErrSkipTask))
+	s.Equal(SkipTaskReasonRetentionExpired, GetSkipTaskReason(err))
+	s.True(result.Skipped)
+
+	// the confirmed skip is remembered, so the source cluster is not reached again
+	_, err = sendHistory()
+	s.True(errors.Is(err, ErrSkipTask))
+}
+
+func (s *nDCHistoryResenderSuite) TestCurrentExecutionCheck_RemovesSkippedRun() {
+	workflowID := uuid.New()
+	runID := uuid.New()
+	invariantMock := checks.NewMockInvariant(s.controller)
+	s.rereplicator.currentExecutionFixer.check = invariantMock
+	WithSkippedRunCache(dynamicconfig.GetIntPropertyFn(10), dynamicconfig.GetDurationPropertyFn(time.Minute))(s.rereplicator)
+	key := getRunKey(s.domainID, workflowID, runID)
+	s.rereplicator.skippedRuns.put(key, &SkipTaskError{
+		Reason:     SkipTaskReasonRetentionExpired,
+		DomainID:   s.domainID,
+		WorkflowID: workflowID,
+		RunID:      runID,
+	})
+
+	invariantMock.EXPECT().Check(gomock.Any()).Return(checks.CheckResult{
+		CheckResultType: checks.CheckResultTypeCorrupted,
+	}).Times(1)
+	invariantMock.EXPECT().Fix(gomock.Any()).Return(checks.FixResult{
+		FixResultType: checks.FixResultTypeFixed,
+	}).Times(1)
+	_, skipTask := s.rereplicator.currentExecutionFixer.fix(s.logger, s.domainID, s.domainID, workflowID, runID)
+	s.False(skipTask)
+	// the run may be resent once its current execution is fixed
+	s.Nil(s.rereplicator.skippedRuns.get(key))
+}
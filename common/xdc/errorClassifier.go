@@ -0,0 +1,77 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+)
+
+type (
+	// ErrorClassifier classifies the errors returned by the source and the target clusters,
+	// to decide how the resend reacts to them
+	ErrorClassifier interface {
+		// IsRetryable returns whether the failed call may succeed if retried, the history fetch is retried on such errors,
+		// and the consecutive resends failed with such errors open the circuit breaker of the domain
+		IsRetryable(err error) bool
+		// IsSkippable returns whether the error of the target indicates the run does not exist in the target,
+		// the resend is skipped on such errors if the current execution check allows
+		IsSkippable(err error) bool
+		// IsFatal returns whether the error fails the resend right away, without any retry
+		IsFatal(err error) bool
+	}
+
+	defaultErrorClassifier struct{}
+)
+
+var _ ErrorClassifier = (*defaultErrorClassifier)(nil)
+
+// NewDefaultErrorClassifier creates the ErrorClassifier used by the resender if none is provided
+func NewDefaultErrorClassifier() ErrorClassifier {
+	return &defaultErrorClassifier{}
+}
+
+func (c *defaultErrorClassifier) IsRetryable(
+	err error,
+) bool {
+
+	return common.IsServiceTransientError(err) || common.IsContextTimeoutError(err)
+}
+
+func (c *defaultErrorClassifier) IsSkippable(
+	err error,
+) bool {
+
+	return containsEntityNotExistsError(err)
+}
+
+func (c *defaultErrorClassifier) IsFatal(
+	err error,
+) bool {
+
+	switch err.(type) {
+	case *shared.BadRequestError, *shared.AccessDeniedError:
+		return true
+	}
+	return err == ErrResenderClosed || err == context.Canceled
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/multierr"
+	"go.uber.org/yarpc/yarpcerrors"
+
+	"github.com/uber/cadence/.gen/go/admin"
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+	checks "github.com/uber/cadence/common/reconciliation/common"
+)
+
+func TestDefaultErrorClassifier(t *testing.T) {
+	classifier := NewDefaultErrorClassifier()
+
+	testCases := []struct {
+		name      string
+		err       error
+		retryable bool
+		skippable bool
+		fatal     bool
+	}{
+		{
+			name: "unknown error",
+			err:  errors.New("some random error"),
+		},
+		{
+			name:      "internal service error",
+			err:       &shared.InternalServiceError{},
+			retryable: true,
+		},
+		{
+			name:      "service busy error",
+			err:       &shared.ServiceBusyError{},
+			retryable: true,
+		},
+		{
+			name:      "yarpc unavailable error",
+			err:       yarpcerrors.UnavailableErrorf("some random error"),
+			retryable: true,
+		},
+		{
+			name:      "context timeout",
+			err:       context.DeadlineExceeded,
+			retryable: true,
+		},
+		{
+			name:      "entity not exists error",
+			err:       &shared.EntityNotExistsError{},
+			skippable: true,
+		},
+		{
+			name:      "entity not exists error of one target",
+			err:       multierr.Append(&shared.InternalServiceError{}, &shared.EntityNotExistsError{}),
+			skippable: true,
+		},
+		{
+			name:  "bad request error",
+			err:   &shared.BadRequestError{},
+			fatal: true,
+		},
+		{
+			name:  "access denied error",
+			err:   &shared.AccessDeniedError{},
+			fatal: true,
+		},
+		{
+			name:  "context cancelled",
+			err:   context.Canceled,
+			fatal: true,
+		},
+		{
+			name:  "resender closed",
+			err:   ErrResenderClosed,
+			fatal: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.retryable, classifier.IsRetryable(tc.err))
+			assert.Equal(t, tc.skippable, classifier.IsSkippable(tc.err))
+			assert.Equal(t, tc.fatal, classifier.IsFatal(tc.err))
+		})
+	}
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_ErrorClassifier() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	invariantMock := checks.NewMockInvariant(s.controller)
+	s.rereplicator.currentExecutionFixer.check = invariantMock
+	WithErrorClassifier(&testErrorClassifier{
+		ErrorClassifier: NewDefaultErrorClassifier(),
+		skippable: func(err error) bool {
+			_, ok := err.(*shared.InternalServiceError)
+			return ok
+		},
+	})(s.rereplicator)
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).
+		Return(&shared.InternalServiceError{}).Times(1)
+	invariantMock.EXPECT().Check(gomock.Any()).Return(checks.CheckResult{
+		CheckResultType: checks.CheckResultTypeHealthy,
+	}).Times(1)
+
+	result, err := s.resender.SendSingleWorkflowHistoryWithResult(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.True(errors.Is(err, ErrSkipTask))
+	s.True(result.Skipped)
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uber/cadence/common"
+)
+
+type (
+	// inFlightResendRegistry tracks the in-flight resends keyed by their runs, so they can be listed and cancelled
+	inFlightResendRegistry struct {
+		sync.Mutex
+		resends map[string]map[*inFlightResend]struct{}
+	}
+
+	// inFlightResend is the registry entry of an in-flight resend, which can be cancelled by CancelResend
+	inFlightResend struct {
+		cancel      context.CancelFunc
+		cancelled   bool
+		descriptor  ResendDescriptor
+		startTime   time.Time
+		lastEventID int64
+	}
+)
+
+func newInFlightResendRegistry() *inFlightResendRegistry {
+	return &inFlightResendRegistry{
+		resends: make(map[string]map[*inFlightResend]struct{}),
+	}
+}
+
+// register returns a copy of the context which is cancelled by cancel of the run, along with the registry entry
+// of the resend. the returned func must be called once the resend completes, it returns whether the resend is cancelled
+func (r *inFlightResendRegistry) register(
+	ctx context.Context,
+	descriptor *ResendDescriptor,
+	startTime time.Time,
+) (context.Context, *inFlightResend, func() bool) {
+
+	ctx, cancel := context.WithCancel(ctx)
+	resend := &inFlightResend{
+		cancel:      cancel,
+		descriptor:  *descriptor,
+		startTime:   startTime,
+		lastEventID: common.EmptyEventID,
+	}
+	runKey := getRunKey(descriptor.DomainID, descriptor.WorkflowID, descriptor.RunID)
+
+	r.Lock()
+	resends, ok := r.resends[runKey]
+	if !ok {
+		resends = make(map[*inFlightResend]struct{})
+		r.resends[runKey] = resends
+	}
+	resends[resend] = struct{}{}
+	r.Unlock()
+
+	return ctx, resend, func() bool {
+		cancel()
+
+		r.Lock()
+		defer r.Unlock()
+
+		delete(resends, resend)
+		if len(resends) == 0 {
+			delete(r.resends, runKey)
+		}
+		return resend.cancelled
+	}
+}
+
+// cancel cancels the in-flight resends of the run, it returns whether any in-flight resend of the run is found
+func (r *inFlightResendRegistry) cancel(
+	domainID string,
+	workflowID string,
+	runID string,
+) bool {
+
+	r.Lock()
+	defer r.Unlock()
+
+	resends := r.resends[getRunKey(domainID, workflowID, runID)]
+	for resend := range resends {
+		resend.cancelled = true
+		resend.cancel()
+	}
+	return len(resends) > 0
+}
+
+// snapshot returns the in-flight resends ordered by their start time, the resends completed are removed
+// from the registry under the same lock, so each of them is either fully included or not
+func (r *inFlightResendRegistry) snapshot() []*InFlightResendInfo {
+	r.Lock()
+	defer r.Unlock()
+
+	var infos []*InFlightResendInfo
+	for _, resends := range r.resends {
+		for resend := range resends {
+			infos = append(infos, &InFlightResendInfo{
+				Descriptor:  resend.descriptor,
+				StartTime:   resend.startTime,
+				LastEventID: atomic.LoadInt64(&resend.lastEventID),
+			})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StartTime.Before(infos[j].StartTime)
+	})
+	return infos
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"go.uber.org/yarpc"
+
+	"github.com/uber/cadence/.gen/go/admin"
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+)
+
+func (s *nDCHistoryResenderSuite) TestCancelResend() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	started := make(chan struct{})
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, &shared.InternalServiceError{Message: ctx.Err().Error()}
+		}).Times(1)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.resender.SendSingleWorkflowHistory(context.Background(), s.domainID, workflowID, runID, nil, nil, nil, nil)
+	}()
+	<-started
+	s.False(s.resender.CancelResend(s.domainID, workflowID, uuid.New()))
+	s.True(s.resender.CancelResend(s.domainID, workflowID, runID))
+
+	select {
+	case err := <-errCh:
+		s.Equal(context.Canceled, err)
+	case <-time.After(time.Second):
+		s.Fail("resend is not cancelled")
+	}
+	// the completed resend is removed from the registry
+	s.False(s.resender.CancelResend(s.domainID, workflowID, runID))
+}
+
+func (s *nDCHistoryResenderSuite) TestInFlight() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	started := make(chan struct{})
+	gomock.InOrder(
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).Return(
+			&admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob},
+				NextPageToken:  []byte("some random next page token"),
+				VersionHistory: &shared.VersionHistory{
+					Items: []*shared.VersionHistoryItem{
+						{
+							EventID: common.Int64Ptr(3),
+							Version: common.Int64Ptr(123),
+						},
+					},
+				},
+			}, nil).Times(1),
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+				close(started)
+				<-ctx.Done()
+				return nil, &shared.InternalServiceError{Message: ctx.Err().Error()}
+			}).Times(1),
+	)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	s.Empty(s.resender.InFlight())
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.resender.SendSingleWorkflowHistory(context.Background(), s.domainID, workflowID, runID, nil, nil, nil, nil)
+	}()
+	<-started
+	inFlight := s.resender.InFlight()
+	s.Len(inFlight, 1)
+	s.Equal(s.domainID, inFlight[0].Descriptor.DomainID)
+	s.Equal(workflowID, inFlight[0].Descriptor.WorkflowID)
+	s.Equal(runID, inFlight[0].Descriptor.RunID)
+	s.False(inFlight[0].StartTime.IsZero())
+	s.Equal(int64(2), inFlight[0].LastEventID)
+
+	s.True(s.resender.CancelResend(s.domainID, workflowID, runID))
+	select {
+	case err := <-errCh:
+		s.Equal(context.Canceled, err)
+	case <-time.After(time.Second):
+		s.Fail("resend is not cancelled")
+	}
+	// the completed resend is removed from the registry
+	s.Empty(s.resender.InFlight())
+}
@@ -24,41 +24,207 @@ package xdc
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/opentracing/opentracing-go"
+	"github.com/pborman/uuid"
+	"go.uber.org/multierr"
+	"go.uber.org/thriftrw/wire"
+	"go.uber.org/yarpc/yarpcerrors"
+
 	"github.com/uber/cadence/.gen/go/admin"
 	"github.com/uber/cadence/.gen/go/history"
 	"github.com/uber/cadence/.gen/go/shared"
 	adminClient "github.com/uber/cadence/client/admin"
 	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/archiver/provider"
+	"github.com/uber/cadence/common/backoff"
 	"github.com/uber/cadence/common/cache"
+	"github.com/uber/cadence/common/clock"
+	"github.com/uber/cadence/common/codec"
 	"github.com/uber/cadence/common/collection"
 	"github.com/uber/cadence/common/log"
 	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/metrics"
 	"github.com/uber/cadence/common/persistence"
 	checks "github.com/uber/cadence/common/reconciliation/common"
 	"github.com/uber/cadence/common/service/dynamicconfig"
 )
 
-var (
-	// ErrSkipTask is the error to skip task due to absence of the workflow in the source cluster
-	ErrSkipTask = errors.New("the source workflow does not exist")
-)
+// signalMarkerEventTypes are the event types of the signals and the markers, which are partitioned
+// from the core events by PartitionWorkflowHistory
+var signalMarkerEventTypes = map[shared.EventType]struct{}{
+	shared.EventTypeWorkflowExecutionSignaled:                {},
+	shared.EventTypeSignalExternalWorkflowExecutionInitiated: {},
+	shared.EventTypeSignalExternalWorkflowExecutionFailed:    {},
+	shared.EventTypeExternalWorkflowExecutionSignaled:        {},
+	shared.EventTypeMarkerRecorded:                           {},
+}
 
 const (
-	resendContextTimeout = 30 * time.Second
+	defaultResendContextTimeout = 30 * time.Second
+
+	defaultResendConcurrency = 1
+
+	defaultResendPageBufferSize = 1
+
+	defaultResendFetchConcurrency = 1
+
+	defaultMinAdaptivePageSize = 1
+	defaultMaxAdaptivePageSize = 1000
+
+	defaultDomainConcurrencyWaitTimeout = 5 * time.Second
+
+	defaultMaxTimeoutOverride = 30 * time.Minute
+
+	// lastKnownDomainTTL and lastKnownDomainMaxCount bound the last known domains remembered for the resumed resends
+	lastKnownDomainTTL      = 24 * time.Hour
+	lastKnownDomainMaxCount = 1000
+
+	minCallTimeout = 100 * time.Millisecond
+
+	getHistoryRetryInitialInterval = 100 * time.Millisecond
+	getHistoryRetryMaxInterval     = 2 * time.Second
+	getHistoryRetryMaxAttempts     = 3
+
+	replicationServiceBusyRetryInitialInterval = 100 * time.Millisecond
+	replicationServiceBusyRetryMaxInterval     = 2 * time.Second
+	replicationServiceBusyRetryMaxAttempts     = 5
+
+	// historyEventsFieldID and historyEventIDFieldID are the thrift field IDs of History.events and HistoryEvent.eventId
+	historyEventsFieldID  = 10
+	historyEventIDFieldID = 10
+
+	// pingWorkflowID is the workflow ID used to probe the source cluster, no run of it is expected to exist
+	pingWorkflowID = "cadence-history-resender-ping"
+	// pingDomainName is the domain name used to probe the source cluster without a domain, it is not expected to exist
+	pingDomainName = "cadence-history-resender-ping"
 )
 
+// replayNextPageToken is the placeholder of the next page token of the pages replayed by ReplayPageTokens
+var replayNextPageToken = []byte("replay")
+
 type (
 	// nDCHistoryReplicationFn provides the functionality to deliver replication raw history request to history
-	// the provided func should be thread safe
+	// the provided func should be thread safe. If the target acknowledges the batch with the checksum computed
+	// by ComputeReplicationChecksum, the func should report it by ReportReplicationChecksum with the given context,
+	// so the resender verifies the batch is not corrupted on the way
 	nDCHistoryReplicationFn func(ctx context.Context, request *history.ReplicateEventsV2Request) error
 
 	// NDCHistoryResender is the interface for resending history events to remote
 	NDCHistoryResender interface {
 		// SendSingleWorkflowHistory sends multiple run IDs's history events to remote
 		SendSingleWorkflowHistory(
+			ctx context.Context,
+			domainID string,
+			workflowID string,
+			runID string,
+			startEventID *int64,
+			startEventVersion *int64,
+			endEventID *int64,
+			endEventVersion *int64,
+		) error
+		// SendSingleWorkflowHistoryByDomainName sends one run IDs's history events to remote,
+		// the domain is identified by its name instead of its ID
+		SendSingleWorkflowHistoryByDomainName(
+			ctx context.Context,
+			domainName string,
+			workflowID string,
+			runID string,
+			startEventID *int64,
+			startEventVersion *int64,
+			endEventID *int64,
+			endEventVersion *int64,
+		) error
+		// SendSingleWorkflowHistoryWithResult sends one run IDs's history events to remote
+		// and reports the work done, the result is returned even if the resend fails halfway
+		SendSingleWorkflowHistoryWithResult(
+			ctx context.Context,
+			domainID string,
+			workflowID string,
+			runID string,
+			startEventID *int64,
+			startEventVersion *int64,
+			endEventID *int64,
+			endEventVersion *int64,
+		) (*ResendResult, error)
+		// StreamSingleWorkflowHistory sends one run IDs's history events to remote in background,
+		// the progress of each sent event batch is emitted to the returned progress channel, which must be drained by the caller.
+		// both channels are closed once the resend completes, fails or the context is done,
+		// and the error of the resend, if any, is emitted to the error channel before it is closed
+		StreamSingleWorkflowHistory(
+			ctx context.Context,
+			domainID string,
+			workflowID string,
+			runID string,
+			startEventID *int64,
+			startEventVersion *int64,
+			endEventID *int64,
+			endEventVersion *int64,
+		) (<-chan ResendProgress, <-chan error)
+		// ResendWorkflowHistory sends the history events of the run described by the descriptor to remote,
+		// the resend starts from the descriptor's resume token if provided
+		ResendWorkflowHistory(
+			ctx context.Context,
+			descriptor *ResendDescriptor,
+		) (*ResendResult, error)
+		// EstimateResend paginates through the history events of the run described by the descriptor
+		// and reports what would be sent, without actually sending anything to remote
+		EstimateResend(
+			ctx context.Context,
+			descriptor *ResendDescriptor,
+		) (*ResendResult, error)
+		// EstimateBulkResend estimates the resends of multiple runs, and aggregates the estimates in total and by domain,
+		// the runs failed to be estimated are reported in the result instead of aborting the others
+		EstimateBulkResend(
+			ctx context.Context,
+			descriptors []*ResendDescriptor,
+		) (*BulkEstimateResult, error)
+		// SendMissingHistory sends the history events of the run after the last event already replicated to the target,
+		// SkipTaskError is returned if the target already has all the history events of the run
+		SendMissingHistory(
+			ctx context.Context,
+			domainID string,
+			workflowID string,
+			runID string,
+			targetLastEventID int64,
+			targetLastEventVersion int64,
+		) (*ResendResult, error)
+		// SendWorkflowHistoryByRange sends history events of the run within the event ID range to remote,
+		// the corresponding event versions are resolved from the version histories of the run in remote
+		SendWorkflowHistoryByRange(
+			ctx context.Context,
+			domainID string,
+			workflowID string,
+			runID string,
+			startEventID *int64,
+			endEventID *int64,
+		) (*ResendResult, error)
+		// SendWorkflowHistoryWindow sends history events of the run within the radius around the center event to remote,
+		// the window is clamped to the event ID range of the run
+		SendWorkflowHistoryWindow(
+			ctx context.Context,
+			domainID string,
+			workflowID string,
+			runID string,
+			centerEventID int64,
+			radius int64,
+		) (*ResendResult, error)
+		// FetchWorkflowHistory returns the history events of the run which would be sent to remote, in order,
+		// without sending anything. The batches are passed to the callback instead of being returned if the callback is provided.
+		// Only the events of the event types are returned if any event type is provided, such batches cannot be replicated
+		FetchWorkflowHistory(
+			ctx context.Context,
 			domainID string,
 			workflowID string,
 			runID string,
@@ -66,27 +232,416 @@ type (
 			startEventVersion *int64,
 			endEventID *int64,
 			endEventVersion *int64,
+			eventTypes []shared.EventType,
+			callback FetchBatchCallback,
+		) ([]*FetchedEventBatch, error)
+		// PartitionWorkflowHistory returns the history events of the run partitioned into the core events and
+		// the signal and marker events, without sending anything. The batches are passed to the callback instead
+		// of being returned if the callback is provided. The partitioned batches are for the analysis only,
+		// they cannot be replicated
+		PartitionWorkflowHistory(
+			ctx context.Context,
+			domainID string,
+			workflowID string,
+			runID string,
+			startEventID *int64,
+			startEventVersion *int64,
+			endEventID *int64,
+			endEventVersion *int64,
+			callback PartitionBatchCallback,
+		) ([]*FetchedEventBatch, []*FetchedEventBatch, error)
+		// VerifyWorkflowHistory compares the history events of the run in the source with the ones read from
+		// the target by the target reader, without sending anything, and reports the differences
+		VerifyWorkflowHistory(
+			ctx context.Context,
+			domainID string,
+			workflowID string,
+			runID string,
+			targetReader TargetHistoryReader,
+		) (*VerificationReport, error)
+		// SendMultiWorkflowHistory sends history events of multiple runs to remote
+		SendMultiWorkflowHistory(
+			ctx context.Context,
+			descriptors []*ResendDescriptor,
+		) error
+		// Ping checks whether the admin service of the source cluster of the domain is reachable,
+		// a PingError is returned if the source cluster is unreachable or denies the access
+		Ping(
+			ctx context.Context,
+			domainID string,
+		) error
+		// Validate checks whether the admin services of all the source clusters are reachable, e.g. at startup to
+		// surface the TLS or auth misconfiguration early, the PingErrors of the source clusters are returned combined
+		Validate(
+			ctx context.Context,
+		) error
+		// EstimateReplicationLag estimates how far the replication of the run is behind,
+		// by the time elapsed since the last event of the run in the source cluster
+		EstimateReplicationLag(
+			ctx context.Context,
+			domainID string,
+			workflowID string,
+			runID string,
+		) (time.Duration, error)
+		// GetSourceVersionHistories returns the version histories of the run held by the source cluster for the
+		// diagnostic tools, only the current branch is returned as the admin service does not expose the others
+		// in a typed form
+		GetSourceVersionHistories(
+			ctx context.Context,
+			domainID string,
+			workflowID string,
+			runID string,
+		) (*shared.VersionHistories, error)
+		// SendWorkflowChain sends history events of the runs in the continuation chain to remote, in the order of the chain,
+		// starting from the start run until the run which is not continued as new
+		SendWorkflowChain(
+			ctx context.Context,
+			domainID string,
+			workflowID string,
+			startRunID string,
+		) ([]*WorkflowChainResult, error)
+		// Stats returns a snapshot of the cumulative counters of the resends since the resender is created
+		Stats() ResendStats
+		// CancelResend cancels the in-flight resends of the run, which return context.Canceled,
+		// it returns whether any in-flight resend of the run is found
+		CancelResend(
+			domainID string,
+			workflowID string,
+			runID string,
+		) bool
+		// InFlight returns a snapshot of the in-flight resends, ordered by their start time
+		InFlight() []*InFlightResendInfo
+		// Close cancels all in-flight resends, resends after Close fail with ErrResenderClosed
+		Close()
+	}
+
+	// NDCHistoryResenderDiagnostics is the advanced interface of the resender for the diagnostic tools,
+	// it is separated from NDCHistoryResender since it bypasses the range based pagination of the resends
+	NDCHistoryResenderDiagnostics interface {
+		// ReplayPageTokens resends exactly the pages of the run fetched by the recorded resume tokens, in order
+		ReplayPageTokens(
+			ctx context.Context,
+			descriptor *ResendDescriptor,
+			resumeTokens [][]byte,
+		) (*ResendResult, error)
+	}
+
+	// HistoryFetcher fetches a page of raw history events of a run from the source of the resend,
+	// the request is in the same form as the admin API, so the fetcher can be backed by the source cluster,
+	// the archival storage or a file
+	HistoryFetcher interface {
+		GetRawHistory(
+			ctx context.Context,
+			request *admin.GetWorkflowExecutionRawHistoryV2Request,
+		) (*admin.GetWorkflowExecutionRawHistoryV2Response, error)
+	}
+
+	// AuditSink records every event batch replicated to remote, e.g. into an immutable store for the compliance,
+	// the batch is written right before it is sent, so a batch may be written more than once if the send is retried
+	AuditSink interface {
+		Write(
+			ctx context.Context,
+			domainID string,
+			workflowID string,
+			runID string,
+			batch *shared.DataBlob,
 		) error
 	}
 
+	// AdminHeadersProvider returns the headers attached to each call to the admin service of the source cluster
+	// made for the domain, e.g. for the authentication and the routing between the clusters,
+	// the domain ID is empty for the calls not made for any domain, i.e. Validate
+	AdminHeadersProvider func(ctx context.Context, domainID string) (map[string]string, error)
+
+	// ResendDescriptor describes the history events of a single run to be resent
+	ResendDescriptor struct {
+		DomainID          string
+		WorkflowID        string
+		RunID             string
+		StartEventID      *int64
+		StartEventVersion *int64
+		EndEventID        *int64
+		EndEventVersion   *int64
+		// ResumeToken is the opaque token obtained from ResendResult.GetResumeToken of a prior resend of the same run,
+		// history events before the token are not sent again
+		ResumeToken []byte
+		// Cursor is the cursor obtained from ResendResult.GetCursor of a prior resend of the same run, e.g. persisted
+		// after each batch and restored by UnmarshalResendCursor after a crash, history events up to the last event
+		// of the cursor are not sent again. It cannot be provided together with the resume token
+		Cursor *ResendCursor
+		// TimeoutOverride overrides the rereplication timeout of the domain for this resend if positive,
+		// e.g. for an unusually large workflow, it is bounded by the max timeout override of the resender.
+		// The override takes precedence over the rereplication timeout of the domain, which takes precedence
+		// over the default of no timeout
+		TimeoutOverride time.Duration
+		// CloseTime is the close time of the run if known, zero if the run is still open or the close time is unknown.
+		// The resend of a closed run is bounded by the remaining retention of the run, see getResendTimeout
+		CloseTime time.Time
+
+		// replayPageTokens are the page tokens of the pages fetched in order by ReplayPageTokens,
+		// instead of following the next page tokens returned by the source
+		replayPageTokens [][]byte
+		// missingOnly is set by SendMissingHistory, the start event is the last event of the target,
+		// which must be on the version history of the source, and the target is up to date if nothing is sent
+		missingOnly bool
+	}
+
+	// InFlightResendInfo is the snapshot of an in-flight resend returned by InFlight
+	InFlightResendInfo struct {
+		Descriptor ResendDescriptor
+		StartTime  time.Time
+		// LastEventID is the ID of the last event sent so far, common.EmptyEventID if nothing is sent yet
+		LastEventID int64
+	}
+
+	// ResendResult summarizes the history events sent to remote by a single run resend
+	ResendResult struct {
+		// BatchCount is the number of event batches successfully sent
+		BatchCount int
+		// TotalBytes is the total size of the event batches successfully sent
+		TotalBytes int64
+		// FirstEventID is the ID of the first event sent, common.EmptyEventID if nothing is sent
+		FirstEventID int64
+		// LastEventID is the ID of the last event sent, common.EmptyEventID if nothing is sent
+		LastEventID int64
+		// EventCount is the number of events sent
+		EventCount int64
+		// Skipped indicates the resend is skipped since the run does not exist in the source cluster,
+		// or the target already has all the history events of the run
+		Skipped bool
+		// NextPageToken is the pagination token following the last page fully sent,
+		// it is empty if all pages are sent
+		NextPageToken []byte
+		// FetchedBytes is the total size of the event batches received from the source cluster
+		FetchedBytes int64
+		// SentBytes is the total size of the event batches handed to the targets, including the retries
+		SentBytes int64
+
+		domainID   string
+		workflowID string
+		runID      string
+		// cursorEventID and cursorEventVersion are the ID and the version of the last event sent,
+		// including the ones sent by the prior resend the cursor of which the resend resumes from
+		cursorEventID      int64
+		cursorEventVersion int64
+	}
+
+	// BulkEstimate is the aggregated estimate of the resends of multiple runs
+	BulkEstimate struct {
+		// WorkflowCount is the number of runs estimated
+		WorkflowCount int64
+		// EventCount is the number of events which would be sent
+		EventCount int64
+		// BatchCount is the number of event batches which would be sent
+		BatchCount int64
+		// TotalBytes is the total size of the event batches which would be sent
+		TotalBytes int64
+	}
+
+	// BulkEstimateFailure is a run failed to be estimated by EstimateBulkResend
+	BulkEstimateFailure struct {
+		Descriptor *ResendDescriptor
+		Err        error
+	}
+
+	// BulkEstimateResult is the result of EstimateBulkResend
+	BulkEstimateResult struct {
+		// Total is the estimate of all the runs estimated
+		Total BulkEstimate
+		// Domains is the estimate of the runs estimated keyed by the domain ID
+		Domains map[string]*BulkEstimate
+		// Failures are the runs failed to be estimated, which are not counted in the estimates
+		Failures []*BulkEstimateFailure
+	}
+
+	// WorkflowChainResult is the result of a single run resent as part of the continuation chain
+	WorkflowChainResult struct {
+		RunID  string
+		Result *ResendResult
+	}
+
+	// ResendProgress is the progress of a single event batch sent to remote
+	ResendProgress struct {
+		// BatchIndex is the index of the batch within the resend
+		BatchIndex   int
+		FirstEventID int64
+		LastEventID  int64
+		Bytes        int
+	}
+
+	// FetchedEventBatch is a raw event batch of the run, along with the version history of the events
+	FetchedEventBatch struct {
+		RawEventBatch  *shared.DataBlob
+		VersionHistory *shared.VersionHistory
+		// Filtered indicates only the events of the requested event types are kept in the batch,
+		// the events are no longer contiguous, so the batch is only valid for reading or exporting,
+		// and must never be replicated to remote
+		Filtered bool
+	}
+
+	// FetchBatchCallback is invoked synchronously for each event batch fetched from the source cluster,
+	// the fetch stops with the error returned by the callback
+	FetchBatchCallback func(batch *FetchedEventBatch) error
+
+	// PartitionBatchCallback is invoked synchronously for each event batch fetched from the source cluster,
+	// with the core events and the signal and marker events of the batch, either of which is nil if the batch
+	// has no such events, the fetch stops with the error returned by the callback
+	PartitionBatchCallback func(coreBatch *FetchedEventBatch, signalMarkerBatch *FetchedEventBatch) error
+
+	// ResendBatchCallback is invoked synchronously after each event batch is successfully sent to remote,
+	// batchIndex is the index of the batch within a single run resend
+	ResendBatchCallback func(batchIndex int, firstEventID int64, lastEventID int64, bytes int)
+
+	// TargetDomainMapper returns the ID of the domain of the target to replicate the history events of the source domain
+	// into, e.g. a quarantine domain to validate the events before replicating them into the live domain.
+	// The events are replicated into the source domain if the empty ID is returned
+	TargetDomainMapper func(domainID string, domainName string) (string, error)
+
+	// TargetProgressChecker returns the highest event ID of the run already present on the target, the domain ID is
+	// the one of the target domain. common.EmptyEventID should be returned if the target has none of the events
+	TargetProgressChecker func(ctx context.Context, domainID string, workflowID string, runID string) (int64, error)
+
+	// NDCHistoryResenderOption is used to configure optional behaviors of NDCHistoryResenderImpl
+	NDCHistoryResenderOption func(*NDCHistoryResenderImpl)
+
+	// resendRun is the state of the resend of a single run, shared by the stages of the resend
+	resendRun struct {
+		descriptor       *ResendDescriptor
+		dryRun           bool
+		progressCallback ResendBatchCallback
+		logger           log.Logger
+		scope            metrics.Scope
+		domainEntry      *cache.DomainCacheEntry
+		targetDomainID   string
+		initialPageToken []byte
+		// the events up to the sent event ID are already sent by the resend the cursor is taken from
+		sentEventID int64
+		// the ID of the last event forwarded, the event IDs of a run are contiguous across the version boundaries,
+		// so the first event ID of the next batch is expected to follow it regardless of the versions
+		lastForwardedEventID int64
+		// whether the page token of the result can be used to resume the resend
+		resumable bool
+		inFlight  *inFlightResend
+		result    *ResendResult
+	}
+
 	// NDCHistoryResenderImpl is the implementation of NDCHistoryResender
 	NDCHistoryResenderImpl struct {
 		domainCache           cache.DomainCache
 		adminClient           adminClient.Client
-		historyReplicationFn  nDCHistoryReplicationFn
+		historyReplicationFns []nDCHistoryReplicationFn
 		serializer            persistence.PayloadSerializer
 		rereplicationTimeout  dynamicconfig.DurationPropertyFnWithDomainIDFilter
-		currentExecutionCheck checks.Invariant
+		metricsClient         metrics.Client
 		logger                log.Logger
+
+		rootCtx    context.Context
+		rootCancel context.CancelFunc
+
+		resendConcurrency      dynamicconfig.IntPropertyFnWithDomainIDFilter
+		resendPageSize         dynamicconfig.IntPropertyFnWithDomainIDFilter
+		resendPageBufferSize   dynamicconfig.IntPropertyFnWithDomainIDFilter
+		resendFetchConcurrency dynamicconfig.IntPropertyFnWithDomainIDFilter
+		defaultPageSize        int32
+		verifyConcurrency      dynamicconfig.IntPropertyFnWithDomainIDFilter
+		reverseFetch           dynamicconfig.BoolPropertyFnWithDomainIDFilter
+		adminHeadersProvider   AdminHeadersProvider
+		auditSink              AuditSink
+		failOnAuditError       dynamicconfig.BoolPropertyFnWithDomainIDFilter
+
+		historyFetcher         HistoryFetcher
+		archiverProvider       provider.ArchiverProvider
+		archivalFallback       dynamicconfig.BoolPropertyFnWithDomainIDFilter
+		getHistoryRetryPolicy  backoff.RetryPolicy
+		replicationRetryPolicy backoff.RetryPolicy
+		errorClassifier        ErrorClassifier
+		limiter                *ResendLimiter
+
+		sourceCluster string
+		// sourceAdminClients are the admin clients of the clusters keyed by the cluster name,
+		// the admin client of the resender is used for all the domains if not set
+		sourceAdminClients map[string]adminClient.Client
+
+		batchCallback ResendBatchCallback
+
+		targetDomainMapper TargetDomainMapper
+
+		targetProgressChecker TargetProgressChecker
+
+		currentExecutionFixer *currentExecutionFixer
+
+		eventBlobEncoding dynamicconfig.StringPropertyFnWithDomainFilter
+
+		domainPriority dynamicconfig.StringPropertyFnWithDomainFilter
+
+		batchDelay dynamicconfig.DurationPropertyFnWithDomainIDFilter
+
+		largeBatchThreshold dynamicconfig.IntPropertyFnWithDomainIDFilter
+		maxBatchSize        dynamicconfig.IntPropertyFnWithDomainIDFilter
+		splitBatchSize      dynamicconfig.IntPropertyFnWithDomainIDFilter
+		circuitBreaker      *resendCircuitBreaker
+		timeSource          clock.TimeSource
+
+		sharedResendsLock sync.Mutex
+		sharedResends     map[string]*sharedResend
+
+		// skippedRuns remembers the runs skipped as the workflow is already deleted in the target
+		skippedRuns *skippedRunCache
+
+		stats *resendStatsRecorder
+
+		multiResendRetryBudget dynamicconfig.IntPropertyFn
+
+		inFlightResends *inFlightResendRegistry
+
+		// lastKnownDomains remembers the resolved domains, used by the resumed resends if the domain cannot be resolved
+		lastKnownDomains cache.Cache
+
+		strictVersionHistoryValidation dynamicconfig.BoolPropertyFnWithDomainIDFilter
+
+		eventBatchValidation  dynamicconfig.BoolPropertyFnWithDomainIDFilter
+		skipInvalidEventBatch dynamicconfig.BoolPropertyFnWithDomainIDFilter
+		failOnHistoryGap      dynamicconfig.BoolPropertyFnWithDomainIDFilter
+
+		skipEmptyVersionHistory dynamicconfig.BoolPropertyFnWithDomainIDFilter
+
+		trimBatchToEndEvent dynamicconfig.BoolPropertyFnWithDomainIDFilter
+
+		maxResendBytes dynamicconfig.IntPropertyFnWithDomainIDFilter
+		maxPages       dynamicconfig.IntPropertyFnWithDomainIDFilter
+
+		quietExpectedErrors dynamicconfig.BoolPropertyFnWithDomainIDFilter
+
+		targetShardCount int
+
+		targetPageBytes     dynamicconfig.IntPropertyFnWithDomainIDFilter
+		minAdaptivePageSize dynamicconfig.IntPropertyFnWithDomainIDFilter
+		maxAdaptivePageSize dynamicconfig.IntPropertyFnWithDomainIDFilter
+
+		getHistoryTimeout  dynamicconfig.DurationPropertyFnWithDomainIDFilter
+		replicationTimeout dynamicconfig.DurationPropertyFnWithDomainIDFilter
+
+		rereplicationTimeoutJitter dynamicconfig.FloatPropertyFnWithDomainIDFilter
+		maxTimeoutOverride         dynamicconfig.DurationPropertyFnWithDomainIDFilter
+		randLock                   sync.Mutex
+		rand                       *rand.Rand
+
+		tracer opentracing.Tracer
 	}
 
 	historyBatch struct {
 		versionHistory *shared.VersionHistory
 		rawEventBatch  *shared.DataBlob
+		// nextPageToken is only set on the last batch of a page
+		nextPageToken []byte
+		lastInPage    bool
 	}
 )
 
-// NewNDCHistoryResender create a new NDCHistoryResenderImpl
+// NewNDCHistoryResender create a new NDCHistoryResenderImpl,
+// it returns an error if the domain cache, admin client, history replication function or serializer is missing.
+// the calls to the admin client and the history replication function are retried by the retry policies of the resender,
+// so they should not be retried by the client or the function again
 func NewNDCHistoryResender(
 	domainCache cache.DomainCache,
 	adminClient adminClient.Client,
@@ -94,170 +649,409 @@ func NewNDCHistoryResender(
 	serializer persistence.PayloadSerializer,
 	rereplicationTimeout dynamicconfig.DurationPropertyFnWithDomainIDFilter,
 	currentExecutionCheck checks.Invariant,
+	metricsClient metrics.Client,
 	logger log.Logger,
-) *NDCHistoryResenderImpl {
+	opts ...NDCHistoryResenderOption,
+) (*NDCHistoryResenderImpl, error) {
 
-	return &NDCHistoryResenderImpl{
+	// the missing dependencies are reported upfront, instead of a nil pointer dereference halfway through a resend
+	switch {
+	case domainCache == nil:
+		return nil, errors.New("NDC history resender is created without domainCache")
+	case adminClient == nil:
+		return nil, errors.New("NDC history resender is created without adminClient")
+	case serializer == nil:
+		return nil, errors.New("NDC history resender is created without serializer")
+	case historyReplicationFn == nil:
+		return nil, errors.New("NDC history resender is created without historyReplicationFn")
+	}
+
+	rootCtx, rootCancel := context.WithCancel(context.Background())
+	resender := &NDCHistoryResenderImpl{
 		domainCache:           domainCache,
 		adminClient:           adminClient,
-		historyReplicationFn:  historyReplicationFn,
+		historyReplicationFns: []nDCHistoryReplicationFn{historyReplicationFn},
 		serializer:            serializer,
 		rereplicationTimeout:  rereplicationTimeout,
-		currentExecutionCheck: currentExecutionCheck,
+		metricsClient:         metricsClient,
 		logger:                logger,
+
+		rootCtx:    rootCtx,
+		rootCancel: rootCancel,
+
+		historyFetcher:         NewAdminHistoryFetcher(adminClient),
+		errorClassifier:        NewDefaultErrorClassifier(),
+		getHistoryRetryPolicy:  createGetHistoryRetryPolicy(),
+		replicationRetryPolicy: createReplicationRetryPolicy(),
+		limiter:                NewResendLimiter(&ResendLimiterOptions{}),
+		circuitBreaker:         newResendCircuitBreaker(nil, nil),
+		inFlightResends:        newInFlightResendRegistry(),
+		sharedResends:          make(map[string]*sharedResend),
+		stats:                  newResendStatsRecorder(),
+		defaultPageSize:        defaultPageSize,
+		timeSource:             clock.NewRealTimeSource(),
+		rand:                   rand.New(rand.NewSource(time.Now().UnixNano())),
+		tracer:                 opentracing.NoopTracer{},
 	}
+	resender.lastKnownDomains = cache.New(&cache.Options{
+		TTL:      lastKnownDomainTTL,
+		MaxCount: lastKnownDomainMaxCount,
+	})
+	resender.skippedRuns = newSkippedRunCache(nil, nil)
+	resender.currentExecutionFixer = newCurrentExecutionFixer(
+		rootCtx,
+		currentExecutionCheck,
+		func(domainID string, workflowID string, runID string) {
+			// the run may be resent once its current execution is fixed
+			resender.skippedRuns.remove(getRunKey(domainID, workflowID, runID))
+		},
+	)
+	for _, opt := range opts {
+		opt(resender)
+	}
+	for i, historyReplicationFn := range resender.historyReplicationFns {
+		if historyReplicationFn == nil {
+			rootCancel()
+			return nil, fmt.Errorf("NDC history resender is created with nil historyReplicationFn at index %v", i)
+		}
+	}
+	return resender, nil
 }
 
-// SendSingleWorkflowHistory sends one run IDs's history events to remote
-func (n *NDCHistoryResenderImpl) SendSingleWorkflowHistory(
+// Close cancels all in-flight resends, resends after Close fail with ErrResenderClosed.
+// it is safe to call Close multiple times and concurrently
+func (n *NDCHistoryResenderImpl) Close() {
+	n.rootCancel()
+}
+
+func (n *NDCHistoryResenderImpl) isClosed() bool {
+	return n.rootCtx.Err() != nil
+}
+
+// withRootContext returns a context which is cancelled when either the given context is done or the resender is closed
+func (n *NDCHistoryResenderImpl) withRootContext(
+	ctx context.Context,
+) (context.Context, context.CancelFunc) {
+
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-n.rootCtx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// Ping checks whether the admin service of the source cluster of the domain is reachable,
+// by fetching the history of a run which does not exist
+func (n *NDCHistoryResenderImpl) Ping(
+	ctx context.Context,
 	domainID string,
-	workflowID string,
-	runID string,
-	startEventID *int64,
-	startEventVersion *int64,
-	endEventID *int64,
-	endEventVersion *int64,
 ) error {
 
-	ctx := context.Background()
-	var cancel context.CancelFunc
-	if n.rereplicationTimeout != nil {
-		resendContextTimeout := n.rereplicationTimeout(domainID)
-		if resendContextTimeout > 0 {
-			ctx, cancel = context.WithTimeout(ctx, resendContextTimeout)
-			defer cancel()
-		}
+	if n.isClosed() {
+		return ErrResenderClosed
 	}
 
-	historyIterator := collection.NewPagingIterator(n.getPaginationFn(
+	domainEntry, err := n.domainCache.GetDomainByID(domainID)
+	if err != nil {
+		return err
+	}
+
+	sourceAdminClient, err := n.getSourceAdminClient(domainEntry)
+	if err != nil {
+		return err
+	}
+	return n.pingAdminClient(
 		ctx,
+		sourceAdminClient,
+		n.getSourceClusterOfDomain(domainEntry),
 		domainID,
-		workflowID,
-		runID,
-		startEventID,
-		startEventVersion,
-		endEventID,
-		endEventVersion))
+		domainEntry.GetInfo().Name,
+		n.getGetHistoryTimeout(domainID),
+	)
+}
 
-	for historyIterator.HasNext() {
-		result, err := historyIterator.Next()
-		if err != nil {
-			n.logger.Error("failed to get history events",
-				tag.WorkflowDomainID(domainID),
-				tag.WorkflowID(workflowID),
-				tag.WorkflowRunID(runID),
-				tag.Error(err))
-			return err
-		}
-		historyBatch := result.(*historyBatch)
-		replicationRequest := n.createReplicationRawRequest(
-			domainID,
-			workflowID,
-			runID,
-			historyBatch.rawEventBatch,
-			historyBatch.versionHistory.GetItems())
+// Validate checks whether the admin services of all the source clusters are reachable, by fetching the history of
+// a run of a domain which does not exist, so no domain is needed. it is meant to be called at startup to surface
+// the TLS or auth misconfiguration early, instead of deep into the first resend
+func (n *NDCHistoryResenderImpl) Validate(
+	ctx context.Context,
+) error {
 
-		err = n.sendReplicationRawRequest(ctx, replicationRequest)
-		switch err.(type) {
-		case nil:
-			// continue to process the events
-			break
-		case *shared.EntityNotExistsError:
-			// Case 1: the workflow pass the retention period
-			// Case 2: the workflow is corrupted
-			if skipTask := n.fixCurrentExecution(
-				domainID,
-				workflowID,
-				runID,
-			); skipTask {
-				return ErrSkipTask
-			}
-			return err
-		default:
-			n.logger.Error("failed to replicate events",
-				tag.WorkflowDomainID(domainID),
-				tag.WorkflowID(workflowID),
-				tag.WorkflowRunID(runID),
-				tag.Error(err))
-			return err
+	if n.isClosed() {
+		return ErrResenderClosed
+	}
+
+	sourceAdminClients := n.sourceAdminClients
+	if sourceAdminClients == nil {
+		sourceAdminClients = map[string]adminClient.Client{n.sourceCluster: n.adminClient}
+	}
+	sourceClusters := make([]string, 0, len(sourceAdminClients))
+	for sourceCluster := range sourceAdminClients {
+		sourceClusters = append(sourceClusters, sourceCluster)
+	}
+	sort.Strings(sourceClusters)
+
+	var validateErr error
+	for _, sourceCluster := range sourceClusters {
+		if err := n.pingAdminClient(
+			ctx,
+			sourceAdminClients[sourceCluster],
+			sourceCluster,
+			"",
+			pingDomainName,
+			n.getGetHistoryTimeout(""),
+		); err != nil {
+			validateErr = multierr.Append(validateErr, err)
 		}
 	}
-	return nil
+	return validateErr
 }
 
-func (n *NDCHistoryResenderImpl) getPaginationFn(
+// pingAdminClient fetches the history of a run which does not exist from the admin service of the source cluster,
+// and returns PingError if the admin service cannot be used. the domain ID is empty if no domain is pinged
+func (n *NDCHistoryResenderImpl) pingAdminClient(
 	ctx context.Context,
+	sourceAdminClient adminClient.Client,
+	sourceCluster string,
 	domainID string,
-	workflowID string,
-	runID string,
-	startEventID *int64,
-	startEventVersion *int64,
-	endEventID *int64,
-	endEventVersion *int64,
-) collection.PaginationFn {
-
-	return func(paginationToken []byte) ([]interface{}, []byte, error) {
+	domainName string,
+	timeout time.Duration,
+) error {
 
-		response, err := n.getHistory(
-			ctx,
-			domainID,
-			workflowID,
-			runID,
-			startEventID,
-			startEventVersion,
-			endEventID,
-			endEventVersion,
-			paginationToken,
-			defaultPageSize,
-		)
-		if err != nil {
-			return nil, nil, err
-		}
+	ctx, cancel := clock.ContextWithTimeout(ctx, n.timeSource, timeout)
+	defer cancel()
+	ctx, err := n.withAdminHeaders(ctx, domainID)
+	if err != nil {
+		return err
+	}
+	_, err = sourceAdminClient.GetWorkflowExecutionRawHistoryV2(ctx, &admin.GetWorkflowExecutionRawHistoryV2Request{
+		Domain: common.StringPtr(domainName),
+		Execution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(pingWorkflowID),
+			RunId:      common.StringPtr(uuid.New()),
+		},
+		MaximumPageSize: common.Int32Ptr(1),
+	}, getAdminCallOptions(ctx)...)
 
-		var paginateItems []interface{}
-		versionHistory := response.GetVersionHistory()
-		for _, history := range response.GetHistoryBatches() {
-			batch := &historyBatch{
-				versionHistory: versionHistory,
-				rawEventBatch:  history,
-			}
-			paginateItems = append(paginateItems, batch)
-		}
-		return paginateItems, response.NextPageToken, nil
+	switch {
+	case err == nil:
+		return nil
+	case isPingResponse(err):
+		// the source cluster is reachable, just the run does not exist
+		return nil
+	case isAccessDeniedError(err):
+		return &PingError{SourceCluster: sourceCluster, Cause: ErrSourceAccessDenied, Err: err}
+	case isTLSHandshakeError(err):
+		// checked before the unreachable one, since the transports report the failed handshakes as unavailable
+		return &PingError{SourceCluster: sourceCluster, Cause: ErrSourceTLSHandshakeFailed, Err: err}
+	case isUnreachableError(err):
+		return &PingError{SourceCluster: sourceCluster, Cause: ErrSourceUnreachable, Err: err}
+	default:
+		return err
 	}
 }
 
-func (n *NDCHistoryResenderImpl) createReplicationRawRequest(
+// SendMultiWorkflowHistory sends history events of multiple runs to remote,
+// runs of the same domain are resent with bounded concurrency, and duplicated runs are only resent once.
+// errors of individual runs do not abort the others and are returned combined.
+// the retries of all the runs are drawn from a single retry budget if configured
+func (n *NDCHistoryResenderImpl) SendMultiWorkflowHistory(
+	ctx context.Context,
+	descriptors []*ResendDescriptor,
+) error {
+
+	if n.isClosed() {
+		return ErrResenderClosed
+	}
+	if n.multiResendRetryBudget != nil {
+		if size := n.multiResendRetryBudget(); size > 0 {
+			budget := n.stats.registerRetryBudget(int64(size))
+			defer n.stats.unregisterRetryBudget(budget)
+			ctx = context.WithValue(ctx, retryBudgetKey, budget)
+		}
+	}
+
+	var errLock sync.Mutex
+	var resendErr error
+	n.forEachDescriptor(descriptors, func(descriptor *ResendDescriptor) {
+		if _, err := n.ResendWorkflowHistory(ctx, descriptor); err != nil {
+			errLock.Lock()
+			resendErr = multierr.Append(resendErr, err)
+			errLock.Unlock()
+		}
+	})
+	return resendErr
+}
+
+// EstimateBulkResend estimates the resends of multiple runs, e.g. to plan the rebuild of a cluster, and aggregates
+// the estimates in total and by domain. runs of the same domain are estimated with bounded concurrency, and duplicated
+// runs are only estimated once. the runs failed to be estimated are reported in the result without aborting the others,
+// and the error of the context is returned along with the partial result if the context is done
+func (n *NDCHistoryResenderImpl) EstimateBulkResend(
+	ctx context.Context,
+	descriptors []*ResendDescriptor,
+) (*BulkEstimateResult, error) {
+
+	if n.isClosed() {
+		return nil, ErrResenderClosed
+	}
+
+	var resultLock sync.Mutex
+	bulkResult := &BulkEstimateResult{
+		Domains: make(map[string]*BulkEstimate),
+	}
+	n.forEachDescriptor(descriptors, func(descriptor *ResendDescriptor) {
+		result, err := n.EstimateResend(ctx, descriptor)
+
+		resultLock.Lock()
+		defer resultLock.Unlock()
+
+		if err != nil {
+			bulkResult.Failures = append(bulkResult.Failures, &BulkEstimateFailure{
+				Descriptor: descriptor,
+				Err:        err,
+			})
+			return
+		}
+		domainEstimate, ok := bulkResult.Domains[descriptor.DomainID]
+		if !ok {
+			domainEstimate = &BulkEstimate{}
+			bulkResult.Domains[descriptor.DomainID] = domainEstimate
+		}
+		domainEstimate.add(result)
+		bulkResult.Total.add(result)
+	})
+	return bulkResult, ctx.Err()
+}
+
+func (e *BulkEstimate) add(
+	result *ResendResult,
+) {
+
+	e.WorkflowCount++
+	e.EventCount += result.EventCount
+	e.BatchCount += int64(result.BatchCount)
+	e.TotalBytes += result.TotalBytes
+}
+
+// forEachDescriptor calls the fn for each of the descriptors and returns once all the calls complete,
+// the runs of the same domain are handled with bounded concurrency, and duplicated runs are only handled once
+func (n *NDCHistoryResenderImpl) forEachDescriptor(
+	descriptors []*ResendDescriptor,
+	fn func(descriptor *ResendDescriptor),
+) {
+
+	type runKey struct {
+		domainID   string
+		workflowID string
+		runID      string
+	}
+
+	seen := make(map[runKey]struct{}, len(descriptors))
+	descriptorsByDomain := make(map[string][]*ResendDescriptor)
+	for _, descriptor := range descriptors {
+		key := runKey{
+			domainID:   descriptor.DomainID,
+			workflowID: descriptor.WorkflowID,
+			runID:      descriptor.RunID,
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		descriptorsByDomain[descriptor.DomainID] = append(descriptorsByDomain[descriptor.DomainID], descriptor)
+	}
+
+	var wg sync.WaitGroup
+	for domainID, domainDescriptors := range descriptorsByDomain {
+		descriptorCh := make(chan *ResendDescriptor, len(domainDescriptors))
+		for _, descriptor := range domainDescriptors {
+			descriptorCh <- descriptor
+		}
+		close(descriptorCh)
+
+		concurrency := n.getResendConcurrency(domainID)
+		if concurrency > len(domainDescriptors) {
+			concurrency = len(domainDescriptors)
+		}
+		wg.Add(concurrency)
+		for i := 0; i < concurrency; i++ {
+			go func() {
+				defer wg.Done()
+
+				for descriptor := range descriptorCh {
+					fn(descriptor)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// SendSingleWorkflowHistory sends one run IDs's history events to remote
+func (n *NDCHistoryResenderImpl) SendSingleWorkflowHistory(
+	ctx context.Context,
 	domainID string,
 	workflowID string,
 	runID string,
-	historyBlob *shared.DataBlob,
-	versionHistoryItems []*shared.VersionHistoryItem,
-) *history.ReplicateEventsV2Request {
+	startEventID *int64,
+	startEventVersion *int64,
+	endEventID *int64,
+	endEventVersion *int64,
+) error {
 
-	request := &history.ReplicateEventsV2Request{
-		DomainUUID: common.StringPtr(domainID),
-		WorkflowExecution: &shared.WorkflowExecution{
-			WorkflowId: common.StringPtr(workflowID),
-			RunId:      common.StringPtr(runID),
-		},
-		Events:              historyBlob,
-		VersionHistoryItems: versionHistoryItems,
-	}
-	return request
+	_, err := n.SendSingleWorkflowHistoryWithResult(
+		ctx,
+		domainID,
+		workflowID,
+		runID,
+		startEventID,
+		startEventVersion,
+		endEventID,
+		endEventVersion,
+	)
+	return err
 }
 
-func (n *NDCHistoryResenderImpl) sendReplicationRawRequest(
+// SendSingleWorkflowHistoryByDomainName sends one run IDs's history events to remote,
+// the domain name is resolved to the domain ID up front, EntityNotExistsError is returned if the domain is unknown
+func (n *NDCHistoryResenderImpl) SendSingleWorkflowHistoryByDomainName(
 	ctx context.Context,
-	request *history.ReplicateEventsV2Request,
+	domainName string,
+	workflowID string,
+	runID string,
+	startEventID *int64,
+	startEventVersion *int64,
+	endEventID *int64,
+	endEventVersion *int64,
 ) error {
 
-	ctx, cancel := context.WithTimeout(ctx, resendContextTimeout)
-	defer cancel()
-	return n.historyReplicationFn(ctx, request)
+	domainEntry, err := n.domainCache.GetDomain(domainName)
+	if err != nil {
+		if _, ok := err.(*shared.EntityNotExistsError); ok {
+			return &shared.EntityNotExistsError{Message: fmt.Sprintf("Domain %v does not exist.", domainName)}
+		}
+		return err
+	}
+	return n.SendSingleWorkflowHistory(
+		ctx,
+		domainEntry.GetInfo().ID,
+		workflowID,
+		runID,
+		startEventID,
+		startEventVersion,
+		endEventID,
+		endEventVersion,
+	)
 }
 
-func (n *NDCHistoryResenderImpl) getHistory(
+// SendSingleWorkflowHistoryWithResult sends one run IDs's history events to remote
+// and reports the work done, the result is returned even if the resend fails halfway
+func (n *NDCHistoryResenderImpl) SendSingleWorkflowHistoryWithResult(
 	ctx context.Context,
 	domainID string,
 	workflowID string,
@@ -266,72 +1060,2742 @@ func (n *NDCHistoryResenderImpl) getHistory(
 	startEventVersion *int64,
 	endEventID *int64,
 	endEventVersion *int64,
-	token []byte,
-	pageSize int32,
-) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+) (*ResendResult, error) {
+
+	return n.ResendWorkflowHistory(ctx, &ResendDescriptor{
+		DomainID:          domainID,
+		WorkflowID:        workflowID,
+		RunID:             runID,
+		StartEventID:      startEventID,
+		StartEventVersion: startEventVersion,
+		EndEventID:        endEventID,
+		EndEventVersion:   endEventVersion,
+	})
+}
+
+// StreamSingleWorkflowHistory sends one run IDs's history events to remote in background,
+// and emits the progress of each sent event batch to the returned progress channel
+func (n *NDCHistoryResenderImpl) StreamSingleWorkflowHistory(
+	ctx context.Context,
+	domainID string,
+	workflowID string,
+	runID string,
+	startEventID *int64,
+	startEventVersion *int64,
+	endEventID *int64,
+	endEventVersion *int64,
+) (<-chan ResendProgress, <-chan error) {
+
+	progressCh := make(chan ResendProgress)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		defer close(progressCh)
+
+		progressCallback := func(batchIndex int, firstEventID int64, lastEventID int64, bytes int) {
+			progress := ResendProgress{
+				BatchIndex:   batchIndex,
+				FirstEventID: firstEventID,
+				LastEventID:  lastEventID,
+				Bytes:        bytes,
+			}
+			select {
+			case progressCh <- progress:
+			case <-ctx.Done():
+				// the resend will fail with the context error
+			}
+		}
+
+		_, err := n.resendWorkflowHistory(ctx, &ResendDescriptor{
+			DomainID:          domainID,
+			WorkflowID:        workflowID,
+			RunID:             runID,
+			StartEventID:      startEventID,
+			StartEventVersion: startEventVersion,
+			EndEventID:        endEventID,
+			EndEventVersion:   endEventVersion,
+		}, false, progressCallback)
+		if err != nil {
+			errCh <- err
+		}
+	}()
+	return progressCh, errCh
+}
+
+// ResendWorkflowHistory sends the history events of the run described by the descriptor to remote,
+// the resend starts from the descriptor's resume token if provided.
+// the result is returned even if the resend fails halfway, so the resend can be resumed later
+func (n *NDCHistoryResenderImpl) ResendWorkflowHistory(
+	ctx context.Context,
+	descriptor *ResendDescriptor,
+) (*ResendResult, error) {
+
+	return n.resendWorkflowHistory(ctx, descriptor, false, nil)
+}
+
+// ReplayPageTokens resends exactly the pages of the run fetched by the recorded resume tokens, in order,
+// to reproduce a replication deterministically for debugging. Each token is a resume token of the run obtained from
+// ResendResult.GetResumeToken, an empty token stands for the first page. All the tokens are validated against the run
+// before anything is sent. The pages are fetched with the range of the descriptor and the page size of the resends,
+// which should be the same as the ones of the recorded replication, since the page tokens are only valid for them.
+// The replay goes through the same validations, limits and bookkeeping as ResendWorkflowHistory,
+// except it is not deduplicated with the other resends, and the result is returned even if the replay fails halfway
+func (n *NDCHistoryResenderImpl) ReplayPageTokens(
+	ctx context.Context,
+	descriptor *ResendDescriptor,
+	resumeTokens [][]byte,
+) (*ResendResult, error) {
+
+	if n.isClosed() {
+		return nil, ErrResenderClosed
+	}
+	if len(resumeTokens) == 0 {
+		return nil, &shared.BadRequestError{Message: "Resume tokens to replay cannot be empty."}
+	}
+	if len(descriptor.ResumeToken) != 0 || descriptor.Cursor != nil {
+		return nil, &shared.BadRequestError{Message: "Resume token and resend cursor cannot be provided to replay page tokens."}
+	}
+	pageTokens := make([][]byte, 0, len(resumeTokens))
+	for _, resumeToken := range resumeTokens {
+		pageToken, err := decodeResumeToken(resumeToken, descriptor.DomainID, descriptor.WorkflowID, descriptor.RunID)
+		if err != nil {
+			return nil, err
+		}
+		pageTokens = append(pageTokens, pageToken)
+	}
+
+	replayDescriptor := *descriptor
+	replayDescriptor.replayPageTokens = pageTokens
+	result, err := n.doResendWorkflowHistory(ctx, &replayDescriptor, false, nil)
+	n.stats.record(result, err)
+	return result, err
+}
+
+// FetchWorkflowHistory returns the history events of the run which would be sent to remote, in order,
+// without sending anything. The batches are passed to the callback instead of being returned if the callback is provided,
+// so the memory is bounded for the large histories.
+// If any event type is provided, each batch is filtered to the events of the event types and the batches without
+// such events are skipped, since the replication requires contiguous events, the filtering is only supported here
+// where nothing is replicated, and the filtered batches are marked as such
+func (n *NDCHistoryResenderImpl) FetchWorkflowHistory(
+	ctx context.Context,
+	domainID string,
+	workflowID string,
+	runID string,
+	startEventID *int64,
+	startEventVersion *int64,
+	endEventID *int64,
+	endEventVersion *int64,
+	eventTypes []shared.EventType,
+	callback FetchBatchCallback,
+) ([]*FetchedEventBatch, error) {
 
-	logger := n.logger.WithTags(tag.WorkflowRunID(runID))
+	if n.isClosed() {
+		return nil, ErrResenderClosed
+	}
+	if err := validateResendRange(&ResendDescriptor{
+		DomainID:          domainID,
+		WorkflowID:        workflowID,
+		RunID:             runID,
+		StartEventID:      startEventID,
+		StartEventVersion: startEventVersion,
+		EndEventID:        endEventID,
+		EndEventVersion:   endEventVersion,
+	}); err != nil {
+		return nil, err
+	}
 
 	domainEntry, err := n.domainCache.GetDomainByID(domainID)
 	if err != nil {
-		logger.Error("error getting domain", tag.Error(err))
 		return nil, err
 	}
-	domainName := domainEntry.GetInfo().Name
-
-	ctx, cancel := context.WithTimeout(ctx, resendContextTimeout)
+	ctx, cancel := n.withRootContext(ctx)
 	defer cancel()
-	response, err := n.adminClient.GetWorkflowExecutionRawHistoryV2(ctx, &admin.GetWorkflowExecutionRawHistoryV2Request{
-		Domain: common.StringPtr(domainName),
-		Execution: &shared.WorkflowExecution{
-			WorkflowId: common.StringPtr(workflowID),
-			RunId:      common.StringPtr(runID),
+
+	historyIterator := collection.NewPagingIteratorWithContext(ctx, n.getPaginationFn(
+		ctx,
+		domainEntry,
+		workflowID,
+		runID,
+		startEventID,
+		startEventVersion,
+		endEventID,
+		endEventVersion,
+		nil,
+		common.EmptyEventID,
+		true,
+	))
+	if n.isReverseFetch(domainID) {
+		// the history cannot be paginated backwards, so the whole history is buffered to be fetched newest-first,
+		// the pages buffered are capped by the pagination
+		historyIterator = newReverseIterator(historyIterator, n.getMaxResendBytes(domainID))
+	}
+	var eventTypeFilter map[shared.EventType]struct{}
+	if len(eventTypes) > 0 {
+		eventTypeFilter = make(map[shared.EventType]struct{}, len(eventTypes))
+		for _, eventType := range eventTypes {
+			eventTypeFilter[eventType] = struct{}{}
+		}
+	}
+	var batches []*FetchedEventBatch
+	for historyIterator.HasNext() {
+		result, err := historyIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		historyBatch := result.(*historyBatch)
+		batch := &FetchedEventBatch{
+			RawEventBatch:  historyBatch.rawEventBatch,
+			VersionHistory: historyBatch.versionHistory,
+		}
+		if eventTypeFilter != nil {
+			filteredEventBatch, err := n.filterEventBatch(historyBatch.rawEventBatch, eventTypeFilter)
+			if err != nil {
+				return nil, err
+			}
+			if filteredEventBatch == nil {
+				continue
+			}
+			batch.RawEventBatch = filteredEventBatch
+			batch.Filtered = true
+		}
+		if callback == nil {
+			batches = append(batches, batch)
+			continue
+		}
+		if err := callback(batch); err != nil {
+			return nil, err
+		}
+	}
+	return batches, nil
+}
+
+// PartitionWorkflowHistory returns the history events of the run partitioned into the core events, i.e. the durable
+// state of the run, and the signal and marker events, without sending anything, e.g. for the migrations deferring
+// the signals and the markers. The batches are passed to the callback instead of being returned if the callback
+// is provided. Since the replication requires contiguous events, the batches split by the partitioning are
+// marked as filtered and must never be replicated to remote
+func (n *NDCHistoryResenderImpl) PartitionWorkflowHistory(
+	ctx context.Context,
+	domainID string,
+	workflowID string,
+	runID string,
+	startEventID *int64,
+	startEventVersion *int64,
+	endEventID *int64,
+	endEventVersion *int64,
+	callback PartitionBatchCallback,
+) ([]*FetchedEventBatch, []*FetchedEventBatch, error) {
+
+	var coreBatches []*FetchedEventBatch
+	var signalMarkerBatches []*FetchedEventBatch
+	if _, err := n.FetchWorkflowHistory(
+		ctx,
+		domainID,
+		workflowID,
+		runID,
+		startEventID,
+		startEventVersion,
+		endEventID,
+		endEventVersion,
+		nil,
+		func(batch *FetchedEventBatch) error {
+			signalMarkerEventBatch, coreEventBatch, err := n.partitionEventBatch(batch.RawEventBatch, signalMarkerEventTypes)
+			if err != nil {
+				return err
+			}
+			split := signalMarkerEventBatch != nil && coreEventBatch != nil
+			var coreBatch, signalMarkerBatch *FetchedEventBatch
+			if coreEventBatch != nil {
+				coreBatch = &FetchedEventBatch{
+					RawEventBatch:  coreEventBatch,
+					VersionHistory: batch.VersionHistory,
+					Filtered:       split,
+				}
+			}
+			if signalMarkerEventBatch != nil {
+				signalMarkerBatch = &FetchedEventBatch{
+					RawEventBatch:  signalMarkerEventBatch,
+					VersionHistory: batch.VersionHistory,
+					Filtered:       split,
+				}
+			}
+			if callback != nil {
+				return callback(coreBatch, signalMarkerBatch)
+			}
+			if coreBatch != nil {
+				coreBatches = append(coreBatches, coreBatch)
+			}
+			if signalMarkerBatch != nil {
+				signalMarkerBatches = append(signalMarkerBatches, signalMarkerBatch)
+			}
+			return nil
 		},
-		StartEventId:      startEventID,
-		StartEventVersion: startEventVersion,
-		EndEventId:        endEventID,
-		EndEventVersion:   endEventVersion,
-		MaximumPageSize:   common.Int32Ptr(pageSize),
-		NextPageToken:     token,
-	})
+	); err != nil {
+		return nil, nil, err
+	}
+	return coreBatches, signalMarkerBatches, nil
+}
+
+// SendWorkflowHistoryWindow sends history events of the run within the radius around the center event to remote,
+// i.e. the events from centerEventID-radius to centerEventID+radius, clamped to the event ID range of the run.
+// the corresponding event versions are resolved from the version histories of the run in remote
+func (n *NDCHistoryResenderImpl) SendWorkflowHistoryWindow(
+	ctx context.Context,
+	domainID string,
+	workflowID string,
+	runID string,
+	centerEventID int64,
+	radius int64,
+) (*ResendResult, error) {
+
+	if n.isClosed() {
+		return nil, ErrResenderClosed
+	}
+	if centerEventID < common.FirstEventID || radius < 0 {
+		return nil, &shared.BadRequestError{Message: fmt.Sprintf(
+			"Invalid event window, center event ID: %v, radius: %v.", centerEventID, radius,
+		)}
+	}
+
+	ctx, cancel := n.withRootContext(ctx)
+	defer cancel()
+	versionHistory, err := n.getVersionHistoryForRange(ctx, domainID, workflowID, runID, nil, nil)
 	if err != nil {
-		logger.Error("error getting history", tag.Error(err))
 		return nil, err
 	}
+	lastItem, err := versionHistory.GetLastItem()
+	if err != nil {
+		return nil, err
+	}
+	lastEventID := lastItem.GetEventID()
+	if centerEventID > lastEventID {
+		return nil, &shared.BadRequestError{Message: fmt.Sprintf(
+			"Invalid event window, center event ID: %v is larger than last event ID: %v.", centerEventID, lastEventID,
+		)}
+	}
 
-	return response, nil
+	descriptor := &ResendDescriptor{
+		DomainID:   domainID,
+		WorkflowID: workflowID,
+		RunID:      runID,
+	}
+	// the start and end events are exclusive, the bound is left empty if the window reaches beyond the run
+	if radius < lastEventID {
+		if startEventID := centerEventID - radius - 1; startEventID >= common.FirstEventID {
+			startEventVersion, err := versionHistory.GetEventVersion(startEventID)
+			if err != nil {
+				return nil, err
+			}
+			descriptor.StartEventID = common.Int64Ptr(startEventID)
+			descriptor.StartEventVersion = common.Int64Ptr(startEventVersion)
+		}
+		if endEventID := centerEventID + radius + 1; endEventID <= lastEventID {
+			endEventVersion, err := versionHistory.GetEventVersion(endEventID)
+			if err != nil {
+				return nil, err
+			}
+			descriptor.EndEventID = common.Int64Ptr(endEventID)
+			descriptor.EndEventVersion = common.Int64Ptr(endEventVersion)
+		}
+	}
+	return n.ResendWorkflowHistory(ctx, descriptor)
+}
+
+// EstimateReplicationLag estimates how far the replication of the run is behind, by the time elapsed
+// since the last event of the run in the source cluster, only the page of the last event is fetched.
+// ErrNoHistoryEvents is returned if the run has no history events
+func (n *NDCHistoryResenderImpl) EstimateReplicationLag(
+	ctx context.Context,
+	domainID string,
+	workflowID string,
+	runID string,
+) (time.Duration, error) {
+
+	if n.isClosed() {
+		return 0, ErrResenderClosed
+	}
+
+	domainEntry, err := n.domainCache.GetDomainByID(domainID)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := n.withRootContext(ctx)
+	defer cancel()
+
+	lastEvent, err := n.getLastEvent(ctx, domainEntry, workflowID, runID)
+	if err != nil {
+		return 0, err
+	}
+	lag := n.timeSource.Now().Sub(time.Unix(0, lastEvent.GetTimestamp()))
+	if lag < 0 {
+		// the clocks of the clusters are not in sync
+		return 0, nil
+	}
+	return lag, nil
 }
 
-func (n *NDCHistoryResenderImpl) fixCurrentExecution(
+// GetSourceVersionHistories returns the version histories of the run held by the source cluster, read from the typed
+// version history carried by the response of the first page of the history events, which is the current branch,
+// as the admin service does not expose the other branches in a typed form
+func (n *NDCHistoryResenderImpl) GetSourceVersionHistories(
+	ctx context.Context,
 	domainID string,
 	workflowID string,
 	runID string,
-) bool {
+) (*shared.VersionHistories, error) {
 
-	if n.currentExecutionCheck == nil {
-		return false
+	if n.isClosed() {
+		return nil, ErrResenderClosed
 	}
-	execution := &checks.CurrentExecution{
-		Execution: checks.Execution{
-			DomainID:   domainID,
-			WorkflowID: workflowID,
-			State:      persistence.WorkflowStateRunning,
-		},
+
+	domainEntry, err := n.domainCache.GetDomainByID(domainID)
+	if err != nil {
+		return nil, err
 	}
-	res := n.currentExecutionCheck.Check(execution)
-	switch res.CheckResultType {
-	case checks.CheckResultTypeCorrupted:
-		n.logger.Error(
-			"Encounter corrupted workflow",
-			tag.WorkflowDomainID(domainID),
-			tag.WorkflowID(workflowID),
-			tag.WorkflowRunID(runID),
-		)
-		n.currentExecutionCheck.Fix(execution)
-		return false
-	case checks.CheckResultTypeFailed:
-		return false
-	default:
-		return true
+	ctx, cancel := n.withRootContext(ctx)
+	defer cancel()
+
+	versionHistory, err := n.getCurrentVersionHistory(ctx, domainEntry, workflowID, runID)
+	if err != nil {
+		return nil, err
+	}
+	return persistence.NewVersionHistories(versionHistory).ToThrift(), nil
+}
+
+// SendWorkflowChain sends history events of the runs in the continuation chain to remote, in the order of the chain,
+// starting from the start run until the run which is not continued as new. The results of the runs sent
+// are returned even if the chain fails halfway
+func (n *NDCHistoryResenderImpl) SendWorkflowChain(
+	ctx context.Context,
+	domainID string,
+	workflowID string,
+	startRunID string,
+) ([]*WorkflowChainResult, error) {
+
+	if n.isClosed() {
+		return nil, ErrResenderClosed
+	}
+
+	domainEntry, err := n.domainCache.GetDomainByID(domainID)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := n.withRootContext(ctx)
+	defer cancel()
+
+	var results []*WorkflowChainResult
+	visitedRunIDs := make(map[string]struct{})
+	for runID := startRunID; runID != ""; {
+		if _, ok := visitedRunIDs[runID]; ok {
+			n.logger.Warn("cycle detected in the continuation chain",
+				tag.WorkflowDomainID(domainID),
+				tag.WorkflowID(workflowID),
+				tag.WorkflowRunID(runID))
+			break
+		}
+		visitedRunIDs[runID] = struct{}{}
+
+		result, err := n.SendSingleWorkflowHistoryWithResult(ctx, domainID, workflowID, runID, nil, nil, nil, nil)
+		results = append(results, &WorkflowChainResult{
+			RunID:  runID,
+			Result: result,
+		})
+		if err != nil {
+			return results, err
+		}
+
+		lastEvent, err := n.getLastEvent(ctx, domainEntry, workflowID, runID)
+		if err != nil {
+			return results, err
+		}
+		// the run is the end of the chain if it is not continued as new
+		runID = lastEvent.GetWorkflowExecutionContinuedAsNewEventAttributes().GetNewExecutionRunId()
+	}
+	return results, nil
+}
+
+// getLastEvent returns the last event of the run in the source cluster, only the page of the last event is fetched,
+// ErrNoHistoryEvents is returned if the run has no history events
+func (n *NDCHistoryResenderImpl) getLastEvent(
+	ctx context.Context,
+	domainEntry *cache.DomainCacheEntry,
+	workflowID string,
+	runID string,
+) (*shared.HistoryEvent, error) {
+
+	// the first page tells the ID of the last event by the version history
+	response, err := n.getHistory(ctx, domainEntry, workflowID, runID, nil, nil, nil, nil, nil, 1)
+	if err != nil {
+		return nil, err
+	}
+	versionHistory := response.GetVersionHistory()
+	items := versionHistory.GetItems()
+	if len(response.NextPageToken) != 0 && len(items) != 0 {
+		lastEventID := items[len(items)-1].GetEventID()
+		if lastEventID > common.FirstEventID {
+			// the start event is exclusive, so the page only contains the batch of the last event
+			tailStartEventID := lastEventID - 1
+			tailStartEventVersion, err := persistence.NewVersionHistoryFromThrift(versionHistory).GetEventVersion(tailStartEventID)
+			if err != nil {
+				return nil, err
+			}
+			response, err = n.getHistory(
+				ctx,
+				domainEntry,
+				workflowID,
+				runID,
+				common.Int64Ptr(tailStartEventID),
+				common.Int64Ptr(tailStartEventVersion),
+				nil,
+				nil,
+				nil,
+				1,
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	historyBatches := response.GetHistoryBatches()
+	if len(historyBatches) == 0 {
+		return nil, ErrNoHistoryEvents
+	}
+	events, err := n.deserializeEventBatch(historyBatches[len(historyBatches)-1])
+	if err != nil {
+		return nil, &shared.InternalServiceError{Message: fmt.Sprintf("Failed to deserialize history events: %v.", err)}
+	}
+	if len(events) == 0 {
+		return nil, ErrNoHistoryEvents
+	}
+	return events[len(events)-1], nil
+}
+
+// EstimateResend paginates through the history events of the run described by the descriptor
+// and reports what would be sent, without actually sending anything to remote.
+// the rereplication timeout is honored, and EntityNotExistsError is returned if the run does not exist in remote
+func (n *NDCHistoryResenderImpl) EstimateResend(
+	ctx context.Context,
+	descriptor *ResendDescriptor,
+) (*ResendResult, error) {
+
+	return n.resendWorkflowHistory(ctx, descriptor, true, nil)
+}
+
+// SendMissingHistory sends the history events of the run after the last event already replicated to the target,
+// i.e. starting from targetLastEventID+1 instead of the first event. SkipTaskError with SkipTaskReasonUpToDate is returned
+// if the source has no event after the target last event. BadRequestError is returned if the target last event is not
+// in the version history of the source. The whole history is sent if targetLastEventID is common.EmptyEventID
+func (n *NDCHistoryResenderImpl) SendMissingHistory(
+	ctx context.Context,
+	domainID string,
+	workflowID string,
+	runID string,
+	targetLastEventID int64,
+	targetLastEventVersion int64,
+) (*ResendResult, error) {
+
+	descriptor := &ResendDescriptor{
+		DomainID:   domainID,
+		WorkflowID: workflowID,
+		RunID:      runID,
+	}
+	if targetLastEventID >= common.FirstEventID {
+		// the start event is exclusive
+		descriptor.StartEventID = common.Int64Ptr(targetLastEventID)
+		descriptor.StartEventVersion = common.Int64Ptr(targetLastEventVersion)
+		descriptor.missingOnly = true
+	}
+	return n.ResendWorkflowHistory(ctx, descriptor)
+}
+
+// SendWorkflowHistoryByRange sends history events of the run within the event ID range to remote,
+// the corresponding event versions are resolved from the current version history of the run in remote
+func (n *NDCHistoryResenderImpl) SendWorkflowHistoryByRange(
+	ctx context.Context,
+	domainID string,
+	workflowID string,
+	runID string,
+	startEventID *int64,
+	endEventID *int64,
+) (*ResendResult, error) {
+
+	if n.isClosed() {
+		return nil, ErrResenderClosed
+	}
+	if startEventID == nil && endEventID == nil {
+		return nil, &shared.BadRequestError{Message: "Start event ID and end event ID cannot be both empty."}
+	}
+
+	ctx, cancel := n.withRootContext(ctx)
+	defer cancel()
+	versionHistory, err := n.getVersionHistoryForRange(ctx, domainID, workflowID, runID, startEventID, endEventID)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptor := &ResendDescriptor{
+		DomainID:     domainID,
+		WorkflowID:   workflowID,
+		RunID:        runID,
+		StartEventID: startEventID,
+		EndEventID:   endEventID,
+	}
+	if startEventID != nil {
+		startEventVersion, err := versionHistory.GetEventVersion(*startEventID)
+		if err != nil {
+			return nil, err
+		}
+		descriptor.StartEventVersion = common.Int64Ptr(startEventVersion)
+	}
+	if endEventID != nil {
+		endEventVersion, err := versionHistory.GetEventVersion(*endEventID)
+		if err != nil {
+			return nil, err
+		}
+		descriptor.EndEventVersion = common.Int64Ptr(endEventVersion)
+	}
+	return n.ResendWorkflowHistory(ctx, descriptor)
+}
+
+func (n *NDCHistoryResenderImpl) resendWorkflowHistory(
+	ctx context.Context,
+	descriptor *ResendDescriptor,
+	dryRun bool,
+	progressCallback ResendBatchCallback,
+) (*ResendResult, error) {
+
+	if dryRun || progressCallback != nil || !n.isDedupable(ctx) {
+		result, err := n.doResendWorkflowHistory(ctx, descriptor, dryRun, progressCallback)
+		if !dryRun {
+			n.stats.record(result, err)
+		}
+		return result, err
+	}
+
+	// concurrent identical resends share a single pagination and replication,
+	// the shared resend runs with a context detached from the callers, so a caller giving up does not fail the others,
+	// it is bounded by the configured resend timeout, or the max timeout override if none is configured,
+	// and canceled once the last caller stops waiting for it or the resender is closed
+	resend, shared := n.joinSharedResend(ctx, descriptor)
+	if shared {
+		n.metricsClient.IncCounter(metrics.NDCHistoryResenderScope, metrics.HistoryResendDedupedCounter)
+	}
+
+	select {
+	case <-ctx.Done():
+		n.leaveSharedResend(resend)
+		return nil, ctx.Err()
+	case <-resend.doneCh:
+	}
+	if resend.result == nil {
+		return nil, resend.err
+	}
+	// each caller gets its own copy of the shared result
+	resultCopy := *resend.result
+	return &resultCopy, resend.err
+}
+
+// Stats returns a snapshot of the cumulative counters of the resends since the resender is created,
+// along with the retries left in the budgets of the in-flight multi-run resends
+func (n *NDCHistoryResenderImpl) Stats() ResendStats {
+	return n.stats.snapshot()
+}
+
+// CancelResend cancels the in-flight resends of the run, which return context.Canceled,
+// it returns whether any in-flight resend of the run is found
+func (n *NDCHistoryResenderImpl) CancelResend(
+	domainID string,
+	workflowID string,
+	runID string,
+) bool {
+
+	return n.inFlightResends.cancel(domainID, workflowID, runID)
+}
+
+// InFlight returns a snapshot of the in-flight resends, ordered by their start time, e.g. to debug a stuck replication.
+// the resends completed are removed from the registry under the same lock, so each of them is either fully included or not
+func (n *NDCHistoryResenderImpl) InFlight() []*InFlightResendInfo {
+	return n.inFlightResends.snapshot()
+}
+
+// doResendWorkflowHistory resends the history events of the run, each stage of the resend is implemented
+// by the helpers of the feature it belongs to
+func (n *NDCHistoryResenderImpl) doResendWorkflowHistory(
+	ctx context.Context,
+	descriptor *ResendDescriptor,
+	dryRun bool,
+	progressCallback ResendBatchCallback,
+) (_ *ResendResult, retError error) {
+
+	logger := n.logger
+	defer func() {
+		// one malformed run should not crash the goroutine of the caller
+		if rec := recover(); rec != nil {
+			retError = n.handleResendPanic(logger, descriptor, rec)
+		}
+	}()
+
+	if n.isClosed() {
+		return nil, ErrResenderClosed
+	}
+	ctx, logger = n.withResendLogger(ctx)
+	if !dryRun {
+		if skippedResult, skipTaskErr := n.getSkippedRunResult(descriptor); skipTaskErr != nil {
+			return skippedResult, skipTaskErr
+		}
+	}
+	span, ctx := n.startResendSpan(ctx, descriptor, dryRun)
+	defer func() { finishSpan(span, retError) }()
+
+	ctx, run, err := n.newResendRun(ctx, logger, descriptor, dryRun, progressCallback)
+	if err != nil {
+		return nil, err
+	}
+	tagResendSpan(ctx, span)
+	if !dryRun {
+		// estimation should not be blocked by or trip the circuit breaker
+		if err := n.checkResendCircuit(run); err != nil {
+			return nil, err
+		}
+		defer func() { n.recordResendCircuitResult(run, retError) }()
+	}
+	ctx, stopMetrics := n.startResendMetrics(ctx, run)
+	defer stopMetrics()
+
+	ctx, rootCancel := n.withRootContext(ctx)
+	defer rootCancel()
+	ctx, inFlight, unregister := n.inFlightResends.register(ctx, descriptor, n.timeSource.Now())
+	defer func() {
+		if cancelled := unregister(); cancelled && retError != nil {
+			// the error is caused by the cancellation, which may be wrapped by the clients
+			retError = context.Canceled
+		}
+	}()
+	run.inFlight = inFlight
+	releaseSlot, err := n.acquireResendSlot(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseSlot()
+	if resendContextTimeout := n.getResendTimeout(descriptor, run.domainEntry); resendContextTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = clock.ContextWithTimeout(ctx, n.timeSource, resendContextTimeout)
+		defer cancel()
+	}
+
+	historyIterator := n.getResendHistoryIterator(ctx, run)
+	for historyIterator.HasNext() {
+		result, err := historyIterator.Next()
+		if err != nil {
+			return run.result, n.handleGetHistoryError(ctx, run, err)
+		}
+		if err := n.resendHistoryBatch(ctx, run, result.(*historyBatch)); err != nil {
+			return run.result, err
+		}
+	}
+	return run.result, n.completeResend(ctx, run)
+}
+
+// handleResendPanic logs and meters the panic of the resend, and returns the error the resend fails with
+func (n *NDCHistoryResenderImpl) handleResendPanic(
+	logger log.Logger,
+	descriptor *ResendDescriptor,
+	rec interface{},
+) error {
+
+	n.metricsClient.IncCounter(metrics.NDCHistoryResenderScope, metrics.HistoryResendPanicCounter)
+	logger.Error("history resend panics",
+		tag.WorkflowDomainID(descriptor.DomainID),
+		tag.WorkflowID(descriptor.WorkflowID),
+		tag.WorkflowRunID(descriptor.RunID),
+		tag.Value(rec),
+		tag.SysStackTrace(string(debug.Stack())))
+	return &ResendPanicError{Value: rec}
+}
+
+// newResendRun validates the resend and resolves the domains of the run, the returned context carries
+// the priority of the resend and whether its expected errors are logged quietly
+func (n *NDCHistoryResenderImpl) newResendRun(
+	ctx context.Context,
+	logger log.Logger,
+	descriptor *ResendDescriptor,
+	dryRun bool,
+	progressCallback ResendBatchCallback,
+) (context.Context, *resendRun, error) {
+
+	domainID := descriptor.DomainID
+	workflowID := descriptor.WorkflowID
+	runID := descriptor.RunID
+
+	if err := validateResendRange(descriptor); err != nil {
+		return nil, nil, err
+	}
+	if err := n.validateTargetShardID(ctx); err != nil {
+		return nil, nil, err
+	}
+	initialPageToken, sentEventID, err := getResendStartPosition(descriptor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the domain is resolved only once, so the resend is not affected if the domain is changed halfway
+	domainEntry, err := n.getDomainEntry(logger, domainID, len(initialPageToken) != 0)
+	if err != nil {
+		logger.Error("error getting domain",
+			tag.WorkflowDomainID(domainID),
+			tag.WorkflowID(workflowID),
+			tag.WorkflowRunID(runID),
+			tag.Error(err))
+		return nil, nil, err
+	}
+	if !domainEntry.IsGlobalDomain() {
+		logger.Warn("resend of history events of a local domain",
+			tag.WorkflowDomainID(domainID),
+			tag.WorkflowDomainName(domainEntry.GetInfo().Name),
+			tag.WorkflowID(workflowID),
+			tag.WorkflowRunID(runID))
+		return nil, nil, ErrDomainNotReplicated
+	}
+	// the target domain is resolved once as well, the config of the resend is still read by the source domain ID
+	targetDomainID, err := n.getTargetDomainID(domainID, domainEntry)
+	if err != nil {
+		logger.Error("error getting target domain",
+			tag.WorkflowDomainID(domainID),
+			tag.WorkflowID(workflowID),
+			tag.WorkflowRunID(runID),
+			tag.Error(err))
+		return nil, nil, err
+	}
+	ctx = n.withResendPriority(ctx, domainEntry.GetInfo().Name)
+	ctx = n.withQuietExpectedErrors(ctx, domainID)
+
+	scope := n.metricsClient.Scope(metrics.NDCHistoryResenderScope, metrics.DomainTag(domainEntry.GetInfo().Name))
+	if dryRun {
+		// estimation should not be counted as resend
+		scope = metrics.NoopScope(metrics.Common)
+	}
+	return ctx, &resendRun{
+		descriptor:       descriptor,
+		dryRun:           dryRun,
+		progressCallback: progressCallback,
+		logger: logger.WithTags(
+			tag.WorkflowDomainID(domainID),
+			tag.WorkflowID(workflowID),
+			tag.WorkflowRunID(runID),
+			tag.SourceCluster(n.getSourceClusterOfDomain(domainEntry)),
+		),
+		scope:                scope,
+		domainEntry:          domainEntry,
+		targetDomainID:       targetDomainID,
+		initialPageToken:     initialPageToken,
+		sentEventID:          sentEventID,
+		lastForwardedEventID: common.EmptyEventID,
+		resumable:            true,
+		result:               newResendResult(descriptor, initialPageToken),
+	}, nil
+}
+
+// handleGetHistoryError logs the error of getting the history events to resend,
+// and returns the error the resend fails with
+func (n *NDCHistoryResenderImpl) handleGetHistoryError(
+	ctx context.Context,
+	run *resendRun,
+	err error,
+) error {
+
+	getErrorLogFn(ctx, run.logger, err, run.logger.Error)("failed to get history events",
+		tag.Counter(run.result.BatchCount),
+		tag.Error(err))
+	if tokenErr, ok := err.(*InvalidPageTokenError); ok {
+		tokenErr.MadeProgress = run.result.BatchCount > 0
+	}
+	if _, ok := err.(*shared.EntityNotExistsError); ok {
+		// the run does not exist in the source cluster, there is nothing to resend,
+		// so the current execution in the target is not checked
+		run.scope.IncCounter(metrics.HistoryResendEntityNotExistsCounter)
+		if run.result.BatchCount > 0 {
+			return &ResendPartialError{
+				Err:         err,
+				BatchCount:  run.result.BatchCount,
+				TotalBytes:  run.result.TotalBytes,
+				LastEventID: run.result.LastEventID,
+			}
+		}
+	}
+	return err
+}
+
+// resendHistoryBatch verifies the batch of the history events and sends it to the target cluster,
+// the batch is only verified and counted by the dry run
+func (n *NDCHistoryResenderImpl) resendHistoryBatch(
+	ctx context.Context,
+	run *resendRun,
+	batch *historyBatch,
+) error {
+
+	if err := verifyMissingHistoryBatch(run.descriptor, batch); err != nil {
+		return err
+	}
+	firstEventID, lastEventID := n.getBatchEventIDRange(run.logger, batch.rawEventBatch)
+	if lastEventID != common.EmptyEventID && lastEventID <= run.sentEventID {
+		// the batch is already sent by the resend the cursor is taken from
+		run.advancePageToken(batch)
+		return nil
+	}
+	if skipped, err := n.verifyHistoryBatch(run, batch, firstEventID); err != nil || skipped {
+		return err
+	}
+	if !run.dryRun {
+		if err := n.sendHistoryBatch(ctx, run, batch, firstEventID, lastEventID); err != nil {
+			return err
+		}
+	}
+	n.recordSentBatch(ctx, run, batch, firstEventID, lastEventID)
+	return nil
+}
+
+// sendHistoryBatch sends the batch of the history events to the target cluster
+func (n *NDCHistoryResenderImpl) sendHistoryBatch(
+	ctx context.Context,
+	run *resendRun,
+	batch *historyBatch,
+	firstEventID int64,
+	lastEventID int64,
+) error {
+
+	domainID := run.descriptor.DomainID
+	if maxBytes := n.getMaxResendBytes(domainID); maxBytes > 0 {
+		if bytesReached := run.result.TotalBytes + int64(len(batch.rawEventBatch.GetData())); bytesReached > maxBytes {
+			run.logger.Error("history events to resend are too large", tag.WorkflowHistorySize(int(bytesReached)))
+			return &ResendTooLargeError{
+				BytesReached: bytesReached,
+				MaxBytes:     maxBytes,
+			}
+		}
+	}
+	if run.result.BatchCount > 0 {
+		if err := n.waitBatchDelay(ctx, domainID); err != nil {
+			return err
+		}
+	}
+
+	replicationRequest, err := n.createReplicationRawRequest(
+		domainID,
+		run.targetDomainID,
+		run.descriptor.WorkflowID,
+		run.descriptor.RunID,
+		run.result.BatchCount,
+		batch.rawEventBatch,
+		batch.versionHistory.GetItems())
+	if err != nil {
+		return err
+	}
+	if err := n.auditBatch(
+		ctx,
+		run.scope,
+		domainID,
+		run.descriptor.WorkflowID,
+		run.descriptor.RunID,
+		batch.rawEventBatch,
+	); err != nil {
+		return err
+	}
+
+	sendSpan, sendCtx := n.startReplicateEventsSpan(ctx, run.descriptor, firstEventID, lastEventID)
+	sendErr := n.sendReplicationRawRequest(sendCtx, domainID, replicationRequest)
+	finishSpan(sendSpan, sendErr)
+	switch {
+	case sendErr == nil:
+		return nil
+	case n.errorClassifier.IsSkippable(sendErr):
+		return n.handleRunNotExistsInTarget(ctx, run, sendErr)
+	default:
+		n.logResendError(ctx, run.scope, run.logger, "failed to replicate events", sendErr)
+		return sendErr
+	}
+}
+
+// recordSentBatch records the batch of the history events sent in the result of the resend,
+// and reports the progress of the resend
+func (n *NDCHistoryResenderImpl) recordSentBatch(
+	ctx context.Context,
+	run *resendRun,
+	batch *historyBatch,
+	firstEventID int64,
+	lastEventID int64,
+) {
+
+	batchSize := int64(len(batch.rawEventBatch.GetData()))
+	run.scope.AddCounter(metrics.HistoryResendBytes, batchSize)
+	if run.result.BatchCount == 0 {
+		run.result.FirstEventID = firstEventID
+	}
+	run.result.LastEventID = lastEventID
+	run.advanceCursor(batch, lastEventID)
+	atomic.StoreInt64(&run.inFlight.lastEventID, lastEventID)
+	run.lastForwardedEventID = lastEventID
+	if firstEventID != common.EmptyEventID {
+		// the IDs of the events in a batch are consecutive
+		run.result.EventCount += lastEventID - firstEventID + 1
+	}
+	batchIndex := run.result.BatchCount
+	run.result.BatchCount++
+	run.result.TotalBytes += batchSize
+
+	if !run.dryRun && n.batchCallback != nil {
+		n.batchCallback(batchIndex, firstEventID, lastEventID, int(batchSize))
+	}
+	if run.progressCallback != nil {
+		run.progressCallback(batchIndex, firstEventID, lastEventID, int(batchSize))
+	}
+	reportProgress(ctx, run.descriptor, batch.versionHistory, lastEventID)
+}
+
+// completeResend completes the resend once all the batches of the history events are sent
+func (n *NDCHistoryResenderImpl) completeResend(
+	ctx context.Context,
+	run *resendRun,
+) error {
+
+	descriptor := run.descriptor
+	if descriptor.missingOnly && run.result.BatchCount == 0 {
+		// the target already has the last event of the source, the skip is not remembered
+		// as the run may still be open and have more events later
+		run.scope.IncCounter(metrics.HistoryResendSkipTaskCounter)
+		run.result.Skipped = true
+		return &SkipTaskError{
+			Reason:     SkipTaskReasonUpToDate,
+			DomainID:   descriptor.DomainID,
+			WorkflowID: descriptor.WorkflowID,
+			RunID:      descriptor.RunID,
+		}
+	}
+	run.scope.IncCounter(metrics.HistoryResendSuccess)
+	if reporter, ok := ctx.Value(progressReporterKey).(ProgressReporter); ok {
+		reporter(100)
+	}
+	if !run.dryRun {
+		run.logger.Info("history resend completed",
+			tag.Counter(run.result.BatchCount),
+			tag.WorkflowEventCount(int(run.result.EventCount)),
+			tag.WorkflowHistorySizeBytes(int(run.result.TotalBytes)))
+	}
+	return nil
+}
+
+// logResendError logs the error of the resend, the timeout of the resend, which likely indicates a slow cluster,
+// and the cancellation of the resend, by the operator or the shutdown, are logged and metered separately
+func (n *NDCHistoryResenderImpl) logResendError(
+	ctx context.Context,
+	scope metrics.Scope,
+	logger log.Logger,
+	msg string,
+	err error,
+) {
+
+	switch getContextError(ctx, err) {
+	case context.DeadlineExceeded:
+		scope.IncCounter(metrics.HistoryResendDeadlineExceededCounter)
+		logger.Error(msg+", deadline exceeded", tag.Error(err))
+	case context.Canceled:
+		scope.IncCounter(metrics.HistoryResendCancelledCounter)
+		logger.Warn(msg+", cancelled", tag.Error(err))
+	default:
+		getErrorLogFn(ctx, logger, err, logger.Error)(msg, tag.Error(err))
+	}
+}
+
+// withQuietExpectedErrors returns a copy of the context carrying whether the expected errors of the resend
+// are logged at Debug level, so the config is read once for each resend
+func (n *NDCHistoryResenderImpl) withQuietExpectedErrors(
+	ctx context.Context,
+	domainID string,
+) context.Context {
+
+	if n.quietExpectedErrors == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, quietExpectedErrorsKey, n.quietExpectedErrors(domainID))
+}
+
+// getErrorLogFn returns logger.Debug if the error is expected, i.e. the run is missing or the task is skipped,
+// and the resend carried by the context is quiet, otherwise the given log func
+func getErrorLogFn(
+	ctx context.Context,
+	logger log.Logger,
+	err error,
+	logFn func(msg string, tags ...tag.Tag),
+) func(msg string, tags ...tag.Tag) {
+
+	if quiet, _ := ctx.Value(quietExpectedErrorsKey).(bool); !quiet {
+		return logFn
+	}
+	var entityNotExistsErr *shared.EntityNotExistsError
+	if errors.As(err, &entityNotExistsErr) || errors.Is(err, ErrSkipTask) {
+		return logger.Debug
+	}
+	return logFn
+}
+
+// getContextError returns context.DeadlineExceeded or context.Canceled if the error is caused by the context,
+// the error of the context takes precedence, since the clients may wrap the error of the context, or nil otherwise
+func getContextError(
+	ctx context.Context,
+	err error,
+) error {
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	switch {
+	case common.IsContextTimeoutError(err):
+		return context.DeadlineExceeded
+	case err == context.Canceled || yarpcerrors.IsCancelled(err):
+		return context.Canceled
+	default:
+		return nil
+	}
+}
+
+func (n *NDCHistoryResenderImpl) getPaginationFn(
+	ctx context.Context,
+	domainEntry *cache.DomainCacheEntry,
+	workflowID string,
+	runID string,
+	startEventID *int64,
+	startEventVersion *int64,
+	endEventID *int64,
+	endEventVersion *int64,
+	initialPageToken []byte,
+	targetLastEventID int64,
+	trimToEndEvent bool,
+) collection.PaginationFn {
+
+	domainID := domainEntry.GetInfo().ID
+	// the admin client of the source cluster is picked once, not per page
+	historyFetcher, historyFetcherErr := n.getHistoryFetcher(domainEntry)
+	firstPage := true
+	var pageCount int64
+	pageSizer := &adaptivePageSizer{}
+	return func(paginationToken []byte) ([]interface{}, []byte, error) {
+
+		if historyFetcherErr != nil {
+			return nil, nil, historyFetcherErr
+		}
+		isFirstPage := firstPage
+		if firstPage {
+			// the paging iterator always starts with an empty token
+			paginationToken = initialPageToken
+			firstPage = false
+		}
+
+		if maxPages := n.getMaxPages(domainID); maxPages > 0 {
+			// the pages fetched by the concurrent segments of a resend are counted together
+			if fetchedPages := addFetchedPage(ctx, &pageCount) - 1; fetchedPages >= maxPages {
+				return nil, nil, &TooManyPagesError{
+					PageCount:     fetchedPages,
+					MaxPages:      maxPages,
+					LastPageToken: paginationToken,
+				}
+			}
+		}
+
+		span, ctx := n.startSpan(ctx, getHistorySpanName)
+		span.SetTag(spanTagDomainID, domainID)
+		span.SetTag(spanTagWorkflowID, workflowID)
+		span.SetTag(spanTagRunID, runID)
+		if startEventID != nil {
+			span.SetTag(spanTagStartEventID, *startEventID)
+		}
+		if endEventID != nil {
+			span.SetTag(spanTagEndEventID, *endEventID)
+		}
+		response, err := n.getHistoryFrom(
+			ctx,
+			historyFetcher,
+			domainEntry,
+			workflowID,
+			runID,
+			startEventID,
+			startEventVersion,
+			endEventID,
+			endEventVersion,
+			paginationToken,
+			n.getAdaptivePageSize(domainID, pageSizer),
+		)
+		if _, ok := err.(*shared.EntityNotExistsError); ok && isFirstPage && len(initialPageToken) == 0 {
+			// nothing is sent yet, so the whole history can be read from the archival storage instead
+			if archivalFetcher, archivalResponse := n.getArchivedHistory(
+				ctx,
+				domainEntry,
+				workflowID,
+				runID,
+				startEventID,
+				startEventVersion,
+				endEventID,
+				endEventVersion,
+			); archivalResponse != nil {
+				historyFetcher = archivalFetcher
+				response, err = archivalResponse, nil
+			}
+		}
+		finishSpan(span, err)
+		if _, ok := err.(*shared.BadRequestError); ok && len(paginationToken) != 0 {
+			// the other parameters of the request are the same for all the pages,
+			// so the token is taken as rejected, either corrupted or incompatible with the source
+			err = &InvalidPageTokenError{
+				Resumed: isFirstPage,
+				Err:     err,
+			}
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		addFetchedBytes(ctx, response.GetHistoryBatches())
+		pageSizer.observe(response.GetHistoryBatches())
+
+		rawHistoryBatches, err := n.transcodeEventBatches(domainEntry.GetInfo().Name, response.GetHistoryBatches())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var paginateItems []interface{}
+		versionHistory := response.GetVersionHistory()
+		historyBatches := n.skipReplicatedBatches(n.getLogger(ctx), rawHistoryBatches, targetLastEventID)
+		if trimToEndEvent && endEventID != nil && n.trimBatchToEndEvent != nil && n.trimBatchToEndEvent(domainID) {
+			historyBatches, err = n.trimBatchesToEndEvent(n.getLogger(ctx), historyBatches, *endEventID)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		if len(historyBatches) > 0 && len(versionHistory.GetItems()) == 0 {
+			if n.skipEmptyVersionHistory == nil || !n.skipEmptyVersionHistory(domainID) {
+				return nil, nil, &shared.InternalServiceError{Message: fmt.Sprintf(
+					"Version history of events is empty, domain ID: %v, workflow ID: %v, run ID: %v.",
+					domainID, workflowID, runID,
+				)}
+			}
+			n.getLogger(ctx).Warn("skipping history events with empty version history",
+				tag.WorkflowDomainID(domainID),
+				tag.WorkflowID(workflowID),
+				tag.WorkflowRunID(runID),
+				tag.Counter(len(historyBatches)))
+			historyBatches = nil
+		}
+		// some source servers send an empty but non-nil token for the last page,
+		// which would otherwise be taken as the token of the next page
+		nextPageToken := response.NextPageToken
+		if len(nextPageToken) == 0 {
+			nextPageToken = nil
+		}
+		for i, history := range historyBatches {
+			batch := &historyBatch{
+				versionHistory: versionHistory,
+				rawEventBatch:  history,
+			}
+			if i == len(historyBatches)-1 {
+				batch.nextPageToken = nextPageToken
+				batch.lastInPage = true
+			}
+			paginateItems = append(paginateItems, batch)
+		}
+		return paginateItems, nextPageToken, nil
+	}
+}
+
+// getResendHistoryIterator returns the iterator of the batches of the history events to resend, the segments of
+// the history are fetched concurrently if configured, unless the resend starts from a page token
+func (n *NDCHistoryResenderImpl) getResendHistoryIterator(
+	ctx context.Context,
+	run *resendRun,
+) collection.Iterator {
+
+	descriptor := run.descriptor
+	domainID := descriptor.DomainID
+	targetLastEventID := n.getTargetLastEventID(ctx, run.targetDomainID, descriptor.WorkflowID, descriptor.RunID)
+	if fetchConcurrency := n.getResendFetchConcurrency(domainID); fetchConcurrency > 1 && len(run.initialPageToken) == 0 &&
+		len(descriptor.replayPageTokens) == 0 {
+		if paginationFnProviders := n.getSegmentPaginationFnProviders(
+			ctx,
+			run.domainEntry,
+			descriptor,
+			targetLastEventID,
+		); len(paginationFnProviders) > 1 {
+			// the page token of a segment cannot be used to resume the resend
+			run.resumable = false
+			return collection.NewConcurrentPagingIterator(ctx, paginationFnProviders, fetchConcurrency)
+		}
+	}
+
+	paginationFn := n.getPaginationFn(
+		ctx,
+		run.domainEntry,
+		descriptor.WorkflowID,
+		descriptor.RunID,
+		descriptor.StartEventID,
+		descriptor.StartEventVersion,
+		descriptor.EndEventID,
+		descriptor.EndEventVersion,
+		run.initialPageToken,
+		targetLastEventID,
+		true)
+	if len(descriptor.replayPageTokens) != 0 {
+		paginationFn = newPageTokenSequencePaginationFn(paginationFn, descriptor.replayPageTokens)
+	}
+	if pageBufferSize := n.getResendPageBufferSize(domainID); pageBufferSize > 1 {
+		// the page being sent is also held in memory
+		return collection.NewBufferedPagingIterator(ctx, paginationFn, pageBufferSize-1)
+	}
+	return collection.NewPagingIteratorWithContext(ctx, paginationFn)
+}
+
+// getSegmentPaginationFnProviders splits the events to resend into segments of one page size of events,
+// so the segments can be fetched concurrently, the events of a batch belong to the segment of its first event.
+// nil is returned if the events cannot be split, so the events are fetched page by page where the errors are handled
+func (n *NDCHistoryResenderImpl) getSegmentPaginationFnProviders(
+	ctx context.Context,
+	domainEntry *cache.DomainCacheEntry,
+	descriptor *ResendDescriptor,
+	targetLastEventID int64,
+) []collection.PaginationFnProvider {
+
+	domainID := domainEntry.GetInfo().ID
+	workflowID := descriptor.WorkflowID
+	runID := descriptor.RunID
+	versionHistory, err := n.getVersionHistoryForRange(
+		ctx,
+		domainID,
+		workflowID,
+		runID,
+		descriptor.StartEventID,
+		descriptor.EndEventID,
+	)
+	if err != nil {
+		return nil
+	}
+	lastItem, err := versionHistory.GetLastItem()
+	if err != nil {
+		return nil
+	}
+
+	// the start and end events are exclusive, and must be on the version history the segments are split by
+	startEventID := common.FirstEventID - 1
+	if descriptor.StartEventID != nil {
+		startEventID = *descriptor.StartEventID
+		if version, err := versionHistory.GetEventVersion(startEventID); err != nil || version != *descriptor.StartEventVersion {
+			return nil
+		}
+	}
+	endEventID := lastItem.GetEventID() + 1
+	if descriptor.EndEventID != nil {
+		endEventID = *descriptor.EndEventID
+		if version, err := versionHistory.GetEventVersion(endEventID); err != nil || version != *descriptor.EndEventVersion {
+			return nil
+		}
+	}
+
+	// all the segments are replicated with the same version history
+	thriftVersionHistory := versionHistory.ToThrift()
+	segmentSize := int64(n.getResendPageSize(domainID))
+	var paginationFnProviders []collection.PaginationFnProvider
+	for firstEventID := startEventID + 1; firstEventID < endEventID; firstEventID += segmentSize {
+		segmentStartEventID, segmentStartEventVersion := descriptor.StartEventID, descriptor.StartEventVersion
+		if firstEventID > startEventID+1 {
+			version, err := versionHistory.GetEventVersion(firstEventID - 1)
+			if err != nil {
+				return nil
+			}
+			segmentStartEventID, segmentStartEventVersion = common.Int64Ptr(firstEventID-1), common.Int64Ptr(version)
+		}
+		segmentEndEventID, segmentEndEventVersion := descriptor.EndEventID, descriptor.EndEventVersion
+		isLastSegment := firstEventID+segmentSize >= endEventID
+		if !isLastSegment {
+			version, err := versionHistory.GetEventVersion(firstEventID + segmentSize)
+			if err != nil {
+				return nil
+			}
+			segmentEndEventID, segmentEndEventVersion = common.Int64Ptr(firstEventID+segmentSize), common.Int64Ptr(version)
+		}
+
+		paginationFnProviders = append(paginationFnProviders, func(ctx context.Context) collection.PaginationFn {
+			paginationFn := n.getPaginationFn(
+				ctx,
+				domainEntry,
+				workflowID,
+				runID,
+				segmentStartEventID,
+				segmentStartEventVersion,
+				segmentEndEventID,
+				segmentEndEventVersion,
+				nil,
+				targetLastEventID,
+				isLastSegment,
+			)
+			return func(paginationToken []byte) ([]interface{}, []byte, error) {
+				items, nextPageToken, err := paginationFn(paginationToken)
+				for _, item := range items {
+					item.(*historyBatch).versionHistory = thriftVersionHistory
+				}
+				return items, nextPageToken, err
+			}
+		})
+	}
+	return paginationFnProviders
+}
+
+func getRunKey(
+	domainID string,
+	workflowID string,
+	runID string,
+) string {
+
+	return strings.Join([]string{domainID, workflowID, runID}, "/")
+}
+
+// getResendKey returns the key identifying the resends of the same run, range, resume token, cursor and timeout
+func getResendKey(
+	descriptor *ResendDescriptor,
+) string {
+
+	formatInt64Ptr := func(value *int64) string {
+		if value == nil {
+			return "nil"
+		}
+		return strconv.FormatInt(*value, 10)
+	}
+	cursorKey := "nil"
+	if descriptor.Cursor != nil {
+		cursorKey = strconv.FormatInt(descriptor.Cursor.LastEventID, 10) + ":" +
+			base64.StdEncoding.EncodeToString(descriptor.Cursor.NextPageToken)
+	}
+	return strings.Join([]string{
+		descriptor.DomainID,
+		descriptor.WorkflowID,
+		descriptor.RunID,
+		formatInt64Ptr(descriptor.StartEventID),
+		formatInt64Ptr(descriptor.StartEventVersion),
+		formatInt64Ptr(descriptor.EndEventID),
+		formatInt64Ptr(descriptor.EndEventVersion),
+		base64.StdEncoding.EncodeToString(descriptor.ResumeToken),
+		cursorKey,
+		strconv.FormatInt(int64(descriptor.TimeoutOverride), 10),
+		strconv.FormatInt(descriptor.CloseTime.UnixNano(), 10),
+		strconv.FormatBool(descriptor.missingOnly),
+	}, "/")
+}
+
+func validateResendRange(
+	descriptor *ResendDescriptor,
+) error {
+
+	if (descriptor.StartEventID == nil) != (descriptor.StartEventVersion == nil) {
+		return &shared.BadRequestError{Message: fmt.Sprintf(
+			"Start event ID and start event version must be provided together, start event ID: %v, start event version: %v.",
+			int64PtrString(descriptor.StartEventID), int64PtrString(descriptor.StartEventVersion),
+		)}
+	}
+	if (descriptor.EndEventID == nil) != (descriptor.EndEventVersion == nil) {
+		return &shared.BadRequestError{Message: fmt.Sprintf(
+			"End event ID and end event version must be provided together, end event ID: %v, end event version: %v.",
+			int64PtrString(descriptor.EndEventID), int64PtrString(descriptor.EndEventVersion),
+		)}
+	}
+	if descriptor.StartEventID != nil && descriptor.EndEventID != nil &&
+		*descriptor.StartEventID > *descriptor.EndEventID {
+		return &shared.BadRequestError{Message: fmt.Sprintf(
+			"Start event ID: %v is larger than end event ID: %v.",
+			*descriptor.StartEventID, *descriptor.EndEventID,
+		)}
+	}
+	return nil
+}
+
+// validateTargetShardID returns BadRequestError if the target shard ID carried by the context, if any,
+// is out of the range of the target shards
+func (n *NDCHistoryResenderImpl) validateTargetShardID(
+	ctx context.Context,
+) error {
+
+	shardID, ok := GetTargetShardID(ctx)
+	if !ok {
+		return nil
+	}
+	if shardID < 0 || (n.targetShardCount > 0 && shardID >= n.targetShardCount) {
+		return &shared.BadRequestError{Message: fmt.Sprintf(
+			"Invalid target shard ID: %v, number of target shards: %v.", shardID, n.targetShardCount,
+		)}
+	}
+	return nil
+}
+
+func int64PtrString(
+	value *int64,
+) string {
+
+	if value == nil {
+		return "nil"
+	}
+	return strconv.FormatInt(*value, 10)
+}
+
+// createReplicationRawRequest returns the request replicating the event batch at the batch index of the resend,
+// the target cannot apply the events without the version history, so InternalServiceError is returned if it is empty.
+// The request is addressed to the target domain ID, while the domain ID stays the source of the config of the resend
+func (n *NDCHistoryResenderImpl) createReplicationRawRequest(
+	domainID string,
+	targetDomainID string,
+	workflowID string,
+	runID string,
+	batchIndex int,
+	historyBlob *shared.DataBlob,
+	versionHistoryItems []*shared.VersionHistoryItem,
+) (*history.ReplicateEventsV2Request, error) {
+
+	if len(versionHistoryItems) == 0 {
+		return nil, &shared.InternalServiceError{Message: fmt.Sprintf(
+			"Version history items of event batch are empty, domain ID: %v, workflow ID: %v, run ID: %v, batch index: %v.",
+			domainID, workflowID, runID, batchIndex,
+		)}
+	}
+
+	request := &history.ReplicateEventsV2Request{
+		DomainUUID: common.StringPtr(targetDomainID),
+		WorkflowExecution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		Events:              historyBlob,
+		VersionHistoryItems: versionHistoryItems,
+	}
+	return request, nil
+}
+
+// getTargetDomainID returns the ID of the domain of the target to replicate the history events of the domain into,
+// which is the domain itself unless it is remapped by the target domain mapper
+func (n *NDCHistoryResenderImpl) getTargetDomainID(
+	domainID string,
+	domainEntry *cache.DomainCacheEntry,
+) (string, error) {
+
+	if n.targetDomainMapper == nil {
+		return domainID, nil
+	}
+	targetDomainID, err := n.targetDomainMapper(domainID, domainEntry.GetInfo().Name)
+	if err != nil {
+		return "", err
+	}
+	if targetDomainID == "" {
+		return domainID, nil
+	}
+	return targetDomainID, nil
+}
+
+func (n *NDCHistoryResenderImpl) sendReplicationRawRequest(
+	ctx context.Context,
+	domainID string,
+	request *history.ReplicateEventsV2Request,
+) error {
+
+	requests, err := n.splitReplicationRawRequest(domainID, request)
+	if err != nil {
+		return err
+	}
+	// the chunks are sent in order, and the resend fails on the first chunk failed, so the events stay contiguous
+	for _, request := range requests {
+		if err := n.checkBatchSize(ctx, domainID, request); err != nil {
+			return err
+		}
+		if len(n.historyReplicationFns) == 0 {
+			return ErrHistoryReplicationFnNotSet
+		}
+		var sendErr error
+		for _, historyReplicationFn := range n.historyReplicationFns {
+			sendErr = multierr.Append(sendErr, n.sendReplicationRawRequestToTarget(ctx, domainID, request, historyReplicationFn))
+		}
+		if sendErr != nil {
+			return sendErr
+		}
+	}
+	return nil
+}
+
+// splitReplicationRawRequest splits the request into the requests of the contiguous chunks of the events of the batch,
+// if the batch exceeds the split batch size. each chunk is within the size unless it has a single larger event,
+// and carries the same version history items as the request. the request is returned as is if it is not split
+func (n *NDCHistoryResenderImpl) splitReplicationRawRequest(
+	domainID string,
+	request *history.ReplicateEventsV2Request,
+) ([]*history.ReplicateEventsV2Request, error) {
+
+	if n.splitBatchSize == nil || request.Events == nil {
+		return []*history.ReplicateEventsV2Request{request}, nil
+	}
+	splitBatchSize := n.splitBatchSize(domainID)
+	if splitBatchSize <= 0 || len(request.Events.GetData()) <= splitBatchSize {
+		return []*history.ReplicateEventsV2Request{request}, nil
+	}
+
+	blob, err := persistence.NewDataBlobFromThriftWithError(request.Events)
+	if err != nil {
+		return nil, &shared.InternalServiceError{Message: fmt.Sprintf("Failed to deserialize history events: %v.", err)}
+	}
+	events, err := n.serializer.DeserializeBatchEvents(blob)
+	if err != nil {
+		return nil, &shared.InternalServiceError{Message: fmt.Sprintf("Failed to deserialize history events: %v.", err)}
+	}
+	// the size of a chunk is estimated by the sizes of its events serialized alone,
+	// which is never less than the size of the chunk serialized
+	var chunks [][]*shared.HistoryEvent
+	var chunk []*shared.HistoryEvent
+	chunkSize := 0
+	for _, event := range events {
+		eventBlob, err := n.serializer.SerializeBatchEvents([]*shared.HistoryEvent{event}, blob.Encoding)
+		if err != nil {
+			return nil, &shared.InternalServiceError{Message: fmt.Sprintf("Failed to serialize history events: %v.", err)}
+		}
+		eventSize := len(eventBlob.Data)
+		if len(chunk) > 0 && chunkSize+eventSize > splitBatchSize {
+			chunks = append(chunks, chunk)
+			chunk = nil
+			chunkSize = 0
+		}
+		chunk = append(chunk, event)
+		chunkSize += eventSize
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) <= 1 {
+		return []*history.ReplicateEventsV2Request{request}, nil
+	}
+
+	requests := make([]*history.ReplicateEventsV2Request, 0, len(chunks))
+	for _, chunk := range chunks {
+		chunkBlob, err := n.serializer.SerializeBatchEvents(chunk, blob.Encoding)
+		if err != nil {
+			return nil, &shared.InternalServiceError{Message: fmt.Sprintf("Failed to serialize history events: %v.", err)}
+		}
+		chunkRequest := *request
+		chunkRequest.Events = chunkBlob.ToThrift()
+		requests = append(requests, &chunkRequest)
+	}
+	n.metricsClient.IncCounter(metrics.NDCHistoryResenderScope, metrics.HistoryResendBatchSplitCounter)
+	return requests, nil
+}
+
+// auditBatch writes the event batch to the audit sink if any, the error of the sink fails the resend only if configured
+func (n *NDCHistoryResenderImpl) auditBatch(
+	ctx context.Context,
+	scope metrics.Scope,
+	domainID string,
+	workflowID string,
+	runID string,
+	batch *shared.DataBlob,
+) error {
+
+	if n.auditSink == nil {
+		return nil
+	}
+	err := n.auditSink.Write(ctx, domainID, workflowID, runID, batch)
+	if err == nil {
+		return nil
+	}
+
+	scope.IncCounter(metrics.HistoryResendAuditFailedCounter)
+	logger := n.getLogger(ctx).WithTags(
+		tag.WorkflowDomainID(domainID),
+		tag.WorkflowID(workflowID),
+		tag.WorkflowRunID(runID),
+		tag.Error(err),
+	)
+	if n.failOnAuditError != nil && n.failOnAuditError(domainID) {
+		logger.Error("failed to audit event batch")
+		return err
+	}
+	logger.Warn("failed to audit event batch, the batch is sent without audit")
+	return nil
+}
+
+// checkBatchSize reports the event batch of the request if it exceeds the large batch threshold,
+// and returns ErrBatchTooLarge if it exceeds the max batch size
+func (n *NDCHistoryResenderImpl) checkBatchSize(
+	ctx context.Context,
+	domainID string,
+	request *history.ReplicateEventsV2Request,
+) error {
+
+	batchSize := len(request.GetEvents().GetData())
+	if n.largeBatchThreshold != nil {
+		if threshold := n.largeBatchThreshold(domainID); threshold > 0 && batchSize >= threshold {
+			n.metricsClient.IncCounter(metrics.NDCHistoryResenderScope, metrics.HistoryResendLargeBatchCounter)
+			n.getLogger(ctx).Warn("history event batch to replicate is large",
+				tag.WorkflowDomainID(domainID),
+				tag.WorkflowID(request.GetWorkflowExecution().GetWorkflowId()),
+				tag.WorkflowRunID(request.GetWorkflowExecution().GetRunId()),
+				tag.WorkflowEventBatchSize(batchSize))
+		}
+	}
+	if n.maxBatchSize != nil {
+		if maxBatchSize := n.maxBatchSize(domainID); maxBatchSize > 0 && batchSize > maxBatchSize {
+			return ErrBatchTooLarge
+		}
+	}
+	return nil
+}
+
+// deserializeEventBatch deserializes the event batch read from the source,
+// the unsupported encoding of the batch is returned as an error
+func (n *NDCHistoryResenderImpl) deserializeEventBatch(
+	historyBatch *shared.DataBlob,
+) ([]*shared.HistoryEvent, error) {
+
+	blob, err := persistence.NewDataBlobFromThriftWithError(historyBatch)
+	if err != nil {
+		return nil, err
+	}
+	return n.serializer.DeserializeBatchEvents(blob)
+}
+
+// waitBatchDelay waits for the delay between the event batches, the wait is interrupted if the context is done
+func (n *NDCHistoryResenderImpl) waitBatchDelay(
+	ctx context.Context,
+	domainID string,
+) error {
+
+	if n.batchDelay == nil {
+		return nil
+	}
+	delay := n.batchDelay(domainID)
+	if delay <= 0 {
+		return nil
+	}
+	// the delay is measured by the time source, so it can be elapsed by a fake time source in tests
+	delayCtx, cancel := clock.ContextWithTimeout(ctx, n.timeSource, delay)
+	defer cancel()
+	<-delayCtx.Done()
+	return ctx.Err()
+}
+
+func (n *NDCHistoryResenderImpl) sendReplicationRawRequestToTarget(
+	ctx context.Context,
+	domainID string,
+	request *history.ReplicateEventsV2Request,
+	historyReplicationFn nDCHistoryReplicationFn,
+) error {
+
+	if historyReplicationFn == nil {
+		return ErrHistoryReplicationFnNotSet
+	}
+	op := func() error {
+		if err := n.limiter.waitGlobalOpsToken(ctx); err != nil {
+			return err
+		}
+		if err := n.limiter.waitGlobalBytes(ctx, int64(len(request.GetEvents().GetData()))); err != nil {
+			return err
+		}
+
+		ctx, cancel, err := n.withCallTimeout(ctx, n.getReplicationTimeout(domainID))
+		if err != nil {
+			return err
+		}
+		defer cancel()
+		ctx, ack := withReplicationChecksumAck(ctx)
+		addSentBytes(ctx, request.GetEvents())
+		if err := historyReplicationFn(ctx, request); err != nil {
+			return err
+		}
+		return n.verifyReplicationChecksum(ctx, request, ack)
+	}
+	if n.replicationRetryPolicy == nil {
+		return op()
+	}
+	// the same batch is retried on service busy error, so the progress of the resend is kept
+	return backoff.RetryContext(ctx, n.timeSource, op, n.replicationRetryPolicy, n.withRetryBudget(ctx, n.isRetryableReplicationError))
+}
+
+// verifyReplicationChecksum returns ErrReplicationChecksumMismatch if the checksum reported by the target
+// mismatches the checksum of the event batch sent, the verification is skipped if the target reports none
+func (n *NDCHistoryResenderImpl) verifyReplicationChecksum(
+	ctx context.Context,
+	request *history.ReplicateEventsV2Request,
+	ack *replicationChecksumAck,
+) error {
+
+	reportedChecksum, ok := ack.get()
+	if !ok {
+		return nil
+	}
+	if checksum := ComputeReplicationChecksum(request); checksum != reportedChecksum {
+		n.metricsClient.IncCounter(metrics.NDCHistoryResenderScope, metrics.HistoryResendChecksumMismatchCounter)
+		n.getLogger(ctx).Error("checksum of replicated history event batch mismatches",
+			tag.WorkflowDomainID(request.GetDomainUUID()),
+			tag.WorkflowID(request.GetWorkflowExecution().GetWorkflowId()),
+			tag.WorkflowRunID(request.GetWorkflowExecution().GetRunId()),
+			tag.Number(int64(checksum)),
+			tag.NextNumber(int64(reportedChecksum)))
+		return ErrReplicationChecksumMismatch
+	}
+	return nil
+}
+
+func containsEntityNotExistsError(
+	err error,
+) bool {
+
+	for _, err := range multierr.Errors(err) {
+		if _, ok := err.(*shared.EntityNotExistsError); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *NDCHistoryResenderImpl) getHistory(
+	ctx context.Context,
+	domainEntry *cache.DomainCacheEntry,
+	workflowID string,
+	runID string,
+	startEventID *int64,
+	startEventVersion *int64,
+	endEventID *int64,
+	endEventVersion *int64,
+	token []byte,
+	pageSize int32,
+) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+
+	historyFetcher, err := n.getHistoryFetcher(domainEntry)
+	if err != nil {
+		return nil, err
+	}
+	return n.getHistoryFrom(
+		ctx,
+		historyFetcher,
+		domainEntry,
+		workflowID,
+		runID,
+		startEventID,
+		startEventVersion,
+		endEventID,
+		endEventVersion,
+		token,
+		pageSize,
+	)
+}
+
+func (n *NDCHistoryResenderImpl) getHistoryFrom(
+	ctx context.Context,
+	historyFetcher HistoryFetcher,
+	domainEntry *cache.DomainCacheEntry,
+	workflowID string,
+	runID string,
+	startEventID *int64,
+	startEventVersion *int64,
+	endEventID *int64,
+	endEventVersion *int64,
+	token []byte,
+	pageSize int32,
+) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+
+	domainID := domainEntry.GetInfo().ID
+	domainName := domainEntry.GetInfo().Name
+	logger := n.getLogger(ctx).WithTags(
+		tag.WorkflowRunID(runID),
+		tag.SourceCluster(n.getSourceClusterOfDomain(domainEntry)),
+	)
+
+	sw := n.metricsClient.Scope(
+		metrics.NDCHistoryResenderScope,
+		metrics.DomainTag(domainName),
+	).StartTimer(metrics.HistoryResendGetHistoryLatency)
+	defer sw.Stop()
+
+	request := &admin.GetWorkflowExecutionRawHistoryV2Request{
+		Domain: common.StringPtr(domainName),
+		Execution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		StartEventId:      startEventID,
+		StartEventVersion: startEventVersion,
+		EndEventId:        endEventID,
+		EndEventVersion:   endEventVersion,
+		MaximumPageSize:   common.Int32Ptr(pageSize),
+		NextPageToken:     token,
+	}
+	var response *admin.GetWorkflowExecutionRawHistoryV2Response
+	var err error
+	op := func() error {
+		if err := n.limiter.waitGetHistoryToken(ctx, domainID); err != nil {
+			return err
+		}
+		if err := n.limiter.waitGlobalOpsToken(ctx); err != nil {
+			return err
+		}
+
+		ctx, cancel, err := n.withCallTimeout(ctx, n.getGetHistoryTimeout(domainID))
+		if err != nil {
+			return err
+		}
+		defer cancel()
+		ctx, err = n.withAdminHeaders(ctx, domainID)
+		if err != nil {
+			return err
+		}
+
+		response, err = historyFetcher.GetRawHistory(ctx, request)
+		if isSourceThrottledError(err) {
+			// operators raise the quotas of the source cluster on this signal
+			n.metricsClient.Scope(
+				metrics.NDCHistoryResenderScope,
+				metrics.DomainTag(domainName),
+			).IncCounter(metrics.HistoryResendSourceThrottledCounter)
+			logger.Warn("history fetch is throttled by source cluster", tag.Error(err))
+		}
+		return err
+	}
+	for {
+		if n.getHistoryRetryPolicy != nil {
+			err = backoff.RetryContext(ctx, n.timeSource, op, n.getHistoryRetryPolicy, n.withRetryBudget(ctx, n.isRetryableGetHistoryError))
+		} else {
+			err = op()
+		}
+		if err == nil || !isOversizedResponseError(err) || pageSize <= 1 {
+			break
+		}
+		// the page exceeds the transport frame size, so the same page is fetched again with the halved page size
+		pageSize /= 2
+		request.MaximumPageSize = common.Int32Ptr(pageSize)
+		n.metricsClient.IncCounter(metrics.NDCHistoryResenderScope, metrics.HistoryResendPageSizeReducedCounter)
+		logger.Warn("history page is too large, retrying with smaller page size",
+			tag.Number(int64(pageSize)),
+			tag.Error(err))
+	}
+	if err == nil {
+		// the size of the page is only known once fetched, so the bytes are waited for afterwards,
+		// which holds back the following calls instead
+		err = n.limiter.waitGlobalBytes(ctx, getDataBlobsSize(response.GetHistoryBatches()))
+	}
+	if err != nil {
+		n.logResendError(
+			ctx,
+			n.metricsClient.Scope(metrics.NDCHistoryResenderScope, metrics.DomainTag(domainName)),
+			logger,
+			"error getting history",
+			err,
+		)
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// isSourceThrottledError returns whether the admin service of the source cluster rejects the call for its quotas,
+// the transport reports the exhausted quotas as ResourceExhausted as well, unlike the oversized responses
+func isSourceThrottledError(
+	err error,
+) bool {
+
+	if _, ok := err.(*shared.ServiceBusyError); ok {
+		return true
+	}
+	return yarpcerrors.IsResourceExhausted(err) && !isOversizedResponseError(err)
+}
+
+// isOversizedResponseError returns whether the response is rejected by the transport for exceeding the frame size,
+// which is told apart by the message only, since the code of the error, if any, is shared with the exhausted quotas
+func isOversizedResponseError(
+	err error,
+) bool {
+
+	if err == nil {
+		return false
+	}
+	message := strings.ToLower(err.Error())
+	return (strings.Contains(message, "frame") && strings.Contains(message, "too large")) ||
+		strings.Contains(message, "larger than max")
+}
+
+// withAdminHeaders returns a copy of the context carrying the headers provided for the call to the admin service
+func (n *NDCHistoryResenderImpl) withAdminHeaders(
+	ctx context.Context,
+	domainID string,
+) (context.Context, error) {
+
+	if n.adminHeadersProvider == nil {
+		return ctx, nil
+	}
+	headers, err := n.adminHeadersProvider(ctx, domainID)
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, adminHeadersKey, headers), nil
+}
+
+// getVersionHistoryForRange returns the current version history in remote if it covers the event ID range
+func (n *NDCHistoryResenderImpl) getVersionHistoryForRange(
+	ctx context.Context,
+	domainID string,
+	workflowID string,
+	runID string,
+	startEventID *int64,
+	endEventID *int64,
+) (*persistence.VersionHistory, error) {
+
+	domainEntry, err := n.domainCache.GetDomainByID(domainID)
+	if err != nil {
+		return nil, err
+	}
+	versionHistory, err := n.getCurrentVersionHistory(ctx, domainEntry, workflowID, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	maxEventID := int64(common.EmptyEventID)
+	if startEventID != nil {
+		maxEventID = *startEventID
+	}
+	if endEventID != nil && *endEventID > maxEventID {
+		maxEventID = *endEventID
+	}
+	lastItem, err := versionHistory.GetLastItem()
+	if err != nil {
+		return nil, err
+	}
+	if lastItem.GetEventID() < maxEventID {
+		return nil, &shared.BadRequestError{Message: fmt.Sprintf(
+			"Event ID: %v is not found in the current version history.", maxEventID,
+		)}
+	}
+	return versionHistory, nil
+}
+
+// getCurrentVersionHistory returns the current version history of the run in remote,
+// carried by the response of the first page of the history events
+func (n *NDCHistoryResenderImpl) getCurrentVersionHistory(
+	ctx context.Context,
+	domainEntry *cache.DomainCacheEntry,
+	workflowID string,
+	runID string,
+) (*persistence.VersionHistory, error) {
+
+	response, err := n.getHistory(ctx, domainEntry, workflowID, runID, nil, nil, nil, nil, nil, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.GetVersionHistory().GetItems()) == 0 {
+		return nil, &shared.BadRequestError{Message: "Workflow does not have version histories."}
+	}
+	return persistence.NewVersionHistoryFromThrift(response.GetVersionHistory()), nil
+}
+
+func (n *NDCHistoryResenderImpl) getTargetLastEventID(
+	ctx context.Context,
+	domainID string,
+	workflowID string,
+	runID string,
+) int64 {
+
+	if n.targetProgressChecker == nil {
+		return common.EmptyEventID
+	}
+	lastEventID, err := n.targetProgressChecker(ctx, domainID, workflowID, runID)
+	if err != nil {
+		n.getLogger(ctx).Warn("failed to check the progress of target, resending all history events",
+			tag.WorkflowDomainID(domainID),
+			tag.WorkflowID(workflowID),
+			tag.WorkflowRunID(runID),
+			tag.Error(err))
+		return common.EmptyEventID
+	}
+	return lastEventID
+}
+
+// skipReplicatedBatches removes the event batches whose events are all present on the target
+func (n *NDCHistoryResenderImpl) skipReplicatedBatches(
+	logger log.Logger,
+	historyBatches []*shared.DataBlob,
+	targetLastEventID int64,
+) []*shared.DataBlob {
+
+	if targetLastEventID < common.FirstEventID {
+		return historyBatches
+	}
+
+	var batchesToSend []*shared.DataBlob
+	for _, historyBatch := range historyBatches {
+		_, lastEventID := n.getBatchEventIDRange(logger, historyBatch)
+		if lastEventID != common.EmptyEventID && lastEventID <= targetLastEventID {
+			continue
+		}
+		batchesToSend = append(batchesToSend, historyBatch)
+	}
+	return batchesToSend
+}
+
+// newPageTokenSequencePaginationFn returns the pagination fn fetching the pages of the page tokens in order,
+// regardless of the next page tokens returned by the source, the first page token must be the initial page token
+// of the pagination fn
+func newPageTokenSequencePaginationFn(
+	paginationFn collection.PaginationFn,
+	pageTokens [][]byte,
+) collection.PaginationFn {
+
+	index := 0
+	return func(_ []byte) ([]interface{}, []byte, error) {
+		items, _, err := paginationFn(pageTokens[index])
+		if err != nil {
+			return nil, nil, err
+		}
+		index++
+		if index == len(pageTokens) {
+			return items, nil, nil
+		}
+		// the paging iterator stops at an empty token, the token passed back in is ignored anyway
+		return items, replayNextPageToken, nil
+	}
+}
+
+// newReverseIterator drains the iterator of the history batches and returns an iterator of the batches in reverse order,
+// ResendTooLargeError is encountered once the batches buffered exceed maxBytes, 0 means unlimited.
+// The error encountered while draining is returned by the first Next instead
+func newReverseIterator(
+	iterator collection.Iterator,
+	maxBytes int64,
+) collection.Iterator {
+
+	var items []interface{}
+	var iterErr error
+	var bytesReached int64
+	for iterator.HasNext() {
+		item, err := iterator.Next()
+		if err != nil {
+			iterErr = err
+			break
+		}
+		bytesReached += int64(len(item.(*historyBatch).rawEventBatch.GetData()))
+		if maxBytes > 0 && bytesReached > maxBytes {
+			iterErr = &ResendTooLargeError{
+				BytesReached: bytesReached,
+				MaxBytes:     maxBytes,
+			}
+			break
+		}
+		items = append(items, item)
+	}
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+	return collection.NewPagingIterator(func(paginationToken []byte) ([]interface{}, []byte, error) {
+		if iterErr != nil {
+			return nil, nil, iterErr
+		}
+		return items, nil, nil
+	})
+}
+
+// getBatchEventIDRange returns the IDs of the first and the last event in the batch,
+// common.EmptyEventID is returned if the batch cannot be decoded
+func (n *NDCHistoryResenderImpl) getBatchEventIDRange(
+	logger log.Logger,
+	rawEventBatch *shared.DataBlob,
+) (int64, int64) {
+
+	if rawEventBatch.GetEncodingType() == shared.EncodingTypeThriftRW {
+		firstEventID, lastEventID, err := decodeBatchEventIDRange(rawEventBatch.GetData())
+		if err != nil {
+			logger.Warn("failed to decode history events", tag.Error(err))
+			return common.EmptyEventID, common.EmptyEventID
+		}
+		return firstEventID, lastEventID
+	}
+
+	// only the thriftrw encoding can be partially decoded, other encodings require the full deserialization
+	events, err := n.deserializeEventBatch(rawEventBatch)
+	if err != nil || len(events) == 0 {
+		logger.Warn("failed to decode history events", tag.Error(err))
+		return common.EmptyEventID, common.EmptyEventID
+	}
+	return events[0].GetEventId(), events[len(events)-1].GetEventId()
+}
+
+// trimBatchesToEndEvent drops the events from the end event onwards, as the end event is exclusive,
+// only the batch containing the end event is deserialized and re-serialized
+func (n *NDCHistoryResenderImpl) trimBatchesToEndEvent(
+	logger log.Logger,
+	historyBatches []*shared.DataBlob,
+	endEventID int64,
+) ([]*shared.DataBlob, error) {
+
+	trimmedBatches := make([]*shared.DataBlob, 0, len(historyBatches))
+	for _, historyBatch := range historyBatches {
+		firstEventID, lastEventID := n.getBatchEventIDRange(logger, historyBatch)
+		if lastEventID < endEventID {
+			trimmedBatches = append(trimmedBatches, historyBatch)
+			continue
+		}
+		if firstEventID >= endEventID {
+			break
+		}
+
+		blob, err := persistence.NewDataBlobFromThriftWithError(historyBatch)
+		if err != nil {
+			return nil, &shared.InternalServiceError{Message: fmt.Sprintf("Failed to deserialize history events: %v.", err)}
+		}
+		events, err := n.serializer.DeserializeBatchEvents(blob)
+		if err != nil {
+			return nil, &shared.InternalServiceError{Message: fmt.Sprintf("Failed to deserialize history events: %v.", err)}
+		}
+		trimmedEvents := events[:0]
+		for _, event := range events {
+			if event.GetEventId() < endEventID {
+				trimmedEvents = append(trimmedEvents, event)
+			}
+		}
+		trimmedBlob, err := n.serializer.SerializeBatchEvents(trimmedEvents, blob.Encoding)
+		if err != nil {
+			return nil, &shared.InternalServiceError{Message: fmt.Sprintf("Failed to serialize history events: %v.", err)}
+		}
+		trimmedBatches = append(trimmedBatches, trimmedBlob.ToThrift())
+		// the batches after the one containing the end event are beyond the end event as well
+		break
+	}
+	return trimmedBatches, nil
+}
+
+// filterEventBatch re-serializes the event batch with only the events of the event types in the filter,
+// nil is returned if no event of the batch matches
+func (n *NDCHistoryResenderImpl) filterEventBatch(
+	historyBatch *shared.DataBlob,
+	eventTypeFilter map[shared.EventType]struct{},
+) (*shared.DataBlob, error) {
+
+	filteredBatch, _, err := n.partitionEventBatch(historyBatch, eventTypeFilter)
+	return filteredBatch, err
+}
+
+// partitionEventBatch splits the event batch into the events of the event types and the rest, in the original order,
+// either of which is nil if there is no such event, and the batch itself is returned if it is not split
+func (n *NDCHistoryResenderImpl) partitionEventBatch(
+	historyBatch *shared.DataBlob,
+	eventTypes map[shared.EventType]struct{},
+) (*shared.DataBlob, *shared.DataBlob, error) {
+
+	blob, err := persistence.NewDataBlobFromThriftWithError(historyBatch)
+	if err != nil {
+		return nil, nil, &shared.InternalServiceError{Message: fmt.Sprintf("Failed to deserialize history events: %v.", err)}
+	}
+	events, err := n.serializer.DeserializeBatchEvents(blob)
+	if err != nil {
+		return nil, nil, &shared.InternalServiceError{Message: fmt.Sprintf("Failed to deserialize history events: %v.", err)}
+	}
+	var matchedEvents []*shared.HistoryEvent
+	var unmatchedEvents []*shared.HistoryEvent
+	for _, event := range events {
+		if _, ok := eventTypes[event.GetEventType()]; ok {
+			matchedEvents = append(matchedEvents, event)
+		} else {
+			unmatchedEvents = append(unmatchedEvents, event)
+		}
+	}
+	switch {
+	case len(matchedEvents) == 0 && len(unmatchedEvents) == 0:
+		return nil, nil, nil
+	case len(unmatchedEvents) == 0:
+		return historyBatch, nil, nil
+	case len(matchedEvents) == 0:
+		return nil, historyBatch, nil
+	}
+	matchedBlob, err := n.serializer.SerializeBatchEvents(matchedEvents, blob.Encoding)
+	if err != nil {
+		return nil, nil, &shared.InternalServiceError{Message: fmt.Sprintf("Failed to serialize history events: %v.", err)}
+	}
+	unmatchedBlob, err := n.serializer.SerializeBatchEvents(unmatchedEvents, blob.Encoding)
+	if err != nil {
+		return nil, nil, &shared.InternalServiceError{Message: fmt.Sprintf("Failed to serialize history events: %v.", err)}
+	}
+	return matchedBlob.ToThrift(), unmatchedBlob.ToThrift(), nil
+}
+
+// transcodeEventBatches re-serializes the event batches in the encoding configured for the domain,
+// so the target receives the same encoding regardless of the encoding persisted by the source
+func (n *NDCHistoryResenderImpl) transcodeEventBatches(
+	domainName string,
+	historyBatches []*shared.DataBlob,
+) ([]*shared.DataBlob, error) {
+
+	if n.eventBlobEncoding == nil {
+		return historyBatches, nil
+	}
+	encoding := common.EncodingType(n.eventBlobEncoding(domainName))
+	switch encoding {
+	case "":
+		return historyBatches, nil
+	case common.EncodingTypeThriftRW, common.EncodingTypeJSON:
+	default:
+		return nil, persistence.NewUnknownEncodingTypeError(encoding)
+	}
+
+	transcodedBatches := make([]*shared.DataBlob, 0, len(historyBatches))
+	for _, historyBatch := range historyBatches {
+		blob, err := persistence.NewDataBlobFromThriftWithError(historyBatch)
+		if err != nil {
+			return nil, &shared.InternalServiceError{Message: fmt.Sprintf("Failed to deserialize history events: %v.", err)}
+		}
+		if blob.Encoding == encoding {
+			transcodedBatches = append(transcodedBatches, historyBatch)
+			continue
+		}
+		events, err := n.serializer.DeserializeBatchEvents(blob)
+		if err != nil {
+			return nil, &shared.InternalServiceError{Message: fmt.Sprintf("Failed to deserialize history events: %v.", err)}
+		}
+		transcodedBlob, err := n.serializer.SerializeBatchEvents(events, encoding)
+		if err != nil {
+			return nil, &shared.InternalServiceError{Message: fmt.Sprintf("Failed to serialize history events: %v.", err)}
+		}
+		transcodedBatches = append(transcodedBatches, transcodedBlob.ToThrift())
+	}
+	return transcodedBatches, nil
+}
+
+// decodeBatchEventIDRange reads only the event IDs of the first and the last event
+// from the thriftrw encoded batch, instead of deserializing all the events
+func decodeBatchEventIDRange(
+	data []byte,
+) (int64, int64, error) {
+
+	value, err := codec.DecodeWire(data)
+	if err != nil {
+		return common.EmptyEventID, common.EmptyEventID, err
+	}
+
+	firstEventID := common.EmptyEventID
+	lastEventID := common.EmptyEventID
+	for _, field := range value.GetStruct().Fields {
+		if field.ID != historyEventsFieldID || field.Value.Type() != wire.TList {
+			continue
+		}
+		events := field.Value.GetList()
+		lastIndex := events.Size() - 1
+		index := 0
+		err = events.ForEach(func(event wire.Value) error {
+			if index == 0 || index == lastIndex {
+				eventID, err := decodeEventID(event)
+				if err != nil {
+					return err
+				}
+				if index == 0 {
+					firstEventID = eventID
+				}
+				if index == lastIndex {
+					lastEventID = eventID
+				}
+			}
+			index++
+			return nil
+		})
+		events.Close()
+		if err != nil {
+			return common.EmptyEventID, common.EmptyEventID, err
+		}
+	}
+	if firstEventID == common.EmptyEventID || lastEventID == common.EmptyEventID {
+		return common.EmptyEventID, common.EmptyEventID, errors.New("no event ID found in history events")
+	}
+	return firstEventID, lastEventID, nil
+}
+
+func decodeEventID(
+	event wire.Value,
+) (int64, error) {
+
+	if event.Type() != wire.TStruct {
+		return common.EmptyEventID, fmt.Errorf("unexpected history event type: %v", event.Type())
+	}
+	for _, field := range event.GetStruct().Fields {
+		if field.ID == historyEventIDFieldID && field.Value.Type() == wire.TI64 {
+			return field.Value.GetI64(), nil
+		}
+	}
+	return common.EmptyEventID, errors.New("event ID not found in history event")
+}
+
+func (n *NDCHistoryResenderImpl) isRetryableGetHistoryError(
+	err error,
+) bool {
+
+	// the throttled calls are always backed off and retried rather than failing the resend
+	return (isSourceThrottledError(err) || n.errorClassifier.IsRetryable(err)) && !n.errorClassifier.IsFatal(err)
+}
+
+// isRetryableReplicationError returns whether the target is busy, the other errors of the target are not retried
+// since the target may be overloaded already
+func (n *NDCHistoryResenderImpl) isRetryableReplicationError(
+	err error,
+) bool {
+
+	return common.IsServiceBusyError(err) && !n.errorClassifier.IsFatal(err)
+}
+
+func createReplicationRetryPolicy() backoff.RetryPolicy {
+	policy := backoff.NewExponentialRetryPolicy(replicationServiceBusyRetryInitialInterval)
+	policy.SetMaximumInterval(replicationServiceBusyRetryMaxInterval)
+	policy.SetMaximumAttempts(replicationServiceBusyRetryMaxAttempts)
+	return policy
+}
+
+func createGetHistoryRetryPolicy() backoff.RetryPolicy {
+	policy := backoff.NewExponentialRetryPolicy(getHistoryRetryInitialInterval)
+	policy.SetMaximumInterval(getHistoryRetryMaxInterval)
+	policy.SetMaximumAttempts(getHistoryRetryMaxAttempts)
+	return policy
+}
+
+// withCallTimeout returns the context of a call to remote which times out after the timeout, or once the time
+// left before the deadline of the resend runs out if sooner, so the calls of a resend never outlive its
+// rereplication timeout in total. ErrResendDeadlineExceeded is returned if the time left is too short for a call
+func (n *NDCHistoryResenderImpl) withCallTimeout(
+	ctx context.Context,
+	timeout time.Duration,
+) (context.Context, context.CancelFunc, error) {
+
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := deadline.Sub(n.timeSource.Now())
+		if remaining < minCallTimeout {
+			return nil, nil, ErrResendDeadlineExceeded
+		}
+		if remaining < timeout {
+			timeout = remaining
+		}
+	}
+	ctx, cancel := clock.ContextWithTimeout(ctx, n.timeSource, timeout)
+	return ctx, cancel, nil
+}
+
+func (n *NDCHistoryResenderImpl) getGetHistoryTimeout(
+	domainID string,
+) time.Duration {
+
+	if n.getHistoryTimeout == nil {
+		return defaultResendContextTimeout
+	}
+	if timeout := n.getHistoryTimeout(domainID); timeout > 0 {
+		return timeout
+	}
+	return defaultResendContextTimeout
+}
+
+func (n *NDCHistoryResenderImpl) getReplicationTimeout(
+	domainID string,
+) time.Duration {
+
+	if n.replicationTimeout == nil {
+		return defaultResendContextTimeout
+	}
+	if timeout := n.replicationTimeout(domainID); timeout > 0 {
+		return timeout
+	}
+	return defaultResendContextTimeout
+}
+
+func (n *NDCHistoryResenderImpl) getMaxPages(
+	domainID string,
+) int64 {
+
+	if n.maxPages == nil {
+		return 0
+	}
+	return int64(n.maxPages(domainID))
+}
+
+func (n *NDCHistoryResenderImpl) isReverseFetch(
+	domainID string,
+) bool {
+
+	return n.reverseFetch != nil && n.reverseFetch(domainID)
+}
+
+func (n *NDCHistoryResenderImpl) getMaxResendBytes(
+	domainID string,
+) int64 {
+
+	if n.maxResendBytes == nil {
+		return 0
+	}
+	return int64(n.maxResendBytes(domainID))
+}
+
+// getResendTimeout returns the timeout override of the descriptor bounded by the max timeout override if any,
+// otherwise the rereplication timeout of the domain, 0 means no timeout.
+// If the close time of the run is known, the timeout is further capped by the remaining retention of the run,
+// since the source cluster may delete the history halfway through the resend once the retention expires.
+// This is a heuristic, the deletion is delayed by the retention timer of the source cluster, so the resend
+// of a run past its retention is still tried with the min call timeout instead of being failed up front
+func (n *NDCHistoryResenderImpl) getResendTimeout(
+	descriptor *ResendDescriptor,
+	domainEntry *cache.DomainCacheEntry,
+) time.Duration {
+
+	var timeout time.Duration
+	if descriptor.TimeoutOverride <= 0 {
+		timeout = n.getRereplicationTimeout(descriptor.DomainID)
+	} else {
+		maxTimeoutOverride := defaultMaxTimeoutOverride
+		if n.maxTimeoutOverride != nil {
+			if override := n.maxTimeoutOverride(descriptor.DomainID); override > 0 {
+				maxTimeoutOverride = override
+			}
+		}
+		timeout = descriptor.TimeoutOverride
+		if timeout > maxTimeoutOverride {
+			timeout = maxTimeoutOverride
+		}
+	}
+
+	if descriptor.CloseTime.IsZero() || domainEntry == nil {
+		return timeout
+	}
+	retention := time.Duration(domainEntry.GetRetentionDays(descriptor.WorkflowID)) * time.Hour * 24
+	if retention <= 0 {
+		return timeout
+	}
+	remainingRetention := descriptor.CloseTime.Add(retention).Sub(n.timeSource.Now())
+	if remainingRetention < minCallTimeout {
+		remainingRetention = minCallTimeout
+	}
+	if timeout <= 0 || remainingRetention < timeout {
+		return remainingRetention
+	}
+	return timeout
+}
+
+func (n *NDCHistoryResenderImpl) getRereplicationTimeout(
+	domainID string,
+) time.Duration {
+
+	if n.rereplicationTimeout == nil {
+		return 0
+	}
+	timeout := n.rereplicationTimeout(domainID)
+	if timeout <= 0 || n.rereplicationTimeoutJitter == nil {
+		return timeout
+	}
+	coefficient := n.rereplicationTimeoutJitter(domainID)
+	if coefficient <= 0 {
+		return timeout
+	}
+	if coefficient > 1 {
+		coefficient = 1
+	}
+
+	n.randLock.Lock()
+	random := n.rand.Float64()
+	n.randLock.Unlock()
+
+	jitteredTimeout := time.Duration(float64(timeout) * (1 - coefficient + 2*coefficient*random))
+	if jitteredTimeout <= 0 {
+		// zero timeout means no timeout at all
+		return timeout
+	}
+	return jitteredTimeout
+}
+
+// withResendLogger returns a copy of the context carrying the logger tagged with the correlation ID of the resend
+func (n *NDCHistoryResenderImpl) withResendLogger(
+	ctx context.Context,
+) (context.Context, log.Logger) {
+
+	correlationID, ok := ctx.Value(resendCorrelationIDKey).(string)
+	if !ok || correlationID == "" {
+		correlationID = uuid.New()
+		ctx = WithResendCorrelationID(ctx, correlationID)
+	}
+	logger := n.logger.WithTags(tag.ResendCorrelationID(correlationID))
+	if shardID, ok := GetResendShardID(ctx); ok {
+		logger = logger.WithTags(tag.ShardID(shardID))
+	}
+	return context.WithValue(ctx, resendLoggerKey, logger), logger
+}
+
+// getLogger returns the logger of the resend carried by the context, or the logger of the resender
+func (n *NDCHistoryResenderImpl) getLogger(
+	ctx context.Context,
+) log.Logger {
+
+	if logger, ok := ctx.Value(resendLoggerKey).(log.Logger); ok {
+		return logger
+	}
+	return n.logger
+}
+
+// withResendPriority returns a copy of the context carrying the priority configured for the domain,
+// unless the context already carries one
+func (n *NDCHistoryResenderImpl) withResendPriority(
+	ctx context.Context,
+	domainName string,
+) context.Context {
+
+	if _, ok := ctx.Value(resendPriorityKey).(ResendPriority); ok || n.domainPriority == nil {
+		return ctx
+	}
+	priority, err := ParseResendPriority(n.domainPriority(domainName))
+	if err != nil {
+		n.getLogger(ctx).Warn("invalid resend priority of domain, normal priority is used",
+			tag.WorkflowDomainName(domainName),
+			tag.Error(err))
+	}
+	return WithResendPriority(ctx, priority)
+}
+
+// getDomainEntry resolves the domain, the last known domain is returned for the resumed resend
+// if the domain cannot be resolved anymore, e.g. the domain is deleted after the previous pages are sent
+func (n *NDCHistoryResenderImpl) getDomainEntry(
+	logger log.Logger,
+	domainID string,
+	resumed bool,
+) (*cache.DomainCacheEntry, error) {
+
+	domainEntry, err := n.domainCache.GetDomainByID(domainID)
+	if err == nil {
+		n.lastKnownDomains.Put(domainID, domainEntry)
+		return domainEntry, nil
+	}
+	if !resumed {
+		return nil, err
+	}
+
+	lastKnownDomainEntry, ok := n.lastKnownDomains.Get(domainID).(*cache.DomainCacheEntry)
+	if !ok {
+		return nil, err
+	}
+	logger.Warn("failed to resolve domain, using the last known domain for the resumed resend",
+		tag.WorkflowDomainID(domainID),
+		tag.WorkflowDomainName(lastKnownDomainEntry.GetInfo().Name),
+		tag.Error(err))
+	return lastKnownDomainEntry, nil
+}
+
+// getSourceAdminClient returns the admin client of the source cluster of the domain
+func (n *NDCHistoryResenderImpl) getSourceAdminClient(
+	domainEntry *cache.DomainCacheEntry,
+) (adminClient.Client, error) {
+
+	if n.sourceAdminClients == nil {
+		return n.adminClient, nil
+	}
+	sourceCluster := n.getSourceClusterOfDomain(domainEntry)
+	sourceAdminClient, ok := n.sourceAdminClients[sourceCluster]
+	if !ok {
+		return nil, &SourceAdminClientNotFoundError{
+			DomainID:      domainEntry.GetInfo().ID,
+			SourceCluster: sourceCluster,
+		}
+	}
+	return sourceAdminClient, nil
+}
+
+// getHistoryFetcher returns the history fetcher set by the option, or the one fetching via the admin client of
+// the source cluster of the domain, which changes as the domain fails over
+func (n *NDCHistoryResenderImpl) getHistoryFetcher(
+	domainEntry *cache.DomainCacheEntry,
+) (HistoryFetcher, error) {
+
+	if n.historyFetcher != nil {
+		return n.historyFetcher, nil
+	}
+	sourceAdminClient, err := n.getSourceAdminClient(domainEntry)
+	if err != nil {
+		return nil, err
+	}
+	return NewAdminHistoryFetcher(sourceAdminClient), nil
+}
+
+func (n *NDCHistoryResenderImpl) getSourceClusterOfDomain(
+	domainEntry *cache.DomainCacheEntry,
+) string {
+
+	if n.sourceCluster != "" {
+		return n.sourceCluster
+	}
+	return domainEntry.GetReplicationConfig().ActiveClusterName
+}
+
+func (n *NDCHistoryResenderImpl) getResendPageBufferSize(
+	domainID string,
+) int {
+
+	if n.resendPageBufferSize == nil {
+		return defaultResendPageBufferSize
+	}
+	if bufferSize := n.resendPageBufferSize(domainID); bufferSize > 0 {
+		return bufferSize
+	}
+	return defaultResendPageBufferSize
+}
+
+func (n *NDCHistoryResenderImpl) getResendFetchConcurrency(
+	domainID string,
+) int {
+
+	if n.resendFetchConcurrency == nil {
+		return defaultResendFetchConcurrency
+	}
+	if concurrency := n.resendFetchConcurrency(domainID); concurrency > 0 {
+		return concurrency
+	}
+	return defaultResendFetchConcurrency
+}
+
+func (n *NDCHistoryResenderImpl) getResendPageSize(
+	domainID string,
+) int32 {
+
+	if n.resendPageSize == nil {
+		return n.defaultPageSize
+	}
+	if pageSize := n.resendPageSize(domainID); pageSize > 0 {
+		return int32(pageSize)
+	}
+	return n.defaultPageSize
+}
+
+// getAdaptivePageSize returns the page size of the next page of the pagination, adjusted to the target page bytes
+// by the average size of the event batches fetched so far if configured, or the configured page size otherwise
+func (n *NDCHistoryResenderImpl) getAdaptivePageSize(
+	domainID string,
+	pageSizer *adaptivePageSizer,
+) int32 {
+
+	pageSize := n.getResendPageSize(domainID)
+	if n.targetPageBytes == nil {
+		return pageSize
+	}
+	targetPageBytes := n.targetPageBytes(domainID)
+	if targetPageBytes <= 0 {
+		return pageSize
+	}
+	minPageSize := defaultMinAdaptivePageSize
+	if n.minAdaptivePageSize != nil && n.minAdaptivePageSize(domainID) > 0 {
+		minPageSize = n.minAdaptivePageSize(domainID)
+	}
+	maxPageSize := defaultMaxAdaptivePageSize
+	if n.maxAdaptivePageSize != nil && n.maxAdaptivePageSize(domainID) > 0 {
+		maxPageSize = n.maxAdaptivePageSize(domainID)
+	}
+	return pageSizer.pageSize(pageSize, int64(targetPageBytes), int32(minPageSize), int32(maxPageSize))
+}
+
+func (n *NDCHistoryResenderImpl) getResendConcurrency(
+	domainID string,
+) int {
+
+	if n.resendConcurrency == nil {
+		return defaultResendConcurrency
+	}
+	if concurrency := n.resendConcurrency(domainID); concurrency > 0 {
+		return concurrency
 	}
+	return defaultResendConcurrency
 }
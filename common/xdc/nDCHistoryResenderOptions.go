@@ -0,0 +1,645 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"math/rand"
+
+	"github.com/opentracing/opentracing-go"
+
+	adminClient "github.com/uber/cadence/client/admin"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/archiver/provider"
+	"github.com/uber/cadence/common/backoff"
+	"github.com/uber/cadence/common/clock"
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/service/dynamicconfig"
+)
+
+// WithAdditionalHistoryReplicationFns sets the history replication functions the history events are delivered to
+// in addition to the one the resender is created with, the events are delivered to the functions in order
+func WithAdditionalHistoryReplicationFns(
+	historyReplicationFns ...nDCHistoryReplicationFn,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.historyReplicationFns = append(n.historyReplicationFns, historyReplicationFns...)
+	}
+}
+
+// WithResendConcurrency sets the max number of runs of a domain resent concurrently by SendMultiWorkflowHistory
+func WithResendConcurrency(
+	resendConcurrency dynamicconfig.IntPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.resendConcurrency = resendConcurrency
+	}
+}
+
+// WithMultiResendRetryBudget sets the max number of retries shared by all the runs of a SendMultiWorkflowHistory call,
+// the retryable failures are returned immediately once the budget is exhausted. the retries are unlimited if not set
+func WithMultiResendRetryBudget(
+	retryBudget dynamicconfig.IntPropertyFn,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.multiResendRetryBudget = retryBudget
+	}
+}
+
+// WithResendPageSize sets the page size used when fetching history events from remote,
+// the value is read for each page so it can be tuned while a resend is in progress
+func WithResendPageSize(
+	resendPageSize dynamicconfig.IntPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.resendPageSize = resendPageSize
+	}
+}
+
+// WithDefaultPageSize sets the page size used when fetching history events from remote if the page size
+// set by WithResendPageSize is not positive for the domain or is not set at all, the dynamic config page size
+// of the domain always takes precedence. The page size should be within (0, common.GetHistoryMaxPageSize],
+// the page size above the range is logged and clamped to it, 100 is used if not set or not positive
+func WithDefaultPageSize(
+	pageSize int32,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		clampedPageSize := pageSize
+		if clampedPageSize <= 0 {
+			clampedPageSize = defaultPageSize
+		} else if clampedPageSize > common.GetHistoryMaxPageSize {
+			clampedPageSize = common.GetHistoryMaxPageSize
+		}
+		if clampedPageSize != pageSize {
+			n.logger.Warn("invalid default page size of NDC history resender is replaced",
+				tag.ResendPageSize(pageSize),
+				tag.DefaultValue(clampedPageSize))
+		}
+		n.defaultPageSize = clampedPageSize
+	}
+}
+
+// WithResendPageBufferSize sets the max number of pages of history events held in memory during a resend,
+// a size larger than 1 allows the subsequent pages to be fetched from remote while the current page is being sent,
+// 1 is used if not set
+func WithResendPageBufferSize(
+	resendPageBufferSize dynamicconfig.IntPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.resendPageBufferSize = resendPageBufferSize
+	}
+}
+
+// WithResendFetchConcurrency sets the max number of pages of history events fetched concurrently during a resend,
+// the pages are still replicated one after another in the order of the events, and up to that many pages are
+// held in memory, the page buffer size is not used if the concurrency is larger than 1, 1 is used if not set
+func WithResendFetchConcurrency(
+	resendFetchConcurrency dynamicconfig.IntPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.resendFetchConcurrency = resendFetchConcurrency
+	}
+}
+
+// WithReverseFetch sets whether the event batches of a run are fetched newest-first by FetchWorkflowHistory,
+// so the latest events can be inspected or exported before the older ones. As the history cannot be paginated
+// backwards, the whole history of the run is held in memory, bounded by the max resend bytes and the max pages.
+// The resends are not affected and always replicate the events oldest-first, as the NDC replication of the history
+// service cannot apply the events out of order. The event batches are fetched oldest-first if not set
+func WithReverseFetch(
+	reverse dynamicconfig.BoolPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.reverseFetch = reverse
+	}
+}
+
+// WithAdminHeadersProvider sets the provider of the headers attached to each call to the admin service,
+// it is invoked per call, and the headers are carried by the context passed to the HistoryFetcher,
+// no extra header is attached if not set
+func WithAdminHeadersProvider(
+	provider AdminHeadersProvider,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.adminHeadersProvider = provider
+	}
+}
+
+// WithAuditSink sets the sink writing each event batch before it is sent to remote, and whether the error of the sink
+// fails the resend, the error is logged and ignored if not set. The batches are not audited if the sink is not set
+func WithAuditSink(
+	sink AuditSink,
+	failOnAuditError dynamicconfig.BoolPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.auditSink = sink
+		n.failOnAuditError = failOnAuditError
+	}
+}
+
+// WithVerifyConcurrency sets the max number of event batches of a run compared concurrently by VerifyWorkflowHistory,
+// 1 is used if not set
+func WithVerifyConcurrency(
+	verifyConcurrency dynamicconfig.IntPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.verifyConcurrency = verifyConcurrency
+	}
+}
+
+// WithGetHistoryRetryPolicy sets the retry policy used when fetching history events from remote fails with retryable errors,
+// nil retry policy disables the retry
+func WithGetHistoryRetryPolicy(
+	retryPolicy backoff.RetryPolicy,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.getHistoryRetryPolicy = retryPolicy
+	}
+}
+
+// WithHistoryFetcher sets the source of the history events to resend,
+// the events are fetched from the source cluster via the admin client if not set
+func WithHistoryFetcher(
+	historyFetcher HistoryFetcher,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.historyFetcher = historyFetcher
+	}
+}
+
+// WithArchivalFallback sets the archiver provider used to read the history events from the archival storage,
+// if the run does not exist in the source cluster before anything is sent and the fallback is enabled for the domain.
+// the resend is skipped as before if the archived history is not available
+func WithArchivalFallback(
+	archiverProvider provider.ArchiverProvider,
+	archivalFallback dynamicconfig.BoolPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.archiverProvider = archiverProvider
+		n.archivalFallback = archivalFallback
+	}
+}
+
+// WithErrorClassifier sets the classifier of the errors of the source and the target clusters,
+// the default classifier is used if not set
+func WithErrorClassifier(
+	errorClassifier ErrorClassifier,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.errorClassifier = errorClassifier
+	}
+}
+
+// WithReplicationRetryPolicy sets the retry policy used when replicating a batch to the target fails
+// with service busy error, nil retry policy disables the retry. The resender is the only layer retrying
+// the service busy error of the target, so the history replication function should not retry it again
+func WithReplicationRetryPolicy(
+	retryPolicy backoff.RetryPolicy,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.replicationRetryPolicy = retryPolicy
+	}
+}
+
+// WithSourceCluster sets the name of the cluster history events are resent from,
+// by default the active cluster of the domain is considered as the source cluster
+func WithSourceCluster(
+	sourceCluster string,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.sourceCluster = sourceCluster
+	}
+}
+
+// WithSourceAdminClients sets the admin clients of the clusters keyed by the cluster name, the one of the source cluster
+// of the domain is picked per call, so the resender follows the failovers of the domain, the calls fail with
+// SourceAdminClientNotFoundError if the source cluster is not in the map. The history events are fetched via the
+// picked admin client unless the history fetcher is set by a later option.
+// the admin client of the resender is used for all the domains if not set
+func WithSourceAdminClients(
+	adminClients map[string]adminClient.Client,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.sourceAdminClients = adminClients
+		// the history fetcher is picked per resend, see getHistoryFetcher
+		n.historyFetcher = nil
+	}
+}
+
+// WithBatchCallback sets the callback invoked after each event batch is successfully sent to remote,
+// the callback is not invoked for failed batches or by EstimateResend
+func WithBatchCallback(
+	batchCallback ResendBatchCallback,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.batchCallback = batchCallback
+	}
+}
+
+// WithTargetProgressChecker sets the checker used to skip the event batches already present on the target,
+// all event batches are sent if the checker fails
+func WithTargetProgressChecker(
+	checker TargetProgressChecker,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.targetProgressChecker = checker
+	}
+}
+
+// WithResendLimiter sets the limiter of the resends, which is shared by all the resenders of the host
+// to limit the resends of the host as a whole, the resends are not limited if not set
+func WithResendLimiter(
+	limiter *ResendLimiter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.limiter = limiter
+	}
+}
+
+// WithBatchDelay sets the delay between the event batches sent to the target, to pace the resend,
+// 0 disables the delay, the delay is disabled if not set
+func WithBatchDelay(
+	batchDelay dynamicconfig.DurationPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.batchDelay = batchDelay
+	}
+}
+
+// WithEventBlobEncoding sets the encoding of the event batches sent to the target, the batches
+// read from the source in a different encoding are transcoded, empty encoding forwards the batches as is.
+// The batches are forwarded as is if not set
+func WithEventBlobEncoding(
+	eventBlobEncoding dynamicconfig.StringPropertyFnWithDomainFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.eventBlobEncoding = eventBlobEncoding
+	}
+}
+
+// WithDomainPriority sets the name of the ResendPriority of the resends of a domain, which is high, normal or low,
+// the priority carried by the context of the resend takes precedence, normal is used if not set
+func WithDomainPriority(
+	domainPriority dynamicconfig.StringPropertyFnWithDomainFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.domainPriority = domainPriority
+	}
+}
+
+// WithLargeBatchThreshold sets the size in bytes from which the event batches are reported as large by a warning
+// and a metric before being sent, 0 disables the report, the report is disabled if not set
+func WithLargeBatchThreshold(
+	largeBatchThreshold dynamicconfig.IntPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.largeBatchThreshold = largeBatchThreshold
+	}
+}
+
+// WithMaxBatchSize sets the max size in bytes of the event batches, ErrBatchTooLarge is returned
+// instead of sending larger batches, 0 means unlimited, there is no limit if not set
+func WithMaxBatchSize(
+	maxBatchSize dynamicconfig.IntPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.maxBatchSize = maxBatchSize
+	}
+}
+
+// WithSplitBatchSize sets the size in bytes from which the event batches are split at the event boundaries into
+// multiple replication requests of contiguous events, each within the size unless it has a single larger event,
+// e.g. for the targets with strict request size limits. 0 disables the split, the batches are not split if not set
+func WithSplitBatchSize(
+	splitBatchSize dynamicconfig.IntPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.splitBatchSize = splitBatchSize
+	}
+}
+
+// WithTargetDomainMapper sets the mapper translating the source domain into the domain of the target
+// to replicate the history events into, the events are replicated into the source domain if not set
+func WithTargetDomainMapper(
+	mapper TargetDomainMapper,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.targetDomainMapper = mapper
+	}
+}
+
+// WithCircuitBreaker sets the number of consecutive failures after which the resends of a domain
+// fail fast with ErrResendCircuitOpen, until the cooldown elapses. 0 threshold disables the circuit breaker,
+// the circuit breaker is disabled if not set
+func WithCircuitBreaker(
+	threshold dynamicconfig.IntPropertyFnWithDomainIDFilter,
+	cooldown dynamicconfig.DurationPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.circuitBreaker = newResendCircuitBreaker(threshold, cooldown)
+	}
+}
+
+// WithAsyncCurrentExecutionFix sets the number of workers checking and fixing the current execution records
+// in background and the size of their queue. When the workflow does not exist in the target cluster, the fix is queued
+// and the resend fails with ErrCurrentExecutionFixPending until the fix is done, the retried resend gets the result
+// of the fix. The fix is done synchronously if the queue is full, or if not set
+func WithAsyncCurrentExecutionFix(
+	workerCount dynamicconfig.IntPropertyFn,
+	queueSize dynamicconfig.IntPropertyFn,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.currentExecutionFixer.workerCount = workerCount
+		n.currentExecutionFixer.queueSize = queueSize
+	}
+}
+
+// WithSkippedRunCache sets the max number of the skipped runs remembered and for how long,
+// resends of a remembered run fail with SkipTaskError without reaching the source cluster.
+// the skipped runs are not remembered if not set, or if either of them is not positive
+func WithSkippedRunCache(
+	maxCount dynamicconfig.IntPropertyFn,
+	ttl dynamicconfig.DurationPropertyFn,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.skippedRuns.maxCount = maxCount
+		n.skippedRuns.ttl = ttl
+	}
+}
+
+// WithCurrentExecutionStates sets the workflow states the current execution is checked against, in order,
+// when the run does not exist in remote. only the running state is checked if not set
+func WithCurrentExecutionStates(
+	states ...int,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.currentExecutionFixer.states = states
+	}
+}
+
+// WithCurrentExecutionFixDisabled sets whether the current execution is left unchecked when the run
+// does not exist in remote, so the EntityNotExistsError is returned as is instead of a SkipTaskError,
+// e.g. for an external repair pipeline to handle. The current execution is checked if not set
+func WithCurrentExecutionFixDisabled(
+	disabled dynamicconfig.BoolPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.currentExecutionFixer.disabled = disabled
+	}
+}
+
+// WithStrictVersionHistoryValidation sets whether the version history of each event batch is validated before being sent,
+// batches with version history items not in increasing order or not ending with the requested end event version are rejected
+func WithStrictVersionHistoryValidation(
+	enabled dynamicconfig.BoolPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.strictVersionHistoryValidation = enabled
+	}
+}
+
+// WithEventBatchValidation sets whether each event batch is verified to deserialize into
+// well formed history events before being sent to remote
+func WithEventBatchValidation(
+	validation dynamicconfig.BoolPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.eventBatchValidation = validation
+	}
+}
+
+// WithTrimBatchToEndEvent sets whether the event batch containing the end event is trimmed, so the events
+// from the end event onwards are not sent to remote. The batches ending before the end event are sent as is
+func WithTrimBatchToEndEvent(
+	trim dynamicconfig.BoolPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.trimBatchToEndEvent = trim
+	}
+}
+
+// WithSkipInvalidEventBatch sets whether the event batches failing the validation are skipped,
+// instead of failing the resend
+func WithSkipInvalidEventBatch(
+	skip dynamicconfig.BoolPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.skipInvalidEventBatch = skip
+	}
+}
+
+// WithFailOnHistoryGap sets whether a gap between the event IDs of consecutive event batches fails the resend
+// with HistoryGapError, the gaps are always logged and metered, the resend continues if not set
+func WithFailOnHistoryGap(
+	fail dynamicconfig.BoolPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.failOnHistoryGap = fail
+	}
+}
+
+// WithSkipEmptyVersionHistory sets whether the event batches returned with an empty version history are skipped,
+// instead of failing the resend
+func WithSkipEmptyVersionHistory(
+	skip dynamicconfig.BoolPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.skipEmptyVersionHistory = skip
+	}
+}
+
+// WithRereplicationTimeoutJitter sets the jitter coefficient of the rereplication timeout,
+// the timeout of each resend is randomly picked from (1-coefficient)*timeout to (1+coefficient)*timeout
+func WithRereplicationTimeoutJitter(
+	coefficient dynamicconfig.FloatPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.rereplicationTimeoutJitter = coefficient
+	}
+}
+
+// WithRandSource sets the source of the random numbers jittering the rereplication timeout,
+// e.g. a fixed seed for deterministic tests, a source seeded by the current time is used if not set
+func WithRandSource(
+	source rand.Source,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.rand = rand.New(source)
+	}
+}
+
+// WithMaxTimeoutOverride sets the max timeout a resend can be given by ResendDescriptor.TimeoutOverride,
+// larger overrides are capped to it, 30m is used if not set
+func WithMaxTimeoutOverride(
+	maxTimeoutOverride dynamicconfig.DurationPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.maxTimeoutOverride = maxTimeoutOverride
+	}
+}
+
+// WithMaxResendBytes sets the max total size of history events sent by a single run resend,
+// non-positive value means unlimited
+func WithMaxResendBytes(
+	maxResendBytes dynamicconfig.IntPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.maxResendBytes = maxResendBytes
+	}
+}
+
+// WithMaxPages sets the max number of history pages fetched by a single run resend, which fails with
+// TooManyPagesError once the history spans more pages, so a misbehaving source cannot hang the resend.
+// the number of pages is unlimited if not set
+func WithMaxPages(
+	maxPages dynamicconfig.IntPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.maxPages = maxPages
+	}
+}
+
+// WithQuietExpectedErrors sets whether the expected errors of the resends of a domain, i.e. the runs missing in
+// either cluster and the skipped tasks, are logged at Debug level instead, e.g. during a planned cleanup.
+// the value is read once for each resend. the expected errors are logged as the other errors if not set
+func WithQuietExpectedErrors(
+	quietExpectedErrors dynamicconfig.BoolPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.quietExpectedErrors = quietExpectedErrors
+	}
+}
+
+// WithAdaptivePageSize sets the target size in bytes of each history page fetched from remote, the page size of
+// the next page is adjusted to the target by the average size of the event batches fetched so far, within the bounds
+// of the min and max page size. the configured page size is used for the first page, and for all the pages if the
+// target is not positive. the min and max page size default to 1 and 1000 if not set
+func WithAdaptivePageSize(
+	targetPageBytes dynamicconfig.IntPropertyFnWithDomainIDFilter,
+	minPageSize dynamicconfig.IntPropertyFnWithDomainIDFilter,
+	maxPageSize dynamicconfig.IntPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.targetPageBytes = targetPageBytes
+		n.minAdaptivePageSize = minPageSize
+		n.maxAdaptivePageSize = maxPageSize
+	}
+}
+
+// WithTargetShardCount sets the number of history shards of the target, which bounds the target shard IDs
+// carried by the contexts of the resends. the target shard IDs are only checked to be non-negative if not set
+func WithTargetShardCount(
+	shardCount int,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.targetShardCount = shardCount
+	}
+}
+
+// WithGetHistoryTimeout sets the timeout of each call fetching history events from remote,
+// 30s is used if not set
+func WithGetHistoryTimeout(
+	timeout dynamicconfig.DurationPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.getHistoryTimeout = timeout
+	}
+}
+
+// WithReplicationTimeout sets the timeout of each call delivering history events to a history replication function,
+// 30s is used if not set
+func WithReplicationTimeout(
+	timeout dynamicconfig.DurationPropertyFnWithDomainIDFilter,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.replicationTimeout = timeout
+	}
+}
+
+// WithTracer sets the tracer used to create spans around resend operations,
+// a noop tracer is used if not set
+func WithTracer(
+	tracer opentracing.Tracer,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.tracer = tracer
+	}
+}
+
+// WithTimeSource sets the time source measuring the timeouts, the circuit breaker cooldown and the replication lag,
+// the real time source is used if not set
+func WithTimeSource(
+	timeSource clock.TimeSource,
+) NDCHistoryResenderOption {
+
+	return func(n *NDCHistoryResenderImpl) {
+		n.timeSource = timeSource
+	}
+}
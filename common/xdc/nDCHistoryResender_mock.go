@@ -28,9 +28,14 @@
 package xdc
 
 import (
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
+
+	admin "github.com/uber/cadence/.gen/go/admin"
+	shared "github.com/uber/cadence/.gen/go/shared"
 )
 
 // MockNDCHistoryResender is a mock of NDCHistoryResender interface
@@ -57,15 +62,449 @@ func (m *MockNDCHistoryResender) EXPECT() *MockNDCHistoryResenderMockRecorder {
 }
 
 // SendSingleWorkflowHistory mocks base method
-func (m *MockNDCHistoryResender) SendSingleWorkflowHistory(domainID, workflowID, runID string, startEventID, startEventVersion, endEventID, endEventVersion *int64) error {
+func (m *MockNDCHistoryResender) SendSingleWorkflowHistory(ctx context.Context, domainID, workflowID, runID string, startEventID, startEventVersion, endEventID, endEventVersion *int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SendSingleWorkflowHistory", domainID, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion)
+	ret := m.ctrl.Call(m, "SendSingleWorkflowHistory", ctx, domainID, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SendSingleWorkflowHistory indicates an expected call of SendSingleWorkflowHistory
-func (mr *MockNDCHistoryResenderMockRecorder) SendSingleWorkflowHistory(domainID, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion interface{}) *gomock.Call {
+func (mr *MockNDCHistoryResenderMockRecorder) SendSingleWorkflowHistory(ctx, domainID, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendSingleWorkflowHistory", reflect.TypeOf((*MockNDCHistoryResender)(nil).SendSingleWorkflowHistory), ctx, domainID, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion)
+}
+
+// SendSingleWorkflowHistoryByDomainName mocks base method
+func (m *MockNDCHistoryResender) SendSingleWorkflowHistoryByDomainName(ctx context.Context, domainName, workflowID, runID string, startEventID, startEventVersion, endEventID, endEventVersion *int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendSingleWorkflowHistoryByDomainName", ctx, domainName, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendSingleWorkflowHistoryByDomainName indicates an expected call of SendSingleWorkflowHistoryByDomainName
+func (mr *MockNDCHistoryResenderMockRecorder) SendSingleWorkflowHistoryByDomainName(ctx, domainName, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendSingleWorkflowHistoryByDomainName", reflect.TypeOf((*MockNDCHistoryResender)(nil).SendSingleWorkflowHistoryByDomainName), ctx, domainName, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion)
+}
+
+// SendSingleWorkflowHistoryWithResult mocks base method
+func (m *MockNDCHistoryResender) SendSingleWorkflowHistoryWithResult(ctx context.Context, domainID, workflowID, runID string, startEventID, startEventVersion, endEventID, endEventVersion *int64) (*ResendResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendSingleWorkflowHistoryWithResult", ctx, domainID, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion)
+	ret0, _ := ret[0].(*ResendResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendSingleWorkflowHistoryWithResult indicates an expected call of SendSingleWorkflowHistoryWithResult
+func (mr *MockNDCHistoryResenderMockRecorder) SendSingleWorkflowHistoryWithResult(ctx, domainID, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendSingleWorkflowHistoryWithResult", reflect.TypeOf((*MockNDCHistoryResender)(nil).SendSingleWorkflowHistoryWithResult), ctx, domainID, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion)
+}
+
+// StreamSingleWorkflowHistory mocks base method
+func (m *MockNDCHistoryResender) StreamSingleWorkflowHistory(ctx context.Context, domainID, workflowID, runID string, startEventID, startEventVersion, endEventID, endEventVersion *int64) (<-chan ResendProgress, <-chan error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamSingleWorkflowHistory", ctx, domainID, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion)
+	ret0, _ := ret[0].(<-chan ResendProgress)
+	ret1, _ := ret[1].(<-chan error)
+	return ret0, ret1
+}
+
+// StreamSingleWorkflowHistory indicates an expected call of StreamSingleWorkflowHistory
+func (mr *MockNDCHistoryResenderMockRecorder) StreamSingleWorkflowHistory(ctx, domainID, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamSingleWorkflowHistory", reflect.TypeOf((*MockNDCHistoryResender)(nil).StreamSingleWorkflowHistory), ctx, domainID, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion)
+}
+
+// ResendWorkflowHistory mocks base method
+func (m *MockNDCHistoryResender) ResendWorkflowHistory(ctx context.Context, descriptor *ResendDescriptor) (*ResendResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResendWorkflowHistory", ctx, descriptor)
+	ret0, _ := ret[0].(*ResendResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResendWorkflowHistory indicates an expected call of ResendWorkflowHistory
+func (mr *MockNDCHistoryResenderMockRecorder) ResendWorkflowHistory(ctx, descriptor interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResendWorkflowHistory", reflect.TypeOf((*MockNDCHistoryResender)(nil).ResendWorkflowHistory), ctx, descriptor)
+}
+
+// EstimateResend mocks base method
+func (m *MockNDCHistoryResender) EstimateResend(ctx context.Context, descriptor *ResendDescriptor) (*ResendResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EstimateResend", ctx, descriptor)
+	ret0, _ := ret[0].(*ResendResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EstimateResend indicates an expected call of EstimateResend
+func (mr *MockNDCHistoryResenderMockRecorder) EstimateResend(ctx, descriptor interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EstimateResend", reflect.TypeOf((*MockNDCHistoryResender)(nil).EstimateResend), ctx, descriptor)
+}
+
+// EstimateBulkResend mocks base method
+func (m *MockNDCHistoryResender) EstimateBulkResend(ctx context.Context, descriptors []*ResendDescriptor) (*BulkEstimateResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EstimateBulkResend", ctx, descriptors)
+	ret0, _ := ret[0].(*BulkEstimateResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EstimateBulkResend indicates an expected call of EstimateBulkResend
+func (mr *MockNDCHistoryResenderMockRecorder) EstimateBulkResend(ctx, descriptors interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EstimateBulkResend", reflect.TypeOf((*MockNDCHistoryResender)(nil).EstimateBulkResend), ctx, descriptors)
+}
+
+// SendMissingHistory mocks base method
+func (m *MockNDCHistoryResender) SendMissingHistory(ctx context.Context, domainID, workflowID, runID string, targetLastEventID, targetLastEventVersion int64) (*ResendResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendMissingHistory", ctx, domainID, workflowID, runID, targetLastEventID, targetLastEventVersion)
+	ret0, _ := ret[0].(*ResendResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendMissingHistory indicates an expected call of SendMissingHistory
+func (mr *MockNDCHistoryResenderMockRecorder) SendMissingHistory(ctx, domainID, workflowID, runID, targetLastEventID, targetLastEventVersion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMissingHistory", reflect.TypeOf((*MockNDCHistoryResender)(nil).SendMissingHistory), ctx, domainID, workflowID, runID, targetLastEventID, targetLastEventVersion)
+}
+
+// SendWorkflowHistoryByRange mocks base method
+func (m *MockNDCHistoryResender) SendWorkflowHistoryByRange(ctx context.Context, domainID, workflowID, runID string, startEventID, endEventID *int64) (*ResendResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendWorkflowHistoryByRange", ctx, domainID, workflowID, runID, startEventID, endEventID)
+	ret0, _ := ret[0].(*ResendResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendWorkflowHistoryByRange indicates an expected call of SendWorkflowHistoryByRange
+func (mr *MockNDCHistoryResenderMockRecorder) SendWorkflowHistoryByRange(ctx, domainID, workflowID, runID, startEventID, endEventID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendWorkflowHistoryByRange", reflect.TypeOf((*MockNDCHistoryResender)(nil).SendWorkflowHistoryByRange), ctx, domainID, workflowID, runID, startEventID, endEventID)
+}
+
+// SendWorkflowHistoryWindow mocks base method
+func (m *MockNDCHistoryResender) SendWorkflowHistoryWindow(ctx context.Context, domainID, workflowID, runID string, centerEventID, radius int64) (*ResendResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendWorkflowHistoryWindow", ctx, domainID, workflowID, runID, centerEventID, radius)
+	ret0, _ := ret[0].(*ResendResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendWorkflowHistoryWindow indicates an expected call of SendWorkflowHistoryWindow
+func (mr *MockNDCHistoryResenderMockRecorder) SendWorkflowHistoryWindow(ctx, domainID, workflowID, runID, centerEventID, radius interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendWorkflowHistoryWindow", reflect.TypeOf((*MockNDCHistoryResender)(nil).SendWorkflowHistoryWindow), ctx, domainID, workflowID, runID, centerEventID, radius)
+}
+
+// FetchWorkflowHistory mocks base method
+func (m *MockNDCHistoryResender) FetchWorkflowHistory(ctx context.Context, domainID, workflowID, runID string, startEventID, startEventVersion, endEventID, endEventVersion *int64, eventTypes []shared.EventType, callback FetchBatchCallback) ([]*FetchedEventBatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchWorkflowHistory", ctx, domainID, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion, eventTypes, callback)
+	ret0, _ := ret[0].([]*FetchedEventBatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchWorkflowHistory indicates an expected call of FetchWorkflowHistory
+func (mr *MockNDCHistoryResenderMockRecorder) FetchWorkflowHistory(ctx, domainID, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion, eventTypes, callback interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchWorkflowHistory", reflect.TypeOf((*MockNDCHistoryResender)(nil).FetchWorkflowHistory), ctx, domainID, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion, eventTypes, callback)
+}
+
+// PartitionWorkflowHistory mocks base method
+func (m *MockNDCHistoryResender) PartitionWorkflowHistory(ctx context.Context, domainID, workflowID, runID string, startEventID, startEventVersion, endEventID, endEventVersion *int64, callback PartitionBatchCallback) ([]*FetchedEventBatch, []*FetchedEventBatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PartitionWorkflowHistory", ctx, domainID, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion, callback)
+	ret0, _ := ret[0].([]*FetchedEventBatch)
+	ret1, _ := ret[1].([]*FetchedEventBatch)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// PartitionWorkflowHistory indicates an expected call of PartitionWorkflowHistory
+func (mr *MockNDCHistoryResenderMockRecorder) PartitionWorkflowHistory(ctx, domainID, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion, callback interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PartitionWorkflowHistory", reflect.TypeOf((*MockNDCHistoryResender)(nil).PartitionWorkflowHistory), ctx, domainID, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion, callback)
+}
+
+// VerifyWorkflowHistory mocks base method
+func (m *MockNDCHistoryResender) VerifyWorkflowHistory(ctx context.Context, domainID, workflowID, runID string, targetReader TargetHistoryReader) (*VerificationReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyWorkflowHistory", ctx, domainID, workflowID, runID, targetReader)
+	ret0, _ := ret[0].(*VerificationReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyWorkflowHistory indicates an expected call of VerifyWorkflowHistory
+func (mr *MockNDCHistoryResenderMockRecorder) VerifyWorkflowHistory(ctx, domainID, workflowID, runID, targetReader interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyWorkflowHistory", reflect.TypeOf((*MockNDCHistoryResender)(nil).VerifyWorkflowHistory), ctx, domainID, workflowID, runID, targetReader)
+}
+
+// SendMultiWorkflowHistory mocks base method
+func (m *MockNDCHistoryResender) SendMultiWorkflowHistory(ctx context.Context, descriptors []*ResendDescriptor) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendMultiWorkflowHistory", ctx, descriptors)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendMultiWorkflowHistory indicates an expected call of SendMultiWorkflowHistory
+func (mr *MockNDCHistoryResenderMockRecorder) SendMultiWorkflowHistory(ctx, descriptors interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMultiWorkflowHistory", reflect.TypeOf((*MockNDCHistoryResender)(nil).SendMultiWorkflowHistory), ctx, descriptors)
+}
+
+// Ping mocks base method
+func (m *MockNDCHistoryResender) Ping(ctx context.Context, domainID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx, domainID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping
+func (mr *MockNDCHistoryResenderMockRecorder) Ping(ctx, domainID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockNDCHistoryResender)(nil).Ping), ctx, domainID)
+}
+
+// Validate mocks base method
+func (m *MockNDCHistoryResender) Validate(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Validate", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Validate indicates an expected call of Validate
+func (mr *MockNDCHistoryResenderMockRecorder) Validate(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Validate", reflect.TypeOf((*MockNDCHistoryResender)(nil).Validate), ctx)
+}
+
+// EstimateReplicationLag mocks base method
+func (m *MockNDCHistoryResender) EstimateReplicationLag(ctx context.Context, domainID, workflowID, runID string) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EstimateReplicationLag", ctx, domainID, workflowID, runID)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EstimateReplicationLag indicates an expected call of EstimateReplicationLag
+func (mr *MockNDCHistoryResenderMockRecorder) EstimateReplicationLag(ctx, domainID, workflowID, runID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EstimateReplicationLag", reflect.TypeOf((*MockNDCHistoryResender)(nil).EstimateReplicationLag), ctx, domainID, workflowID, runID)
+}
+
+// GetSourceVersionHistories mocks base method
+func (m *MockNDCHistoryResender) GetSourceVersionHistories(ctx context.Context, domainID, workflowID, runID string) (*shared.VersionHistories, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSourceVersionHistories", ctx, domainID, workflowID, runID)
+	ret0, _ := ret[0].(*shared.VersionHistories)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSourceVersionHistories indicates an expected call of GetSourceVersionHistories
+func (mr *MockNDCHistoryResenderMockRecorder) GetSourceVersionHistories(ctx, domainID, workflowID, runID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSourceVersionHistories", reflect.TypeOf((*MockNDCHistoryResender)(nil).GetSourceVersionHistories), ctx, domainID, workflowID, runID)
+}
+
+// SendWorkflowChain mocks base method
+func (m *MockNDCHistoryResender) SendWorkflowChain(ctx context.Context, domainID, workflowID, startRunID string) ([]*WorkflowChainResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendWorkflowChain", ctx, domainID, workflowID, startRunID)
+	ret0, _ := ret[0].([]*WorkflowChainResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendWorkflowChain indicates an expected call of SendWorkflowChain
+func (mr *MockNDCHistoryResenderMockRecorder) SendWorkflowChain(ctx, domainID, workflowID, startRunID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendWorkflowChain", reflect.TypeOf((*MockNDCHistoryResender)(nil).SendWorkflowChain), ctx, domainID, workflowID, startRunID)
+}
+
+// Stats mocks base method
+func (m *MockNDCHistoryResender) Stats() ResendStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(ResendStats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats
+func (mr *MockNDCHistoryResenderMockRecorder) Stats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockNDCHistoryResender)(nil).Stats))
+}
+
+// CancelResend mocks base method
+func (m *MockNDCHistoryResender) CancelResend(domainID, workflowID, runID string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelResend", domainID, workflowID, runID)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// CancelResend indicates an expected call of CancelResend
+func (mr *MockNDCHistoryResenderMockRecorder) CancelResend(domainID, workflowID, runID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelResend", reflect.TypeOf((*MockNDCHistoryResender)(nil).CancelResend), domainID, workflowID, runID)
+}
+
+// InFlight mocks base method
+func (m *MockNDCHistoryResender) InFlight() []*InFlightResendInfo {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InFlight")
+	ret0, _ := ret[0].([]*InFlightResendInfo)
+	return ret0
+}
+
+// InFlight indicates an expected call of InFlight
+func (mr *MockNDCHistoryResenderMockRecorder) InFlight() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InFlight", reflect.TypeOf((*MockNDCHistoryResender)(nil).InFlight))
+}
+
+// Close mocks base method
+func (m *MockNDCHistoryResender) Close() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Close")
+}
+
+// Close indicates an expected call of Close
+func (mr *MockNDCHistoryResenderMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockNDCHistoryResender)(nil).Close))
+}
+
+// MockNDCHistoryResenderDiagnostics is a mock of NDCHistoryResenderDiagnostics interface
+type MockNDCHistoryResenderDiagnostics struct {
+	ctrl     *gomock.Controller
+	recorder *MockNDCHistoryResenderDiagnosticsMockRecorder
+}
+
+// MockNDCHistoryResenderDiagnosticsMockRecorder is the mock recorder for MockNDCHistoryResenderDiagnostics
+type MockNDCHistoryResenderDiagnosticsMockRecorder struct {
+	mock *MockNDCHistoryResenderDiagnostics
+}
+
+// NewMockNDCHistoryResenderDiagnostics creates a new mock instance
+func NewMockNDCHistoryResenderDiagnostics(ctrl *gomock.Controller) *MockNDCHistoryResenderDiagnostics {
+	mock := &MockNDCHistoryResenderDiagnostics{ctrl: ctrl}
+	mock.recorder = &MockNDCHistoryResenderDiagnosticsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockNDCHistoryResenderDiagnostics) EXPECT() *MockNDCHistoryResenderDiagnosticsMockRecorder {
+	return m.recorder
+}
+
+// ReplayPageTokens mocks base method
+func (m *MockNDCHistoryResenderDiagnostics) ReplayPageTokens(ctx context.Context, descriptor *ResendDescriptor, resumeTokens [][]byte) (*ResendResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplayPageTokens", ctx, descriptor, resumeTokens)
+	ret0, _ := ret[0].(*ResendResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplayPageTokens indicates an expected call of ReplayPageTokens
+func (mr *MockNDCHistoryResenderDiagnosticsMockRecorder) ReplayPageTokens(ctx, descriptor, resumeTokens interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplayPageTokens", reflect.TypeOf((*MockNDCHistoryResenderDiagnostics)(nil).ReplayPageTokens), ctx, descriptor, resumeTokens)
+}
+
+// MockHistoryFetcher is a mock of HistoryFetcher interface
+type MockHistoryFetcher struct {
+	ctrl     *gomock.Controller
+	recorder *MockHistoryFetcherMockRecorder
+}
+
+// MockHistoryFetcherMockRecorder is the mock recorder for MockHistoryFetcher
+type MockHistoryFetcherMockRecorder struct {
+	mock *MockHistoryFetcher
+}
+
+// NewMockHistoryFetcher creates a new mock instance
+func NewMockHistoryFetcher(ctrl *gomock.Controller) *MockHistoryFetcher {
+	mock := &MockHistoryFetcher{ctrl: ctrl}
+	mock.recorder = &MockHistoryFetcherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockHistoryFetcher) EXPECT() *MockHistoryFetcherMockRecorder {
+	return m.recorder
+}
+
+// GetRawHistory mocks base method
+func (m *MockHistoryFetcher) GetRawHistory(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRawHistory", ctx, request)
+	ret0, _ := ret[0].(*admin.GetWorkflowExecutionRawHistoryV2Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRawHistory indicates an expected call of GetRawHistory
+func (mr *MockHistoryFetcherMockRecorder) GetRawHistory(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRawHistory", reflect.TypeOf((*MockHistoryFetcher)(nil).GetRawHistory), ctx, request)
+}
+
+// MockAuditSink is a mock of AuditSink interface
+type MockAuditSink struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditSinkMockRecorder
+}
+
+// MockAuditSinkMockRecorder is the mock recorder for MockAuditSink
+type MockAuditSinkMockRecorder struct {
+	mock *MockAuditSink
+}
+
+// NewMockAuditSink creates a new mock instance
+func NewMockAuditSink(ctrl *gomock.Controller) *MockAuditSink {
+	mock := &MockAuditSink{ctrl: ctrl}
+	mock.recorder = &MockAuditSinkMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockAuditSink) EXPECT() *MockAuditSinkMockRecorder {
+	return m.recorder
+}
+
+// Write mocks base method
+func (m *MockAuditSink) Write(ctx context.Context, domainID, workflowID, runID string, batch *shared.DataBlob) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Write", ctx, domainID, workflowID, runID, batch)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Write indicates an expected call of Write
+func (mr *MockAuditSinkMockRecorder) Write(ctx, domainID, workflowID, runID, batch interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendSingleWorkflowHistory", reflect.TypeOf((*MockNDCHistoryResender)(nil).SendSingleWorkflowHistory), domainID, workflowID, runID, startEventID, startEventVersion, endEventID, endEventVersion)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Write", reflect.TypeOf((*MockAuditSink)(nil).Write), ctx, domainID, workflowID, runID, batch)
 }
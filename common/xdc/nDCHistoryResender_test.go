@@ -22,6 +22,10 @@ package xdc
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
 	"testing"
 	"time"
 
@@ -29,20 +33,33 @@ import (
 	"github.com/pborman/uuid"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+	"go.uber.org/multierr"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 
 	"github.com/uber/cadence/.gen/go/admin"
 	"github.com/uber/cadence/.gen/go/admin/adminservicetest"
 	"github.com/uber/cadence/.gen/go/history"
 	"github.com/uber/cadence/.gen/go/history/historyservicetest"
 	"github.com/uber/cadence/.gen/go/shared"
+	adminClient "github.com/uber/cadence/client/admin"
 	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/backoff"
 	"github.com/uber/cadence/common/cache"
+	"github.com/uber/cadence/common/clock"
 	"github.com/uber/cadence/common/cluster"
+	"github.com/uber/cadence/common/collection"
 	"github.com/uber/cadence/common/log"
 	"github.com/uber/cadence/common/log/loggerimpl"
+	"github.com/uber/cadence/common/metrics"
 	"github.com/uber/cadence/common/mocks"
 	"github.com/uber/cadence/common/persistence"
 	checks "github.com/uber/cadence/common/reconciliation/common"
+	"github.com/uber/cadence/common/service/dynamicconfig"
 )
 
 type (
@@ -55,15 +72,32 @@ type (
 		mockAdminClient   *adminservicetest.MockClient
 		mockHistoryClient *historyservicetest.MockClient
 
-		domainID   string
-		domainName string
+		domainID    string
+		domainName  string
+		domainEntry *cache.DomainCacheEntry
 
 		mockClusterMetadata *mocks.ClusterMetadata
 
-		serializer persistence.PayloadSerializer
-		logger     log.Logger
+		serializer    persistence.PayloadSerializer
+		metricsClient metrics.Client
+		logger        log.Logger
 
 		rereplicator *NDCHistoryResenderImpl
+		// resender and diagnostics are the interfaces of the rereplicator used by the callers
+		resender    NDCHistoryResender
+		diagnostics NDCHistoryResenderDiagnostics
+	}
+
+	testErrorClassifier struct {
+		ErrorClassifier
+		skippable func(err error) bool
+	}
+
+	// testDelayTimeSource is the fake time source which notifies the timers of the delay
+	testDelayTimeSource struct {
+		*clock.EventTimeSource
+		delay   time.Duration
+		delayCh chan struct{}
 	}
 )
 
@@ -72,6 +106,18 @@ func TestNDCHistoryResenderSuite(t *testing.T) {
 	suite.Run(t, s)
 }
 
+func (c *testErrorClassifier) IsSkippable(err error) bool {
+	return c.skippable(err)
+}
+
+func (ts *testDelayTimeSource) AfterFunc(d time.Duration, f func()) func() bool {
+	stop := ts.EventTimeSource.AfterFunc(d, f)
+	if d == ts.delay {
+		ts.delayCh <- struct{}{}
+	}
+	return stop
+}
+
 func (s *nDCHistoryResenderSuite) SetupSuite() {
 }
 
@@ -88,12 +134,13 @@ func (s *nDCHistoryResenderSuite) SetupTest() {
 	s.mockDomainCache = cache.NewMockDomainCache(s.controller)
 
 	s.logger = loggerimpl.NewDevelopmentForTest(s.Suite)
+	s.metricsClient = metrics.NewClient(tally.NoopScope, metrics.Common)
 	s.mockClusterMetadata = &mocks.ClusterMetadata{}
 	s.mockClusterMetadata.On("IsGlobalDomainEnabled").Return(true)
 
 	s.domainID = uuid.New()
 	s.domainName = "some random domain name"
-	domainEntry := cache.NewGlobalDomainCacheEntryForTest(
+	s.domainEntry = cache.NewGlobalDomainCacheEntryForTest(
 		&persistence.DomainInfo{ID: s.domainID, Name: s.domainName},
 		&persistence.DomainConfig{Retention: 1},
 		&persistence.DomainReplicationConfig{
@@ -106,11 +153,11 @@ func (s *nDCHistoryResenderSuite) SetupTest() {
 		1234,
 		nil,
 	)
-	s.mockDomainCache.EXPECT().GetDomainByID(s.domainID).Return(domainEntry, nil).AnyTimes()
-	s.mockDomainCache.EXPECT().GetDomain(s.domainName).Return(domainEntry, nil).AnyTimes()
+	s.mockDomainCache.EXPECT().GetDomainByID(s.domainID).Return(s.domainEntry, nil).AnyTimes()
+	s.mockDomainCache.EXPECT().GetDomain(s.domainName).Return(s.domainEntry, nil).AnyTimes()
 	s.serializer = persistence.NewPayloadSerializer()
 
-	s.rereplicator = NewNDCHistoryResender(
+	rereplicator, err := NewNDCHistoryResender(
 		s.mockDomainCache,
 		s.mockAdminClient,
 		func(ctx context.Context, request *history.ReplicateEventsV2Request) error {
@@ -119,8 +166,21 @@ func (s *nDCHistoryResenderSuite) SetupTest() {
 		persistence.NewPayloadSerializer(),
 		nil,
 		nil,
+		s.metricsClient,
 		s.logger,
 	)
+	s.NoError(err)
+	s.useRereplicator(rereplicator)
+}
+
+// useRereplicator sets the rereplicator tested through the interfaces used by the callers
+func (s *nDCHistoryResenderSuite) useRereplicator(
+	rereplicator *NDCHistoryResenderImpl,
+) {
+
+	s.rereplicator = rereplicator
+	s.resender = rereplicator
+	s.diagnostics = rereplicator
 }
 
 func (s *nDCHistoryResenderSuite) TearDownTest() {
@@ -208,7 +268,8 @@ func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory() {
 			Events:              blob,
 		}).Return(nil).Times(2)
 
-	err := s.rereplicator.SendSingleWorkflowHistory(
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
 		s.domainID,
 		workflowID,
 		runID,
@@ -221,181 +282,3952 @@ func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory() {
 	s.Nil(err)
 }
 
-func (s *nDCHistoryResenderSuite) TestCreateReplicateRawEventsRequest() {
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_EmptyNextPageToken() {
 	workflowID := "some random workflow ID"
 	runID := uuid.New()
-	blob := &shared.DataBlob{
-		EncodingType: shared.EncodingTypeThriftRW.Ptr(),
-		Data:         []byte("some random history blob"),
-	}
-	versionHistoryItems := []*shared.VersionHistoryItem{
+	blob := s.serializeEvents([]*shared.HistoryEvent{
 		{
-			EventID: common.Int64Ptr(1),
-			Version: common.Int64Ptr(1),
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
 		},
-	}
+	})
+	// the empty but non-nil token means the last page
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			NextPageToken:  []byte{},
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(1)
 
-	s.Equal(&history.ReplicateEventsV2Request{
-		DomainUUID: common.StringPtr(s.domainID),
-		WorkflowExecution: &shared.WorkflowExecution{
-			WorkflowId: common.StringPtr(workflowID),
-			RunId:      common.StringPtr(runID),
-		},
-		VersionHistoryItems: versionHistoryItems,
-		Events:              blob,
-	}, s.rereplicator.createReplicationRawRequest(
+	result, err := s.resender.SendSingleWorkflowHistoryWithResult(
+		context.Background(),
 		s.domainID,
 		workflowID,
 		runID,
-		blob,
-		versionHistoryItems))
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.NoError(err)
+	s.Equal(1, result.BatchCount)
+	s.Nil(result.NextPageToken)
+	s.Nil(result.GetResumeToken())
 }
 
-func (s *nDCHistoryResenderSuite) TestSendReplicationRawRequest() {
+func (s *nDCHistoryResenderSuite) TestFetchWorkflowHistory_Reverse() {
 	workflowID := "some random workflow ID"
 	runID := uuid.New()
-	item := &shared.VersionHistoryItem{
-		EventID: common.Int64Ptr(1),
-		Version: common.Int64Ptr(1),
-	}
-	request := &history.ReplicateEventsV2Request{
-		DomainUUID: common.StringPtr(s.domainID),
-		WorkflowExecution: &shared.WorkflowExecution{
-			WorkflowId: common.StringPtr(workflowID),
-			RunId:      common.StringPtr(runID),
+	token := []byte{1}
+	blob1 := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
 		},
-		Events: &shared.DataBlob{
-			EncodingType: shared.EncodingTypeThriftRW.Ptr(),
-			Data:         []byte("some random history blob"),
+	})
+	blob2 := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(3),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskStarted.Ptr(),
 		},
-		VersionHistoryItems: []*shared.VersionHistoryItem{item},
+	})
+	blob3 := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(4),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskCompleted.Ptr(),
+		},
+	})
+	versionHistory := &shared.VersionHistory{
+		Items: []*shared.VersionHistoryItem{
+			{
+				EventID: common.Int64Ptr(4),
+				Version: common.Int64Ptr(123),
+			},
+		},
+	}
+	WithReverseFetch(func(domainID string) bool { return true })(s.rereplicator)
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			if len(request.NextPageToken) == 0 {
+				return &admin.GetWorkflowExecutionRawHistoryV2Response{
+					HistoryBatches: []*shared.DataBlob{blob1, blob2},
+					NextPageToken:  token,
+					VersionHistory: versionHistory,
+				}, nil
+			}
+			return &admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob3},
+				VersionHistory: versionHistory,
+			}, nil
+		}).Times(7)
+	fetchHistory := func() ([]*FetchedEventBatch, error) {
+		return s.resender.FetchWorkflowHistory(
+			context.Background(),
+			s.domainID,
+			workflowID,
+			runID,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+		)
 	}
 
-	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), request).Return(nil).Times(1)
-	err := s.rereplicator.sendReplicationRawRequest(context.Background(), request)
-	s.Nil(err)
+	batches, err := fetchHistory()
+	s.NoError(err)
+	s.Equal([]*FetchedEventBatch{
+		{RawEventBatch: blob3, VersionHistory: versionHistory},
+		{RawEventBatch: blob2, VersionHistory: versionHistory},
+		{RawEventBatch: blob1, VersionHistory: versionHistory},
+	}, batches)
+
+	// the buffered batches are bounded by the max resend bytes
+	maxBytes := len(blob1.Data) + len(blob2.Data)
+	WithMaxResendBytes(func(domainID string) int { return maxBytes })(s.rereplicator)
+	_, err = fetchHistory()
+	s.Equal(&ResendTooLargeError{
+		BytesReached: int64(maxBytes + len(blob3.Data)),
+		MaxBytes:     int64(maxBytes),
+	}, err)
+	WithMaxResendBytes(nil)(s.rereplicator)
+
+	// the buffered pages are bounded by the max pages
+	WithMaxPages(func(domainID string) int { return 1 })(s.rereplicator)
+	_, err = fetchHistory()
+	s.True(errors.Is(err, ErrTooManyPages))
+
+	WithMaxPages(nil)(s.rereplicator)
+
+	// the resends are not affected, the history events are replicated oldest-first
+	var replicatedBatches []*shared.DataBlob
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *history.ReplicateEventsV2Request, opts ...yarpc.CallOption) error {
+			replicatedBatches = append(replicatedBatches, request.Events)
+			return nil
+		}).Times(3)
+	_, err = s.resender.SendSingleWorkflowHistoryWithResult(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.NoError(err)
+	s.Equal([]*shared.DataBlob{blob1, blob2, blob3}, replicatedBatches)
 }
 
-func (s *nDCHistoryResenderSuite) TestSendReplicationRawRequest_Err() {
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistoryByDomainName() {
 	workflowID := "some random workflow ID"
 	runID := uuid.New()
-	item := &shared.VersionHistoryItem{
-		EventID: common.Int64Ptr(1),
-		Version: common.Int64Ptr(1),
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *history.ReplicateEventsV2Request, opts ...yarpc.CallOption) error {
+			s.Equal(s.domainID, request.GetDomainUUID())
+			return nil
+		}).Times(1)
+
+	err := s.resender.SendSingleWorkflowHistoryByDomainName(
+		context.Background(),
+		s.domainName,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.NoError(err)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistoryByDomainName_UnknownDomain() {
+	domainName := "some random unknown domain name"
+	s.mockDomainCache.EXPECT().GetDomain(domainName).Return(nil, &shared.EntityNotExistsError{}).Times(1)
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).Times(0)
+
+	err := s.resender.SendSingleWorkflowHistoryByDomainName(
+		context.Background(),
+		domainName,
+		"some random workflow ID",
+		uuid.New(),
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.IsType(&shared.EntityNotExistsError{}, err)
+	s.Contains(err.Error(), domainName)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_AuditSink() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+
+	auditSink := NewMockAuditSink(s.controller)
+	WithAuditSink(auditSink, nil)(s.rereplicator)
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+	gomock.InOrder(
+		auditSink.EXPECT().Write(gomock.Any(), s.domainID, workflowID, runID, blob).Return(nil).Times(1),
+		s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(1),
+	)
+
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.NoError(err)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_AuditSinkError() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	auditErr := errors.New("some random error")
+
+	for _, failOnAuditError := range []bool{false, true} {
+		auditSink := NewMockAuditSink(s.controller)
+		WithAuditSink(auditSink, func(domainID string) bool { return failOnAuditError })(s.rereplicator)
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob},
+				VersionHistory: &shared.VersionHistory{
+					Items: []*shared.VersionHistoryItem{
+						{
+							EventID: common.Int64Ptr(2),
+							Version: common.Int64Ptr(123),
+						},
+					},
+				},
+			}, nil).Times(1)
+		auditSink.EXPECT().Write(gomock.Any(), s.domainID, workflowID, runID, blob).Return(auditErr).Times(1)
+		if failOnAuditError {
+			s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+		} else {
+			s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+		}
+
+		err := s.resender.SendSingleWorkflowHistory(
+			context.Background(),
+			s.domainID,
+			workflowID,
+			runID,
+			nil,
+			nil,
+			nil,
+			nil,
+		)
+		if failOnAuditError {
+			s.Equal(auditErr, err)
+		} else {
+			s.NoError(err)
+		}
 	}
-	request := &history.ReplicateEventsV2Request{
-		DomainUUID: common.StringPtr(s.domainID),
-		WorkflowExecution: &shared.WorkflowExecution{
-			WorkflowId: common.StringPtr(workflowID),
-			RunId:      common.StringPtr(runID),
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_ContextError() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
 		},
-		Events: &shared.DataBlob{
-			EncodingType: shared.EncodingTypeThriftRW.Ptr(),
-			Data:         []byte("some random history blob"),
+	})
+
+	testCases := []struct {
+		replicationErr error
+		expectedMsg    string
+	}{
+		{
+			replicationErr: context.DeadlineExceeded,
+			expectedMsg:    "failed to replicate events, deadline exceeded",
+		},
+		{
+			replicationErr: yarpcerrors.DeadlineExceededErrorf("some random error"),
+			expectedMsg:    "failed to replicate events, deadline exceeded",
+		},
+		{
+			replicationErr: context.Canceled,
+			expectedMsg:    "failed to replicate events, cancelled",
+		},
+		{
+			replicationErr: yarpcerrors.CancelledErrorf("some random error"),
+			expectedMsg:    "failed to replicate events, cancelled",
+		},
+		{
+			replicationErr: &shared.InternalServiceError{Message: "some random error"},
+			expectedMsg:    "failed to replicate events",
 		},
-		VersionHistoryItems: []*shared.VersionHistoryItem{item},
 	}
-	retryErr := &shared.RetryTaskV2Error{
-		DomainId:   common.StringPtr(s.domainID),
-		WorkflowId: common.StringPtr(workflowID),
-		RunId:      common.StringPtr(runID),
+	for _, tc := range testCases {
+		core, observedLogs := observer.New(zap.InfoLevel)
+		s.rereplicator.logger = loggerimpl.NewLogger(zap.New(core))
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob},
+				VersionHistory: &shared.VersionHistory{
+					Items: []*shared.VersionHistoryItem{
+						{
+							EventID: common.Int64Ptr(2),
+							Version: common.Int64Ptr(123),
+						},
+					},
+				},
+			}, nil).Times(1)
+		s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(tc.replicationErr).Times(1)
+
+		err := s.resender.SendSingleWorkflowHistory(
+			context.Background(),
+			s.domainID,
+			workflowID,
+			runID,
+			nil,
+			nil,
+			nil,
+			nil,
+		)
+		s.Error(err)
+		s.Equal(1, observedLogs.FilterMessage(tc.expectedMsg).Len(), tc.expectedMsg)
 	}
+}
 
-	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), request).Return(retryErr).Times(1)
-	err := s.rereplicator.sendReplicationRawRequest(context.Background(), request)
-	s.Equal(retryErr, err)
+func (s *nDCHistoryResenderSuite) TestGetContextError() {
+	s.Nil(getContextError(context.Background(), errors.New("some random error")))
+	s.Equal(context.DeadlineExceeded, getContextError(context.Background(), context.DeadlineExceeded))
+	s.Equal(context.Canceled, getContextError(context.Background(), context.Canceled))
+
+	// the error of the context takes precedence over the error wrapped by the clients
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Equal(context.Canceled, getContextError(ctx, &shared.InternalServiceError{Message: "some random error"}))
+	ctx, cancel = context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	s.Equal(context.DeadlineExceeded, getContextError(ctx, errors.New("some random error")))
 }
 
-func (s *nDCHistoryResenderSuite) TestGetHistory() {
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_TooManyPages() {
 	workflowID := "some random workflow ID"
 	runID := uuid.New()
-	startEventID := int64(123)
-	endEventID := int64(345)
-	version := int64(20)
-	nextTokenIn := []byte("some random next token in")
-	nextTokenOut := []byte("some random next token out")
-	pageSize := int32(59)
-	blob := []byte("some random events blob")
+	WithMaxPages(func(domainID string) int { return 2 })(s.rereplicator)
 
-	response := &admin.GetWorkflowExecutionRawHistoryV2Response{
-		HistoryBatches: []*shared.DataBlob{&shared.DataBlob{
-			EncodingType: shared.EncodingTypeThriftRW.Ptr(),
-			Data:         blob,
-		}},
-		NextPageToken: nextTokenOut,
+	for i := int64(1); i <= 2; i++ {
+		blob := s.serializeEvents([]*shared.HistoryEvent{
+			{
+				EventId:   common.Int64Ptr(i),
+				Version:   common.Int64Ptr(123),
+				Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+				EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+			},
+		})
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob},
+				NextPageToken:  []byte(fmt.Sprintf("some random token %v", i)),
+				VersionHistory: &shared.VersionHistory{
+					Items: []*shared.VersionHistoryItem{
+						{
+							EventID: common.Int64Ptr(100),
+							Version: common.Int64Ptr(123),
+						},
+					},
+				},
+			}, nil).Times(1)
 	}
-	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), &admin.GetWorkflowExecutionRawHistoryV2Request{
-		Domain: common.StringPtr(s.domainName),
-		Execution: &shared.WorkflowExecution{
-			WorkflowId: common.StringPtr(workflowID),
-			RunId:      common.StringPtr(runID),
-		},
-		StartEventId:      common.Int64Ptr(startEventID),
-		StartEventVersion: common.Int64Ptr(version),
-		EndEventId:        common.Int64Ptr(endEventID),
-		EndEventVersion:   common.Int64Ptr(version),
-		MaximumPageSize:   common.Int32Ptr(pageSize),
-		NextPageToken:     nextTokenIn,
-	}).Return(response, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(2)
 
-	out, err := s.rereplicator.getHistory(
+	err := s.resender.SendSingleWorkflowHistory(
 		context.Background(),
 		s.domainID,
 		workflowID,
 		runID,
-		&startEventID,
-		&version,
-		&endEventID,
-		&version,
-		nextTokenIn,
-		pageSize)
-	s.Nil(err)
-	s.Equal(response, out)
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.IsType(&TooManyPagesError{}, err)
+	tooManyPagesErr := err.(*TooManyPagesError)
+	s.Equal(int64(2), tooManyPagesErr.PageCount)
+	s.Equal(int64(2), tooManyPagesErr.MaxPages)
+	s.Equal([]byte("some random token 2"), tooManyPagesErr.LastPageToken)
+	s.Equal(ErrTooManyPages, tooManyPagesErr.Unwrap())
 }
 
-func (s *nDCHistoryResenderSuite) TestCurrentExecutionCheck() {
-	domainID := uuid.New()
-	workflowID1 := uuid.New()
-	workflowID2 := uuid.New()
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_Panic() {
+	workflowID := "some random workflow ID"
 	runID := uuid.New()
-	invariantMock := checks.NewMockInvariant(s.controller)
-	s.rereplicator = NewNDCHistoryResender(
-		s.mockDomainCache,
-		s.mockAdminClient,
-		func(ctx context.Context, request *history.ReplicateEventsV2Request) error {
-			return s.mockHistoryClient.ReplicateEventsV2(ctx, request)
-		},
-		persistence.NewPayloadSerializer(),
-		nil,
-		invariantMock,
-		s.logger,
-	)
-	execution1 := &checks.CurrentExecution{
-		Execution: checks.Execution{
-			DomainID:   domainID,
-			WorkflowID: workflowID1,
-			State:      persistence.WorkflowStateRunning,
-		},
-	}
-	execution2 := &checks.CurrentExecution{
-		Execution: checks.Execution{
-			DomainID:   domainID,
-			WorkflowID: workflowID2,
-			State:      persistence.WorkflowStateRunning,
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
 		},
-	}
-	invariantMock.EXPECT().Check(execution1).Return(checks.CheckResult{
-		CheckResultType: checks.CheckResultTypeCorrupted,
-	}).Times(1)
-	invariantMock.EXPECT().Check(execution2).Return(checks.CheckResult{
+	})
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *history.ReplicateEventsV2Request, opts ...yarpc.CallOption) error {
+			panic("some random panic")
+		}).Times(1)
+
+	var err error
+	s.NotPanics(func() {
+		err = s.resender.SendSingleWorkflowHistory(
+			context.Background(),
+			s.domainID,
+			workflowID,
+			runID,
+			nil,
+			nil,
+			nil,
+			nil,
+		)
+	})
+	s.Equal(&ResendPanicError{Value: "some random panic"}, err)
+	s.True(errors.Is(err, ErrResendPanic))
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_PanicBeforeFetch() {
+	// the panics before the history is fetched are recovered as well
+	WithSkippedRunCache(func(opts ...dynamicconfig.FilterOption) int {
+		panic("some random panic")
+	}, dynamicconfig.GetDurationPropertyFn(time.Minute))(s.rereplicator)
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).Times(0)
+
+	var err error
+	s.NotPanics(func() {
+		err = s.resender.SendSingleWorkflowHistory(
+			context.Background(),
+			s.domainID,
+			"some random workflow ID",
+			uuid.New(),
+			nil,
+			nil,
+			nil,
+			nil,
+		)
+	})
+	s.Equal(&ResendPanicError{Value: "some random panic"}, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_PanicInBufferedFetch() {
+	// the pages are fetched in background by the buffered paging iterator
+	WithResendPageBufferSize(func(domainID string) int { return 2 })(s.rereplicator)
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			panic("some random panic")
+		}).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	var err error
+	s.NotPanics(func() {
+		err = s.resender.SendSingleWorkflowHistory(
+			context.Background(),
+			s.domainID,
+			"some random workflow ID",
+			uuid.New(),
+			nil,
+			nil,
+			nil,
+			nil,
+		)
+	})
+	s.IsType(&collection.PaginationPanicError{}, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_QuietExpectedErrors() {
+	workflowID := "some random workflow ID"
+	core, observedLogs := observer.New(zap.DebugLevel)
+	s.rereplicator.logger = loggerimpl.NewLogger(zap.New(core))
+	quiet := true
+	WithGetHistoryRetryPolicy(nil)(s.rereplicator)
+	WithQuietExpectedErrors(func(domainID string) bool { return quiet })(s.rereplicator)
+
+	gomock.InOrder(
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(nil, &shared.EntityNotExistsError{}).Times(1),
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(nil, &shared.InternalServiceError{}).Times(1),
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(nil, &shared.EntityNotExistsError{}).Times(1),
+	)
+	sendHistory := func() error {
+		return s.resender.SendSingleWorkflowHistory(
+			context.Background(),
+			s.domainID,
+			workflowID,
+			uuid.New(),
+			nil,
+			nil,
+			nil,
+			nil,
+		)
+	}
+
+	assertLogLevel := func(level zapcore.Level) {
+		logs := observedLogs.TakeAll()
+		s.Len(logs, 2)
+		s.Equal("error getting history", logs[0].Message)
+		s.Equal("failed to get history events", logs[1].Message)
+		for _, entry := range logs {
+			s.Equal(level, entry.Level)
+		}
+	}
+
+	s.IsType(&shared.EntityNotExistsError{}, sendHistory())
+	assertLogLevel(zap.DebugLevel)
+
+	// the genuine failures are logged at Error level regardless
+	s.IsType(&shared.InternalServiceError{}, sendHistory())
+	assertLogLevel(zap.ErrorLevel)
+
+	// the config is read for each resend
+	quiet = false
+	s.IsType(&shared.EntityNotExistsError{}, sendHistory())
+	assertLogLevel(zap.ErrorLevel)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_LocalDomain() {
+	domainID := uuid.New()
+	domainEntry := cache.NewLocalDomainCacheEntryForTest(
+		&persistence.DomainInfo{ID: domainID, Name: "some random local domain name"},
+		&persistence.DomainConfig{Retention: 1},
+		cluster.TestCurrentClusterName,
+		nil,
+	)
+	s.mockDomainCache.EXPECT().GetDomainByID(domainID).Return(domainEntry, nil).AnyTimes()
+
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		domainID,
+		"some random workflow ID",
+		uuid.New(),
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.Equal(ErrDomainNotReplicated, err)
+	s.False(errors.Is(err, ErrSkipTask))
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_PageBuffer() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	token := []byte{1}
+	blob1 := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	blob2 := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(3),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskStarted.Ptr(),
+		},
+	})
+	versionHistory := &shared.VersionHistory{
+		Items: []*shared.VersionHistoryItem{
+			{
+				EventID: common.Int64Ptr(3),
+				Version: common.Int64Ptr(123),
+			},
+		},
+	}
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			if len(request.NextPageToken) == 0 {
+				return &admin.GetWorkflowExecutionRawHistoryV2Response{
+					HistoryBatches: []*shared.DataBlob{blob1},
+					NextPageToken:  token,
+					VersionHistory: versionHistory,
+				}, nil
+			}
+			s.Equal(token, request.NextPageToken)
+			return &admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob2},
+				VersionHistory: versionHistory,
+			}, nil
+		}).Times(2)
+	gomock.InOrder(
+		s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), &history.ReplicateEventsV2Request{
+			DomainUUID: common.StringPtr(s.domainID),
+			WorkflowExecution: &shared.WorkflowExecution{
+				WorkflowId: common.StringPtr(workflowID),
+				RunId:      common.StringPtr(runID),
+			},
+			VersionHistoryItems: versionHistory.Items,
+			Events:              blob1,
+		}).Return(nil).Times(1),
+		s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), &history.ReplicateEventsV2Request{
+			DomainUUID: common.StringPtr(s.domainID),
+			WorkflowExecution: &shared.WorkflowExecution{
+				WorkflowId: common.StringPtr(workflowID),
+				RunId:      common.StringPtr(runID),
+			},
+			VersionHistoryItems: versionHistory.Items,
+			Events:              blob2,
+		}).Return(nil).Times(1),
+	)
+
+	WithResendPageBufferSize(func(domainID string) int { return 3 })(s.rereplicator)
+	result, err := s.resender.SendSingleWorkflowHistoryWithResult(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		common.Int64Ptr(1),
+		common.Int64Ptr(123),
+		nil,
+		nil,
+	)
+	s.NoError(err)
+	s.Equal(2, result.BatchCount)
+	s.Equal(int64(2), result.FirstEventID)
+	s.Equal(int64(3), result.LastEventID)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_TargetProgressChecker() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob1 := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(1),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeWorkflowExecutionStarted.Ptr(),
+		},
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	blob2 := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(3),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskStarted.Ptr(),
+		},
+	})
+	versionHistory := &shared.VersionHistory{
+		Items: []*shared.VersionHistoryItem{
+			{
+				EventID: common.Int64Ptr(3),
+				Version: common.Int64Ptr(123),
+			},
+		},
+	}
+	newReplicationRequest := func(blob *shared.DataBlob) *history.ReplicateEventsV2Request {
+		return &history.ReplicateEventsV2Request{
+			DomainUUID: common.StringPtr(s.domainID),
+			WorkflowExecution: &shared.WorkflowExecution{
+				WorkflowId: common.StringPtr(workflowID),
+				RunId:      common.StringPtr(runID),
+			},
+			VersionHistoryItems: versionHistory.Items,
+			Events:              blob,
+		}
+	}
+
+	testCases := []struct {
+		checkerErr      error
+		expectedBatches []*shared.DataBlob
+	}{
+		{
+			checkerErr:      nil,
+			expectedBatches: []*shared.DataBlob{blob2},
+		},
+		{
+			checkerErr:      errors.New("some random error"),
+			expectedBatches: []*shared.DataBlob{blob1, blob2},
+		},
+	}
+
+	for _, tc := range testCases {
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob1, blob2},
+				VersionHistory: versionHistory,
+			}, nil).Times(1)
+		for _, blob := range tc.expectedBatches {
+			s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), newReplicationRequest(blob)).Return(nil).Times(1)
+		}
+
+		checkerErr := tc.checkerErr
+		WithTargetProgressChecker(func(ctx context.Context, domainID string, wid string, rid string) (int64, error) {
+			s.Equal(s.domainID, domainID)
+			s.Equal(workflowID, wid)
+			s.Equal(runID, rid)
+			return 2, checkerErr
+		})(s.rereplicator)
+		result, err := s.resender.SendSingleWorkflowHistoryWithResult(
+			context.Background(),
+			s.domainID,
+			workflowID,
+			runID,
+			nil,
+			nil,
+			common.Int64Ptr(3),
+			common.Int64Ptr(123),
+		)
+		s.NoError(err)
+		s.Equal(len(tc.expectedBatches), result.BatchCount)
+		s.Equal(int64(3), result.LastEventID)
+	}
+}
+
+func (s *nDCHistoryResenderSuite) TestStreamSingleWorkflowHistory() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob1 := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	blob2 := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(3),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskStarted.Ptr(),
+		},
+		{
+			EventId:   common.Int64Ptr(4),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskCompleted.Ptr(),
+		},
+	})
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob1, blob2},
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(4),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+	progressCh, errCh := s.resender.StreamSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		common.Int64Ptr(1),
+		common.Int64Ptr(123),
+		nil,
+		nil,
+	)
+	var progresses []ResendProgress
+	for progress := range progressCh {
+		progresses = append(progresses, progress)
+	}
+	s.NoError(<-errCh)
+	s.Equal([]ResendProgress{
+		{
+			BatchIndex:   0,
+			FirstEventID: 2,
+			LastEventID:  2,
+			Bytes:        len(blob1.Data),
+		},
+		{
+			BatchIndex:   1,
+			FirstEventID: 3,
+			LastEventID:  4,
+			Bytes:        len(blob2.Data),
+		},
+	}, progresses)
+}
+
+func (s *nDCHistoryResenderSuite) TestStreamSingleWorkflowHistory_Error() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(nil, &shared.BadRequestError{Message: "some random error"}).Times(1)
+
+	progressCh, errCh := s.resender.StreamSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		common.Int64Ptr(1),
+		common.Int64Ptr(123),
+		nil,
+		nil,
+	)
+	for range progressCh {
+		s.Fail("no event batch should be sent")
+	}
+	s.IsType(&shared.BadRequestError{}, <-errCh)
+	_, ok := <-errCh
+	s.False(ok)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_DomainResolvedOnce() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	token := []byte{1}
+	mockDomainCache := cache.NewMockDomainCache(s.controller)
+	mockDomainCache.EXPECT().GetDomainByID(s.domainID).Return(s.domainEntry, nil).Times(1)
+	s.rereplicator.domainCache = mockDomainCache
+
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	versionHistory := &shared.VersionHistory{
+		Items: []*shared.VersionHistoryItem{
+			{
+				EventID: common.Int64Ptr(2),
+				Version: common.Int64Ptr(123),
+			},
+		},
+	}
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			s.Equal(s.domainName, request.GetDomain())
+			response := &admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob},
+				VersionHistory: versionHistory,
+			}
+			if len(request.NextPageToken) == 0 {
+				response.NextPageToken = token
+			}
+			return response, nil
+		}).Times(2)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		common.Int64Ptr(1),
+		common.Int64Ptr(123),
+		nil,
+		nil,
+	)
+	s.NoError(err)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_EmptyVersionHistory() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			VersionHistory: &shared.VersionHistory{},
+		}, nil).Times(2)
+
+	skipEmptyVersionHistory := false
+	WithSkipEmptyVersionHistory(func(domainID string) bool { return skipEmptyVersionHistory })(s.rereplicator)
+	sendHistory := func() (*ResendResult, error) {
+		return s.resender.SendSingleWorkflowHistoryWithResult(
+			context.Background(),
+			s.domainID,
+			workflowID,
+			runID,
+			common.Int64Ptr(1),
+			common.Int64Ptr(123),
+			nil,
+			nil,
+		)
+	}
+
+	_, err := sendHistory()
+	s.IsType(&shared.InternalServiceError{}, err)
+	s.Contains(err.Error(), s.domainID)
+	s.Contains(err.Error(), workflowID)
+	s.Contains(err.Error(), runID)
+
+	skipEmptyVersionHistory = true
+	result, err := sendHistory()
+	s.NoError(err)
+	s.Equal(0, result.BatchCount)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistoryWithResult() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	token := []byte{1}
+	blob1 := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+		{
+			EventId:   common.Int64Ptr(3),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskStarted.Ptr(),
+		},
+	})
+	blob2 := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(4),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskCompleted.Ptr(),
+		},
+	})
+	versionHistory := &shared.VersionHistory{
+		Items: []*shared.VersionHistoryItem{
+			{
+				EventID: common.Int64Ptr(4),
+				Version: common.Int64Ptr(123),
+			},
+		},
+	}
+
+	gomock.InOrder(
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob1},
+				NextPageToken:  token,
+				VersionHistory: versionHistory,
+			}, nil).Times(1),
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob2},
+				NextPageToken:  nil,
+				VersionHistory: versionHistory,
+			}, nil).Times(1),
+	)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+	result, err := s.resender.SendSingleWorkflowHistoryWithResult(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.NoError(err)
+	s.Equal(&ResendResult{
+		BatchCount:         2,
+		TotalBytes:         int64(len(blob1.Data) + len(blob2.Data)),
+		FirstEventID:       2,
+		LastEventID:        4,
+		EventCount:         3,
+		Skipped:            false,
+		NextPageToken:      nil,
+		FetchedBytes:       int64(len(blob1.Data) + len(blob2.Data)),
+		SentBytes:          int64(len(blob1.Data) + len(blob2.Data)),
+		domainID:           s.domainID,
+		workflowID:         workflowID,
+		runID:              runID,
+		cursorEventID:      4,
+		cursorEventVersion: 123,
+	}, result)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistoryWithResult_PartialResend() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	token := []byte{1}
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+
+	gomock.InOrder(
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob},
+				NextPageToken:  token,
+				VersionHistory: &shared.VersionHistory{
+					Items: []*shared.VersionHistoryItem{
+						{
+							EventID: common.Int64Ptr(2),
+							Version: common.Int64Ptr(123),
+						},
+					},
+				},
+			}, nil).Times(1),
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(nil, &shared.AccessDeniedError{}).Times(1),
+	)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	result, err := s.resender.SendSingleWorkflowHistoryWithResult(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.IsType(&shared.AccessDeniedError{}, err)
+	s.Equal(1, result.BatchCount)
+	s.Equal(int64(2), result.FirstEventID)
+	s.Equal(int64(2), result.LastEventID)
+	s.False(result.Skipped)
+	s.Equal(token, result.NextPageToken)
+}
+
+func (s *nDCHistoryResenderSuite) TestReplayPageTokens() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	token1 := []byte("some random next page token 1")
+	token2 := []byte("some random next page token 2")
+	newBlob := func(eventID int64) *shared.DataBlob {
+		return s.serializeEvents([]*shared.HistoryEvent{
+			{
+				EventId:   common.Int64Ptr(eventID),
+				Version:   common.Int64Ptr(123),
+				Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+				EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+			},
+		})
+	}
+	blob1 := newBlob(1)
+	blob2 := newBlob(2)
+	newRequest := func(token []byte) *admin.GetWorkflowExecutionRawHistoryV2Request {
+		return &admin.GetWorkflowExecutionRawHistoryV2Request{
+			Domain: common.StringPtr(s.domainName),
+			Execution: &shared.WorkflowExecution{
+				WorkflowId: common.StringPtr(workflowID),
+				RunId:      common.StringPtr(runID),
+			},
+			MaximumPageSize: common.Int32Ptr(defaultPageSize),
+			NextPageToken:   token,
+		}
+	}
+	newResponse := func(blob *shared.DataBlob, token []byte) *admin.GetWorkflowExecutionRawHistoryV2Response {
+		return &admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			NextPageToken:  token,
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}
+	}
+	newResumeToken := func(runID string, token []byte) []byte {
+		return (&ResendResult{
+			NextPageToken: token,
+			domainID:      s.domainID,
+			workflowID:    workflowID,
+			runID:         runID,
+		}).GetResumeToken()
+	}
+	descriptor := &ResendDescriptor{
+		DomainID:   s.domainID,
+		WorkflowID: workflowID,
+		RunID:      runID,
+	}
+
+	// the token of another run is rejected before anything is sent
+	_, err := s.diagnostics.ReplayPageTokens(
+		context.Background(),
+		descriptor,
+		[][]byte{nil, newResumeToken(uuid.New(), token1)},
+	)
+	s.IsType(&shared.BadRequestError{}, err)
+
+	gomock.InOrder(
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), newRequest(nil)).
+			Return(newResponse(blob1, token1), nil).Times(1),
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), newRequest(token1)).
+			Return(newResponse(blob2, token2), nil).Times(1),
+	)
+	var sentBlobs []*shared.DataBlob
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, request *history.ReplicateEventsV2Request, opts ...yarpc.CallOption) error {
+			sentBlobs = append(sentBlobs, request.Events)
+			return nil
+		}).Times(2)
+
+	// the page of token 2 is not recorded, so it is not replayed
+	result, err := s.diagnostics.ReplayPageTokens(
+		context.Background(),
+		descriptor,
+		[][]byte{nil, newResumeToken(runID, token1)},
+	)
+	s.NoError(err)
+	s.Equal(2, result.BatchCount)
+	s.Equal(int64(1), result.FirstEventID)
+	s.Equal(int64(2), result.LastEventID)
+	s.Equal(token2, result.NextPageToken)
+	s.Equal([]*shared.DataBlob{blob1, blob2}, sentBlobs)
+	// the replay is counted as a resend
+	s.Equal(int64(1), s.resender.Stats().WorkflowCount)
+
+	_, err = s.diagnostics.ReplayPageTokens(context.Background(), descriptor, nil)
+	s.IsType(&shared.BadRequestError{}, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_BatchCallback() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob1 := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+		{
+			EventId:   common.Int64Ptr(3),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskStarted.Ptr(),
+		},
+	})
+	blob2 := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(4),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskCompleted.Ptr(),
+		},
+	})
+
+	type batchInfo struct {
+		batchIndex   int
+		firstEventID int64
+		lastEventID  int64
+		bytes        int
+	}
+	var batches []batchInfo
+	WithBatchCallback(func(batchIndex int, firstEventID int64, lastEventID int64, bytes int) {
+		batches = append(batches, batchInfo{batchIndex, firstEventID, lastEventID, bytes})
+	})(s.rereplicator)
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob1, blob2, blob2},
+			NextPageToken:  nil,
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+	gomock.InOrder(
+		s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(2),
+		s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(&shared.InternalServiceError{}).Times(1),
+	)
+
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.IsType(&shared.InternalServiceError{}, err)
+	s.Equal([]batchInfo{
+		{0, 2, 3, len(blob1.Data)},
+		{1, 4, 4, len(blob2.Data)},
+	}, batches)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_TooLarge() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	maxBytes := len(blob.Data) + 1
+	WithMaxResendBytes(func(domainID string) int { return maxBytes })(s.rereplicator)
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob, blob},
+			NextPageToken:  nil,
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	result, err := s.resender.SendSingleWorkflowHistoryWithResult(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.True(errors.Is(err, ErrResendTooLarge))
+	s.Equal(&ResendTooLargeError{
+		BytesReached: int64(2 * len(blob.Data)),
+		MaxBytes:     int64(maxBytes),
+	}, err)
+	s.Equal(1, result.BatchCount)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_PartialEntityNotExists() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	notExistsErr := &shared.EntityNotExistsError{}
+
+	gomock.InOrder(
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob},
+				NextPageToken:  []byte{1},
+				VersionHistory: &shared.VersionHistory{
+					Items: []*shared.VersionHistoryItem{
+						{
+							EventID: common.Int64Ptr(2),
+							Version: common.Int64Ptr(123),
+						},
+					},
+				},
+			}, nil).Times(1),
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(nil, notExistsErr).Times(1),
+	)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.Equal(&ResendPartialError{
+		Err:         notExistsErr,
+		BatchCount:  1,
+		TotalBytes:  int64(len(blob.Data)),
+		LastEventID: 2,
+	}, err)
+	s.Equal(notExistsErr, errors.Unwrap(err))
+}
+
+func (s *nDCHistoryResenderSuite) TestSendWorkflowHistoryByRange() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	execution := &shared.WorkflowExecution{
+		WorkflowId: common.StringPtr(workflowID),
+		RunId:      common.StringPtr(runID),
+	}
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), &admin.GetWorkflowExecutionRawHistoryV2Request{
+		Domain:          common.StringPtr(s.domainName),
+		Execution:       execution,
+		MaximumPageSize: common.Int32Ptr(1),
+	}).Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+		VersionHistory: &shared.VersionHistory{
+			BranchToken: []byte{2},
+			Items: []*shared.VersionHistoryItem{
+				{EventID: common.Int64Ptr(5), Version: common.Int64Ptr(1)},
+				{EventID: common.Int64Ptr(10), Version: common.Int64Ptr(2)},
+			},
+		},
+	}, nil).Times(1)
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), &admin.GetWorkflowExecutionRawHistoryV2Request{
+		Domain:            common.StringPtr(s.domainName),
+		Execution:         execution,
+		StartEventId:      common.Int64Ptr(3),
+		StartEventVersion: common.Int64Ptr(1),
+		EndEventId:        common.Int64Ptr(8),
+		EndEventVersion:   common.Int64Ptr(2),
+		MaximumPageSize:   common.Int32Ptr(defaultPageSize),
+	}).Return(&admin.GetWorkflowExecutionRawHistoryV2Response{}, nil).Times(1)
+
+	result, err := s.resender.SendWorkflowHistoryByRange(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		common.Int64Ptr(3),
+		common.Int64Ptr(8),
+	)
+	s.NoError(err)
+	s.Equal(0, result.BatchCount)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendMissingHistory() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	execution := &shared.WorkflowExecution{
+		WorkflowId: common.StringPtr(workflowID),
+		RunId:      common.StringPtr(runID),
+	}
+	versionHistory := &shared.VersionHistory{
+		Items: []*shared.VersionHistoryItem{
+			{
+				EventID: common.Int64Ptr(10),
+				Version: common.Int64Ptr(123),
+			},
+		},
+	}
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(6),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), &admin.GetWorkflowExecutionRawHistoryV2Request{
+		Domain:            common.StringPtr(s.domainName),
+		Execution:         execution,
+		StartEventId:      common.Int64Ptr(5),
+		StartEventVersion: common.Int64Ptr(123),
+		MaximumPageSize:   common.Int32Ptr(defaultPageSize),
+	}).Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+		HistoryBatches: []*shared.DataBlob{blob},
+		VersionHistory: versionHistory,
+	}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	result, err := s.resender.SendMissingHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		5,
+		123,
+	)
+	s.NoError(err)
+	s.Equal(int64(6), result.FirstEventID)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendMissingHistory_UpToDate() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	WithSkippedRunCache(dynamicconfig.GetIntPropertyFn(10), dynamicconfig.GetDurationPropertyFn(time.Minute))(s.rereplicator)
+	// the source returns no event after the start event if the target is up to date
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), &admin.GetWorkflowExecutionRawHistoryV2Request{
+		Domain: common.StringPtr(s.domainName),
+		Execution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		StartEventId:      common.Int64Ptr(10),
+		StartEventVersion: common.Int64Ptr(123),
+		MaximumPageSize:   common.Int32Ptr(defaultPageSize),
+	}).Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+		VersionHistory: &shared.VersionHistory{
+			Items: []*shared.VersionHistoryItem{
+				{
+					EventID: common.Int64Ptr(10),
+					Version: common.Int64Ptr(123),
+				},
+			},
+		},
+	}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	result, err := s.resender.SendMissingHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		10,
+		123,
+	)
+	s.True(result.Skipped)
+	s.True(errors.Is(err, ErrSkipTask))
+	s.Equal(SkipTaskReasonUpToDate, GetSkipTaskReason(err))
+	s.Equal(int64(1), s.resender.Stats().SkipCount)
+
+	// the run may have more events later, so the up to date skip is not remembered
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{}, nil).Times(1)
+	result, err = s.resender.ResendWorkflowHistory(context.Background(), &ResendDescriptor{
+		DomainID:   s.domainID,
+		WorkflowID: workflowID,
+		RunID:      runID,
+	})
+	s.NoError(err)
+	s.False(result.Skipped)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendMissingHistory_EmptyVersionHistory() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(11),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			VersionHistory: &shared.VersionHistory{},
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	_, err := s.resender.SendMissingHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		10,
+		123,
+	)
+	s.IsType(&shared.InternalServiceError{}, err)
+	s.Contains(err.Error(), runID)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendMissingHistory_TargetVersionMismatch() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	// the source sends the events after the lowest common ancestor of the branches
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(3),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(10),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	result, err := s.resender.SendMissingHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		5,
+		456,
+	)
+	s.IsType(&shared.BadRequestError{}, err)
+	s.Contains(err.Error(), "456")
+	s.Equal(0, result.BatchCount)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendMissingHistory_SourceRejected() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	// the target last event is in none of the version histories of the source
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(nil, &shared.BadRequestError{}).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	_, err := s.resender.SendMissingHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		5,
+		456,
+	)
+	s.IsType(&shared.BadRequestError{}, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendMissingHistory_EmptyTarget() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), &admin.GetWorkflowExecutionRawHistoryV2Request{
+		Domain: common.StringPtr(s.domainName),
+		Execution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		MaximumPageSize: common.Int32Ptr(defaultPageSize),
+	}).Return(&admin.GetWorkflowExecutionRawHistoryV2Response{}, nil).Times(1)
+
+	result, err := s.resender.SendMissingHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		common.EmptyEventID,
+		common.EmptyVersion,
+	)
+	s.NoError(err)
+	s.Equal(0, result.BatchCount)
+}
+
+func (s *nDCHistoryResenderSuite) TestGetSourceVersionHistories() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	versionHistory := &shared.VersionHistory{
+		BranchToken: []byte{1},
+		Items: []*shared.VersionHistoryItem{
+			{
+				EventID: common.Int64Ptr(5),
+				Version: common.Int64Ptr(1),
+			},
+			{
+				EventID: common.Int64Ptr(10),
+				Version: common.Int64Ptr(2),
+			},
+		},
+	}
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), &admin.GetWorkflowExecutionRawHistoryV2Request{
+		Domain: common.StringPtr(s.domainName),
+		Execution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		MaximumPageSize: common.Int32Ptr(1),
+	}).Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+		VersionHistory: versionHistory,
+	}, nil).Times(1)
+	s.mockAdminClient.EXPECT().DescribeWorkflowExecution(gomock.Any(), gomock.Any()).Times(0)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	out, err := s.resender.GetSourceVersionHistories(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+	)
+	s.NoError(err)
+	s.Equal(&shared.VersionHistories{
+		CurrentVersionHistoryIndex: common.Int32Ptr(0),
+		Histories:                  []*shared.VersionHistory{versionHistory},
+	}, out)
+}
+
+func (s *nDCHistoryResenderSuite) TestGetSourceVersionHistories_AdminHeaders() {
+	headers := map[string]string{
+		"some random header":  "some random value",
+		"other random header": "other random value",
+	}
+	WithAdminHeadersProvider(func(ctx context.Context, domainID string) (map[string]string, error) {
+		s.Equal(s.domainID, domainID)
+		return headers, nil
+	})(s.rereplicator)
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			s.Equal(headers, GetAdminHeaders(ctx))
+			s.Len(opts, len(headers))
+			return &admin.GetWorkflowExecutionRawHistoryV2Response{}, nil
+		}).Times(1)
+
+	_, err := s.resender.GetSourceVersionHistories(
+		context.Background(),
+		s.domainID,
+		"some random workflow ID",
+		uuid.New(),
+	)
+	s.IsType(&shared.BadRequestError{}, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestGetSourceVersionHistories_NoVersionHistories() {
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{}, nil).Times(1)
+
+	out, err := s.resender.GetSourceVersionHistories(
+		context.Background(),
+		s.domainID,
+		"some random workflow ID",
+		uuid.New(),
+	)
+	s.IsType(&shared.BadRequestError{}, err)
+	s.Nil(out)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_BatchDelayCancelled() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	timeSource := &testDelayTimeSource{
+		EventTimeSource: clock.NewEventTimeSource().Update(time.Now()),
+		delay:           time.Second,
+		delayCh:         make(chan struct{}, 2),
+	}
+	WithTimeSource(timeSource)(s.rereplicator)
+	WithBatchDelay(func(domainID string) time.Duration { return timeSource.delay })(s.rereplicator)
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob, blob, blob},
+			NextPageToken:  nil,
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	go func() {
+		// the second batch is sent once the delay after the first batch elapses
+		<-timeSource.delayCh
+		timeSource.Update(timeSource.Now().Add(timeSource.delay))
+		// the delay after the second batch is interrupted by the cancellation,
+		// the resend runs detached from the context of the caller, so it is canceled by the run
+		<-timeSource.delayCh
+		s.True(s.resender.CancelResend(s.domainID, workflowID, runID))
+	}()
+
+	result, err := s.resender.SendSingleWorkflowHistoryWithResult(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.Equal(context.Canceled, err)
+	s.Equal(2, result.BatchCount)
+}
+
+func (s *nDCHistoryResenderSuite) TestFetchWorkflowHistory() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	token := []byte{1}
+	blob1 := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	blob2 := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(3),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskStarted.Ptr(),
+		},
+	})
+	versionHistory := &shared.VersionHistory{
+		Items: []*shared.VersionHistoryItem{
+			{
+				EventID: common.Int64Ptr(3),
+				Version: common.Int64Ptr(123),
+			},
+		},
+	}
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			if len(request.NextPageToken) == 0 {
+				return &admin.GetWorkflowExecutionRawHistoryV2Response{
+					HistoryBatches: []*shared.DataBlob{blob1},
+					NextPageToken:  token,
+					VersionHistory: versionHistory,
+				}, nil
+			}
+			return &admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob2},
+				VersionHistory: versionHistory,
+			}, nil
+		}).Times(4)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	batches, err := s.resender.FetchWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.NoError(err)
+	s.Equal([]*FetchedEventBatch{
+		{RawEventBatch: blob1, VersionHistory: versionHistory},
+		{RawEventBatch: blob2, VersionHistory: versionHistory},
+	}, batches)
+
+	var streamedBatches []*FetchedEventBatch
+	batches, err = s.resender.FetchWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		func(batch *FetchedEventBatch) error {
+			streamedBatches = append(streamedBatches, batch)
+			return nil
+		},
+	)
+	s.NoError(err)
+	s.Nil(batches)
+	s.Equal([]*FetchedEventBatch{
+		{RawEventBatch: blob1, VersionHistory: versionHistory},
+		{RawEventBatch: blob2, VersionHistory: versionHistory},
+	}, streamedBatches)
+}
+
+func (s *nDCHistoryResenderSuite) TestFetchWorkflowHistory_EventTypeFilter() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	scheduledEvent := &shared.HistoryEvent{
+		EventId:   common.Int64Ptr(2),
+		Version:   common.Int64Ptr(123),
+		Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+		EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+	}
+	startedEvent := &shared.HistoryEvent{
+		EventId:   common.Int64Ptr(3),
+		Version:   common.Int64Ptr(123),
+		Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+		EventType: shared.EventTypeDecisionTaskStarted.Ptr(),
+	}
+	timedOutEvent := &shared.HistoryEvent{
+		EventId:   common.Int64Ptr(4),
+		Version:   common.Int64Ptr(123),
+		Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+		EventType: shared.EventTypeDecisionTaskTimedOut.Ptr(),
+	}
+	blob1 := s.serializeEvents([]*shared.HistoryEvent{scheduledEvent, startedEvent})
+	blob2 := s.serializeEvents([]*shared.HistoryEvent{timedOutEvent})
+	versionHistory := &shared.VersionHistory{
+		Items: []*shared.VersionHistoryItem{
+			{
+				EventID: common.Int64Ptr(4),
+				Version: common.Int64Ptr(123),
+			},
+		},
+	}
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).Return(
+		&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob1, blob2},
+			VersionHistory: versionHistory,
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	batches, err := s.resender.FetchWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+		[]shared.EventType{shared.EventTypeDecisionTaskStarted},
+		nil,
+	)
+	s.NoError(err)
+	s.Len(batches, 1)
+	s.True(batches[0].Filtered)
+	s.Equal(versionHistory, batches[0].VersionHistory)
+	s.Equal(s.serializeEvents([]*shared.HistoryEvent{startedEvent}), batches[0].RawEventBatch)
+}
+
+func (s *nDCHistoryResenderSuite) TestPartitionWorkflowHistory() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	newEvent := func(eventID int64, eventType shared.EventType) *shared.HistoryEvent {
+		return &shared.HistoryEvent{
+			EventId:   common.Int64Ptr(eventID),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(123),
+			EventType: eventType.Ptr(),
+		}
+	}
+	scheduledEvent := newEvent(2, shared.EventTypeDecisionTaskScheduled)
+	signaledEvent := newEvent(3, shared.EventTypeWorkflowExecutionSignaled)
+	markerEvent := newEvent(4, shared.EventTypeMarkerRecorded)
+	activityEvent := newEvent(5, shared.EventTypeActivityTaskScheduled)
+	blob1 := s.serializeEvents([]*shared.HistoryEvent{scheduledEvent, signaledEvent, markerEvent})
+	blob2 := s.serializeEvents([]*shared.HistoryEvent{activityEvent})
+	versionHistory := &shared.VersionHistory{
+		Items: []*shared.VersionHistoryItem{
+			{
+				EventID: common.Int64Ptr(5),
+				Version: common.Int64Ptr(123),
+			},
+		},
+	}
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).Return(
+		&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob1, blob2},
+			VersionHistory: versionHistory,
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	coreBatches, signalMarkerBatches, err := s.resender.PartitionWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.NoError(err)
+	s.Len(coreBatches, 2)
+	s.True(coreBatches[0].Filtered)
+	s.Equal(s.serializeEvents([]*shared.HistoryEvent{scheduledEvent}), coreBatches[0].RawEventBatch)
+	s.False(coreBatches[1].Filtered)
+	s.Equal(blob2, coreBatches[1].RawEventBatch)
+	s.Len(signalMarkerBatches, 1)
+	s.True(signalMarkerBatches[0].Filtered)
+	s.Equal(versionHistory, signalMarkerBatches[0].VersionHistory)
+	s.Equal(s.serializeEvents([]*shared.HistoryEvent{signaledEvent, markerEvent}), signalMarkerBatches[0].RawEventBatch)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_TrimBatchToEndEvent() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	timestamp := time.Now().UnixNano()
+	newEvent := func(eventID int64) *shared.HistoryEvent {
+		return &shared.HistoryEvent{
+			EventId:   common.Int64Ptr(eventID),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(timestamp),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		}
+	}
+	blob1 := s.serializeEvents([]*shared.HistoryEvent{newEvent(2), newEvent(3)})
+	blob2 := s.serializeEvents([]*shared.HistoryEvent{newEvent(4), newEvent(5), newEvent(6)})
+	WithTrimBatchToEndEvent(func(domainID string) bool { return true })(s.rereplicator)
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob1, blob2},
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(6),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+	var sentEvents [][]*shared.HistoryEvent
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *history.ReplicateEventsV2Request, opts ...yarpc.CallOption) error {
+			events, err := s.serializer.DeserializeBatchEvents(persistence.NewDataBlobFromThrift(request.Events))
+			s.NoError(err)
+			sentEvents = append(sentEvents, events)
+			return nil
+		}).Times(2)
+
+	result, err := s.resender.SendSingleWorkflowHistoryWithResult(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		common.Int64Ptr(6),
+		common.Int64Ptr(123),
+	)
+	s.NoError(err)
+	s.Equal(int64(5), result.LastEventID)
+	s.Equal([][]*shared.HistoryEvent{
+		{newEvent(2), newEvent(3)},
+		{newEvent(4), newEvent(5)},
+	}, sentEvents)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_ConcurrentFetch() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	timestamp := time.Now().UnixNano()
+	newEvent := func(eventID int64) *shared.HistoryEvent {
+		return &shared.HistoryEvent{
+			EventId:   common.Int64Ptr(eventID),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(timestamp),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		}
+	}
+	versionHistory := &shared.VersionHistory{
+		BranchToken: []byte{1},
+		Items: []*shared.VersionHistoryItem{
+			{
+				EventID: common.Int64Ptr(5),
+				Version: common.Int64Ptr(123),
+			},
+		},
+	}
+	WithResendPageSize(func(domainID string) int { return 2 })(s.rereplicator)
+	WithResendFetchConcurrency(func(domainID string) int { return 3 })(s.rereplicator)
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), &admin.GetWorkflowExecutionRawHistoryV2Request{
+		Domain: common.StringPtr(s.domainName),
+		Execution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		MaximumPageSize: common.Int32Ptr(1),
+	}).Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+		VersionHistory: versionHistory,
+	}, nil).Times(1)
+	// the events are split into the segments of (0, 3), (2, 5) and (4, 6)
+	lastSegmentFetched := make(chan struct{})
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			var events []*shared.HistoryEvent
+			switch request.GetStartEventId() {
+			case 0:
+				s.Equal(int64(3), request.GetEndEventId())
+				// the first segment is fetched after the last one
+				select {
+				case <-lastSegmentFetched:
+				case <-time.After(time.Second):
+					s.Fail("segments are not fetched concurrently")
+				}
+				events = []*shared.HistoryEvent{newEvent(1), newEvent(2)}
+			case 2:
+				s.Equal(int64(5), request.GetEndEventId())
+				events = []*shared.HistoryEvent{newEvent(3), newEvent(4)}
+			case 4:
+				s.Nil(request.EndEventId)
+				defer close(lastSegmentFetched)
+				events = []*shared.HistoryEvent{newEvent(5)}
+			}
+			return &admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{s.serializeEvents(events)},
+				VersionHistory: versionHistory,
+			}, nil
+		}).Times(3)
+	var sentEvents [][]*shared.HistoryEvent
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *history.ReplicateEventsV2Request, opts ...yarpc.CallOption) error {
+			events, err := s.serializer.DeserializeBatchEvents(persistence.NewDataBlobFromThrift(request.Events))
+			s.NoError(err)
+			sentEvents = append(sentEvents, events)
+			return nil
+		}).Times(3)
+
+	result, err := s.resender.SendSingleWorkflowHistoryWithResult(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.NoError(err)
+	s.Equal(int64(5), result.LastEventID)
+	s.Empty(result.NextPageToken)
+	s.Equal([][]*shared.HistoryEvent{
+		{newEvent(1), newEvent(2)},
+		{newEvent(3), newEvent(4)},
+		{newEvent(5)},
+	}, sentEvents)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_ConcurrentFetch_Err() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	versionHistory := &shared.VersionHistory{
+		BranchToken: []byte{1},
+		Items: []*shared.VersionHistoryItem{
+			{
+				EventID: common.Int64Ptr(5),
+				Version: common.Int64Ptr(123),
+			},
+		},
+	}
+	WithResendPageSize(func(domainID string) int { return 2 })(s.rereplicator)
+	WithResendFetchConcurrency(func(domainID string) int { return 3 })(s.rereplicator)
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), &admin.GetWorkflowExecutionRawHistoryV2Request{
+		Domain: common.StringPtr(s.domainName),
+		Execution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		MaximumPageSize: common.Int32Ptr(1),
+	}).Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+		VersionHistory: versionHistory,
+	}, nil).Times(1)
+	firstSegmentStarted := make(chan struct{})
+	firstSegmentCancelled := make(chan struct{})
+	fetchErr := &shared.BadRequestError{Message: "some random error"}
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			switch request.GetStartEventId() {
+			case 0:
+				close(firstSegmentStarted)
+				<-ctx.Done()
+				close(firstSegmentCancelled)
+				return nil, ctx.Err()
+			case 2:
+				// the second segment fails while the first one is still being fetched
+				<-firstSegmentStarted
+				return nil, fetchErr
+			default:
+				return &admin.GetWorkflowExecutionRawHistoryV2Response{}, nil
+			}
+		}).MinTimes(2).MaxTimes(3)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.Equal(fetchErr, err)
+	select {
+	case <-firstSegmentCancelled:
+	case <-time.After(time.Second):
+		s.Fail("outstanding fetch is not cancelled")
+	}
+}
+
+func (s *nDCHistoryResenderSuite) TestSendWorkflowHistoryWindow() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	execution := &shared.WorkflowExecution{
+		WorkflowId: common.StringPtr(workflowID),
+		RunId:      common.StringPtr(runID),
+	}
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), &admin.GetWorkflowExecutionRawHistoryV2Request{
+		Domain:          common.StringPtr(s.domainName),
+		Execution:       execution,
+		MaximumPageSize: common.Int32Ptr(1),
+	}).Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+		VersionHistory: &shared.VersionHistory{
+			BranchToken: []byte{1},
+			Items: []*shared.VersionHistoryItem{
+				{EventID: common.Int64Ptr(5), Version: common.Int64Ptr(1)},
+				{EventID: common.Int64Ptr(10), Version: common.Int64Ptr(2)},
+			},
+		},
+	}, nil).Times(5)
+	gomock.InOrder(
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), &admin.GetWorkflowExecutionRawHistoryV2Request{
+			Domain:            common.StringPtr(s.domainName),
+			Execution:         execution,
+			StartEventId:      common.Int64Ptr(3),
+			StartEventVersion: common.Int64Ptr(1),
+			EndEventId:        common.Int64Ptr(9),
+			EndEventVersion:   common.Int64Ptr(2),
+			MaximumPageSize:   common.Int32Ptr(defaultPageSize),
+		}).Return(&admin.GetWorkflowExecutionRawHistoryV2Response{}, nil).Times(1),
+		// clamped to the last event
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), &admin.GetWorkflowExecutionRawHistoryV2Request{
+			Domain:            common.StringPtr(s.domainName),
+			Execution:         execution,
+			StartEventId:      common.Int64Ptr(6),
+			StartEventVersion: common.Int64Ptr(2),
+			MaximumPageSize:   common.Int32Ptr(defaultPageSize),
+		}).Return(&admin.GetWorkflowExecutionRawHistoryV2Response{}, nil).Times(1),
+		// clamped to the first event
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), &admin.GetWorkflowExecutionRawHistoryV2Request{
+			Domain:          common.StringPtr(s.domainName),
+			Execution:       execution,
+			EndEventId:      common.Int64Ptr(5),
+			EndEventVersion: common.Int64Ptr(1),
+			MaximumPageSize: common.Int32Ptr(defaultPageSize),
+		}).Return(&admin.GetWorkflowExecutionRawHistoryV2Response{}, nil).Times(1),
+	)
+
+	_, err := s.resender.SendWorkflowHistoryWindow(context.Background(), s.domainID, workflowID, runID, 6, 2)
+	s.NoError(err)
+	_, err = s.resender.SendWorkflowHistoryWindow(context.Background(), s.domainID, workflowID, runID, 9, 2)
+	s.NoError(err)
+	_, err = s.resender.SendWorkflowHistoryWindow(context.Background(), s.domainID, workflowID, runID, 2, 2)
+	s.NoError(err)
+
+	_, err = s.resender.SendWorkflowHistoryWindow(context.Background(), s.domainID, workflowID, runID, 0, 2)
+	s.IsType(&shared.BadRequestError{}, err)
+	_, err = s.resender.SendWorkflowHistoryWindow(context.Background(), s.domainID, workflowID, runID, 6, -1)
+	s.IsType(&shared.BadRequestError{}, err)
+	_, err = s.resender.SendWorkflowHistoryWindow(context.Background(), s.domainID, workflowID, runID, -5, 2)
+	s.IsType(&shared.BadRequestError{}, err)
+	s.Contains(err.Error(), "-5")
+
+	// the center is beyond the last event of the run
+	_, err = s.resender.SendWorkflowHistoryWindow(context.Background(), s.domainID, workflowID, runID, 11, 2)
+	s.IsType(&shared.BadRequestError{}, err)
+	s.Contains(err.Error(), "center event ID: 11 is larger than last event ID: 10")
+	_, err = s.resender.SendWorkflowHistoryWindow(context.Background(), s.domainID, workflowID, runID, 100, 0)
+	s.IsType(&shared.BadRequestError{}, err)
+	s.Contains(err.Error(), "center event ID: 100")
+}
+
+func (s *nDCHistoryResenderSuite) TestEstimateResend() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob, blob},
+			NextPageToken:  nil,
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	result, err := s.resender.EstimateResend(context.Background(), &ResendDescriptor{
+		DomainID:   s.domainID,
+		WorkflowID: workflowID,
+		RunID:      runID,
+	})
+	s.NoError(err)
+	s.Equal(2, result.BatchCount)
+	s.Equal(int64(2*len(blob.Data)), result.TotalBytes)
+	s.Equal(int64(2), result.FirstEventID)
+	s.Equal(int64(2), result.LastEventID)
+}
+
+func (s *nDCHistoryResenderSuite) TestEstimateResend_EntityNotExists() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(nil, &shared.EntityNotExistsError{}).Times(1)
+
+	result, err := s.resender.EstimateResend(context.Background(), &ResendDescriptor{
+		DomainID:   s.domainID,
+		WorkflowID: workflowID,
+		RunID:      runID,
+	})
+	s.IsType(&shared.EntityNotExistsError{}, err)
+	s.Equal(0, result.BatchCount)
+}
+
+func (s *nDCHistoryResenderSuite) TestEstimateBulkResend() {
+	workflowID := "some random workflow ID"
+	runID1 := uuid.New()
+	runID2 := uuid.New()
+	runID3 := uuid.New()
+	WithResendConcurrency(func(domainID string) int { return 2 })(s.rereplicator)
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	historyBatches := map[string][]*shared.DataBlob{
+		runID1: {blob, blob},
+		runID2: {blob},
+	}
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			batches, ok := historyBatches[request.Execution.GetRunId()]
+			if !ok {
+				return nil, &shared.EntityNotExistsError{}
+			}
+			return &admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: batches,
+				VersionHistory: &shared.VersionHistory{
+					Items: []*shared.VersionHistoryItem{
+						{
+							EventID: common.Int64Ptr(2),
+							Version: common.Int64Ptr(123),
+						},
+					},
+				},
+			}, nil
+		}).Times(3)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	result, err := s.resender.EstimateBulkResend(context.Background(), []*ResendDescriptor{
+		{DomainID: s.domainID, WorkflowID: workflowID, RunID: runID1},
+		{DomainID: s.domainID, WorkflowID: workflowID, RunID: runID2},
+		{DomainID: s.domainID, WorkflowID: workflowID, RunID: runID3},
+		{DomainID: s.domainID, WorkflowID: workflowID, RunID: runID1},
+	})
+	s.NoError(err)
+	expected := BulkEstimate{
+		WorkflowCount: 2,
+		EventCount:    3,
+		BatchCount:    3,
+		TotalBytes:    int64(3 * len(blob.Data)),
+	}
+	s.Equal(expected, result.Total)
+	s.Equal(map[string]*BulkEstimate{s.domainID: &expected}, result.Domains)
+	s.Len(result.Failures, 1)
+	s.Equal(runID3, result.Failures[0].Descriptor.RunID)
+	s.IsType(&shared.EntityNotExistsError{}, result.Failures[0].Err)
+	// the estimation is not counted
+	s.Equal(ResendStats{}, s.resender.Stats())
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_InvalidRange() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+
+	testCases := []struct {
+		name              string
+		startEventID      *int64
+		startEventVersion *int64
+		endEventID        *int64
+		endEventVersion   *int64
+		errMessage        string
+	}{
+		{
+			name:         "start event ID without version",
+			startEventID: common.Int64Ptr(10),
+			errMessage:   "start event ID: 10, start event version: nil",
+		},
+		{
+			name:              "start event version without ID",
+			startEventVersion: common.Int64Ptr(100),
+			errMessage:        "start event ID: nil, start event version: 100",
+		},
+		{
+			name:       "end event ID without version",
+			endEventID: common.Int64Ptr(20),
+			errMessage: "end event ID: 20, end event version: nil",
+		},
+		{
+			name:            "end event version without ID",
+			endEventVersion: common.Int64Ptr(100),
+			errMessage:      "end event ID: nil, end event version: 100",
+		},
+		{
+			name:              "start event ID larger than end event ID",
+			startEventID:      common.Int64Ptr(20),
+			startEventVersion: common.Int64Ptr(100),
+			endEventID:        common.Int64Ptr(10),
+			endEventVersion:   common.Int64Ptr(100),
+			errMessage:        "Start event ID: 20 is larger than end event ID: 10",
+		},
+	}
+
+	for _, tc := range testCases {
+		err := s.resender.SendSingleWorkflowHistory(
+			context.Background(),
+			s.domainID,
+			workflowID,
+			runID,
+			tc.startEventID,
+			tc.startEventVersion,
+			tc.endEventID,
+			tc.endEventVersion,
+		)
+		s.IsType(&shared.BadRequestError{}, err, tc.name)
+		s.Contains(err.Error(), tc.errMessage, tc.name)
+	}
+}
+
+func (s *nDCHistoryResenderSuite) TestSendMultiWorkflowHistory() {
+	workflowID := "some random workflow ID"
+	runID1 := uuid.New()
+	runID2 := uuid.New()
+	pageSize := defaultPageSize
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	versionHistoryItems := []*shared.VersionHistoryItem{
+		{
+			EventID: common.Int64Ptr(2),
+			Version: common.Int64Ptr(123),
+		},
+	}
+	rereplicator, err := NewNDCHistoryResender(
+		s.mockDomainCache,
+		s.mockAdminClient,
+		func(ctx context.Context, request *history.ReplicateEventsV2Request) error {
+			return s.mockHistoryClient.ReplicateEventsV2(ctx, request)
+		},
+		persistence.NewPayloadSerializer(),
+		nil,
+		nil,
+		s.metricsClient,
+		s.logger,
+		WithResendConcurrency(func(domainID string) int { return 2 }),
+		WithGetHistoryRetryPolicy(nil),
+	)
+	s.NoError(err)
+	s.useRereplicator(rereplicator)
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(
+		gomock.Any(),
+		&admin.GetWorkflowExecutionRawHistoryV2Request{
+			Domain: common.StringPtr(s.domainName),
+			Execution: &shared.WorkflowExecution{
+				WorkflowId: common.StringPtr(workflowID),
+				RunId:      common.StringPtr(runID1),
+			},
+			MaximumPageSize: common.Int32Ptr(pageSize),
+		}).Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+		HistoryBatches: []*shared.DataBlob{blob},
+		VersionHistory: &shared.VersionHistory{
+			Items: versionHistoryItems,
+		},
+	}, nil).Times(1)
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(
+		gomock.Any(),
+		&admin.GetWorkflowExecutionRawHistoryV2Request{
+			Domain: common.StringPtr(s.domainName),
+			Execution: &shared.WorkflowExecution{
+				WorkflowId: common.StringPtr(workflowID),
+				RunId:      common.StringPtr(runID2),
+			},
+			MaximumPageSize: common.Int32Ptr(pageSize),
+		}).Return(nil, &shared.InternalServiceError{}).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(
+		gomock.Any(),
+		&history.ReplicateEventsV2Request{
+			DomainUUID: common.StringPtr(s.domainID),
+			WorkflowExecution: &shared.WorkflowExecution{
+				WorkflowId: common.StringPtr(workflowID),
+				RunId:      common.StringPtr(runID1),
+			},
+			VersionHistoryItems: versionHistoryItems,
+			Events:              blob,
+		}).Return(nil).Times(1)
+
+	err = s.resender.SendMultiWorkflowHistory(context.Background(), []*ResendDescriptor{
+		{DomainID: s.domainID, WorkflowID: workflowID, RunID: runID1},
+		{DomainID: s.domainID, WorkflowID: workflowID, RunID: runID2},
+		{DomainID: s.domainID, WorkflowID: workflowID, RunID: runID1},
+	})
+	s.Error(err)
+	s.Len(multierr.Errors(err), 1)
+	s.IsType(&shared.InternalServiceError{}, multierr.Errors(err)[0])
+}
+
+func (s *nDCHistoryResenderSuite) TestClose() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.resender.Close()
+		}()
+	}
+	wg.Wait()
+
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.Equal(ErrResenderClosed, err)
+
+	err = s.resender.SendMultiWorkflowHistory(context.Background(), []*ResendDescriptor{
+		{DomainID: s.domainID, WorkflowID: workflowID, RunID: runID},
+	})
+	s.Equal(ErrResenderClosed, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestClose_CancelInFlightResend() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			s.resender.Close()
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}).Times(1)
+
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.Equal(context.Canceled, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestCreateReplicateRawEventsRequest() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := &shared.DataBlob{
+		EncodingType: shared.EncodingTypeThriftRW.Ptr(),
+		Data:         []byte("some random history blob"),
+	}
+	versionHistoryItems := []*shared.VersionHistoryItem{
+		{
+			EventID: common.Int64Ptr(1),
+			Version: common.Int64Ptr(1),
+		},
+	}
+
+	request, err := s.rereplicator.createReplicationRawRequest(
+		s.domainID,
+		s.domainID,
+		workflowID,
+		runID,
+		0,
+		blob,
+		versionHistoryItems)
+	s.NoError(err)
+	s.Equal(&history.ReplicateEventsV2Request{
+		DomainUUID: common.StringPtr(s.domainID),
+		WorkflowExecution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		VersionHistoryItems: versionHistoryItems,
+		Events:              blob,
+	}, request)
+}
+
+func (s *nDCHistoryResenderSuite) TestGetTargetDomainID() {
+	quarantineDomainID := uuid.New()
+	mapperErr := &shared.InternalServiceError{Message: "some random error"}
+
+	targetDomainID, err := s.rereplicator.getTargetDomainID(s.domainID, s.domainEntry)
+	s.NoError(err)
+	s.Equal(s.domainID, targetDomainID)
+
+	testCases := []struct {
+		name             string
+		targetDomainID   string
+		mapperErr        error
+		expectedDomainID string
+	}{
+		{
+			name:             "remapped",
+			targetDomainID:   quarantineDomainID,
+			expectedDomainID: quarantineDomainID,
+		},
+		{
+			name:             "not remapped",
+			targetDomainID:   "",
+			expectedDomainID: s.domainID,
+		},
+		{
+			name:      "error",
+			mapperErr: mapperErr,
+		},
+	}
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			WithTargetDomainMapper(func(domainID string, domainName string) (string, error) {
+				s.Equal(s.domainID, domainID)
+				s.Equal(s.domainName, domainName)
+				return tc.targetDomainID, tc.mapperErr
+			})(s.rereplicator)
+
+			targetDomainID, err := s.rereplicator.getTargetDomainID(s.domainID, s.domainEntry)
+			if tc.mapperErr != nil {
+				s.Equal(tc.mapperErr, err)
+				return
+			}
+			s.NoError(err)
+			s.Equal(tc.expectedDomainID, targetDomainID)
+		})
+	}
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_TargetDomainMapper() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	token := []byte{1}
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+		{
+			EventId:   common.Int64Ptr(3),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskStarted.Ptr(),
+		},
+	})
+	versionHistoryItems := []*shared.VersionHistoryItem{
+		{
+			EventID: common.Int64Ptr(3),
+			Version: common.Int64Ptr(123),
+		},
+	}
+	quarantineDomainID := uuid.New()
+
+	// the target domain is resolved once per resend, not per batch
+	mapperCalls := 0
+	WithTargetDomainMapper(func(domainID string, domainName string) (string, error) {
+		mapperCalls++
+		return quarantineDomainID, nil
+	})(s.rereplicator)
+	// the batches would be split or rejected by the config of the target domain
+	configOfDomain := func(domainID string) int {
+		if domainID == quarantineDomainID {
+			return 1
+		}
+		return 0
+	}
+	WithSplitBatchSize(configOfDomain)(s.rereplicator)
+	WithMaxBatchSize(configOfDomain)(s.rereplicator)
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			NextPageToken:  token,
+			VersionHistory: &shared.VersionHistory{Items: versionHistoryItems},
+		}, nil).Times(1)
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			NextPageToken:  nil,
+			VersionHistory: &shared.VersionHistory{Items: versionHistoryItems},
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(
+		gomock.Any(),
+		&history.ReplicateEventsV2Request{
+			DomainUUID: common.StringPtr(quarantineDomainID),
+			WorkflowExecution: &shared.WorkflowExecution{
+				WorkflowId: common.StringPtr(workflowID),
+				RunId:      common.StringPtr(runID),
+			},
+			VersionHistoryItems: versionHistoryItems,
+			Events:              blob,
+		}).Return(nil).Times(2)
+
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.NoError(err)
+	s.Equal(1, mapperCalls)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_TargetDomainMapper_TargetChecks() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	quarantineDomainID := uuid.New()
+	invariantMock := checks.NewMockInvariant(s.controller)
+	s.rereplicator.currentExecutionFixer.check = invariantMock
+	WithTargetDomainMapper(func(domainID string, domainName string) (string, error) {
+		return quarantineDomainID, nil
+	})(s.rereplicator)
+	// the progress and the current execution are checked in the target domain
+	WithTargetProgressChecker(func(ctx context.Context, domainID string, workflowID string, runID string) (int64, error) {
+		s.Equal(quarantineDomainID, domainID)
+		return common.EmptyEventID, nil
+	})(s.rereplicator)
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).
+		Return(&shared.EntityNotExistsError{}).Times(1)
+	invariantMock.EXPECT().Check(&checks.CurrentExecution{
+		Execution: checks.Execution{
+			DomainID:   quarantineDomainID,
+			WorkflowID: workflowID,
+			State:      persistence.WorkflowStateRunning,
+		},
+	}).Return(checks.CheckResult{
 		CheckResultType: checks.CheckResultTypeHealthy,
 	}).Times(1)
-	invariantMock.EXPECT().Fix(gomock.Any()).Return(checks.FixResult{}).Times(1)
 
-	skipTask := s.rereplicator.fixCurrentExecution(domainID, workflowID1, runID)
-	s.False(skipTask)
-	skipTask = s.rereplicator.fixCurrentExecution(domainID, workflowID2, runID)
-	s.True(skipTask)
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.True(errors.Is(err, ErrSkipTask))
+	skipTaskErr, ok := err.(*SkipTaskError)
+	s.True(ok)
+	s.Equal(s.domainID, skipTaskErr.DomainID)
+}
+
+func (s *nDCHistoryResenderSuite) TestCreateReplicateRawEventsRequest_EmptyVersionHistoryItems() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := &shared.DataBlob{
+		EncodingType: shared.EncodingTypeThriftRW.Ptr(),
+		Data:         []byte("some random history blob"),
+	}
+
+	for _, versionHistoryItems := range [][]*shared.VersionHistoryItem{nil, {}} {
+		request, err := s.rereplicator.createReplicationRawRequest(
+			s.domainID,
+			s.domainID,
+			workflowID,
+			runID,
+			3,
+			blob,
+			versionHistoryItems)
+		s.Nil(request)
+		s.IsType(&shared.InternalServiceError{}, err)
+		s.Contains(err.Error(), s.domainID)
+		s.Contains(err.Error(), workflowID)
+		s.Contains(err.Error(), runID)
+		s.Contains(err.Error(), "batch index: 3")
+	}
+}
+
+func (s *nDCHistoryResenderSuite) TestSplitReplicationRawRequest() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	newEvent := func(eventID int64, identity string) *shared.HistoryEvent {
+		return &shared.HistoryEvent{
+			EventId:   common.Int64Ptr(eventID),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(123),
+			EventType: shared.EventTypeDecisionTaskStarted.Ptr(),
+			DecisionTaskStartedEventAttributes: &shared.DecisionTaskStartedEventAttributes{
+				Identity: common.StringPtr(identity),
+			},
+		}
+	}
+	var events []*shared.HistoryEvent
+	for eventID := int64(2); eventID <= 6; eventID++ {
+		events = append(events, newEvent(eventID, "some random identity"))
+	}
+	// the events of the same size
+	eventSize := len(s.serializeEvents(events[:1]).Data)
+	largeEvent := newEvent(7, string(make([]byte, 3*eventSize)))
+	versionHistoryItems := []*shared.VersionHistoryItem{
+		{
+			EventID: common.Int64Ptr(7),
+			Version: common.Int64Ptr(123),
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		splitBatchSize int
+		events         []*shared.HistoryEvent
+		expectedChunks [][]*shared.HistoryEvent
+	}{
+		{
+			name:           "disabled",
+			splitBatchSize: 0,
+			events:         events,
+			expectedChunks: [][]*shared.HistoryEvent{events},
+		},
+		{
+			name:           "batch within split batch size",
+			splitBatchSize: len(s.serializeEvents(events).Data),
+			events:         events,
+			expectedChunks: [][]*shared.HistoryEvent{events},
+		},
+		{
+			name:           "chunks at split batch size",
+			splitBatchSize: 2 * eventSize,
+			events:         events,
+			expectedChunks: [][]*shared.HistoryEvent{events[0:2], events[2:4], events[4:5]},
+		},
+		{
+			name:           "chunks below split batch size",
+			splitBatchSize: 2*eventSize - 1,
+			events:         events,
+			expectedChunks: [][]*shared.HistoryEvent{events[0:1], events[1:2], events[2:3], events[3:4], events[4:5]},
+		},
+		{
+			name:           "single large event",
+			splitBatchSize: eventSize,
+			events:         []*shared.HistoryEvent{largeEvent},
+			expectedChunks: [][]*shared.HistoryEvent{{largeEvent}},
+		},
+		{
+			name:           "large event in its own chunk",
+			splitBatchSize: 2 * eventSize,
+			events:         []*shared.HistoryEvent{events[0], largeEvent, events[1]},
+			expectedChunks: [][]*shared.HistoryEvent{{events[0]}, {largeEvent}, {events[1]}},
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			WithSplitBatchSize(func(domainID string) int { return tc.splitBatchSize })(s.rereplicator)
+			request, err := s.rereplicator.createReplicationRawRequest(
+				s.domainID,
+				s.domainID,
+				workflowID,
+				runID,
+				0,
+				s.serializeEvents(tc.events),
+				versionHistoryItems)
+			s.NoError(err)
+
+			requests, err := s.rereplicator.splitReplicationRawRequest(s.domainID, request)
+			s.NoError(err)
+			s.Len(requests, len(tc.expectedChunks))
+			if len(tc.expectedChunks) == 1 {
+				s.Equal(request, requests[0])
+				return
+			}
+			for i, chunk := range tc.expectedChunks {
+				s.Equal(s.serializeEvents(chunk), requests[i].Events)
+				s.Equal(versionHistoryItems, requests[i].VersionHistoryItems)
+				s.Equal(request.WorkflowExecution, requests[i].WorkflowExecution)
+				if tc.splitBatchSize >= eventSize {
+					s.True(len(requests[i].Events.Data) <= tc.splitBatchSize || len(chunk) == 1)
+				}
+			}
+		})
+	}
+}
+
+func (s *nDCHistoryResenderSuite) TestSendReplicationRawRequest_SplitBatch() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	newEvent := func(eventID int64) *shared.HistoryEvent {
+		return &shared.HistoryEvent{
+			EventId:   common.Int64Ptr(eventID),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(123),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		}
+	}
+	events := []*shared.HistoryEvent{newEvent(2), newEvent(3), newEvent(4)}
+	WithSplitBatchSize(func(domainID string) int { return len(s.serializeEvents(events[:1]).Data) })(s.rereplicator)
+	request, err := s.rereplicator.createReplicationRawRequest(
+		s.domainID,
+		s.domainID,
+		workflowID,
+		runID,
+		0,
+		s.serializeEvents(events),
+		[]*shared.VersionHistoryItem{
+			{
+				EventID: common.Int64Ptr(4),
+				Version: common.Int64Ptr(123),
+			},
+		})
+	s.NoError(err)
+
+	// the chunks are sent in order, and the chunks after the failed one are not sent
+	gomock.InOrder(
+		s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, request *history.ReplicateEventsV2Request, opts ...yarpc.CallOption) error {
+				s.Equal(s.serializeEvents(events[0:1]), request.Events)
+				return nil
+			}).Times(1),
+		s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, request *history.ReplicateEventsV2Request, opts ...yarpc.CallOption) error {
+				s.Equal(s.serializeEvents(events[1:2]), request.Events)
+				return &shared.BadRequestError{}
+			}).Times(1),
+	)
+
+	err = s.rereplicator.sendReplicationRawRequest(context.Background(), s.domainID, request)
+	s.IsType(&shared.BadRequestError{}, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendReplicationRawRequest() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	item := &shared.VersionHistoryItem{
+		EventID: common.Int64Ptr(1),
+		Version: common.Int64Ptr(1),
+	}
+	request := &history.ReplicateEventsV2Request{
+		DomainUUID: common.StringPtr(s.domainID),
+		WorkflowExecution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		Events: &shared.DataBlob{
+			EncodingType: shared.EncodingTypeThriftRW.Ptr(),
+			Data:         []byte("some random history blob"),
+		},
+		VersionHistoryItems: []*shared.VersionHistoryItem{item},
+	}
+
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), request).Return(nil).Times(1)
+	err := s.rereplicator.sendReplicationRawRequest(context.Background(), s.domainID, request)
+	s.Nil(err)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendReplicationRawRequest_HistoryReplicationFnNotSet() {
+	request := &history.ReplicateEventsV2Request{
+		DomainUUID: common.StringPtr(s.domainID),
+		WorkflowExecution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr("some random workflow ID"),
+			RunId:      common.StringPtr(uuid.New()),
+		},
+	}
+
+	s.rereplicator.historyReplicationFns = []nDCHistoryReplicationFn{nil}
+	err := s.rereplicator.sendReplicationRawRequest(context.Background(), s.domainID, request)
+	s.Equal(ErrHistoryReplicationFnNotSet, err)
+
+	s.rereplicator.historyReplicationFns = nil
+	err = s.rereplicator.sendReplicationRawRequest(context.Background(), s.domainID, request)
+	s.Equal(ErrHistoryReplicationFnNotSet, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestNewNDCHistoryResender_MissingDependency() {
+	historyReplicationFn := func(ctx context.Context, request *history.ReplicateEventsV2Request) error {
+		return s.mockHistoryClient.ReplicateEventsV2(ctx, request)
+	}
+	_, err := NewNDCHistoryResender(nil, s.mockAdminClient, historyReplicationFn, s.serializer, nil, nil, s.metricsClient, s.logger)
+	s.EqualError(err, "NDC history resender is created without domainCache")
+	_, err = NewNDCHistoryResender(s.mockDomainCache, nil, historyReplicationFn, s.serializer, nil, nil, s.metricsClient, s.logger)
+	s.EqualError(err, "NDC history resender is created without adminClient")
+	_, err = NewNDCHistoryResender(s.mockDomainCache, s.mockAdminClient, nil, s.serializer, nil, nil, s.metricsClient, s.logger)
+	s.EqualError(err, "NDC history resender is created without historyReplicationFn")
+	_, err = NewNDCHistoryResender(s.mockDomainCache, s.mockAdminClient, historyReplicationFn, nil, nil, nil, s.metricsClient, s.logger)
+	s.EqualError(err, "NDC history resender is created without serializer")
+	_, err = NewNDCHistoryResender(s.mockDomainCache, s.mockAdminClient, historyReplicationFn, s.serializer, nil, nil, s.metricsClient, s.logger,
+		WithAdditionalHistoryReplicationFns(historyReplicationFn, nil))
+	s.EqualError(err, "NDC history resender is created with nil historyReplicationFn at index 2")
+
+	resender, err := NewNDCHistoryResender(s.mockDomainCache, s.mockAdminClient, historyReplicationFn, s.serializer, nil, nil, s.metricsClient, s.logger)
+	s.NoError(err)
+	resender.Close()
+}
+
+func (s *nDCHistoryResenderSuite) TestSendReplicationRawRequest_BatchSize() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	core, observedLogs := observer.New(zap.WarnLevel)
+	s.rereplicator.logger = loggerimpl.NewLogger(zap.New(core))
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	request := &history.ReplicateEventsV2Request{
+		DomainUUID: common.StringPtr(s.domainID),
+		WorkflowExecution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		Events: blob,
+	}
+
+	largeBatchThreshold := len(blob.Data) + 1
+	WithLargeBatchThreshold(func(domainID string) int { return largeBatchThreshold })(s.rereplicator)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), request).Return(nil).Times(2)
+	err := s.rereplicator.sendReplicationRawRequest(context.Background(), s.domainID, request)
+	s.NoError(err)
+	s.Equal(0, observedLogs.Len())
+
+	// the large batch is still sent
+	largeBatchThreshold = len(blob.Data)
+	err = s.rereplicator.sendReplicationRawRequest(context.Background(), s.domainID, request)
+	s.NoError(err)
+	s.Equal(1, observedLogs.FilterMessage("history event batch to replicate is large").Len())
+
+	WithMaxBatchSize(func(domainID string) int { return len(blob.Data) - 1 })(s.rereplicator)
+	err = s.rereplicator.sendReplicationRawRequest(context.Background(), s.domainID, request)
+	s.Equal(ErrBatchTooLarge, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendReplicationRawRequest_Err() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	item := &shared.VersionHistoryItem{
+		EventID: common.Int64Ptr(1),
+		Version: common.Int64Ptr(1),
+	}
+	request := &history.ReplicateEventsV2Request{
+		DomainUUID: common.StringPtr(s.domainID),
+		WorkflowExecution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		Events: &shared.DataBlob{
+			EncodingType: shared.EncodingTypeThriftRW.Ptr(),
+			Data:         []byte("some random history blob"),
+		},
+		VersionHistoryItems: []*shared.VersionHistoryItem{item},
+	}
+	retryErr := &shared.RetryTaskV2Error{
+		DomainId:   common.StringPtr(s.domainID),
+		WorkflowId: common.StringPtr(workflowID),
+		RunId:      common.StringPtr(runID),
+	}
+
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), request).Return(retryErr).Times(1)
+	err := s.rereplicator.sendReplicationRawRequest(context.Background(), s.domainID, request)
+	s.Equal(retryErr, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendReplicationRawRequest_RetryServiceBusy() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	request := &history.ReplicateEventsV2Request{
+		DomainUUID: common.StringPtr(s.domainID),
+		WorkflowExecution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		Events: &shared.DataBlob{
+			EncodingType: shared.EncodingTypeThriftRW.Ptr(),
+			Data:         []byte("some random history blob"),
+		},
+	}
+	retryPolicy := backoff.NewExponentialRetryPolicy(time.Millisecond)
+	retryPolicy.SetMaximumAttempts(3)
+	WithReplicationRetryPolicy(retryPolicy)(s.rereplicator)
+
+	attempts := 0
+	s.rereplicator.historyReplicationFns = []nDCHistoryReplicationFn{
+		func(ctx context.Context, replicationRequest *history.ReplicateEventsV2Request) error {
+			s.Equal(request, replicationRequest)
+			attempts++
+			if attempts <= 2 {
+				return &shared.ServiceBusyError{}
+			}
+			return nil
+		},
+	}
+	err := s.rereplicator.sendReplicationRawRequest(context.Background(), s.domainID, request)
+	s.NoError(err)
+	s.Equal(3, attempts)
+
+	attempts = 0
+	s.rereplicator.historyReplicationFns = []nDCHistoryReplicationFn{
+		func(ctx context.Context, replicationRequest *history.ReplicateEventsV2Request) error {
+			attempts++
+			return &shared.ServiceBusyError{}
+		},
+	}
+	err = s.rereplicator.sendReplicationRawRequest(context.Background(), s.domainID, request)
+	s.IsType(&shared.ServiceBusyError{}, err)
+	s.Equal(4, attempts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	attempts = 0
+	err = s.rereplicator.sendReplicationRawRequest(ctx, s.domainID, request)
+	s.IsType(&shared.ServiceBusyError{}, err)
+	s.Equal(1, attempts)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendReplicationRawRequest_MultiTarget() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	request := &history.ReplicateEventsV2Request{
+		DomainUUID: common.StringPtr(s.domainID),
+		WorkflowExecution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+	}
+	notExistsErr := &shared.EntityNotExistsError{}
+	internalErr := &shared.InternalServiceError{}
+
+	var delivered []int
+	newTarget := func(index int, err error) nDCHistoryReplicationFn {
+		return func(ctx context.Context, request *history.ReplicateEventsV2Request) error {
+			delivered = append(delivered, index)
+			return err
+		}
+	}
+	rereplicator, err := NewNDCHistoryResender(
+		s.mockDomainCache,
+		s.mockAdminClient,
+		newTarget(0, nil),
+		persistence.NewPayloadSerializer(),
+		nil,
+		nil,
+		s.metricsClient,
+		s.logger,
+		WithAdditionalHistoryReplicationFns(newTarget(1, notExistsErr), newTarget(2, internalErr)),
+	)
+	s.NoError(err)
+
+	err = rereplicator.sendReplicationRawRequest(context.Background(), s.domainID, request)
+	s.Equal([]int{0, 1, 2}, delivered)
+	s.Equal([]error{notExistsErr, internalErr}, multierr.Errors(err))
+	s.True(containsEntityNotExistsError(err))
+	s.False(containsEntityNotExistsError(internalErr))
+}
+
+func (s *nDCHistoryResenderSuite) TestSendReplicationRawRequest_Timeout() {
+	request := &history.ReplicateEventsV2Request{
+		DomainUUID: common.StringPtr(s.domainID),
+	}
+	WithReplicationTimeout(func(domainID string) time.Duration { return 10 * time.Millisecond })(s.rereplicator)
+
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), request).
+		DoAndReturn(func(ctx context.Context, request *history.ReplicateEventsV2Request, opts ...yarpc.CallOption) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}).Times(1)
+
+	startTime := time.Now()
+	err := s.rereplicator.sendReplicationRawRequest(context.Background(), s.domainID, request)
+	s.Equal(context.DeadlineExceeded, err)
+	s.True(time.Since(startTime) < defaultResendContextTimeout)
+}
+
+func (s *nDCHistoryResenderSuite) TestGetHistory_Timeout() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	WithGetHistoryTimeout(func(domainID string) time.Duration { return 10 * time.Millisecond })(s.rereplicator)
+	WithGetHistoryRetryPolicy(nil)(s.rereplicator)
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}).Times(1)
+
+	startTime := time.Now()
+	_, err := s.rereplicator.getHistory(
+		context.Background(),
+		s.domainEntry,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		defaultPageSize)
+	s.Equal(context.DeadlineExceeded, err)
+	s.True(time.Since(startTime) < defaultResendContextTimeout)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_RereplicationTimeout_TimeSource() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	timeSource := clock.NewEventTimeSource().Update(time.Now())
+	WithTimeSource(timeSource)(s.rereplicator)
+	WithGetHistoryTimeout(func(domainID string) time.Duration { return time.Hour })(s.rereplicator)
+	s.rereplicator.rereplicationTimeout = func(domainID string) time.Duration { return time.Minute }
+
+	started := make(chan struct{})
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}).Times(1)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.resender.SendSingleWorkflowHistory(context.Background(), s.domainID, workflowID, runID, nil, nil, nil, nil)
+	}()
+	<-started
+	timeSource.Update(timeSource.Now().Add(time.Minute - time.Second))
+	select {
+	case err := <-errCh:
+		s.Fail("resend is aborted before the timeout", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	timeSource.Update(timeSource.Now().Add(time.Second))
+	select {
+	case err := <-errCh:
+		s.Equal(context.DeadlineExceeded, err)
+	case <-time.After(time.Second):
+		s.Fail("resend is not aborted after the timeout")
+	}
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_RereplicationTimeout_SlowPages() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	timeSource := clock.NewEventTimeSource().Update(time.Now())
+	deadline := timeSource.Now().Add(10 * time.Second)
+	WithTimeSource(timeSource)(s.rereplicator)
+	WithGetHistoryTimeout(func(domainID string) time.Duration { return time.Minute })(s.rereplicator)
+	WithReplicationTimeout(func(domainID string) time.Duration { return time.Minute })(s.rereplicator)
+	s.rereplicator.rereplicationTimeout = func(domainID string) time.Duration { return 10 * time.Second }
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+
+	// each page takes almost half of the rereplication timeout, so the time left is too short after two pages
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			callDeadline, ok := ctx.Deadline()
+			s.True(ok)
+			s.False(callDeadline.After(deadline))
+			timeSource.Update(timeSource.Now().Add(4960 * time.Millisecond))
+			return &admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob},
+				NextPageToken:  []byte{1},
+				VersionHistory: &shared.VersionHistory{
+					Items: []*shared.VersionHistoryItem{
+						{
+							EventID: common.Int64Ptr(2),
+							Version: common.Int64Ptr(123),
+						},
+					},
+				},
+			}, nil
+		}).Times(2)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *history.ReplicateEventsV2Request, opts ...yarpc.CallOption) error {
+			callDeadline, ok := ctx.Deadline()
+			s.True(ok)
+			s.False(callDeadline.After(deadline))
+			return nil
+		}).Times(1)
+
+	err := s.resender.SendSingleWorkflowHistory(context.Background(), s.domainID, workflowID, runID, nil, nil, nil, nil)
+	s.Equal(ErrResendDeadlineExceeded, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestGetHistory() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	startEventID := int64(123)
+	endEventID := int64(345)
+	version := int64(20)
+	nextTokenIn := []byte("some random next token in")
+	nextTokenOut := []byte("some random next token out")
+	pageSize := int32(59)
+	blob := []byte("some random events blob")
+
+	response := &admin.GetWorkflowExecutionRawHistoryV2Response{
+		HistoryBatches: []*shared.DataBlob{&shared.DataBlob{
+			EncodingType: shared.EncodingTypeThriftRW.Ptr(),
+			Data:         blob,
+		}},
+		NextPageToken: nextTokenOut,
+	}
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), &admin.GetWorkflowExecutionRawHistoryV2Request{
+		Domain: common.StringPtr(s.domainName),
+		Execution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		StartEventId:      common.Int64Ptr(startEventID),
+		StartEventVersion: common.Int64Ptr(version),
+		EndEventId:        common.Int64Ptr(endEventID),
+		EndEventVersion:   common.Int64Ptr(version),
+		MaximumPageSize:   common.Int32Ptr(pageSize),
+		NextPageToken:     nextTokenIn,
+	}).Return(response, nil).Times(1)
+
+	out, err := s.rereplicator.getHistory(
+		context.Background(),
+		s.domainEntry,
+		workflowID,
+		runID,
+		&startEventID,
+		&version,
+		&endEventID,
+		&version,
+		nextTokenIn,
+		pageSize)
+	s.Nil(err)
+	s.Equal(response, out)
+}
+
+func (s *nDCHistoryResenderSuite) TestGetHistory_RetryTransientError() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	pageSize := int32(59)
+	WithGetHistoryRetryPolicy(backoff.NewExponentialRetryPolicy(time.Millisecond))(s.rereplicator)
+
+	request := &admin.GetWorkflowExecutionRawHistoryV2Request{
+		Domain: common.StringPtr(s.domainName),
+		Execution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		MaximumPageSize: common.Int32Ptr(pageSize),
+	}
+	response := &admin.GetWorkflowExecutionRawHistoryV2Response{}
+	gomock.InOrder(
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), request).Return(nil, &shared.ServiceBusyError{}).Times(1),
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), request).Return(nil, &shared.InternalServiceError{}).Times(1),
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), request).Return(response, nil).Times(1),
+	)
+
+	out, err := s.rereplicator.getHistory(
+		context.Background(),
+		s.domainEntry,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		pageSize)
+	s.NoError(err)
+	s.Equal(response, out)
+}
+
+func (s *nDCHistoryResenderSuite) TestGetHistory_SourceThrottled() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	pageSize := int32(59)
+	core, observedLogs := observer.New(zap.WarnLevel)
+	s.rereplicator.logger = loggerimpl.NewLogger(zap.New(core))
+	WithGetHistoryRetryPolicy(backoff.NewExponentialRetryPolicy(time.Millisecond))(s.rereplicator)
+
+	response := &admin.GetWorkflowExecutionRawHistoryV2Response{}
+	var pageSizes []int32
+	recordPageSize := func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) {
+		pageSizes = append(pageSizes, request.GetMaximumPageSize())
+	}
+	gomock.InOrder(
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Do(recordPageSize).Return(nil, &shared.ServiceBusyError{Message: "some random error"}).Times(1),
+		// the exhausted quotas reported by the transport are backed off as well, without reducing the page size
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Do(recordPageSize).Return(nil, yarpcerrors.ResourceExhaustedErrorf("rate limit exceeded")).Times(1),
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Do(recordPageSize).Return(response, nil).Times(1),
+	)
+
+	out, err := s.rereplicator.getHistory(
+		context.Background(),
+		s.domainEntry,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		pageSize)
+	s.NoError(err)
+	s.Equal(response, out)
+	s.Equal(2, observedLogs.FilterMessage("history fetch is throttled by source cluster").Len())
+	s.Equal([]int32{pageSize, pageSize, pageSize}, pageSizes)
+}
+
+func (s *nDCHistoryResenderSuite) TestGetHistory_NonRetryableError() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	pageSize := int32(59)
+	WithGetHistoryRetryPolicy(backoff.NewExponentialRetryPolicy(time.Millisecond))(s.rereplicator)
+
+	request := &admin.GetWorkflowExecutionRawHistoryV2Request{
+		Domain: common.StringPtr(s.domainName),
+		Execution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		MaximumPageSize: common.Int32Ptr(pageSize),
+	}
+	for _, nonRetryableErr := range []error{&shared.EntityNotExistsError{}, &shared.BadRequestError{}} {
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), request).Return(nil, nonRetryableErr).Times(1)
+
+		_, err := s.rereplicator.getHistory(
+			context.Background(),
+			s.domainEntry,
+			workflowID,
+			runID,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+			pageSize)
+		s.Equal(nonRetryableErr, err)
+	}
+}
+
+func (s *nDCHistoryResenderSuite) TestGetResendPageSize() {
+	s.Equal(defaultPageSize, s.rereplicator.getResendPageSize(s.domainID))
+
+	pageSize := 0
+	WithResendPageSize(func(domainID string) int { return pageSize })(s.rereplicator)
+	s.Equal(defaultPageSize, s.rereplicator.getResendPageSize(s.domainID))
+
+	pageSize = 20
+	s.Equal(int32(20), s.rereplicator.getResendPageSize(s.domainID))
+}
+
+func (s *nDCHistoryResenderSuite) TestGetResendPageSize_DefaultPageSize() {
+	WithDefaultPageSize(50)(s.rereplicator)
+	s.Equal(int32(50), s.rereplicator.getResendPageSize(s.domainID))
+
+	pageSize := 0
+	WithResendPageSize(func(domainID string) int { return pageSize })(s.rereplicator)
+	s.Equal(int32(50), s.rereplicator.getResendPageSize(s.domainID))
+
+	pageSize = 20
+	s.Equal(int32(20), s.rereplicator.getResendPageSize(s.domainID))
+
+	// the invalid page sizes are replaced instead
+	WithResendPageSize(nil)(s.rereplicator)
+	WithDefaultPageSize(0)(s.rereplicator)
+	s.Equal(defaultPageSize, s.rereplicator.getResendPageSize(s.domainID))
+	WithDefaultPageSize(common.GetHistoryMaxPageSize + 1)(s.rereplicator)
+	s.Equal(int32(common.GetHistoryMaxPageSize), s.rereplicator.getResendPageSize(s.domainID))
+}
+
+func (s *nDCHistoryResenderSuite) TestGetRereplicationTimeout() {
+	s.Equal(time.Duration(0), s.rereplicator.getRereplicationTimeout(s.domainID))
+
+	timeout := time.Minute
+	s.rereplicator.rereplicationTimeout = func(domainID string) time.Duration { return timeout }
+	s.Equal(timeout, s.rereplicator.getRereplicationTimeout(s.domainID))
+
+	coefficient := 0.0
+	WithRereplicationTimeoutJitter(func(domainID string) float64 { return coefficient })(s.rereplicator)
+	s.Equal(timeout, s.rereplicator.getRereplicationTimeout(s.domainID))
+
+	coefficient = 0.2
+	WithRandSource(rand.NewSource(0))(s.rereplicator)
+	expectedRand := rand.New(rand.NewSource(0))
+	for i := 0; i < 10; i++ {
+		expected := time.Duration(float64(timeout) * (1 - coefficient + 2*coefficient*expectedRand.Float64()))
+		jitteredTimeout := s.rereplicator.getRereplicationTimeout(s.domainID)
+		s.Equal(expected, jitteredTimeout)
+		s.True(jitteredTimeout >= 48*time.Second && jitteredTimeout < 72*time.Second)
+	}
+}
+
+func (s *nDCHistoryResenderSuite) TestGetResendTimeout() {
+	descriptor := &ResendDescriptor{DomainID: s.domainID}
+	s.Equal(time.Duration(0), s.rereplicator.getResendTimeout(descriptor, s.domainEntry))
+
+	s.rereplicator.rereplicationTimeout = func(domainID string) time.Duration { return time.Minute }
+	s.Equal(time.Minute, s.rereplicator.getResendTimeout(descriptor, s.domainEntry))
+
+	descriptor.TimeoutOverride = 10 * time.Minute
+	s.Equal(10*time.Minute, s.rereplicator.getResendTimeout(descriptor, s.domainEntry))
+
+	descriptor.TimeoutOverride = time.Hour
+	s.Equal(defaultMaxTimeoutOverride, s.rereplicator.getResendTimeout(descriptor, s.domainEntry))
+
+	WithMaxTimeoutOverride(func(domainID string) time.Duration { return 5 * time.Minute })(s.rereplicator)
+	s.Equal(5*time.Minute, s.rereplicator.getResendTimeout(descriptor, s.domainEntry))
+}
+
+func (s *nDCHistoryResenderSuite) TestGetResendTimeout_Retention() {
+	now := time.Now()
+	s.rereplicator.timeSource = clock.NewEventTimeSource().Update(now)
+	workflowID := "some random workflow ID"
+	retention := time.Duration(s.domainEntry.GetRetentionDays(workflowID)) * time.Hour * 24
+	descriptor := &ResendDescriptor{
+		DomainID:   s.domainID,
+		WorkflowID: workflowID,
+	}
+	s.rereplicator.rereplicationTimeout = func(domainID string) time.Duration { return time.Hour }
+
+	// the close time is unknown
+	s.Equal(time.Hour, s.rereplicator.getResendTimeout(descriptor, s.domainEntry))
+
+	// the retention expires long after the timeout
+	descriptor.CloseTime = now
+	s.Equal(time.Hour, s.rereplicator.getResendTimeout(descriptor, s.domainEntry))
+
+	// the retention expires before the timeout
+	descriptor.CloseTime = now.Add(-retention + 10*time.Minute)
+	s.Equal(10*time.Minute, s.rereplicator.getResendTimeout(descriptor, s.domainEntry))
+
+	// the retention is already expired
+	descriptor.CloseTime = now.Add(-retention - time.Minute)
+	s.Equal(minCallTimeout, s.rereplicator.getResendTimeout(descriptor, s.domainEntry))
+
+	// the retention caps the resend without timeout as well
+	s.rereplicator.rereplicationTimeout = nil
+	descriptor.CloseTime = now.Add(-retention + 10*time.Minute)
+	s.Equal(10*time.Minute, s.rereplicator.getResendTimeout(descriptor, s.domainEntry))
+}
+
+func (s *nDCHistoryResenderSuite) TestEstimateReplicationLag() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	now := time.Now()
+	s.rereplicator.timeSource = clock.NewEventTimeSource().Update(now)
+
+	firstBlob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(1),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(now.Add(-time.Hour).UnixNano()),
+			EventType: shared.EventTypeWorkflowExecutionStarted.Ptr(),
+		},
+	})
+	lastBlob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(4),
+			Version:   common.Int64Ptr(124),
+			Timestamp: common.Int64Ptr(now.Add(-2 * time.Minute).UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+		{
+			EventId:   common.Int64Ptr(5),
+			Version:   common.Int64Ptr(124),
+			Timestamp: common.Int64Ptr(now.Add(-time.Minute).UnixNano()),
+			EventType: shared.EventTypeDecisionTaskStarted.Ptr(),
+		},
+	})
+	versionHistory := &shared.VersionHistory{
+		Items: []*shared.VersionHistoryItem{
+			{
+				EventID: common.Int64Ptr(3),
+				Version: common.Int64Ptr(123),
+			},
+			{
+				EventID: common.Int64Ptr(5),
+				Version: common.Int64Ptr(124),
+			},
+		},
+	}
+	gomock.InOrder(
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), &admin.GetWorkflowExecutionRawHistoryV2Request{
+			Domain: common.StringPtr(s.domainName),
+			Execution: &shared.WorkflowExecution{
+				WorkflowId: common.StringPtr(workflowID),
+				RunId:      common.StringPtr(runID),
+			},
+			MaximumPageSize: common.Int32Ptr(1),
+		}).Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{firstBlob},
+			NextPageToken:  []byte{1},
+			VersionHistory: versionHistory,
+		}, nil).Times(1),
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), &admin.GetWorkflowExecutionRawHistoryV2Request{
+			Domain: common.StringPtr(s.domainName),
+			Execution: &shared.WorkflowExecution{
+				WorkflowId: common.StringPtr(workflowID),
+				RunId:      common.StringPtr(runID),
+			},
+			StartEventId:      common.Int64Ptr(4),
+			StartEventVersion: common.Int64Ptr(124),
+			MaximumPageSize:   common.Int32Ptr(1),
+		}).Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{lastBlob},
+			VersionHistory: versionHistory,
+		}, nil).Times(1),
+	)
+
+	lag, err := s.resender.EstimateReplicationLag(context.Background(), s.domainID, workflowID, runID)
+	s.NoError(err)
+	s.Equal(time.Minute, lag)
+}
+
+func (s *nDCHistoryResenderSuite) TestEstimateReplicationLag_NoHistoryEvents() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			VersionHistory: &shared.VersionHistory{},
+		}, nil).Times(1)
+
+	_, err := s.resender.EstimateReplicationLag(context.Background(), s.domainID, workflowID, runID)
+	s.Equal(ErrNoHistoryEvents, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendWorkflowChain() {
+	workflowID := "some random workflow ID"
+	runID1 := uuid.New()
+	runID2 := uuid.New()
+	newRunBlob := func(nextRunID string) *shared.DataBlob {
+		events := []*shared.HistoryEvent{
+			{
+				EventId:   common.Int64Ptr(1),
+				Version:   common.Int64Ptr(123),
+				Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+				EventType: shared.EventTypeWorkflowExecutionStarted.Ptr(),
+			},
+		}
+		if nextRunID != "" {
+			events = append(events, &shared.HistoryEvent{
+				EventId:   common.Int64Ptr(2),
+				Version:   common.Int64Ptr(123),
+				Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+				EventType: shared.EventTypeWorkflowExecutionContinuedAsNew.Ptr(),
+				WorkflowExecutionContinuedAsNewEventAttributes: &shared.WorkflowExecutionContinuedAsNewEventAttributes{
+					NewExecutionRunId: common.StringPtr(nextRunID),
+				},
+			})
+		}
+		return s.serializeEvents(events)
+	}
+	nextRunIDs := map[string]string{runID1: runID2}
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			return &admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{newRunBlob(nextRunIDs[request.Execution.GetRunId()])},
+				VersionHistory: &shared.VersionHistory{
+					Items: []*shared.VersionHistoryItem{
+						{
+							EventID: common.Int64Ptr(2),
+							Version: common.Int64Ptr(123),
+						},
+					},
+				},
+			}, nil
+		}).AnyTimes()
+	var sentRunIDs []string
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *history.ReplicateEventsV2Request, opts ...yarpc.CallOption) error {
+			sentRunIDs = append(sentRunIDs, request.WorkflowExecution.GetRunId())
+			return nil
+		}).AnyTimes()
+
+	results, err := s.resender.SendWorkflowChain(context.Background(), s.domainID, workflowID, runID1)
+	s.NoError(err)
+	s.Len(results, 2)
+	s.Equal(runID1, results[0].RunID)
+	s.Equal(1, results[0].Result.BatchCount)
+	s.Equal(runID2, results[1].RunID)
+	s.Equal(1, results[1].Result.BatchCount)
+	s.Equal([]string{runID1, runID2}, sentRunIDs)
+
+	// the chain stops at the cycle, instead of resending the runs again
+	nextRunIDs[runID2] = runID1
+	sentRunIDs = nil
+	results, err = s.resender.SendWorkflowChain(context.Background(), s.domainID, workflowID, runID1)
+	s.NoError(err)
+	s.Len(results, 2)
+	s.Equal([]string{runID1, runID2}, sentRunIDs)
+}
+
+func (s *nDCHistoryResenderSuite) TestPing() {
+	testCases := []struct {
+		adminErr      error
+		expectedCause error
+		expectedErr   error
+	}{
+		{
+			adminErr: &shared.EntityNotExistsError{},
+		},
+		{
+			adminErr:      yarpcerrors.UnavailableErrorf("some random error"),
+			expectedCause: ErrSourceUnreachable,
+		},
+		{
+			adminErr:      context.DeadlineExceeded,
+			expectedCause: ErrSourceUnreachable,
+		},
+		{
+			adminErr:      &shared.AccessDeniedError{},
+			expectedCause: ErrSourceAccessDenied,
+		},
+		{
+			adminErr:      yarpcerrors.UnauthenticatedErrorf("some random error"),
+			expectedCause: ErrSourceAccessDenied,
+		},
+		{
+			adminErr:      yarpcerrors.UnavailableErrorf("authentication handshake failed: x509: certificate signed by unknown authority"),
+			expectedCause: ErrSourceTLSHandshakeFailed,
+		},
+		{
+			adminErr:    &shared.InternalServiceError{},
+			expectedErr: &shared.InternalServiceError{},
+		},
+	}
+
+	for _, tc := range testCases {
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+				s.Equal(s.domainName, request.GetDomain())
+				s.Equal(int32(1), request.GetMaximumPageSize())
+				return nil, tc.adminErr
+			}).Times(1)
+
+		err := s.resender.Ping(context.Background(), s.domainID)
+		switch {
+		case tc.expectedCause != nil:
+			s.True(errors.Is(err, tc.expectedCause))
+			pingErr, ok := err.(*PingError)
+			s.True(ok)
+			s.Equal(tc.adminErr, pingErr.Err)
+			s.Equal(cluster.TestCurrentClusterName, pingErr.SourceCluster)
+		case tc.expectedErr != nil:
+			s.Equal(tc.expectedErr, err)
+		default:
+			s.NoError(err)
+		}
+	}
+}
+
+func (s *nDCHistoryResenderSuite) TestPing_AdminHeaders() {
+	headers := map[string]string{
+		"some random header":  "some random value",
+		"other random header": "other random value",
+	}
+	WithAdminHeadersProvider(func(ctx context.Context, domainID string) (map[string]string, error) {
+		s.Equal(s.domainID, domainID)
+		return headers, nil
+	})(s.rereplicator)
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			s.Equal(headers, GetAdminHeaders(ctx))
+			s.Len(opts, len(headers))
+			return nil, &shared.EntityNotExistsError{}
+		}).Times(1)
+
+	s.NoError(s.resender.Ping(context.Background(), s.domainID))
+}
+
+func (s *nDCHistoryResenderSuite) TestValidate_AdminHeaders() {
+	headers := map[string]string{"some random header": "some random value"}
+	WithAdminHeadersProvider(func(ctx context.Context, domainID string) (map[string]string, error) {
+		// no domain is validated
+		s.Empty(domainID)
+		return headers, nil
+	})(s.rereplicator)
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			s.Equal(headers, GetAdminHeaders(ctx))
+			s.Len(opts, len(headers))
+			return nil, &shared.EntityNotExistsError{}
+		}).Times(1)
+
+	s.NoError(s.resender.Validate(context.Background()))
+}
+
+func (s *nDCHistoryResenderSuite) TestValidate() {
+	sourceAdminClient := adminservicetest.NewMockClient(s.controller)
+	WithSourceAdminClients(map[string]adminClient.Client{
+		cluster.TestCurrentClusterName:     s.mockAdminClient,
+		cluster.TestAlternativeClusterName: sourceAdminClient,
+	})(s.rereplicator)
+	tlsErr := yarpcerrors.UnavailableErrorf("authentication handshake failed: x509: certificate has expired")
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			// no domain is needed to validate the source cluster
+			s.Equal(pingDomainName, request.GetDomain())
+			return nil, &shared.EntityNotExistsError{}
+		}).Times(1)
+	sourceAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).Return(nil, tlsErr).Times(1)
+
+	err := s.resender.Validate(context.Background())
+	s.Len(multierr.Errors(err), 1)
+	s.True(errors.Is(err, ErrSourceTLSHandshakeFailed))
+	pingErr, ok := multierr.Errors(err)[0].(*PingError)
+	s.True(ok)
+	s.Equal(cluster.TestAlternativeClusterName, pingErr.SourceCluster)
+	s.Equal(tlsErr, pingErr.Err)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_SourceEntityNotExists() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	invariantMock := checks.NewMockInvariant(s.controller)
+	s.rereplicator.currentExecutionFixer.check = invariantMock
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(nil, &shared.EntityNotExistsError{}).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+	// the run does not exist in the source, the current execution in the target is not checked
+	invariantMock.EXPECT().Check(gomock.Any()).Times(0)
+
+	result, err := s.resender.SendSingleWorkflowHistoryWithResult(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.IsType(&shared.EntityNotExistsError{}, err)
+	s.False(result.Skipped)
+}
+
+func (s *nDCHistoryResenderSuite) TestDecodeBatchEventIDRange() {
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+		{
+			EventId:   common.Int64Ptr(3),
+			Version:   common.Int64Ptr(123),
+			EventType: shared.EventTypeDecisionTaskStarted.Ptr(),
+		},
+		{
+			EventId:   common.Int64Ptr(4),
+			Version:   common.Int64Ptr(123),
+			EventType: shared.EventTypeDecisionTaskCompleted.Ptr(),
+		},
+	})
+	firstEventID, lastEventID, err := decodeBatchEventIDRange(blob.Data)
+	s.NoError(err)
+	s.Equal(int64(2), firstEventID)
+	s.Equal(int64(4), lastEventID)
+
+	_, _, err = decodeBatchEventIDRange([]byte("some random history blob"))
+	s.Error(err)
+	_, _, err = decodeBatchEventIDRange(s.serializeEvents([]*shared.HistoryEvent{{}}).Data)
+	s.Error(err)
+}
+
+func (s *nDCHistoryResenderSuite) TestTranscodeEventBatches() {
+	events := []*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+			DecisionTaskScheduledEventAttributes: &shared.DecisionTaskScheduledEventAttributes{
+				TaskList:                   &shared.TaskList{Name: common.StringPtr("some random task list")},
+				StartToCloseTimeoutSeconds: common.Int32Ptr(10),
+				Attempt:                    common.Int64Ptr(1),
+			},
+		},
+		{
+			EventId:   common.Int64Ptr(3),
+			Version:   common.Int64Ptr(123),
+			EventType: shared.EventTypeDecisionTaskStarted.Ptr(),
+			DecisionTaskStartedEventAttributes: &shared.DecisionTaskStartedEventAttributes{
+				ScheduledEventId: common.Int64Ptr(2),
+				Identity:         common.StringPtr("some random identity"),
+			},
+		},
+	}
+	jsonBlob, err := s.serializer.SerializeBatchEvents(events, common.EncodingTypeJSON)
+	s.NoError(err)
+	thriftBlob := s.serializeEvents(events)
+
+	// not configured, the batches are forwarded as is
+	historyBatches, err := s.rereplicator.transcodeEventBatches(s.domainName, []*shared.DataBlob{jsonBlob.ToThrift()})
+	s.NoError(err)
+	s.Equal([]*shared.DataBlob{jsonBlob.ToThrift()}, historyBatches)
+
+	WithEventBlobEncoding(dynamicconfig.GetStringPropertyFnFilteredByDomain(string(common.EncodingTypeThriftRW)))(s.rereplicator)
+	historyBatches, err = s.rereplicator.transcodeEventBatches(s.domainName, []*shared.DataBlob{jsonBlob.ToThrift(), thriftBlob})
+	s.NoError(err)
+	s.Len(historyBatches, 2)
+	s.Equal(thriftBlob, historyBatches[1])
+	s.Equal(shared.EncodingTypeThriftRW, historyBatches[0].GetEncodingType())
+	transcodedEvents, err := s.serializer.DeserializeBatchEvents(persistence.NewDataBlobFromThrift(historyBatches[0]))
+	s.NoError(err)
+	s.Equal(events, transcodedEvents)
+
+	firstEventID, lastEventID := s.rereplicator.getBatchEventIDRange(s.logger, jsonBlob.ToThrift())
+	s.Equal(int64(2), firstEventID)
+	s.Equal(int64(3), lastEventID)
+
+	WithEventBlobEncoding(dynamicconfig.GetStringPropertyFnFilteredByDomain("some random encoding"))(s.rereplicator)
+	_, err = s.rereplicator.transcodeEventBatches(s.domainName, []*shared.DataBlob{thriftBlob})
+	s.Error(err)
 }
 
 func (s *nDCHistoryResenderSuite) serializeEvents(events []*shared.HistoryEvent) *shared.DataBlob {
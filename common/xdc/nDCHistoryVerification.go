@@ -0,0 +1,395 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package xdc
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"sync"
+
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/metrics"
+	"github.com/uber/cadence/common/persistence"
+)
+
+type (
+	// TargetHistoryReader reads the event batch of the run from the target cluster which starts from the first event ID,
+	// nil batch is returned if the target does not have the events
+	TargetHistoryReader func(
+		ctx context.Context,
+		domainID string,
+		workflowID string,
+		runID string,
+		firstEventID int64,
+	) (*shared.DataBlob, error)
+
+	// VerificationDiffType is the type of the difference between the history events of the source and the target
+	VerificationDiffType int
+
+	// VerificationDiff is a range of history events which differs between the source and the target
+	VerificationDiff struct {
+		Type         VerificationDiffType
+		FirstEventID int64
+		LastEventID  int64
+		// Detail describes the mismatch, it is empty for the missing events
+		Detail string
+	}
+
+	// VerificationReport is the result of the verification of the history events of a run in the target
+	VerificationReport struct {
+		// BatchCount is the number of event batches of the source verified
+		BatchCount int
+		// Diffs is the differences found ordered by the event IDs, the consecutive missing ranges are merged
+		Diffs []*VerificationDiff
+	}
+
+	batchVerification struct {
+		diff *VerificationDiff
+	}
+)
+
+const (
+	// VerificationDiffMissing indicates the events are missing in the target
+	VerificationDiffMissing VerificationDiffType = iota
+	// VerificationDiffMismatched indicates the events of the target differ from the source
+	VerificationDiffMismatched
+)
+
+const (
+	defaultVerifyConcurrency = 1
+)
+
+// String returns the name of the diff type
+func (t VerificationDiffType) String() string {
+	switch t {
+	case VerificationDiffMissing:
+		return "Missing"
+	case VerificationDiffMismatched:
+		return "Mismatched"
+	default:
+		return "Unknown"
+	}
+}
+
+// VerifyWorkflowHistory compares the history events of the run in the source with the ones read from the target
+// by the target reader, batch by batch, without sending anything to remote. The event batches are compared
+// concurrently, bounded by the verify concurrency, and the report is ordered by the event IDs regardless
+func (n *NDCHistoryResenderImpl) VerifyWorkflowHistory(
+	ctx context.Context,
+	domainID string,
+	workflowID string,
+	runID string,
+	targetReader TargetHistoryReader,
+) (*VerificationReport, error) {
+
+	if targetReader == nil {
+		return nil, &shared.BadRequestError{Message: "Target history reader is not set."}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	slots := make(chan struct{}, n.getVerifyConcurrency(domainID))
+	var wg sync.WaitGroup
+	var verifications []*batchVerification
+	var verifyErrLock sync.Mutex
+	var verifyErr error
+	_, fetchErr := n.FetchWorkflowHistory(
+		ctx,
+		domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		func(batch *FetchedEventBatch) error {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			verification := &batchVerification{}
+			verifications = append(verifications, verification)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				diff, err := n.verifyEventBatch(ctx, domainID, workflowID, runID, batch.RawEventBatch, targetReader)
+				if err != nil {
+					verifyErrLock.Lock()
+					if verifyErr == nil {
+						verifyErr = err
+						// the outstanding verifications are pointless
+						cancel()
+					}
+					verifyErrLock.Unlock()
+					return
+				}
+				verification.diff = diff
+			}()
+			return nil
+		},
+	)
+	wg.Wait()
+	// the fetch may fail due to the cancellation caused by the verification error
+	if verifyErr != nil {
+		return nil, verifyErr
+	}
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+
+	report := &VerificationReport{
+		BatchCount: len(verifications),
+	}
+	for _, verification := range verifications {
+		diff := verification.diff
+		if diff == nil {
+			continue
+		}
+		if len(report.Diffs) > 0 {
+			lastDiff := report.Diffs[len(report.Diffs)-1]
+			if diff.Type == VerificationDiffMissing && lastDiff.Type == VerificationDiffMissing &&
+				diff.FirstEventID == lastDiff.LastEventID+1 {
+				lastDiff.LastEventID = diff.LastEventID
+				continue
+			}
+		}
+		report.Diffs = append(report.Diffs, diff)
+	}
+	return report, nil
+}
+
+// verifyEventBatch returns the difference between the source event batch and the target one, nil if there is none
+func (n *NDCHistoryResenderImpl) verifyEventBatch(
+	ctx context.Context,
+	domainID string,
+	workflowID string,
+	runID string,
+	sourceBatch *shared.DataBlob,
+	targetReader TargetHistoryReader,
+) (*VerificationDiff, error) {
+
+	firstEventID, lastEventID := n.getBatchEventIDRange(n.getLogger(ctx), sourceBatch)
+	targetBatch, err := targetReader(ctx, domainID, workflowID, runID, firstEventID)
+	if err != nil {
+		return nil, err
+	}
+	if targetBatch == nil {
+		return &VerificationDiff{
+			Type:         VerificationDiffMissing,
+			FirstEventID: firstEventID,
+			LastEventID:  lastEventID,
+		}, nil
+	}
+	if detail := n.compareEventBatches(sourceBatch, targetBatch); detail != "" {
+		return &VerificationDiff{
+			Type:         VerificationDiffMismatched,
+			FirstEventID: firstEventID,
+			LastEventID:  lastEventID,
+			Detail:       detail,
+		}, nil
+	}
+	return nil, nil
+}
+
+// compareEventBatches compares the IDs and the versions of the events, and the checksums of the event batches
+// if they are in the same encoding, the description of the first mismatch is returned, empty if they match
+func (n *NDCHistoryResenderImpl) compareEventBatches(
+	sourceBatch *shared.DataBlob,
+	targetBatch *shared.DataBlob,
+) string {
+
+	sameEncoding := sourceBatch.GetEncodingType() == targetBatch.GetEncodingType()
+	if sameEncoding && crc32.ChecksumIEEE(sourceBatch.GetData()) == crc32.ChecksumIEEE(targetBatch.GetData()) {
+		return ""
+	}
+
+	sourceEvents, err := n.serializer.DeserializeBatchEvents(persistence.NewDataBlobFromThrift(sourceBatch))
+	if err != nil {
+		return fmt.Sprintf("failed to deserialize source events: %v", err)
+	}
+	targetEvents, err := n.serializer.DeserializeBatchEvents(persistence.NewDataBlobFromThrift(targetBatch))
+	if err != nil {
+		return fmt.Sprintf("failed to deserialize target events: %v", err)
+	}
+	if len(sourceEvents) != len(targetEvents) {
+		return fmt.Sprintf("event count mismatch, source: %v, target: %v", len(sourceEvents), len(targetEvents))
+	}
+	for i, sourceEvent := range sourceEvents {
+		targetEvent := targetEvents[i]
+		if sourceEvent.GetEventId() != targetEvent.GetEventId() {
+			return fmt.Sprintf("event ID mismatch, source: %v, target: %v", sourceEvent.GetEventId(), targetEvent.GetEventId())
+		}
+		if sourceEvent.GetVersion() != targetEvent.GetVersion() {
+			return fmt.Sprintf("version mismatch of event %v, source: %v, target: %v",
+				sourceEvent.GetEventId(), sourceEvent.GetVersion(), targetEvent.GetVersion())
+		}
+	}
+	if sameEncoding {
+		return "checksum mismatch"
+	}
+	// the checksums of the batches in different encodings are not comparable
+	return ""
+}
+
+func (n *NDCHistoryResenderImpl) getVerifyConcurrency(
+	domainID string,
+) int {
+
+	if n.verifyConcurrency == nil {
+		return defaultVerifyConcurrency
+	}
+	if concurrency := n.verifyConcurrency(domainID); concurrency > 0 {
+		return concurrency
+	}
+	return defaultVerifyConcurrency
+}
+
+func validateVersionHistoryItems(
+	items []*shared.VersionHistoryItem,
+	endEventVersion *int64,
+) error {
+
+	if len(items) == 0 {
+		return &shared.InternalServiceError{Message: "Version history of events is empty."}
+	}
+	for i := 1; i < len(items); i++ {
+		prev := items[i-1]
+		curr := items[i]
+		if curr.GetEventID() <= prev.GetEventID() || curr.GetVersion() <= prev.GetVersion() {
+			return &shared.InternalServiceError{Message: fmt.Sprintf(
+				"Version history items are not increasing, item %v: (event ID: %v, version: %v), item %v: (event ID: %v, version: %v).",
+				i-1, prev.GetEventID(), prev.GetVersion(), i, curr.GetEventID(), curr.GetVersion(),
+			)}
+		}
+	}
+	lastItem := items[len(items)-1]
+	if endEventVersion != nil && lastItem.GetVersion() != *endEventVersion {
+		return &shared.InternalServiceError{Message: fmt.Sprintf(
+			"Version history last item version: %v does not match end event version: %v.",
+			lastItem.GetVersion(), *endEventVersion,
+		)}
+	}
+	return nil
+}
+
+// validateEventBatch verifies the event batch deserializes into non empty and consecutive history events
+func (n *NDCHistoryResenderImpl) validateEventBatch(
+	rawEventBatch *shared.DataBlob,
+) error {
+
+	events, err := n.deserializeEventBatch(rawEventBatch)
+	if err != nil {
+		return &shared.InternalServiceError{Message: fmt.Sprintf("Failed to deserialize history events: %v.", err)}
+	}
+	if len(events) == 0 {
+		return &shared.InternalServiceError{Message: "History events are empty."}
+	}
+	for i, event := range events {
+		if event.EventType == nil || event.EventId == nil || event.Version == nil {
+			return &shared.InternalServiceError{Message: fmt.Sprintf(
+				"History event %v is missing event type, event ID or version.", i,
+			)}
+		}
+		if i > 0 && event.GetEventId() != events[i-1].GetEventId()+1 {
+			return &shared.InternalServiceError{Message: fmt.Sprintf(
+				"History event IDs are not consecutive, event %v: %v, event %v: %v.",
+				i-1, events[i-1].GetEventId(), i, event.GetEventId(),
+			)}
+		}
+	}
+	return nil
+}
+
+// verifyMissingHistoryBatch verifies the batch resent by SendMissingHistory is on the branch of the last event
+// of the target, the source sends the events after the lowest common ancestor if the target is on another branch
+func verifyMissingHistoryBatch(
+	descriptor *ResendDescriptor,
+	batch *historyBatch,
+) error {
+
+	if !descriptor.missingOnly {
+		return nil
+	}
+	startItem := persistence.NewVersionHistoryItem(*descriptor.StartEventID, *descriptor.StartEventVersion)
+	if !persistence.NewVersionHistoryFromThrift(batch.versionHistory).ContainsItem(startItem) {
+		return &shared.BadRequestError{Message: fmt.Sprintf(
+			"Target last event ID %v with version %v is not in the version history of the run in the source cluster.",
+			startItem.GetEventID(), startItem.GetVersion(),
+		)}
+	}
+	return nil
+}
+
+// verifyHistoryBatch verifies the version history and the events of the batch to resend, and that the batch follows
+// the last one forwarded, it returns whether the batch is skipped as invalid, which leaves an intended gap
+func (n *NDCHistoryResenderImpl) verifyHistoryBatch(
+	run *resendRun,
+	batch *historyBatch,
+	firstEventID int64,
+) (bool, error) {
+
+	domainID := run.descriptor.DomainID
+	if n.strictVersionHistoryValidation != nil && n.strictVersionHistoryValidation(domainID) {
+		if err := validateVersionHistoryItems(
+			batch.versionHistory.GetItems(),
+			run.descriptor.EndEventVersion,
+		); err != nil {
+			run.logger.Error("invalid version history of events", tag.Error(err))
+			return false, err
+		}
+	}
+	if n.eventBatchValidation != nil && n.eventBatchValidation(domainID) {
+		if err := n.validateEventBatch(batch.rawEventBatch); err != nil {
+			run.scope.IncCounter(metrics.HistoryResendInvalidEventBatchCounter)
+			run.logger.Error("invalid history events", tag.Counter(run.result.BatchCount), tag.Error(err))
+			if n.skipInvalidEventBatch == nil || !n.skipInvalidEventBatch(domainID) {
+				return false, err
+			}
+			run.advancePageToken(batch)
+			// the gap left by the skipped batch is intended
+			run.lastForwardedEventID = common.EmptyEventID
+			return true, nil
+		}
+	}
+
+	lastForwardedEventID := run.lastForwardedEventID
+	if lastForwardedEventID != common.EmptyEventID && firstEventID != common.EmptyEventID &&
+		firstEventID != lastForwardedEventID+1 {
+		run.scope.IncCounter(metrics.HistoryResendHistoryGapCounter)
+		run.logger.Error("gap in history events to resend",
+			tag.WorkflowEventID(firstEventID),
+			tag.WorkflowNextEventID(lastForwardedEventID+1))
+		if n.failOnHistoryGap != nil && n.failOnHistoryGap(domainID) {
+			return false, &HistoryGapError{
+				ExpectedEventID: lastForwardedEventID + 1,
+				ActualEventID:   firstEventID,
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,331 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+
+	"github.com/uber/cadence/.gen/go/admin"
+	"github.com/uber/cadence/.gen/go/history"
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+)
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_InvalidEventBatch() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	corruptedBlob := &shared.DataBlob{
+		EncodingType: shared.EncodingTypeThriftRW.Ptr(),
+		Data:         []byte("some random corrupted data"),
+	}
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(3),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskStarted.Ptr(),
+		},
+	})
+	versionHistory := &shared.VersionHistory{
+		Items: []*shared.VersionHistoryItem{
+			{
+				EventID: common.Int64Ptr(3),
+				Version: common.Int64Ptr(123),
+			},
+		},
+	}
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{corruptedBlob, blob},
+			VersionHistory: versionHistory,
+		}, nil).Times(2)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), &history.ReplicateEventsV2Request{
+		DomainUUID: common.StringPtr(s.domainID),
+		WorkflowExecution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		VersionHistoryItems: versionHistory.Items,
+		Events:              blob,
+	}).Return(nil).Times(1)
+
+	skipInvalidEventBatch := false
+	WithEventBatchValidation(func(domainID string) bool { return true })(s.rereplicator)
+	WithSkipInvalidEventBatch(func(domainID string) bool { return skipInvalidEventBatch })(s.rereplicator)
+	sendHistory := func() (*ResendResult, error) {
+		return s.resender.SendSingleWorkflowHistoryWithResult(
+			context.Background(),
+			s.domainID,
+			workflowID,
+			runID,
+			common.Int64Ptr(1),
+			common.Int64Ptr(123),
+			nil,
+			nil,
+		)
+	}
+
+	result, err := sendHistory()
+	s.IsType(&shared.InternalServiceError{}, err)
+	s.Equal(0, result.BatchCount)
+
+	skipInvalidEventBatch = true
+	result, err = sendHistory()
+	s.NoError(err)
+	s.Equal(1, result.BatchCount)
+	s.Equal(int64(3), result.FirstEventID)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_StrictVersionHistoryValidation() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	WithStrictVersionHistoryValidation(func(domainID string) bool { return true })(s.rereplicator)
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			NextPageToken:  nil,
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{EventID: common.Int64Ptr(5), Version: common.Int64Ptr(100)},
+					{EventID: common.Int64Ptr(3), Version: common.Int64Ptr(123)},
+				},
+			},
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	err := s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.IsType(&shared.InternalServiceError{}, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestValidateVersionHistoryItems() {
+	item := func(eventID int64, version int64) *shared.VersionHistoryItem {
+		return &shared.VersionHistoryItem{EventID: common.Int64Ptr(eventID), Version: common.Int64Ptr(version)}
+	}
+
+	s.NoError(validateVersionHistoryItems([]*shared.VersionHistoryItem{item(3, 1), item(5, 2)}, nil))
+	s.NoError(validateVersionHistoryItems([]*shared.VersionHistoryItem{item(3, 1), item(5, 2)}, common.Int64Ptr(2)))
+	s.Error(validateVersionHistoryItems(nil, nil))
+	s.Error(validateVersionHistoryItems([]*shared.VersionHistoryItem{item(5, 1), item(3, 2)}, nil))
+	s.Error(validateVersionHistoryItems([]*shared.VersionHistoryItem{item(3, 2), item(5, 1)}, nil))
+	s.Error(validateVersionHistoryItems([]*shared.VersionHistoryItem{item(3, 1), item(5, 2)}, common.Int64Ptr(1)))
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_HistoryGap() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	newBlob := func(eventIDs ...int64) *shared.DataBlob {
+		var events []*shared.HistoryEvent
+		for _, eventID := range eventIDs {
+			events = append(events, &shared.HistoryEvent{
+				EventId:   common.Int64Ptr(eventID),
+				Version:   common.Int64Ptr(123),
+				Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+				EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+			})
+		}
+		return s.serializeEvents(events)
+	}
+	versionHistory := &shared.VersionHistory{
+		Items: []*shared.VersionHistoryItem{
+			{
+				EventID: common.Int64Ptr(6),
+				Version: common.Int64Ptr(123),
+			},
+		},
+	}
+	failOnHistoryGap := true
+	WithFailOnHistoryGap(func(domainID string) bool { return failOnHistoryGap })(s.rereplicator)
+	resend := func() (*ResendResult, error) {
+		return s.resender.SendSingleWorkflowHistoryWithResult(
+			context.Background(),
+			s.domainID,
+			workflowID,
+			runID,
+			nil,
+			nil,
+			nil,
+			nil,
+		)
+	}
+
+	// contiguous
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{newBlob(2), newBlob(3, 4), newBlob(5)},
+			VersionHistory: versionHistory,
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(3)
+	result, err := resend()
+	s.NoError(err)
+	s.Equal(3, result.BatchCount)
+
+	// gapped
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{newBlob(2), newBlob(4, 5)},
+			VersionHistory: versionHistory,
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	result, err = resend()
+	s.True(errors.Is(err, ErrHistoryGap))
+	s.Equal(&HistoryGapError{ExpectedEventID: 3, ActualEventID: 4}, err)
+	s.Equal(1, result.BatchCount)
+
+	// gapped, logged only
+	failOnHistoryGap = false
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{newBlob(2), newBlob(4, 5)},
+			VersionHistory: versionHistory,
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	result, err = resend()
+	s.NoError(err)
+	s.Equal(2, result.BatchCount)
+}
+
+func (s *nDCHistoryResenderSuite) TestVerifyWorkflowHistory() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	newEvent := func(eventID int64, version int64) *shared.HistoryEvent {
+		return &shared.HistoryEvent{
+			EventId:   common.Int64Ptr(eventID),
+			Version:   common.Int64Ptr(version),
+			Timestamp: common.Int64Ptr(123),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		}
+	}
+	sourceBatches := []*shared.DataBlob{
+		s.serializeEvents([]*shared.HistoryEvent{newEvent(2, 123)}),
+		s.serializeEvents([]*shared.HistoryEvent{newEvent(3, 123)}),
+		s.serializeEvents([]*shared.HistoryEvent{newEvent(4, 123)}),
+		s.serializeEvents([]*shared.HistoryEvent{newEvent(5, 123)}),
+		s.serializeEvents([]*shared.HistoryEvent{newEvent(6, 123)}),
+	}
+	targetBatches := map[int64]*shared.DataBlob{
+		2: sourceBatches[0],
+		5: s.serializeEvents([]*shared.HistoryEvent{newEvent(5, 456)}),
+		6: sourceBatches[4],
+	}
+	WithVerifyConcurrency(func(domainID string) int { return 2 })(s.rereplicator)
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: sourceBatches,
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(6),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	var readLock sync.Mutex
+	var readEventIDs []int64
+	report, err := s.resender.VerifyWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		func(ctx context.Context, domainID, workflowID, runID string, firstEventID int64) (*shared.DataBlob, error) {
+			readLock.Lock()
+			defer readLock.Unlock()
+			readEventIDs = append(readEventIDs, firstEventID)
+			return targetBatches[firstEventID], nil
+		},
+	)
+	s.NoError(err)
+	s.ElementsMatch([]int64{2, 3, 4, 5, 6}, readEventIDs)
+	s.Equal(5, report.BatchCount)
+	s.Len(report.Diffs, 2)
+	s.Equal(&VerificationDiff{
+		Type:         VerificationDiffMissing,
+		FirstEventID: 3,
+		LastEventID:  4,
+	}, report.Diffs[0])
+	s.Equal(VerificationDiffMismatched, report.Diffs[1].Type)
+	s.Equal(int64(5), report.Diffs[1].FirstEventID)
+	s.Equal(int64(5), report.Diffs[1].LastEventID)
+	s.Contains(report.Diffs[1].Detail, "version mismatch")
+}
+
+func (s *nDCHistoryResenderSuite) TestVerifyWorkflowHistory_TargetReaderError() {
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+
+	readErr := &shared.InternalServiceError{Message: "some random error"}
+	report, err := s.resender.VerifyWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		"some random workflow ID",
+		uuid.New(),
+		func(ctx context.Context, domainID, workflowID, runID string, firstEventID int64) (*shared.DataBlob, error) {
+			return nil, readErr
+		},
+	)
+	s.Equal(readErr, err)
+	s.Nil(report)
+}
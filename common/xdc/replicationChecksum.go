@@ -0,0 +1,80 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package xdc
+
+import (
+	"context"
+	"hash/crc32"
+	"sync"
+
+	"github.com/uber/cadence/.gen/go/history"
+)
+
+type (
+	// replicationChecksumAck holds the checksum of the event batch reported by the target
+	replicationChecksumAck struct {
+		sync.Mutex
+		checksum uint32
+		reported bool
+	}
+)
+
+// ComputeReplicationChecksum returns the IEEE crc32 checksum of the event batch of the replication request,
+// computed over the event blob as sent, so the target can compute the same checksum over the blob it receives
+func ComputeReplicationChecksum(
+	request *history.ReplicateEventsV2Request,
+) uint32 {
+
+	return crc32.ChecksumIEEE(request.GetEvents().GetData())
+}
+
+// ReportReplicationChecksum reports the checksum of the event batch computed by the target, it should be called
+// by the replication fn with the context passed to it, if the target acknowledges the batch with a checksum,
+// the resender then verifies it against the checksum of the batch sent. Nothing is verified if not reported
+func ReportReplicationChecksum(
+	ctx context.Context,
+	checksum uint32,
+) {
+
+	ack, ok := ctx.Value(replicationChecksumKey).(*replicationChecksumAck)
+	if !ok {
+		return
+	}
+	ack.Lock()
+	defer ack.Unlock()
+	ack.checksum = checksum
+	ack.reported = true
+}
+
+// withReplicationChecksumAck returns a copy of the context carrying an empty checksum acknowledgement
+func withReplicationChecksumAck(
+	ctx context.Context,
+) (context.Context, *replicationChecksumAck) {
+
+	ack := &replicationChecksumAck{}
+	return context.WithValue(ctx, replicationChecksumKey, ack), ack
+}
+
+// get returns the checksum reported by the target, and whether it is reported at all
+func (a *replicationChecksumAck) get() (uint32, bool) {
+	a.Lock()
+	defer a.Unlock()
+	return a.checksum, a.reported
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package xdc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uber/cadence/.gen/go/history"
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+)
+
+func TestReplicationChecksum(t *testing.T) {
+	request := &history.ReplicateEventsV2Request{
+		Events: &shared.DataBlob{Data: []byte("some random history blob")},
+	}
+	checksum := ComputeReplicationChecksum(request)
+	assert.Equal(t, checksum, ComputeReplicationChecksum(request))
+	assert.NotEqual(t, checksum, ComputeReplicationChecksum(&history.ReplicateEventsV2Request{
+		Events: &shared.DataBlob{Data: []byte("some other history blob")},
+	}))
+
+	// reporting without an acknowledgement in the context is a no-op
+	ReportReplicationChecksum(context.Background(), checksum)
+
+	ctx, ack := withReplicationChecksumAck(context.Background())
+	_, ok := ack.get()
+	assert.False(t, ok)
+	ReportReplicationChecksum(ctx, checksum)
+	reportedChecksum, ok := ack.get()
+	assert.True(t, ok)
+	assert.Equal(t, checksum, reportedChecksum)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendReplicationRawRequest_Checksum() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	request := &history.ReplicateEventsV2Request{
+		DomainUUID: common.StringPtr(s.domainID),
+		WorkflowExecution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		Events: &shared.DataBlob{
+			EncodingType: shared.EncodingTypeThriftRW.Ptr(),
+			Data:         []byte("some random history blob"),
+		},
+	}
+	newTarget := func(checksum *uint32) nDCHistoryReplicationFn {
+		return func(ctx context.Context, replicationRequest *history.ReplicateEventsV2Request) error {
+			if checksum != nil {
+				ReportReplicationChecksum(ctx, *checksum)
+			}
+			return nil
+		}
+	}
+	checksum := ComputeReplicationChecksum(request)
+	corruptedChecksum := checksum + 1
+
+	s.rereplicator.historyReplicationFns = []nDCHistoryReplicationFn{newTarget(&checksum), newTarget(nil)}
+	err := s.rereplicator.sendReplicationRawRequest(context.Background(), s.domainID, request)
+	s.NoError(err)
+
+	s.rereplicator.historyReplicationFns = []nDCHistoryReplicationFn{newTarget(&checksum), newTarget(&corruptedChecksum)}
+	err = s.rereplicator.sendReplicationRawRequest(context.Background(), s.domainID, request)
+	s.Equal(ErrReplicationChecksumMismatch, err)
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/metrics"
+	"github.com/uber/cadence/common/service/dynamicconfig"
+)
+
+const (
+	defaultCircuitBreakerCooldown = 30 * time.Second
+)
+
+type (
+	// resendCircuitBreaker fails fast the resends of the domains with too many consecutive failures,
+	// it is disabled if the threshold is not set
+	resendCircuitBreaker struct {
+		threshold dynamicconfig.IntPropertyFnWithDomainIDFilter
+		cooldown  dynamicconfig.DurationPropertyFnWithDomainIDFilter
+
+		sync.Mutex
+		breakers map[string]*domainCircuitBreaker
+	}
+
+	// domainCircuitBreaker tracks the consecutive resend failures of a domain,
+	// it is removed from the map once a resend of the domain succeeds
+	domainCircuitBreaker struct {
+		consecutiveFailures int
+		openUntil           time.Time
+	}
+)
+
+func newResendCircuitBreaker(
+	threshold dynamicconfig.IntPropertyFnWithDomainIDFilter,
+	cooldown dynamicconfig.DurationPropertyFnWithDomainIDFilter,
+) *resendCircuitBreaker {
+
+	return &resendCircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		breakers:  make(map[string]*domainCircuitBreaker),
+	}
+}
+
+// isOpen returns whether the resends of the domain should fail fast at the given time
+func (b *resendCircuitBreaker) isOpen(
+	domainID string,
+	now time.Time,
+) bool {
+
+	if b.threshold == nil {
+		return false
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	breaker, ok := b.breakers[domainID]
+	return ok && now.Before(breaker.openUntil)
+}
+
+// recordResult opens the circuit breaker of the domain once the consecutive failures reach the threshold,
+// after the cooldown elapses, a single failure reopens the circuit breaker until a resend succeeds.
+// err is the error of the resend, which counts as a failure only if failed is set
+func (b *resendCircuitBreaker) recordResult(
+	logger log.Logger,
+	domainID string,
+	err error,
+	failed bool,
+	now time.Time,
+) {
+
+	if b.threshold == nil {
+		return
+	}
+	threshold := b.threshold(domainID)
+
+	b.Lock()
+	defer b.Unlock()
+
+	if threshold <= 0 || !failed {
+		delete(b.breakers, domainID)
+		return
+	}
+
+	breaker, ok := b.breakers[domainID]
+	if !ok {
+		breaker = &domainCircuitBreaker{}
+		b.breakers[domainID] = breaker
+	}
+	breaker.consecutiveFailures++
+	if breaker.consecutiveFailures >= threshold {
+		breaker.openUntil = now.Add(b.getCooldown(domainID))
+		logger.Warn("history resend circuit breaker is open",
+			tag.WorkflowDomainID(domainID),
+			tag.Counter(breaker.consecutiveFailures),
+			tag.Error(err))
+	}
+}
+
+func (b *resendCircuitBreaker) getCooldown(
+	domainID string,
+) time.Duration {
+
+	if b.cooldown == nil {
+		return defaultCircuitBreakerCooldown
+	}
+	return b.cooldown(domainID)
+}
+
+// isCircuitBreakerFailure returns whether the error indicates the source or the target is unhealthy
+func (n *NDCHistoryResenderImpl) isCircuitBreakerFailure(
+	err error,
+) bool {
+
+	if err == nil {
+		return false
+	}
+	if partialErr, ok := err.(*ResendPartialError); ok {
+		err = partialErr.Err
+	}
+	// the pending fix of the current execution in the target says nothing about the health of either cluster
+	if err == ErrCurrentExecutionFixPending {
+		return false
+	}
+	return n.errorClassifier.IsRetryable(err)
+}
+
+// checkResendCircuit returns ErrResendCircuitOpen if the circuit breaker of the domain of the run is open
+func (n *NDCHistoryResenderImpl) checkResendCircuit(
+	run *resendRun,
+) error {
+
+	if n.circuitBreaker.isOpen(run.descriptor.DomainID, n.timeSource.Now()) {
+		run.scope.IncCounter(metrics.HistoryResendCircuitOpenCounter)
+		return ErrResendCircuitOpen
+	}
+	return nil
+}
+
+// recordResendCircuitResult records the result of the resend in the circuit breaker of the domain of the run
+func (n *NDCHistoryResenderImpl) recordResendCircuitResult(
+	run *resendRun,
+	err error,
+) {
+
+	n.circuitBreaker.recordResult(
+		run.logger,
+		run.descriptor.DomainID,
+		err,
+		n.isCircuitBreakerFailure(err),
+		n.timeSource.Now(),
+	)
+}
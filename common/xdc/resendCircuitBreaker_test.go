@@ -0,0 +1,172 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uber/cadence/.gen/go/admin"
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/clock"
+	"github.com/uber/cadence/common/log/loggerimpl"
+	"github.com/uber/cadence/common/service/dynamicconfig"
+)
+
+func TestResendCircuitBreaker(t *testing.T) {
+	logger := loggerimpl.NewNopLogger()
+	domainID := "some random domain ID"
+	err := errors.New("some random error")
+	now := time.Now()
+	breaker := newResendCircuitBreaker(
+		dynamicconfig.GetIntPropertyFilteredByDomain(2),
+		dynamicconfig.GetDurationPropertyFnFilteredByDomain(time.Minute),
+	)
+
+	breaker.recordResult(logger, domainID, err, true, now)
+	assert.False(t, breaker.isOpen(domainID, now))
+	breaker.recordResult(logger, domainID, err, true, now)
+	assert.True(t, breaker.isOpen(domainID, now))
+	assert.False(t, breaker.isOpen("some other domain ID", now))
+
+	// a single failure reopens the circuit breaker after the cooldown
+	now = now.Add(time.Minute)
+	assert.False(t, breaker.isOpen(domainID, now))
+	breaker.recordResult(logger, domainID, err, true, now)
+	assert.True(t, breaker.isOpen(domainID, now))
+
+	// the errors not counted as failures close the circuit breaker
+	breaker.recordResult(logger, domainID, err, false, now)
+	assert.False(t, breaker.isOpen(domainID, now))
+	assert.Empty(t, breaker.breakers)
+}
+
+func TestResendCircuitBreaker_Disabled(t *testing.T) {
+	logger := loggerimpl.NewNopLogger()
+	domainID := "some random domain ID"
+	now := time.Now()
+
+	for _, breaker := range []*resendCircuitBreaker{
+		newResendCircuitBreaker(nil, nil),
+		newResendCircuitBreaker(dynamicconfig.GetIntPropertyFilteredByDomain(0), nil),
+	} {
+		for i := 0; i < 3; i++ {
+			breaker.recordResult(logger, domainID, errors.New("some random error"), true, now)
+		}
+		assert.False(t, breaker.isOpen(domainID, now))
+	}
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_CircuitBreaker() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	timeSource := clock.NewEventTimeSource().Update(time.Now())
+	s.rereplicator.timeSource = timeSource
+	WithGetHistoryRetryPolicy(nil)(s.rereplicator)
+	WithCircuitBreaker(
+		func(domainID string) int { return 2 },
+		func(domainID string) time.Duration { return time.Minute },
+	)(s.rereplicator)
+	sendHistory := func() error {
+		return s.resender.SendSingleWorkflowHistory(
+			context.Background(),
+			s.domainID,
+			workflowID,
+			runID,
+			common.Int64Ptr(1),
+			common.Int64Ptr(123),
+			nil,
+			nil,
+		)
+	}
+
+	estimateHistory := func() error {
+		_, err := s.resender.EstimateResend(context.Background(), &ResendDescriptor{
+			DomainID:   s.domainID,
+			WorkflowID: workflowID,
+			RunID:      runID,
+		})
+		return err
+	}
+
+	transientErr := &shared.InternalServiceError{Message: "some transient error"}
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(nil, transientErr).Times(4)
+	s.Equal(transientErr, sendHistory())
+	// the failed estimation is not counted by the circuit breaker
+	s.Equal(transientErr, estimateHistory())
+	s.Equal(transientErr, sendHistory())
+	s.Equal(ErrResendCircuitOpen, sendHistory())
+	// the estimation is not blocked by the open circuit breaker
+	s.Equal(transientErr, estimateHistory())
+
+	timeSource.Update(timeSource.Now().Add(time.Minute))
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{},
+			VersionHistory: &shared.VersionHistory{},
+		}, nil).Times(1)
+	s.NoError(sendHistory())
+
+	// the success resets the consecutive failures
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(nil, transientErr).Times(1)
+	s.Equal(transientErr, sendHistory())
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(nil, &shared.BadRequestError{}).Times(1)
+	s.IsType(&shared.BadRequestError{}, sendHistory())
+	s.Empty(s.rereplicator.circuitBreaker.breakers)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendMissingHistory_CircuitOpen() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	WithGetHistoryRetryPolicy(nil)(s.rereplicator)
+	WithCircuitBreaker(
+		func(domainID string) int { return 1 },
+		func(domainID string) time.Duration { return time.Minute },
+	)(s.rereplicator)
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(nil, &shared.ServiceBusyError{}).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	sendMissingHistory := func() error {
+		_, err := s.resender.SendMissingHistory(
+			context.Background(),
+			s.domainID,
+			workflowID,
+			runID,
+			5,
+			123,
+		)
+		return err
+	}
+	s.IsType(&shared.ServiceBusyError{}, sendMissingHistory())
+	// the failures of SendMissingHistory are counted by the circuit breaker of the domain as well
+	s.Equal(ErrResendCircuitOpen, sendMissingHistory())
+}
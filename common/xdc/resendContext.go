@@ -0,0 +1,233 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+)
+
+const (
+	resendCorrelationIDKey resendCtxKey = "resendCorrelationID"
+	resendLoggerKey        resendCtxKey = "resendLogger"
+	resendPriorityKey      resendCtxKey = "resendPriority"
+	replicationChecksumKey resendCtxKey = "replicationChecksum"
+	resendTrafficKey       resendCtxKey = "resendTraffic"
+	resendShardIDKey       resendCtxKey = "resendShardID"
+	adminHeadersKey        resendCtxKey = "adminHeaders"
+	retryBudgetKey         resendCtxKey = "retryBudget"
+	quietExpectedErrorsKey resendCtxKey = "quietExpectedErrors"
+	targetShardIDKey       resendCtxKey = "targetShardID"
+	// progressReporterKey is the context key of the ProgressReporter attached by WithProgressReporter
+	progressReporterKey resendCtxKey = "progressReporter"
+)
+
+type (
+	// ProgressReporter is invoked with the estimated percentage, from 0 to 100, of the history events of the run sent,
+	// e.g. to heartbeat the progress to a job framework
+	ProgressReporter func(percentComplete float64)
+
+	// detachedContext carries the values of the context of a caller, e.g. the priority and the tracing span,
+	// while its cancellation and deadline come from the root context of the resender
+	detachedContext struct {
+		context.Context
+		values context.Context
+	}
+
+	resendCtxKey string
+
+	// resendTraffic counts the bytes of the event batches fetched and sent by a resend
+	resendTraffic struct {
+		fetchedBytes int64
+		sentBytes    int64
+		fetchedPages int64
+	}
+)
+
+// Value returns the value of the context of the caller
+func (c *detachedContext) Value(
+	key interface{},
+) interface{} {
+
+	return c.values.Value(key)
+}
+
+// WithResendCorrelationID returns a copy of the context carrying the correlation ID, which is attached
+// as a log tag to every log line of the resends with the context, a random one is generated for each resend if not provided
+func WithResendCorrelationID(
+	ctx context.Context,
+	correlationID string,
+) context.Context {
+
+	return context.WithValue(ctx, resendCorrelationIDKey, correlationID)
+}
+
+// WithResendShardID returns a copy of the context carrying the ID of the shard driving the resends with the context,
+// the context passed to the admin client and the replication fns carries it along, and it is attached as a log tag
+func WithResendShardID(
+	ctx context.Context,
+	shardID int,
+) context.Context {
+
+	return context.WithValue(ctx, resendShardIDKey, shardID)
+}
+
+// GetResendShardID returns the ID of the shard carried by the context, and whether there is one
+func GetResendShardID(
+	ctx context.Context,
+) (int, bool) {
+
+	shardID, ok := ctx.Value(resendShardIDKey).(int)
+	return shardID, ok
+}
+
+// WithProgressReporter returns a copy of the context carrying the progress reporter, which is invoked synchronously
+// after each event batch of the resends with the context is sent, and once the resend completes
+func WithProgressReporter(
+	ctx context.Context,
+	reporter ProgressReporter,
+) context.Context {
+
+	return context.WithValue(ctx, progressReporterKey, reporter)
+}
+
+// reportProgress reports the estimated percentage of the events sent to the progress reporter carried by the context,
+// if any. the percentage is derived from the position of the last event sent between the start event and the end event
+// of the resend, which are exclusive, or the last event of the version history if the end event is not provided
+func reportProgress(
+	ctx context.Context,
+	descriptor *ResendDescriptor,
+	versionHistory *shared.VersionHistory,
+	lastEventID int64,
+) {
+
+	reporter, ok := ctx.Value(progressReporterKey).(ProgressReporter)
+	if !ok || lastEventID == common.EmptyEventID {
+		return
+	}
+	startEventID := common.FirstEventID - 1
+	if descriptor.StartEventID != nil {
+		startEventID = *descriptor.StartEventID
+	}
+	var endEventID int64
+	if descriptor.EndEventID != nil {
+		endEventID = *descriptor.EndEventID - 1
+	} else if items := versionHistory.GetItems(); len(items) > 0 {
+		endEventID = items[len(items)-1].GetEventID()
+	}
+	if endEventID <= startEventID {
+		return
+	}
+	percentComplete := float64(lastEventID-startEventID) * 100 / float64(endEventID-startEventID)
+	if percentComplete > 100 {
+		percentComplete = 100
+	}
+	reporter(percentComplete)
+}
+
+// WithTargetShardID returns a copy of the context carrying the ID of the target shard owning the runs resent with
+// the context, the context passed to the replication fns carries it along, so the replication fns can send the
+// events to the shard directly without routing. the caller is responsible for the shard owning the runs
+func WithTargetShardID(
+	ctx context.Context,
+	shardID int,
+) context.Context {
+
+	return context.WithValue(ctx, targetShardIDKey, shardID)
+}
+
+// GetTargetShardID returns the ID of the target shard carried by the context, and whether there is one
+func GetTargetShardID(
+	ctx context.Context,
+) (int, bool) {
+
+	shardID, ok := ctx.Value(targetShardIDKey).(int)
+	return shardID, ok
+}
+
+// withResendTraffic returns a copy of the context carrying the traffic counters of the resend
+func withResendTraffic(
+	ctx context.Context,
+) (context.Context, *resendTraffic) {
+
+	traffic := &resendTraffic{}
+	return context.WithValue(ctx, resendTrafficKey, traffic), traffic
+}
+
+// addFetchedBytes adds the size of the event batches to the traffic of the resend carried by the context, if any
+func addFetchedBytes(
+	ctx context.Context,
+	historyBatches []*shared.DataBlob,
+) {
+
+	if traffic, ok := ctx.Value(resendTrafficKey).(*resendTraffic); ok {
+		atomic.AddInt64(&traffic.fetchedBytes, getDataBlobsSize(historyBatches))
+	}
+}
+
+// getDataBlobsSize returns the total size of the data of the blobs
+func getDataBlobsSize(
+	blobs []*shared.DataBlob,
+) int64 {
+
+	var size int64
+	for _, blob := range blobs {
+		size += int64(len(blob.GetData()))
+	}
+	return size
+}
+
+// addFetchedPage counts a page fetched by the resend carried by the context if any, otherwise by the given counter,
+// and returns the number of pages fetched so far including the page
+func addFetchedPage(
+	ctx context.Context,
+	pageCount *int64,
+) int64 {
+
+	if traffic, ok := ctx.Value(resendTrafficKey).(*resendTraffic); ok {
+		return atomic.AddInt64(&traffic.fetchedPages, 1)
+	}
+	return atomic.AddInt64(pageCount, 1)
+}
+
+// addSentBytes adds the size of the event batch to the traffic of the resend carried by the context, if any
+func addSentBytes(
+	ctx context.Context,
+	historyBatch *shared.DataBlob,
+) {
+
+	if traffic, ok := ctx.Value(resendTrafficKey).(*resendTraffic); ok {
+		atomic.AddInt64(&traffic.sentBytes, int64(len(historyBatch.GetData())))
+	}
+}
+
+// GetAdminHeaders returns the headers carried by the context to be attached to the call to the admin service,
+// the HistoryFetcher implementations calling the admin service should attach them
+func GetAdminHeaders(
+	ctx context.Context,
+) map[string]string {
+
+	headers, _ := ctx.Value(adminHeadersKey).(map[string]string)
+	return headers
+}
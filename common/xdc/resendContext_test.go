@@ -0,0 +1,273 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"go.uber.org/yarpc"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/uber/cadence/.gen/go/admin"
+	"github.com/uber/cadence/.gen/go/history"
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/log/loggerimpl"
+)
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_TargetShardID() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	WithTargetShardCount(4)(s.rereplicator)
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).Return(
+		&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *history.ReplicateEventsV2Request, opts ...yarpc.CallOption) error {
+			shardID, ok := GetTargetShardID(ctx)
+			s.True(ok)
+			s.Equal(3, shardID)
+			return nil
+		}).Times(1)
+	sendHistory := func(shardID int) error {
+		return s.resender.SendSingleWorkflowHistory(
+			WithTargetShardID(context.Background(), shardID),
+			s.domainID,
+			workflowID,
+			runID,
+			nil,
+			nil,
+			nil,
+			nil,
+		)
+	}
+
+	s.NoError(sendHistory(3))
+	s.IsType(&shared.BadRequestError{}, sendHistory(4))
+	s.IsType(&shared.BadRequestError{}, sendHistory(-1))
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_ProgressReporter() {
+	workflowID := "some random workflow ID"
+	newEvent := func(eventID int64) *shared.HistoryEvent {
+		return &shared.HistoryEvent{
+			EventId:   common.Int64Ptr(eventID),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(123),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		}
+	}
+	versionHistory := &shared.VersionHistory{
+		Items: []*shared.VersionHistoryItem{
+			{
+				EventID: common.Int64Ptr(4),
+				Version: common.Int64Ptr(123),
+			},
+		},
+	}
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).Return(
+		&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{
+				s.serializeEvents([]*shared.HistoryEvent{newEvent(1), newEvent(2), newEvent(3)}),
+				s.serializeEvents([]*shared.HistoryEvent{newEvent(4)}),
+			},
+			VersionHistory: versionHistory,
+		}, nil).Times(1)
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).Return(
+		&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{
+				s.serializeEvents([]*shared.HistoryEvent{newEvent(3)}),
+				s.serializeEvents([]*shared.HistoryEvent{newEvent(4)}),
+			},
+			VersionHistory: versionHistory,
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(4)
+
+	var progress []float64
+	ctx := WithProgressReporter(context.Background(), func(percentComplete float64) {
+		progress = append(progress, percentComplete)
+	})
+	err := s.resender.SendSingleWorkflowHistory(ctx, s.domainID, workflowID, uuid.New(), nil, nil, nil, nil)
+	s.NoError(err)
+	s.Equal([]float64{75, 100, 100}, progress)
+
+	// the progress is relative to the start event
+	progress = nil
+	err = s.resender.SendSingleWorkflowHistory(
+		ctx,
+		s.domainID,
+		workflowID,
+		uuid.New(),
+		common.Int64Ptr(2),
+		common.Int64Ptr(123),
+		nil,
+		nil,
+	)
+	s.NoError(err)
+	s.Equal([]float64{50, 100, 100}, progress)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_ShardID() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	shardID := 123
+	core, observedLogs := observer.New(zap.InfoLevel)
+	s.rereplicator.logger = loggerimpl.NewLogger(zap.New(core))
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			actualShardID, ok := GetResendShardID(ctx)
+			s.True(ok)
+			s.Equal(shardID, actualShardID)
+			return &admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob},
+				VersionHistory: &shared.VersionHistory{
+					Items: []*shared.VersionHistoryItem{
+						{
+							EventID: common.Int64Ptr(2),
+							Version: common.Int64Ptr(123),
+						},
+					},
+				},
+			}, nil
+		}).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *history.ReplicateEventsV2Request, opts ...yarpc.CallOption) error {
+			actualShardID, ok := GetResendShardID(ctx)
+			s.True(ok)
+			s.Equal(shardID, actualShardID)
+			return nil
+		}).Times(1)
+
+	err := s.resender.SendSingleWorkflowHistory(
+		WithResendShardID(context.Background(), shardID),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.NoError(err)
+	logs := observedLogs.TakeAll()
+	s.NotEmpty(logs)
+	for _, entry := range logs {
+		s.Equal(int64(shardID), entry.ContextMap()["shard-id"])
+	}
+
+	_, ok := GetResendShardID(context.Background())
+	s.False(ok)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_CorrelationID() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	correlationID := "some random correlation ID"
+	core, observedLogs := observer.New(zap.InfoLevel)
+	s.rereplicator.logger = loggerimpl.NewLogger(zap.New(core))
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(2)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+	err := s.resender.SendSingleWorkflowHistory(
+		WithResendCorrelationID(context.Background(), correlationID),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.NoError(err)
+	logs := observedLogs.TakeAll()
+	s.NotEmpty(logs)
+	for _, entry := range logs {
+		s.Equal(correlationID, entry.ContextMap()["xdc-resend-correlation-id"])
+	}
+
+	// a random correlation ID is generated if not provided
+	err = s.resender.SendSingleWorkflowHistory(
+		context.Background(),
+		s.domainID,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	s.NoError(err)
+	logs = observedLogs.TakeAll()
+	s.NotEmpty(logs)
+	generatedCorrelationID := logs[0].ContextMap()["xdc-resend-correlation-id"]
+	s.NotEmpty(generatedCorrelationID)
+	s.NotEqual(correlationID, generatedCorrelationID)
+}
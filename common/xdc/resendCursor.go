@@ -0,0 +1,247 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/persistence"
+)
+
+const (
+	// resendCursorVersion is the current version of the format of ResendCursor
+	resendCursorVersion = 1
+)
+
+type (
+	// ResendCursor is the serializable checkpoint of a single run resend, which can be persisted to an external
+	// storage by MarshalResendCursor after each batch and restored by UnmarshalResendCursor to resume the resend
+	ResendCursor struct {
+		// Version is the version of the cursor format, set by MarshalResendCursor
+		Version          int
+		DomainID         string
+		WorkflowID       string
+		RunID            string
+		LastEventID      int64
+		LastEventVersion int64
+		// NextPageToken is the pagination token following the last page fully sent
+		NextPageToken []byte
+	}
+
+	resendResumeToken struct {
+		DomainID      string
+		WorkflowID    string
+		RunID         string
+		NextPageToken []byte
+	}
+)
+
+// GetResumeToken returns the opaque token which can be used to resume the resend from where it stops,
+// nil is returned if there is nothing left to resend
+func (r *ResendResult) GetResumeToken() []byte {
+	if r == nil || len(r.NextPageToken) == 0 {
+		return nil
+	}
+	token, err := json.Marshal(&resendResumeToken{
+		DomainID:      r.domainID,
+		WorkflowID:    r.workflowID,
+		RunID:         r.runID,
+		NextPageToken: r.NextPageToken,
+	})
+	if err != nil {
+		return nil
+	}
+	return token
+}
+
+func decodeResumeToken(
+	resumeToken []byte,
+	domainID string,
+	workflowID string,
+	runID string,
+) ([]byte, error) {
+
+	if len(resumeToken) == 0 {
+		return nil, nil
+	}
+	token := &resendResumeToken{}
+	if err := json.Unmarshal(resumeToken, token); err != nil {
+		return nil, ErrInvalidResumeToken
+	}
+	if token.DomainID != domainID || token.WorkflowID != workflowID || token.RunID != runID {
+		return nil, &shared.BadRequestError{Message: fmt.Sprintf(
+			"Resume token of domain ID: %v, workflow ID: %v, run ID: %v cannot be used for domain ID: %v, workflow ID: %v, run ID: %v.",
+			token.DomainID, token.WorkflowID, token.RunID, domainID, workflowID, runID,
+		)}
+	}
+	return token.NextPageToken, nil
+}
+
+// GetCursor returns the cursor which can be persisted to resume the resend from where it stops,
+// the cursor is returned even if nothing is sent, in which case the resend is resumed from the start
+func (r *ResendResult) GetCursor() *ResendCursor {
+	if r == nil {
+		return nil
+	}
+	return &ResendCursor{
+		Version:          resendCursorVersion,
+		DomainID:         r.domainID,
+		WorkflowID:       r.workflowID,
+		RunID:            r.runID,
+		LastEventID:      r.cursorEventID,
+		LastEventVersion: r.cursorEventVersion,
+		NextPageToken:    r.NextPageToken,
+	}
+}
+
+// MarshalResendCursor serializes the cursor with the current version of the cursor format
+func MarshalResendCursor(
+	cursor *ResendCursor,
+) ([]byte, error) {
+
+	if cursor == nil {
+		return nil, ErrInvalidResendCursor
+	}
+	versioned := *cursor
+	versioned.Version = resendCursorVersion
+	return json.Marshal(&versioned)
+}
+
+// UnmarshalResendCursor deserializes the cursor serialized by MarshalResendCursor,
+// BadRequestError is returned if the cursor is malformed or of a newer version of the cursor format
+func UnmarshalResendCursor(
+	data []byte,
+) (*ResendCursor, error) {
+
+	cursor := &ResendCursor{}
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, ErrInvalidResendCursor
+	}
+	if cursor.Version < 1 || cursor.Version > resendCursorVersion {
+		return nil, &shared.BadRequestError{Message: fmt.Sprintf(
+			"Resend cursor version %v is not supported, max supported version: %v.",
+			cursor.Version, resendCursorVersion,
+		)}
+	}
+	return cursor, nil
+}
+
+func validateResendCursor(
+	descriptor *ResendDescriptor,
+	domainID string,
+	workflowID string,
+	runID string,
+) error {
+
+	cursor := descriptor.Cursor
+	if len(descriptor.ResumeToken) != 0 {
+		return &shared.BadRequestError{Message: "Resume token and resend cursor cannot be provided together."}
+	}
+	if cursor.DomainID != domainID || cursor.WorkflowID != workflowID || cursor.RunID != runID {
+		return &shared.BadRequestError{Message: fmt.Sprintf(
+			"Resend cursor of domain ID: %v, workflow ID: %v, run ID: %v cannot be used for domain ID: %v, workflow ID: %v, run ID: %v.",
+			cursor.DomainID, cursor.WorkflowID, cursor.RunID, domainID, workflowID, runID,
+		)}
+	}
+	return nil
+}
+
+// getResendStartPosition returns the page token to start the resend from, along with the ID of the last event
+// already sent, the events up to the last event of the cursor are already sent, including the ones in the page
+// following the page token of the cursor, which is partially sent
+func getResendStartPosition(
+	descriptor *ResendDescriptor,
+) ([]byte, int64, error) {
+
+	domainID := descriptor.DomainID
+	workflowID := descriptor.WorkflowID
+	runID := descriptor.RunID
+
+	initialPageToken, err := decodeResumeToken(descriptor.ResumeToken, domainID, workflowID, runID)
+	if err != nil {
+		return nil, common.EmptyEventID, err
+	}
+	sentEventID := common.EmptyEventID
+	if descriptor.Cursor != nil {
+		if err := validateResendCursor(descriptor, domainID, workflowID, runID); err != nil {
+			return nil, common.EmptyEventID, err
+		}
+		initialPageToken = descriptor.Cursor.NextPageToken
+		sentEventID = descriptor.Cursor.LastEventID
+	}
+	if len(descriptor.replayPageTokens) != 0 {
+		initialPageToken = descriptor.replayPageTokens[0]
+	}
+	return initialPageToken, sentEventID, nil
+}
+
+// newResendResult returns the result of the resend before any batch is sent,
+// the cursor of the result is the one the resend is resumed from, if any
+func newResendResult(
+	descriptor *ResendDescriptor,
+	initialPageToken []byte,
+) *ResendResult {
+
+	result := &ResendResult{
+		FirstEventID:       common.EmptyEventID,
+		LastEventID:        common.EmptyEventID,
+		NextPageToken:      initialPageToken,
+		domainID:           descriptor.DomainID,
+		workflowID:         descriptor.WorkflowID,
+		runID:              descriptor.RunID,
+		cursorEventID:      common.EmptyEventID,
+		cursorEventVersion: common.EmptyVersion,
+	}
+	if descriptor.Cursor != nil {
+		result.cursorEventID = descriptor.Cursor.LastEventID
+		result.cursorEventVersion = descriptor.Cursor.LastEventVersion
+	}
+	return result
+}
+
+// advanceCursor advances the cursor of the result past the batch sent
+func (r *resendRun) advanceCursor(
+	batch *historyBatch,
+	lastEventID int64,
+) {
+
+	r.result.cursorEventID = lastEventID
+	r.result.cursorEventVersion = common.EmptyVersion
+	if lastEventVersion, err := persistence.NewVersionHistoryFromThrift(
+		batch.versionHistory,
+	).GetEventVersion(lastEventID); err == nil {
+		r.result.cursorEventVersion = lastEventVersion
+	}
+	r.advancePageToken(batch)
+}
+
+// advancePageToken advances the page token of the result past the page once its last batch is processed
+func (r *resendRun) advancePageToken(
+	batch *historyBatch,
+) {
+
+	if r.resumable && batch.lastInPage {
+		r.result.NextPageToken = batch.nextPageToken
+	}
+}
@@ -0,0 +1,427 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"go.uber.org/yarpc"
+
+	"github.com/uber/cadence/.gen/go/admin"
+	"github.com/uber/cadence/.gen/go/history"
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/cache"
+)
+
+func (s *nDCHistoryResenderSuite) TestResendWorkflowHistory_ResumeToken() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	token := []byte{1}
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+
+	gomock.InOrder(
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob},
+				NextPageToken:  token,
+				VersionHistory: &shared.VersionHistory{
+					Items: []*shared.VersionHistoryItem{
+						{
+							EventID: common.Int64Ptr(2),
+							Version: common.Int64Ptr(123),
+						},
+					},
+				},
+			}, nil).Times(1),
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(nil, &shared.BadRequestError{}).Times(1),
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(
+			gomock.Any(),
+			&admin.GetWorkflowExecutionRawHistoryV2Request{
+				Domain: common.StringPtr(s.domainName),
+				Execution: &shared.WorkflowExecution{
+					WorkflowId: common.StringPtr(workflowID),
+					RunId:      common.StringPtr(runID),
+				},
+				MaximumPageSize: common.Int32Ptr(defaultPageSize),
+				NextPageToken:   token,
+			}).Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			NextPageToken:  nil,
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1),
+	)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+	descriptor := &ResendDescriptor{
+		DomainID:   s.domainID,
+		WorkflowID: workflowID,
+		RunID:      runID,
+	}
+	result, err := s.resender.ResendWorkflowHistory(context.Background(), descriptor)
+	s.Error(err)
+	s.NotNil(result.GetResumeToken())
+
+	descriptor.ResumeToken = result.GetResumeToken()
+	result, err = s.resender.ResendWorkflowHistory(context.Background(), descriptor)
+	s.NoError(err)
+	s.Equal(1, result.BatchCount)
+	s.Nil(result.GetResumeToken())
+}
+
+func (s *nDCHistoryResenderSuite) TestResendWorkflowHistory_InvalidPageToken() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	badRequestErr := &shared.BadRequestError{Message: "Invalid pagination token."}
+
+	gomock.InOrder(
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob},
+				NextPageToken:  []byte("some random next page token"),
+				VersionHistory: &shared.VersionHistory{
+					Items: []*shared.VersionHistoryItem{
+						{
+							EventID: common.Int64Ptr(2),
+							Version: common.Int64Ptr(123),
+						},
+					},
+				},
+			}, nil).Times(1),
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(nil, badRequestErr).Times(2),
+	)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	descriptor := &ResendDescriptor{
+		DomainID:   s.domainID,
+		WorkflowID: workflowID,
+		RunID:      runID,
+	}
+	result, err := s.resender.ResendWorkflowHistory(context.Background(), descriptor)
+	s.True(errors.Is(err, ErrInvalidPageToken))
+	s.Equal(&InvalidPageTokenError{
+		Resumed:      false,
+		MadeProgress: true,
+		Err:          badRequestErr,
+	}, err)
+
+	s.NotNil(result.GetResumeToken())
+
+	// the stale resume token is rejected before anything is sent
+	descriptor.ResumeToken = result.GetResumeToken()
+	_, err = s.resender.ResendWorkflowHistory(context.Background(), descriptor)
+	s.Equal(&InvalidPageTokenError{
+		Resumed:      true,
+		MadeProgress: false,
+		Err:          badRequestErr,
+	}, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestResendWorkflowHistory_InvalidPageToken_FirstPage() {
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(nil, &shared.BadRequestError{}).Times(1)
+
+	// the first page has no token, so the error is not caused by the token
+	_, err := s.resender.ResendWorkflowHistory(context.Background(), &ResendDescriptor{
+		DomainID:   s.domainID,
+		WorkflowID: "some random workflow ID",
+		RunID:      uuid.New(),
+	})
+	s.IsType(&shared.BadRequestError{}, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestResendWorkflowHistory_ResumeToken_LastKnownDomain() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	token := []byte{1}
+	mockDomainCache := cache.NewMockDomainCache(s.controller)
+	s.rereplicator.domainCache = mockDomainCache
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+
+	gomock.InOrder(
+		mockDomainCache.EXPECT().GetDomainByID(s.domainID).Return(nil, &shared.EntityNotExistsError{}).Times(1),
+		mockDomainCache.EXPECT().GetDomainByID(s.domainID).Return(s.domainEntry, nil).Times(1),
+		mockDomainCache.EXPECT().GetDomainByID(s.domainID).Return(nil, &shared.EntityNotExistsError{}).AnyTimes(),
+	)
+	gomock.InOrder(
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob},
+				NextPageToken:  token,
+				VersionHistory: &shared.VersionHistory{
+					Items: []*shared.VersionHistoryItem{
+						{
+							EventID: common.Int64Ptr(2),
+							Version: common.Int64Ptr(123),
+						},
+					},
+				},
+			}, nil).Times(1),
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+			Return(nil, &shared.BadRequestError{}).Times(1),
+		s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(
+			gomock.Any(),
+			&admin.GetWorkflowExecutionRawHistoryV2Request{
+				Domain: common.StringPtr(s.domainName),
+				Execution: &shared.WorkflowExecution{
+					WorkflowId: common.StringPtr(workflowID),
+					RunId:      common.StringPtr(runID),
+				},
+				MaximumPageSize: common.Int32Ptr(defaultPageSize),
+				NextPageToken:   token,
+			}).Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			NextPageToken:  nil,
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1),
+	)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+	descriptor := &ResendDescriptor{
+		DomainID:   s.domainID,
+		WorkflowID: workflowID,
+		RunID:      runID,
+	}
+	// the domain is never resolved
+	_, err := s.resender.ResendWorkflowHistory(context.Background(), descriptor)
+	s.IsType(&shared.EntityNotExistsError{}, err)
+
+	result, err := s.resender.ResendWorkflowHistory(context.Background(), descriptor)
+	s.Error(err)
+	s.NotNil(result.GetResumeToken())
+
+	// the domain cannot be resolved for the second page, the last known domain is used
+	descriptor.ResumeToken = result.GetResumeToken()
+	result, err = s.resender.ResendWorkflowHistory(context.Background(), descriptor)
+	s.NoError(err)
+	s.Equal(1, result.BatchCount)
+	s.Nil(result.GetResumeToken())
+
+	// the last known domain is only used by the resumed resend
+	descriptor.ResumeToken = nil
+	_, err = s.resender.ResendWorkflowHistory(context.Background(), descriptor)
+	s.IsType(&shared.EntityNotExistsError{}, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestResendWorkflowHistory_Cursor() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	newBlob := func(firstEventID int64) *shared.DataBlob {
+		return s.serializeEvents([]*shared.HistoryEvent{
+			{
+				EventId:   common.Int64Ptr(firstEventID),
+				Version:   common.Int64Ptr(123),
+				Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+				EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+			},
+			{
+				EventId:   common.Int64Ptr(firstEventID + 1),
+				Version:   common.Int64Ptr(123),
+				Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+				EventType: shared.EventTypeDecisionTaskStarted.Ptr(),
+			},
+		})
+	}
+	blob1 := newBlob(1)
+	blob2 := newBlob(3)
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob1, blob2},
+			NextPageToken:  nil,
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(4),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(2)
+	var sentBlobs []*shared.DataBlob
+	gomock.InOrder(
+		s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(1),
+		s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(&shared.InternalServiceError{}).Times(1),
+		s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, request *history.ReplicateEventsV2Request, opts ...yarpc.CallOption) error {
+				sentBlobs = append(sentBlobs, request.Events)
+				return nil
+			}).Times(1),
+	)
+
+	descriptor := &ResendDescriptor{
+		DomainID:   s.domainID,
+		WorkflowID: workflowID,
+		RunID:      runID,
+	}
+	result, err := s.resender.ResendWorkflowHistory(context.Background(), descriptor)
+	s.IsType(&shared.InternalServiceError{}, err)
+	data, err := MarshalResendCursor(result.GetCursor())
+	s.NoError(err)
+
+	cursor, err := UnmarshalResendCursor(data)
+	s.NoError(err)
+	s.Equal(&ResendCursor{
+		Version:          resendCursorVersion,
+		DomainID:         s.domainID,
+		WorkflowID:       workflowID,
+		RunID:            runID,
+		LastEventID:      2,
+		LastEventVersion: 123,
+	}, cursor)
+
+	// the page is fetched again, but the batch already sent is not sent again
+	descriptor.Cursor = cursor
+	result, err = s.resender.ResendWorkflowHistory(context.Background(), descriptor)
+	s.NoError(err)
+	s.Equal(1, result.BatchCount)
+	s.Equal(int64(3), result.FirstEventID)
+	s.Equal([]*shared.DataBlob{blob2}, sentBlobs)
+	s.Equal(int64(4), result.GetCursor().LastEventID)
+}
+
+func (s *nDCHistoryResenderSuite) TestResendWorkflowHistory_InvalidCursor() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	cursor := (&ResendResult{
+		NextPageToken:      []byte{1},
+		domainID:           s.domainID,
+		workflowID:         workflowID,
+		runID:              runID,
+		cursorEventID:      2,
+		cursorEventVersion: 123,
+	}).GetCursor()
+
+	_, err := s.resender.ResendWorkflowHistory(context.Background(), &ResendDescriptor{
+		DomainID:   s.domainID,
+		WorkflowID: workflowID,
+		RunID:      uuid.New(),
+		Cursor:     cursor,
+	})
+	s.IsType(&shared.BadRequestError{}, err)
+
+	_, err = s.resender.ResendWorkflowHistory(context.Background(), &ResendDescriptor{
+		DomainID:    s.domainID,
+		WorkflowID:  workflowID,
+		RunID:       runID,
+		ResumeToken: (&ResendResult{NextPageToken: []byte{1}, domainID: s.domainID, workflowID: workflowID, runID: runID}).GetResumeToken(),
+		Cursor:      cursor,
+	})
+	s.IsType(&shared.BadRequestError{}, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestMarshalResendCursor() {
+	cursor := &ResendCursor{
+		DomainID:         s.domainID,
+		WorkflowID:       "some random workflow ID",
+		RunID:            uuid.New(),
+		LastEventID:      2,
+		LastEventVersion: 123,
+		NextPageToken:    []byte("some random next page token"),
+	}
+	data, err := MarshalResendCursor(cursor)
+	s.NoError(err)
+	unmarshaled, err := UnmarshalResendCursor(data)
+	s.NoError(err)
+	s.Equal(resendCursorVersion, unmarshaled.Version)
+	unmarshaled.Version = 0
+	s.Equal(cursor, unmarshaled)
+
+	_, err = UnmarshalResendCursor([]byte("some random cursor"))
+	s.Equal(ErrInvalidResendCursor, err)
+
+	newerCursor := *cursor
+	newerCursor.Version = resendCursorVersion + 1
+	data, err = json.Marshal(&newerCursor)
+	s.NoError(err)
+	_, err = UnmarshalResendCursor(data)
+	s.IsType(&shared.BadRequestError{}, err)
+}
+
+func (s *nDCHistoryResenderSuite) TestResendWorkflowHistory_InvalidResumeToken() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	resumeToken := (&ResendResult{
+		NextPageToken: []byte{1},
+		domainID:      s.domainID,
+		workflowID:    workflowID,
+		runID:         runID,
+	}).GetResumeToken()
+
+	_, err := s.resender.ResendWorkflowHistory(context.Background(), &ResendDescriptor{
+		DomainID:    s.domainID,
+		WorkflowID:  workflowID,
+		RunID:       uuid.New(),
+		ResumeToken: resumeToken,
+	})
+	s.IsType(&shared.BadRequestError{}, err)
+
+	_, err = s.resender.ResendWorkflowHistory(context.Background(), &ResendDescriptor{
+		DomainID:    s.domainID,
+		WorkflowID:  workflowID,
+		RunID:       runID,
+		ResumeToken: []byte("some random token"),
+	})
+	s.Equal(ErrInvalidResumeToken, err)
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+
+	"github.com/uber/cadence/common/clock"
+)
+
+type (
+	// sharedResend is a resend in flight shared by the concurrent identical resends
+	sharedResend struct {
+		key     string
+		doneCh  chan struct{}
+		result  *ResendResult
+		err     error
+		cancel  context.CancelFunc
+		waiters int
+	}
+)
+
+// joinSharedResend returns the resend in flight identical to the descriptor, starting it if there is none,
+// and whether it is shared with another caller
+func (n *NDCHistoryResenderImpl) joinSharedResend(
+	ctx context.Context,
+	descriptor *ResendDescriptor,
+) (*sharedResend, bool) {
+
+	key := getResendKey(descriptor)
+
+	n.sharedResendsLock.Lock()
+	defer n.sharedResendsLock.Unlock()
+
+	if resend, ok := n.sharedResends[key]; ok {
+		resend.waiters++
+		return resend, true
+	}
+
+	resendCtx, cancel := context.WithCancel(&detachedContext{Context: n.rootCtx, values: ctx})
+	resend := &sharedResend{
+		key:     key,
+		doneCh:  make(chan struct{}),
+		cancel:  cancel,
+		waiters: 1,
+	}
+	n.sharedResends[key] = resend
+
+	go func() {
+		defer cancel()
+
+		if n.getResendTimeout(descriptor, nil) <= 0 {
+			var timeoutCancel context.CancelFunc
+			resendCtx, timeoutCancel = clock.ContextWithTimeout(resendCtx, n.timeSource, defaultMaxTimeoutOverride)
+			defer timeoutCancel()
+		}
+		result, err := n.doResendWorkflowHistory(resendCtx, descriptor, false, nil)
+		// the shared resend is counted once
+		n.stats.record(result, err)
+
+		n.sharedResendsLock.Lock()
+		if n.sharedResends[key] == resend {
+			delete(n.sharedResends, key)
+		}
+		n.sharedResendsLock.Unlock()
+
+		resend.result = result
+		resend.err = err
+		close(resend.doneCh)
+	}()
+	return resend, false
+}
+
+// leaveSharedResend stops waiting for the shared resend, which is canceled once no caller waits for it
+func (n *NDCHistoryResenderImpl) leaveSharedResend(
+	resend *sharedResend,
+) {
+
+	n.sharedResendsLock.Lock()
+	defer n.sharedResendsLock.Unlock()
+
+	resend.waiters--
+	if resend.waiters > 0 {
+		return
+	}
+	// the identical resends started from now on do not join the canceled one
+	if n.sharedResends[resend.key] == resend {
+		delete(n.sharedResends, resend.key)
+	}
+	resend.cancel()
+}
+
+// isDedupable returns whether the resend with the context can be shared with the concurrent identical resends,
+// it cannot if the context carries any value scoping the resend to the caller, e.g. the target shard or
+// the admin headers, as the shared resend only sees the values of the context of the first caller
+func (n *NDCHistoryResenderImpl) isDedupable(
+	ctx context.Context,
+) bool {
+
+	if n.adminHeadersProvider != nil {
+		// the headers are provided per caller context
+		return false
+	}
+	for _, key := range []resendCtxKey{
+		targetShardIDKey,
+		resendShardIDKey,
+		progressReporterKey,
+		adminHeadersKey,
+		retryBudgetKey,
+		resendPriorityKey,
+		resendCorrelationIDKey,
+	} {
+		if ctx.Value(key) != nil {
+			return false
+		}
+	}
+	return true
+}
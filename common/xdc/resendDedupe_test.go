@@ -0,0 +1,261 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"go.uber.org/yarpc"
+
+	"github.com/uber/cadence/.gen/go/admin"
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+)
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_DedupeConcurrentResends() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			close(started)
+			<-unblock
+			return nil, &shared.EntityNotExistsError{}
+		}).Times(1)
+	sendHistory := func(endEventID int64) (*ResendResult, error) {
+		return s.resender.SendSingleWorkflowHistoryWithResult(
+			context.Background(),
+			s.domainID,
+			workflowID,
+			runID,
+			common.Int64Ptr(1),
+			common.Int64Ptr(123),
+			common.Int64Ptr(endEventID),
+			common.Int64Ptr(123),
+		)
+	}
+
+	var wg sync.WaitGroup
+	results := make([]*ResendResult, 2)
+	errs := make([]error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = sendHistory(10)
+		}(i)
+	}
+	<-started
+	// give the identical resend time to join the one in flight
+	time.Sleep(50 * time.Millisecond)
+
+	// the resend of a different range is not collapsed
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(nil, &shared.EntityNotExistsError{}).Times(1)
+	_, err := sendHistory(20)
+	s.IsType(&shared.EntityNotExistsError{}, err)
+
+	close(unblock)
+	wg.Wait()
+	for i := 0; i < 2; i++ {
+		s.IsType(&shared.EntityNotExistsError{}, errs[i])
+	}
+	s.Equal(results[0], results[1])
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_DedupeCallerCanceled() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			close(started)
+			<-unblock
+			// the shared resend is not canceled along with the caller starting it
+			s.NoError(ctx.Err())
+			return nil, &shared.EntityNotExistsError{}
+		}).Times(1)
+	sendHistory := func(ctx context.Context) (*ResendResult, error) {
+		return s.resender.SendSingleWorkflowHistoryWithResult(
+			ctx,
+			s.domainID,
+			workflowID,
+			runID,
+			common.Int64Ptr(1),
+			common.Int64Ptr(123),
+			common.Int64Ptr(10),
+			common.Int64Ptr(123),
+		)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErrCh := make(chan error, 1)
+	go func() {
+		_, err := sendHistory(ctx)
+		firstErrCh <- err
+	}()
+	<-started
+	secondErrCh := make(chan error, 1)
+	go func() {
+		_, err := sendHistory(context.Background())
+		secondErrCh <- err
+	}()
+	// give the identical resend time to join the one in flight
+	time.Sleep(50 * time.Millisecond)
+
+	// the caller starting the shared resend stops waiting once its context is canceled
+	cancel()
+	s.Equal(context.Canceled, <-firstErrCh)
+
+	close(unblock)
+	s.IsType(&shared.EntityNotExistsError{}, <-secondErrCh)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_DedupeAllCallersCanceled() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			close(started)
+			<-ctx.Done()
+			close(canceled)
+			return nil, ctx.Err()
+		}).Times(1)
+	sendHistory := func(ctx context.Context) (*ResendResult, error) {
+		return s.resender.SendSingleWorkflowHistoryWithResult(
+			ctx,
+			s.domainID,
+			workflowID,
+			runID,
+			common.Int64Ptr(1),
+			common.Int64Ptr(123),
+			common.Int64Ptr(10),
+			common.Int64Ptr(123),
+		)
+	}
+
+	firstCtx, firstCancel := context.WithCancel(context.Background())
+	firstErrCh := make(chan error, 1)
+	go func() {
+		_, err := sendHistory(firstCtx)
+		firstErrCh <- err
+	}()
+	<-started
+	secondCtx, secondCancel := context.WithCancel(context.Background())
+	secondErrCh := make(chan error, 1)
+	go func() {
+		_, err := sendHistory(secondCtx)
+		secondErrCh <- err
+	}()
+	// give the identical resend time to join the one in flight
+	time.Sleep(50 * time.Millisecond)
+
+	firstCancel()
+	s.Equal(context.Canceled, <-firstErrCh)
+	select {
+	case <-canceled:
+		s.Fail("the shared resend is canceled while a caller still waits for it")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the shared resend is canceled once the last caller stops waiting for it
+	secondCancel()
+	s.Equal(context.Canceled, <-secondErrCh)
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		s.Fail("the shared resend is not canceled after all callers stop waiting for it")
+	}
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_DedupeCallerScopedContext() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	started := make(chan struct{}, 2)
+	unblock := make(chan struct{})
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			started <- struct{}{}
+			<-unblock
+			return nil, &shared.EntityNotExistsError{}
+		}).Times(2)
+
+	// the resends carrying the shard of the caller are not collapsed, even if identical
+	errCh := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func(shardID int) {
+			errCh <- s.resender.SendSingleWorkflowHistory(
+				WithResendShardID(context.Background(), shardID),
+				s.domainID,
+				workflowID,
+				runID,
+				common.Int64Ptr(1),
+				common.Int64Ptr(123),
+				common.Int64Ptr(10),
+				common.Int64Ptr(123),
+			)
+		}(i)
+	}
+	<-started
+	<-started
+	close(unblock)
+	for i := 0; i < 2; i++ {
+		s.IsType(&shared.EntityNotExistsError{}, <-errCh)
+	}
+}
+
+func (s *nDCHistoryResenderSuite) TestGetResendKey() {
+	descriptor := &ResendDescriptor{
+		DomainID:          s.domainID,
+		WorkflowID:        "some random workflow ID",
+		RunID:             uuid.New(),
+		StartEventID:      common.Int64Ptr(1),
+		StartEventVersion: common.Int64Ptr(123),
+	}
+	key := getResendKey(descriptor)
+	s.Equal(key, getResendKey(&ResendDescriptor{
+		DomainID:          descriptor.DomainID,
+		WorkflowID:        descriptor.WorkflowID,
+		RunID:             descriptor.RunID,
+		StartEventID:      common.Int64Ptr(1),
+		StartEventVersion: common.Int64Ptr(123),
+	}))
+
+	otherDescriptors := []ResendDescriptor{*descriptor, *descriptor, *descriptor, *descriptor, *descriptor, *descriptor, *descriptor}
+	otherDescriptors[0].StartEventID = common.Int64Ptr(2)
+	otherDescriptors[1].StartEventVersion = nil
+	otherDescriptors[2].EndEventID = common.Int64Ptr(1)
+	otherDescriptors[3].EndEventVersion = common.Int64Ptr(123)
+	otherDescriptors[4].ResumeToken = []byte{1}
+	otherDescriptors[5].TimeoutOverride = time.Minute
+	otherDescriptors[6].CloseTime = time.Now()
+	for _, otherDescriptor := range otherDescriptors {
+		s.NotEqual(key, getResendKey(&otherDescriptor))
+	}
+}
@@ -0,0 +1,368 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/yarpc/yarpcerrors"
+
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/metrics"
+)
+
+var (
+	// ErrSkipTask is the error to skip task due to absence of the workflow in the source cluster,
+	// the actual error returned is SkipTaskError which unwraps to ErrSkipTask
+	ErrSkipTask = errors.New("the source workflow does not exist")
+	// ErrInvalidResumeToken is the error indicating the resume token cannot be decoded
+	ErrInvalidResumeToken = &shared.BadRequestError{Message: "Invalid resume token."}
+	// ErrDomainNotReplicated is the error indicating the domain is a local domain, which is not replicated
+	// to other clusters, so there is no source cluster to resend the history events from
+	ErrDomainNotReplicated = &shared.BadRequestError{Message: "Domain is not replicated across clusters."}
+	// ErrInvalidResendCursor is the error indicating the resend cursor cannot be decoded
+	ErrInvalidResendCursor = &shared.BadRequestError{Message: "Invalid resend cursor."}
+	// ErrResenderClosed is the error indicating the resender is already closed
+	ErrResenderClosed = errors.New("history resender is closed")
+	// ErrResendTooLarge is the error indicating the history events to resend exceed the byte budget,
+	// the actual error returned is ResendTooLargeError which unwraps to ErrResendTooLarge
+	ErrResendTooLarge = errors.New("history events to resend are too large")
+	// ErrTooManyPages is the error indicating the history events to resend span more pages than allowed,
+	// the actual error returned is TooManyPagesError which unwraps to ErrTooManyPages
+	ErrTooManyPages = errors.New("history events to resend span too many pages")
+	// ErrInvalidPageToken is the error indicating the source cluster rejects the page token of the history events,
+	// e.g. a stale resume token, the actual error returned is InvalidPageTokenError which unwraps to ErrInvalidPageToken
+	ErrInvalidPageToken = errors.New("invalid page token of history events")
+	// ErrResendPanic is the error indicating the resend panics, e.g. on a malformed event batch,
+	// the actual error returned is ResendPanicError which unwraps to ErrResendPanic
+	ErrResendPanic = errors.New("history resend panics")
+	// ErrSourceUnreachable is the error indicating the admin service of the source cluster cannot be reached,
+	// the actual error returned is PingError which unwraps to ErrSourceUnreachable
+	ErrSourceUnreachable = errors.New("source cluster is unreachable")
+	// ErrSourceAccessDenied is the error indicating the admin service of the source cluster denies the access,
+	// the actual error returned is PingError which unwraps to ErrSourceAccessDenied
+	ErrSourceAccessDenied = errors.New("access to source cluster is denied")
+	// ErrSourceTLSHandshakeFailed is the error indicating the TLS handshake with the admin service of the source cluster
+	// fails, e.g. on misconfigured certificates, the actual error returned is PingError which unwraps to it
+	ErrSourceTLSHandshakeFailed = errors.New("TLS handshake with source cluster failed")
+	// ErrSourceAdminClientNotFound is the error indicating no admin client is provided for the source cluster of the domain,
+	// the actual error returned is SourceAdminClientNotFoundError which unwraps to ErrSourceAdminClientNotFound
+	ErrSourceAdminClientNotFound = errors.New("admin client of source cluster is not found")
+	// ErrResendCircuitOpen is the error indicating the resends of the domain are failing fast
+	// after too many consecutive failures
+	ErrResendCircuitOpen = &shared.ServiceBusyError{Message: "History resend circuit breaker of the domain is open."}
+	// ErrResendConcurrencyLimited is the error indicating the resend cannot acquire the concurrency slot of its domain in time
+	ErrResendConcurrencyLimited = &shared.ServiceBusyError{Message: "Too many concurrent history resends of the domain."}
+	// ErrNoHistoryEvents is the error indicating the run has no history events in the source cluster,
+	// so the replication lag cannot be estimated
+	ErrNoHistoryEvents = errors.New("the source workflow has no history events")
+	// ErrHistoryReplicationFnNotSet is the error indicating the history replication function to deliver
+	// the history events to remote is not set
+	ErrHistoryReplicationFnNotSet = &shared.InternalServiceError{Message: "History replication function of the resender is not set."}
+	// ErrBatchTooLarge is the error indicating an event batch exceeds the max batch size, so it is not sent to remote
+	ErrBatchTooLarge = &shared.BadRequestError{Message: "History event batch is too large to replicate."}
+	// ErrResendDeadlineExceeded is the error indicating the time left before the rereplication timeout of the resend
+	// is too short for another call to remote, so the resend is aborted instead of making the call doomed to time out
+	ErrResendDeadlineExceeded = errors.New("time left before the rereplication timeout is too short for another call")
+	// ErrHistoryGap is the error indicating the history events to resend are not contiguous,
+	// the actual error returned is HistoryGapError which unwraps to ErrHistoryGap
+	ErrHistoryGap = errors.New("gap in history events to resend")
+	// ErrReplicationChecksumMismatch is the error indicating the checksum of an event batch reported by the target
+	// does not match the checksum of the batch sent, i.e. the batch is corrupted on the way
+	ErrReplicationChecksumMismatch = &shared.InternalServiceError{Message: "Checksum of the replicated history event batch mismatches."}
+)
+
+// tlsHandshakeErrorSnippets are the snippets of the messages of the errors caused by failed TLS handshakes
+var tlsHandshakeErrorSnippets = []string{
+	"x509:",
+	"tls:",
+	"authentication handshake failed",
+}
+
+const (
+	// SkipTaskReasonRetentionExpired indicates the workflow is already deleted after passing the retention period
+	SkipTaskReasonRetentionExpired SkipTaskReason = iota
+	// SkipTaskReasonCorrupted indicates the workflow is corrupted, the task is retried after its current execution record is fixed
+	SkipTaskReasonCorrupted
+	// SkipTaskReasonUpToDate indicates the target already has all the history events of the workflow in the source cluster
+	SkipTaskReasonUpToDate
+)
+
+type (
+	// SkipTaskReason is the reason why the workflow is absent in the source cluster
+	SkipTaskReason int
+
+	// SkipTaskError is the error returned when the task should be skipped due to absence of the workflow in the source cluster
+	SkipTaskError struct {
+		Reason     SkipTaskReason
+		DomainID   string
+		WorkflowID string
+		RunID      string
+	}
+
+	// PingError is the error returned when the admin service of the source cluster cannot be used
+	PingError struct {
+		SourceCluster string
+		// Cause is one of ErrSourceUnreachable, ErrSourceAccessDenied and ErrSourceTLSHandshakeFailed
+		Cause error
+		// Err is the error returned by the admin service
+		Err error
+	}
+
+	// ResendTooLargeError is the error returned when the history events to resend exceed the byte budget
+	ResendTooLargeError struct {
+		BytesReached int64
+		MaxBytes     int64
+	}
+
+	// SourceAdminClientNotFoundError is the error returned when no admin client is provided for the source cluster of the domain
+	SourceAdminClientNotFoundError struct {
+		DomainID      string
+		SourceCluster string
+	}
+
+	// ResendPanicError is the error returned when the resend panics, Value is the value recovered from the panic
+	ResendPanicError struct {
+		Value interface{}
+	}
+
+	// TooManyPagesError is the error returned when the history events to resend span more pages than allowed,
+	// LastPageToken is the token of the first page not fetched, which can be used to investigate or resume the resend
+	TooManyPagesError struct {
+		PageCount     int64
+		MaxPages      int64
+		LastPageToken []byte
+	}
+
+	// InvalidPageTokenError is the error returned when the source cluster rejects the page token of the history events
+	InvalidPageTokenError struct {
+		// Resumed indicates the rejected token is the resume token of the resend
+		Resumed bool
+		// MadeProgress indicates some event batches are sent by the resend before the token is rejected,
+		// the resend should be restarted from scratch if neither the resend nor the resumed one made progress
+		MadeProgress bool
+		// Err is the error returned by the source cluster
+		Err error
+	}
+
+	// HistoryGapError is the error returned when the first event ID of an event batch to resend
+	// does not follow the last event ID of the previous batch
+	HistoryGapError struct {
+		ExpectedEventID int64
+		ActualEventID   int64
+	}
+
+	// ResendPartialError is the error returned when the run no longer exists in remote
+	// after some of its history events are already sent
+	ResendPartialError struct {
+		// Err is the underlying EntityNotExistsError
+		Err error
+		// BatchCount is the number of event batches successfully sent
+		BatchCount int
+		// TotalBytes is the total size of the event batches successfully sent
+		TotalBytes int64
+		// LastEventID is the ID of the last event successfully sent
+		LastEventID int64
+	}
+)
+
+func (r SkipTaskReason) String() string {
+	switch r {
+	case SkipTaskReasonRetentionExpired:
+		return "RetentionExpired"
+	case SkipTaskReasonCorrupted:
+		return "Corrupted"
+	case SkipTaskReasonUpToDate:
+		return "UpToDate"
+	default:
+		return "Unknown"
+	}
+}
+
+// MetricCounter returns the counter metric of the reason
+func (r SkipTaskReason) MetricCounter() int {
+	switch r {
+	case SkipTaskReasonCorrupted:
+		return metrics.ReplicationTaskSkippedCorruptedCounter
+	case SkipTaskReasonUpToDate:
+		return metrics.ReplicationTaskSkippedUpToDateCounter
+	default:
+		return metrics.ReplicationTaskSkippedRetentionExpiredCounter
+	}
+}
+
+// isTerminal returns whether the run is skipped again if resent, so the skip can be remembered
+func (r SkipTaskReason) isTerminal() bool {
+	return r == SkipTaskReasonRetentionExpired
+}
+
+// GetSkipTaskReason returns the reason of the error skipping task
+func GetSkipTaskReason(
+	err error,
+) SkipTaskReason {
+
+	var skipTaskErr *SkipTaskError
+	if errors.As(err, &skipTaskErr) {
+		return skipTaskErr.Reason
+	}
+	return SkipTaskReasonRetentionExpired
+}
+
+func (e *SkipTaskError) Error() string {
+	return fmt.Sprintf(
+		"%v, reason: %v, domain ID: %v, workflow ID: %v, run ID: %v",
+		ErrSkipTask.Error(), e.Reason, e.DomainID, e.WorkflowID, e.RunID,
+	)
+}
+
+// Unwrap returns ErrSkipTask
+func (e *SkipTaskError) Unwrap() error {
+	return ErrSkipTask
+}
+
+func (e *PingError) Error() string {
+	return fmt.Sprintf("%v, source cluster: %v: %v", e.Cause.Error(), e.SourceCluster, e.Err)
+}
+
+// Unwrap returns one of ErrSourceUnreachable, ErrSourceAccessDenied and ErrSourceTLSHandshakeFailed
+func (e *PingError) Unwrap() error {
+	return e.Cause
+}
+
+func (e *ResendTooLargeError) Error() string {
+	return fmt.Sprintf("%v, bytes reached: %v, max bytes: %v", ErrResendTooLarge.Error(), e.BytesReached, e.MaxBytes)
+}
+
+// Unwrap returns ErrResendTooLarge
+func (e *ResendTooLargeError) Unwrap() error {
+	return ErrResendTooLarge
+}
+
+func (e *SourceAdminClientNotFoundError) Error() string {
+	return fmt.Sprintf("%v, domain ID: %v, source cluster: %v", ErrSourceAdminClientNotFound.Error(), e.DomainID, e.SourceCluster)
+}
+
+// Unwrap returns ErrSourceAdminClientNotFound
+func (e *SourceAdminClientNotFoundError) Unwrap() error {
+	return ErrSourceAdminClientNotFound
+}
+
+func (e *ResendPanicError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrResendPanic.Error(), e.Value)
+}
+
+// Unwrap returns ErrResendPanic
+func (e *ResendPanicError) Unwrap() error {
+	return ErrResendPanic
+}
+
+func (e *TooManyPagesError) Error() string {
+	return fmt.Sprintf(
+		"%v, page count: %v, max pages: %v, last page token: %v",
+		ErrTooManyPages.Error(), e.PageCount, e.MaxPages, base64.StdEncoding.EncodeToString(e.LastPageToken),
+	)
+}
+
+// Unwrap returns ErrTooManyPages
+func (e *TooManyPagesError) Unwrap() error {
+	return ErrTooManyPages
+}
+
+func (e *InvalidPageTokenError) Error() string {
+	return fmt.Sprintf(
+		"%v, resumed: %v, made progress: %v: %v",
+		ErrInvalidPageToken.Error(), e.Resumed, e.MadeProgress, e.Err,
+	)
+}
+
+// Unwrap returns ErrInvalidPageToken
+func (e *InvalidPageTokenError) Unwrap() error {
+	return ErrInvalidPageToken
+}
+
+func (e *HistoryGapError) Error() string {
+	return fmt.Sprintf("%v, expected event ID: %v, actual event ID: %v", ErrHistoryGap.Error(), e.ExpectedEventID, e.ActualEventID)
+}
+
+// Unwrap returns ErrHistoryGap
+func (e *HistoryGapError) Unwrap() error {
+	return ErrHistoryGap
+}
+
+func (e *ResendPartialError) Error() string {
+	return fmt.Sprintf(
+		"history resend stopped after %v batches (%v bytes, last event ID: %v) are sent: %v",
+		e.BatchCount, e.TotalBytes, e.LastEventID, e.Err,
+	)
+}
+
+// Unwrap returns the underlying error
+func (e *ResendPartialError) Unwrap() error {
+	return e.Err
+}
+
+func isPingResponse(
+	err error,
+) bool {
+
+	switch err.(type) {
+	case *shared.EntityNotExistsError, *shared.BadRequestError:
+		return true
+	default:
+		return false
+	}
+}
+
+func isAccessDeniedError(
+	err error,
+) bool {
+
+	if _, ok := err.(*shared.AccessDeniedError); ok {
+		return true
+	}
+	return yarpcerrors.IsPermissionDenied(err) || yarpcerrors.IsUnauthenticated(err)
+}
+
+// isTLSHandshakeError returns whether the error is caused by a failed TLS handshake, the transports only carry
+// the message of the handshake error, so the messages of the crypto/tls and crypto/x509 errors are matched
+func isTLSHandshakeError(
+	err error,
+) bool {
+
+	message := err.Error()
+	for _, snippet := range tlsHandshakeErrorSnippets {
+		if strings.Contains(message, snippet) {
+			return true
+		}
+	}
+	return false
+}
+
+func isUnreachableError(
+	err error,
+) bool {
+
+	return common.IsContextTimeoutError(err) || yarpcerrors.IsUnavailable(err)
+}
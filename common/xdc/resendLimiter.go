@@ -0,0 +1,363 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/uber/cadence/common/clock"
+	"github.com/uber/cadence/common/metrics"
+	"github.com/uber/cadence/common/quotas"
+	"github.com/uber/cadence/common/service/dynamicconfig"
+)
+
+type (
+	// ResendLimiter limits the history resends of a host, i.e. the rps of fetching history events from remote and
+	// the concurrent resends of each domain, and the bytes and the calls per second of all the resends. It is created
+	// once per host and shared by all the resenders of the host, so the limits apply to the host as a whole
+	ResendLimiter struct {
+		getHistoryRPS          dynamicconfig.IntPropertyFnWithDomainIDFilter
+		getHistoryLimitersLock sync.RWMutex
+		getHistoryLimiters     map[string]quotas.Limiter
+
+		maxDomainConcurrency         dynamicconfig.IntPropertyFnWithDomainIDFilter
+		domainConcurrencyWaitTimeout dynamicconfig.DurationPropertyFnWithDomainIDFilter
+		domainSlotsLock              sync.Mutex
+		domainSlots                  map[string]*domainResendSlots
+
+		globalOpsPerSecond dynamicconfig.IntPropertyFn
+		globalOpsLimiter   quotas.Limiter
+		globalBytesLimiter *bytesRateLimiter
+	}
+
+	// ResendLimiterOptions configs ResendLimiter, the limit of a nil or non-positive property is disabled,
+	// and the resends wait 5s for the concurrency slot of the domain if the wait timeout is not set
+	ResendLimiterOptions struct {
+		GetHistoryRPS                dynamicconfig.IntPropertyFnWithDomainIDFilter
+		MaxDomainConcurrency         dynamicconfig.IntPropertyFnWithDomainIDFilter
+		DomainConcurrencyWaitTimeout dynamicconfig.DurationPropertyFnWithDomainIDFilter
+		GlobalBytesPerSecond         dynamicconfig.IntPropertyFn
+		GlobalOpsPerSecond           dynamicconfig.IntPropertyFn
+	}
+
+	// domainResendSlots tracks the concurrent resends of a domain,
+	// it is removed from the map once there is no resend running or waiting
+	domainResendSlots struct {
+		running int
+		// waiting is the number of the waiting resends by their priority
+		waiting [numResendPriorities]int
+		// released is closed and replaced whenever a slot is released
+		released chan struct{}
+	}
+
+	// bytesRateLimiter limits the bytes per second with the burst of the bytes of a second,
+	// the rate limiter is recreated once the limit is changed
+	bytesRateLimiter struct {
+		bytesPerSecond dynamicconfig.IntPropertyFn
+
+		sync.Mutex
+		limit   int
+		limiter *rate.Limiter
+	}
+)
+
+// NewResendLimiter creates a new ResendLimiter
+func NewResendLimiter(
+	options *ResendLimiterOptions,
+) *ResendLimiter {
+
+	limiter := &ResendLimiter{
+		getHistoryRPS:                options.GetHistoryRPS,
+		getHistoryLimiters:           make(map[string]quotas.Limiter),
+		maxDomainConcurrency:         options.MaxDomainConcurrency,
+		domainConcurrencyWaitTimeout: options.DomainConcurrencyWaitTimeout,
+		domainSlots:                  make(map[string]*domainResendSlots),
+		globalBytesLimiter:           &bytesRateLimiter{bytesPerSecond: options.GlobalBytesPerSecond},
+		globalOpsPerSecond:           options.GlobalOpsPerSecond,
+	}
+	if options.GlobalOpsPerSecond != nil {
+		limiter.globalOpsLimiter = quotas.NewDynamicRateLimiter(
+			func() float64 {
+				return float64(options.GlobalOpsPerSecond())
+			},
+		)
+	}
+	return limiter
+}
+
+// acquireDomainSlot waits for a concurrency slot of the domain, a released slot is taken by the waiting resends
+// of the highest ResendPriority carried by their contexts first,
+// the returned function must be called to release the slot once the resend completes
+func (l *ResendLimiter) acquireDomainSlot(
+	ctx context.Context,
+	timeSource clock.TimeSource,
+	domainID string,
+) (func(), error) {
+
+	if l.maxDomainConcurrency == nil {
+		return func() {}, nil
+	}
+
+	priority := GetResendPriority(ctx)
+	if priority < 0 || priority >= numResendPriorities {
+		priority = ResendPriorityNormal
+	}
+	var slots *domainResendSlots
+	var waitCtx context.Context
+	for {
+		// the limit is read for each attempt so it can be tuned while resends are waiting
+		maxConcurrency := l.maxDomainConcurrency(domainID)
+
+		l.domainSlotsLock.Lock()
+		if maxConcurrency <= 0 {
+			if slots != nil {
+				slots.waiting[priority]--
+				l.pruneDomainSlotsLocked(domainID, slots)
+			}
+			l.domainSlotsLock.Unlock()
+			return func() {}, nil
+		}
+		if slots == nil {
+			var ok bool
+			slots, ok = l.domainSlots[domainID]
+			if !ok {
+				slots = &domainResendSlots{released: make(chan struct{})}
+				l.domainSlots[domainID] = slots
+			}
+			// the resend is counted as waiting until it takes a slot or gives up,
+			// so the resends of lower priority woken up by the same release leave the slot to it
+			slots.waiting[priority]++
+		}
+		if slots.running < maxConcurrency && !slots.isPreferredWaitingLocked(priority) {
+			slots.waiting[priority]--
+			slots.running++
+			l.domainSlotsLock.Unlock()
+			return func() { l.releaseDomainSlot(domainID) }, nil
+		}
+		released := slots.released
+		l.domainSlotsLock.Unlock()
+
+		if waitCtx == nil {
+			// the wait timeout is measured by the time source, and spans all the attempts
+			var cancel context.CancelFunc
+			waitCtx, cancel = clock.ContextWithTimeout(ctx, timeSource, l.getDomainConcurrencyWaitTimeout(domainID))
+			defer cancel()
+		}
+		select {
+		case <-released:
+		case <-waitCtx.Done():
+			err := ctx.Err()
+			if err == nil {
+				err = ErrResendConcurrencyLimited
+			}
+			l.domainSlotsLock.Lock()
+			slots.waiting[priority]--
+			l.pruneDomainSlotsLocked(domainID, slots)
+			// the resends of lower priority left waiting for this one can take the free slot
+			close(slots.released)
+			slots.released = make(chan struct{})
+			l.domainSlotsLock.Unlock()
+			return nil, err
+		}
+	}
+}
+
+func (l *ResendLimiter) releaseDomainSlot(
+	domainID string,
+) {
+
+	l.domainSlotsLock.Lock()
+	defer l.domainSlotsLock.Unlock()
+
+	slots := l.domainSlots[domainID]
+	slots.running--
+	close(slots.released)
+	slots.released = make(chan struct{})
+	l.pruneDomainSlotsLocked(domainID, slots)
+}
+
+func (l *ResendLimiter) pruneDomainSlotsLocked(
+	domainID string,
+	slots *domainResendSlots,
+) {
+
+	if slots.running == 0 && slots.getWaitingLocked() == 0 {
+		delete(l.domainSlots, domainID)
+	}
+}
+
+// isPreferredWaitingLocked returns whether any resend of a higher priority than the priority is waiting
+func (s *domainResendSlots) isPreferredWaitingLocked(
+	priority ResendPriority,
+) bool {
+
+	for waitingPriority, waiting := range s.waiting {
+		if waiting > 0 && ResendPriority(waitingPriority).isPreferredOver(priority) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *domainResendSlots) getWaitingLocked() int {
+	waiting := 0
+	for _, count := range s.waiting {
+		waiting += count
+	}
+	return waiting
+}
+
+func (l *ResendLimiter) getDomainConcurrencyWaitTimeout(
+	domainID string,
+) time.Duration {
+
+	if l.domainConcurrencyWaitTimeout == nil {
+		return defaultDomainConcurrencyWaitTimeout
+	}
+	return l.domainConcurrencyWaitTimeout(domainID)
+}
+
+func (l *ResendLimiter) waitGetHistoryToken(
+	ctx context.Context,
+	domainID string,
+) error {
+
+	if l.getHistoryRPS == nil || l.getHistoryRPS(domainID) <= 0 {
+		return nil
+	}
+
+	l.getHistoryLimitersLock.RLock()
+	limiter, ok := l.getHistoryLimiters[domainID]
+	l.getHistoryLimitersLock.RUnlock()
+
+	if !ok {
+		domainLimiter := quotas.NewDynamicRateLimiter(
+			func() float64 {
+				return float64(l.getHistoryRPS(domainID))
+			},
+		)
+
+		l.getHistoryLimitersLock.Lock()
+		limiter, ok = l.getHistoryLimiters[domainID]
+		if !ok {
+			l.getHistoryLimiters[domainID] = domainLimiter
+			limiter = domainLimiter
+		}
+		l.getHistoryLimitersLock.Unlock()
+	}
+
+	if err := limiter.Wait(ctx); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// waitGlobalOpsToken waits for a call allowed by the global throughput limit of the host, if any
+func (l *ResendLimiter) waitGlobalOpsToken(
+	ctx context.Context,
+) error {
+
+	if l.globalOpsLimiter == nil || l.globalOpsPerSecond() <= 0 {
+		return nil
+	}
+	if err := l.globalOpsLimiter.Wait(ctx); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// waitGlobalBytes waits for the bytes allowed by the global throughput limit of the host, if any
+func (l *ResendLimiter) waitGlobalBytes(
+	ctx context.Context,
+	bytes int64,
+) error {
+
+	if l.globalBytesLimiter == nil {
+		return nil
+	}
+	if err := l.globalBytesLimiter.waitN(ctx, bytes); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// waitN waits for the bytes, the bytes beyond the burst are waited for in chunks of the burst,
+// since the rate limiter cannot wait for more than the burst at once
+func (l *bytesRateLimiter) waitN(
+	ctx context.Context,
+	bytes int64,
+) error {
+
+	if l.bytesPerSecond == nil {
+		return nil
+	}
+	limit := l.bytesPerSecond()
+	if limit <= 0 {
+		return nil
+	}
+
+	l.Lock()
+	if l.limiter == nil || l.limit != limit {
+		l.limiter = rate.NewLimiter(rate.Limit(limit), limit)
+		l.limit = limit
+	}
+	limiter := l.limiter
+	l.Unlock()
+
+	for bytes > 0 {
+		chunk := bytes
+		if chunk > int64(limit) {
+			chunk = int64(limit)
+		}
+		if err := limiter.WaitN(ctx, int(chunk)); err != nil {
+			return err
+		}
+		bytes -= chunk
+	}
+	return nil
+}
+
+// acquireResendSlot waits for a concurrency slot of the domain of the run,
+// the returned function must be called to release the slot once the resend completes
+func (n *NDCHistoryResenderImpl) acquireResendSlot(
+	ctx context.Context,
+	run *resendRun,
+) (func(), error) {
+
+	releaseSlot, err := n.limiter.acquireDomainSlot(ctx, n.timeSource, run.descriptor.DomainID)
+	if err == ErrResendConcurrencyLimited {
+		run.scope.IncCounter(metrics.HistoryResendConcurrencyLimitedCounter)
+	}
+	return releaseSlot, err
+}
@@ -0,0 +1,301 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc"
+
+	"github.com/uber/cadence/.gen/go/admin"
+	"github.com/uber/cadence/.gen/go/history"
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/clock"
+	"github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/service/dynamicconfig"
+)
+
+func TestResendLimiter_DomainSlotByPriority(t *testing.T) {
+	domainID := "some random domain ID"
+	limiter := NewResendLimiter(&ResendLimiterOptions{
+		MaxDomainConcurrency:         func(domainID string) int { return 1 },
+		DomainConcurrencyWaitTimeout: func(domainID string) time.Duration { return time.Minute },
+	})
+	timeSource := clock.NewRealTimeSource()
+	acquire := func(priority ResendPriority) <-chan func() {
+		acquiredCh := make(chan func(), 1)
+		go func() {
+			release, err := limiter.acquireDomainSlot(WithResendPriority(context.Background(), priority), timeSource, domainID)
+			assert.NoError(t, err)
+			acquiredCh <- release
+		}()
+		return acquiredCh
+	}
+	waitForWaiting := func(waiting int) {
+		require.Eventually(t, func() bool {
+			limiter.domainSlotsLock.Lock()
+			defer limiter.domainSlotsLock.Unlock()
+			return limiter.domainSlots[domainID].getWaitingLocked() == waiting
+		}, time.Second, time.Millisecond)
+	}
+
+	releaseNormal := <-acquire(ResendPriorityNormal)
+	lowCh := acquire(ResendPriorityLow)
+	waitForWaiting(1)
+	highCh := acquire(ResendPriorityHigh)
+	waitForWaiting(2)
+
+	// the released slot is taken by the resend of the higher priority, though it waits for less time
+	releaseNormal()
+	releaseHigh := <-highCh
+	select {
+	case <-lowCh:
+		require.Fail(t, "the resend of low priority takes the slot before the one of high priority")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	releaseHigh()
+	releaseLow := <-lowCh
+	releaseLow()
+
+	limiter.domainSlotsLock.Lock()
+	defer limiter.domainSlotsLock.Unlock()
+	require.Empty(t, limiter.domainSlots)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_DomainConcurrencyLimited() {
+	workflowID := "some random workflow ID"
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	response := &admin.GetWorkflowExecutionRawHistoryV2Response{
+		VersionHistory: &shared.VersionHistory{},
+	}
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			close(started)
+			<-unblock
+			return response, nil
+		}).Times(1)
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).Return(response, nil).Times(1)
+
+	waitTimeout := 10 * time.Millisecond
+	limiter := NewResendLimiter(&ResendLimiterOptions{
+		MaxDomainConcurrency:         func(domainID string) int { return 1 },
+		DomainConcurrencyWaitTimeout: func(domainID string) time.Duration { return waitTimeout },
+	})
+	WithResendLimiter(limiter)(s.rereplicator)
+	// the limiter is shared by the resenders of the host
+	otherRereplicator, err := NewNDCHistoryResender(
+		s.mockDomainCache,
+		s.mockAdminClient,
+		func(ctx context.Context, request *history.ReplicateEventsV2Request) error {
+			return s.mockHistoryClient.ReplicateEventsV2(ctx, request)
+		},
+		persistence.NewPayloadSerializer(),
+		nil,
+		nil,
+		s.metricsClient,
+		s.logger,
+		WithResendLimiter(limiter),
+	)
+	s.NoError(err)
+	defer otherRereplicator.Close()
+	sendHistory := func(rereplicator *NDCHistoryResenderImpl) error {
+		return rereplicator.SendSingleWorkflowHistory(
+			context.Background(),
+			s.domainID,
+			workflowID,
+			uuid.New(),
+			common.Int64Ptr(1),
+			common.Int64Ptr(123),
+			nil,
+			nil,
+		)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.NoError(sendHistory(s.rereplicator))
+	}()
+	<-started
+
+	// the only slot of the domain is taken by the first resend, even for the other resender
+	s.Equal(ErrResendConcurrencyLimited, sendHistory(s.rereplicator))
+	s.Equal(ErrResendConcurrencyLimited, sendHistory(otherRereplicator))
+
+	waitTimeout = time.Minute
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.NoError(sendHistory(otherRereplicator))
+	}()
+	s.Eventually(func() bool {
+		limiter.domainSlotsLock.Lock()
+		defer limiter.domainSlotsLock.Unlock()
+		return limiter.domainSlots[s.domainID].getWaitingLocked() == 1
+	}, time.Second, time.Millisecond)
+	close(unblock)
+	wg.Wait()
+
+	limiter.domainSlotsLock.Lock()
+	defer limiter.domainSlotsLock.Unlock()
+	s.Empty(limiter.domainSlots)
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_GlobalThroughputLimit() {
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+		{
+			EventId:   common.Int64Ptr(3),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskStarted.Ptr(),
+		},
+	})
+	response := &admin.GetWorkflowExecutionRawHistoryV2Response{
+		HistoryBatches: []*shared.DataBlob{blob},
+		VersionHistory: &shared.VersionHistory{
+			Items: []*shared.VersionHistoryItem{
+				{
+					EventID: common.Int64Ptr(3),
+					Version: common.Int64Ptr(123),
+				},
+			},
+		},
+	}
+	resendCount := 6
+
+	testCases := []struct {
+		name           string
+		bytesPerSecond int
+		opsPerSecond   int
+		// limit and total are the limit per second and the total of the bytes or the calls limited
+		limit int
+		total int
+	}{
+		{
+			name:           "bytes",
+			bytesPerSecond: resendCount * len(blob.Data),
+			limit:          resendCount * len(blob.Data),
+			// each resend fetches and sends the batch once
+			total: 2 * resendCount * len(blob.Data),
+		},
+		{
+			name:         "ops",
+			opsPerSecond: resendCount,
+			limit:        resendCount,
+			// each resend fetches a page and sends a batch
+			total: 2 * resendCount,
+		},
+	}
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+				Return(response, nil).Times(resendCount)
+			s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(resendCount)
+			WithResendLimiter(NewResendLimiter(&ResendLimiterOptions{
+				GlobalBytesPerSecond: dynamicconfig.GetIntPropertyFn(tc.bytesPerSecond),
+				GlobalOpsPerSecond:   dynamicconfig.GetIntPropertyFn(tc.opsPerSecond),
+			}))(s.rereplicator)
+
+			startTime := time.Now()
+			var wg sync.WaitGroup
+			for i := 0; i < resendCount; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					s.NoError(s.resender.SendSingleWorkflowHistory(
+						context.Background(),
+						s.domainID,
+						"some random workflow ID",
+						uuid.New(),
+						nil,
+						nil,
+						nil,
+						nil,
+					))
+				}()
+			}
+			wg.Wait()
+			elapsed := time.Since(startTime)
+
+			// the burst of a second is allowed up front, the rest is limited to the limit per second
+			s.True(
+				float64(tc.total) <= float64(tc.limit)*(1+elapsed.Seconds()),
+				"total: %v, limit: %v, elapsed: %v", tc.total, tc.limit, elapsed,
+			)
+		})
+	}
+}
+
+func (s *nDCHistoryResenderSuite) TestGetHistory_RateLimited() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	pageSize := int32(59)
+	WithResendLimiter(NewResendLimiter(&ResendLimiterOptions{
+		GetHistoryRPS: func(domainID string) int { return 1 },
+	}))(s.rereplicator)
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{}, nil).Times(1)
+	_, err := s.rereplicator.getHistory(
+		context.Background(),
+		s.domainEntry,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		pageSize)
+	s.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.rereplicator.getHistory(
+		ctx,
+		s.domainEntry,
+		workflowID,
+		runID,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		pageSize)
+	s.Equal(context.Canceled, err)
+}
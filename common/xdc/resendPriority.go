@@ -0,0 +1,116 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type (
+	// ResendPriority is the priority of a resend, which is carried by the context of the calls
+	// to the source and the target clusters made by the resend, so the rate limiters and queues can honor it,
+	// e.g. the concurrency slots of a domain in ResendLimiter are taken by the waiting resends of the highest priority first
+	ResendPriority int
+)
+
+const (
+	// ResendPriorityNormal is the default priority of the resends
+	ResendPriorityNormal ResendPriority = iota
+	// ResendPriorityHigh is the priority of the resends preferred over the normal ones
+	ResendPriorityHigh
+	// ResendPriorityLow is the priority of the resends deferred for the normal ones
+	ResendPriorityLow
+
+	numResendPriorities = 3
+)
+
+// String returns the name of the priority
+func (p ResendPriority) String() string {
+	switch p {
+	case ResendPriorityNormal:
+		return "normal"
+	case ResendPriorityHigh:
+		return "high"
+	case ResendPriorityLow:
+		return "low"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(p))
+	}
+}
+
+// isPreferredOver returns whether the resends of the priority are preferred over the ones of the other priority
+func (p ResendPriority) isPreferredOver(
+	other ResendPriority,
+) bool {
+
+	return p.rank() < other.rank()
+}
+
+func (p ResendPriority) rank() int {
+	switch p {
+	case ResendPriorityHigh:
+		return 0
+	case ResendPriorityLow:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// ParseResendPriority parses the name of the priority case-insensitively, empty name is parsed as ResendPriorityNormal
+func ParseResendPriority(
+	name string,
+) (ResendPriority, error) {
+
+	switch strings.ToLower(name) {
+	case "", "normal":
+		return ResendPriorityNormal, nil
+	case "high":
+		return ResendPriorityHigh, nil
+	case "low":
+		return ResendPriorityLow, nil
+	default:
+		return ResendPriorityNormal, fmt.Errorf("unknown resend priority: %v", name)
+	}
+}
+
+// WithResendPriority returns a copy of the context carrying the priority, which overrides the priority
+// configured for the domain of the resends with the context
+func WithResendPriority(
+	ctx context.Context,
+	priority ResendPriority,
+) context.Context {
+
+	return context.WithValue(ctx, resendPriorityKey, priority)
+}
+
+// GetResendPriority returns the priority carried by the context, ResendPriorityNormal is returned if there is none
+func GetResendPriority(
+	ctx context.Context,
+) ResendPriority {
+
+	if priority, ok := ctx.Value(resendPriorityKey).(ResendPriority); ok {
+		return priority
+	}
+	return ResendPriorityNormal
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/yarpc"
+
+	"github.com/uber/cadence/.gen/go/admin"
+	"github.com/uber/cadence/.gen/go/history"
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/service/dynamicconfig"
+)
+
+func TestParseResendPriority(t *testing.T) {
+	testCases := []struct {
+		name     string
+		priority ResendPriority
+		valid    bool
+	}{
+		{name: "", priority: ResendPriorityNormal, valid: true},
+		{name: "normal", priority: ResendPriorityNormal, valid: true},
+		{name: "High", priority: ResendPriorityHigh, valid: true},
+		{name: "LOW", priority: ResendPriorityLow, valid: true},
+		{name: "some random priority", priority: ResendPriorityNormal, valid: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			priority, err := ParseResendPriority(tc.name)
+			assert.Equal(t, tc.priority, priority)
+			assert.Equal(t, tc.valid, err == nil)
+		})
+	}
+}
+
+func TestResendPriority_Context(t *testing.T) {
+	assert.Equal(t, ResendPriorityNormal, GetResendPriority(context.Background()))
+	for _, priority := range []ResendPriority{ResendPriorityHigh, ResendPriorityNormal, ResendPriorityLow} {
+		ctx := WithResendPriority(context.Background(), priority)
+		assert.Equal(t, priority, GetResendPriority(ctx))
+
+		parsed, err := ParseResendPriority(priority.String())
+		assert.NoError(t, err)
+		assert.Equal(t, priority, parsed)
+	}
+}
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_Priority() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	WithDomainPriority(dynamicconfig.GetStringPropertyFnFilteredByDomain("high"))(s.rereplicator)
+
+	var fetchPriorities, sendPriorities []ResendPriority
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			fetchPriorities = append(fetchPriorities, GetResendPriority(ctx))
+			return &admin.GetWorkflowExecutionRawHistoryV2Response{
+				HistoryBatches: []*shared.DataBlob{blob},
+				VersionHistory: &shared.VersionHistory{
+					Items: []*shared.VersionHistoryItem{
+						{
+							EventID: common.Int64Ptr(2),
+							Version: common.Int64Ptr(123),
+						},
+					},
+				},
+			}, nil
+		}).Times(2)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *history.ReplicateEventsV2Request, opts ...yarpc.CallOption) error {
+			sendPriorities = append(sendPriorities, GetResendPriority(ctx))
+			return nil
+		}).Times(2)
+
+	for _, ctx := range []context.Context{
+		context.Background(),
+		// the priority of the context takes precedence over the one of the domain
+		WithResendPriority(context.Background(), ResendPriorityLow),
+	} {
+		err := s.resender.SendSingleWorkflowHistory(
+			ctx,
+			s.domainID,
+			workflowID,
+			runID,
+			nil,
+			nil,
+			common.Int64Ptr(3),
+			common.Int64Ptr(123),
+		)
+		s.NoError(err)
+	}
+	s.Equal([]ResendPriority{ResendPriorityHigh, ResendPriorityLow}, fetchPriorities)
+	s.Equal([]ResendPriority{ResendPriorityHigh, ResendPriorityLow}, sendPriorities)
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/metrics"
+)
+
+var (
+	// ErrResendQueueClosed is the error indicating the resend queue is not started or already stopped,
+	// it is also reported to the callbacks of the requests left in the queue when the queue is stopped
+	ErrResendQueueClosed = errors.New("history resend queue is closed")
+)
+
+type (
+	// ResendQueueRequest is a resend queued to be done in background
+	ResendQueueRequest struct {
+		Descriptor *ResendDescriptor
+		// Callback is invoked once the resend completes or fails, or the queue is stopped before the resend starts,
+		// it can be nil, in which case the failure of the resend is only logged
+		Callback func(result *ResendResult, err error)
+	}
+
+	// ResendQueue queues the resends to be done in background by a fixed number of workers,
+	// so the callers are decoupled from the resend completion while the concurrency of the resends is bounded
+	ResendQueue interface {
+		common.Daemon
+
+		// Enqueue queues the resend and returns immediately if the queue is not full,
+		// otherwise it blocks until the queue has room, the context is done or the queue is stopped
+		Enqueue(ctx context.Context, request *ResendQueueRequest) error
+		// Depth returns the number of the resends waiting in the queue
+		Depth() int
+		// InFlight returns the number of the resends being done by the workers
+		InFlight() int
+	}
+
+	resendQueueImpl struct {
+		status      int32
+		resender    NDCHistoryResender
+		workerCount int
+		scope       metrics.Scope
+		logger      log.Logger
+
+		// enqueueLock guards the requests being enqueued against the draining of the queue on Stop
+		enqueueLock sync.RWMutex
+		requestCh   chan *ResendQueueRequest
+		inFlight    int64
+
+		ctx        context.Context
+		cancel     context.CancelFunc
+		shutdownCh chan struct{}
+		shutdownWG sync.WaitGroup
+	}
+)
+
+var _ ResendQueue = (*resendQueueImpl)(nil)
+
+// NewResendQueue creates a new ResendQueue draining the queue of the given size by the given number of workers,
+// it panics if the resender is missing or the worker count is not positive
+func NewResendQueue(
+	resender NDCHistoryResender,
+	workerCount int,
+	queueSize int,
+	metricsClient metrics.Client,
+	logger log.Logger,
+) ResendQueue {
+
+	if resender == nil {
+		panic("history resender is required")
+	}
+	if workerCount <= 0 {
+		panic("history resend queue requires at least one worker")
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &resendQueueImpl{
+		status:      common.DaemonStatusInitialized,
+		resender:    resender,
+		workerCount: workerCount,
+		scope:       metricsClient.Scope(metrics.NDCHistoryResenderScope),
+		logger:      logger,
+		requestCh:   make(chan *ResendQueueRequest, queueSize),
+		ctx:         ctx,
+		cancel:      cancel,
+		shutdownCh:  make(chan struct{}),
+	}
+}
+
+// Start starts the workers draining the queue
+func (q *resendQueueImpl) Start() {
+	if !atomic.CompareAndSwapInt32(&q.status, common.DaemonStatusInitialized, common.DaemonStatusStarted) {
+		return
+	}
+
+	q.shutdownWG.Add(q.workerCount)
+	for i := 0; i < q.workerCount; i++ {
+		go q.workerLoop()
+	}
+	q.logger.Info("History resend queue started.", tag.Counter(q.workerCount))
+}
+
+// Stop cancels the in-flight resends and waits for the workers to exit,
+// the requests left in the queue fail with ErrResendQueueClosed
+func (q *resendQueueImpl) Stop() {
+	if !atomic.CompareAndSwapInt32(&q.status, common.DaemonStatusStarted, common.DaemonStatusStopped) {
+		return
+	}
+
+	close(q.shutdownCh)
+	q.cancel()
+	q.shutdownWG.Wait()
+
+	q.enqueueLock.Lock()
+	defer q.enqueueLock.Unlock()
+	for {
+		select {
+		case request := <-q.requestCh:
+			q.complete(request, nil, ErrResendQueueClosed)
+		default:
+			q.emitMetrics()
+			q.logger.Info("History resend queue stopped.")
+			return
+		}
+	}
+}
+
+// Enqueue queues the resend and returns immediately if the queue is not full,
+// otherwise it blocks until the queue has room, the context is done or the queue is stopped
+func (q *resendQueueImpl) Enqueue(
+	ctx context.Context,
+	request *ResendQueueRequest,
+) error {
+
+	if request == nil || request.Descriptor == nil {
+		return &shared.BadRequestError{Message: "Resend descriptor is not set."}
+	}
+
+	q.enqueueLock.RLock()
+	defer q.enqueueLock.RUnlock()
+
+	if atomic.LoadInt32(&q.status) != common.DaemonStatusStarted {
+		return ErrResendQueueClosed
+	}
+	select {
+	case q.requestCh <- request:
+		q.emitMetrics()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-q.shutdownCh:
+		return ErrResendQueueClosed
+	}
+}
+
+// Depth returns the number of the resends waiting in the queue
+func (q *resendQueueImpl) Depth() int {
+	return len(q.requestCh)
+}
+
+// InFlight returns the number of the resends being done by the workers
+func (q *resendQueueImpl) InFlight() int {
+	return int(atomic.LoadInt64(&q.inFlight))
+}
+
+func (q *resendQueueImpl) workerLoop() {
+	defer q.shutdownWG.Done()
+
+	for {
+		select {
+		case <-q.shutdownCh:
+			return
+		case request := <-q.requestCh:
+			select {
+			case <-q.shutdownCh:
+				// the queue is stopped while the request is being dequeued
+				q.complete(request, nil, ErrResendQueueClosed)
+				return
+			default:
+			}
+			q.process(request)
+		}
+	}
+}
+
+func (q *resendQueueImpl) process(
+	request *ResendQueueRequest,
+) {
+
+	atomic.AddInt64(&q.inFlight, 1)
+	q.emitMetrics()
+	result, err := q.resender.ResendWorkflowHistory(q.ctx, request.Descriptor)
+	atomic.AddInt64(&q.inFlight, -1)
+	q.emitMetrics()
+
+	q.complete(request, result, err)
+}
+
+func (q *resendQueueImpl) complete(
+	request *ResendQueueRequest,
+	result *ResendResult,
+	err error,
+) {
+
+	if request.Callback != nil {
+		request.Callback(result, err)
+		return
+	}
+	if err != nil {
+		q.logger.Warn("Queued history resend failed.",
+			tag.WorkflowDomainID(request.Descriptor.DomainID),
+			tag.WorkflowID(request.Descriptor.WorkflowID),
+			tag.WorkflowRunID(request.Descriptor.RunID),
+			tag.Error(err))
+	}
+}
+
+func (q *resendQueueImpl) emitMetrics() {
+	q.scope.UpdateGauge(metrics.HistoryResendQueueDepthGauge, float64(q.Depth()))
+	q.scope.UpdateGauge(metrics.HistoryResendQueueInFlightGauge, float64(q.InFlight()))
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+
+	"github.com/uber/cadence/common/log/loggerimpl"
+	"github.com/uber/cadence/common/metrics"
+)
+
+type (
+	resendQueueSuite struct {
+		suite.Suite
+		*require.Assertions
+
+		controller   *gomock.Controller
+		mockResender *MockNDCHistoryResender
+
+		queue ResendQueue
+	}
+)
+
+func TestResendQueueSuite(t *testing.T) {
+	s := new(resendQueueSuite)
+	suite.Run(t, s)
+}
+
+func (s *resendQueueSuite) SetupTest() {
+	s.Assertions = require.New(s.T())
+
+	s.controller = gomock.NewController(s.T())
+	s.mockResender = NewMockNDCHistoryResender(s.controller)
+
+	s.queue = NewResendQueue(
+		s.mockResender,
+		1,
+		1,
+		metrics.NewClient(tally.NoopScope, metrics.Common),
+		loggerimpl.NewDevelopmentForTest(s.Suite),
+	)
+	s.queue.Start()
+}
+
+func (s *resendQueueSuite) TearDownTest() {
+	s.queue.Stop()
+	s.controller.Finish()
+}
+
+func (s *resendQueueSuite) TestEnqueue() {
+	descriptor := &ResendDescriptor{
+		DomainID:   "some random domain ID",
+		WorkflowID: "some random workflow ID",
+		RunID:      "some random run ID",
+	}
+	result := &ResendResult{BatchCount: 1}
+	s.mockResender.EXPECT().ResendWorkflowHistory(gomock.Any(), descriptor).Return(result, nil).Times(1)
+
+	doneCh := make(chan struct{})
+	err := s.queue.Enqueue(context.Background(), &ResendQueueRequest{
+		Descriptor: descriptor,
+		Callback: func(actualResult *ResendResult, err error) {
+			s.NoError(err)
+			s.Equal(result, actualResult)
+			close(doneCh)
+		},
+	})
+	s.NoError(err)
+
+	select {
+	case <-doneCh:
+	case <-time.After(5 * time.Second):
+		s.Fail("queued resend is not done")
+	}
+}
+
+func (s *resendQueueSuite) TestEnqueue_InvalidRequest() {
+	err := s.queue.Enqueue(context.Background(), &ResendQueueRequest{})
+	s.Error(err)
+}
+
+func (s *resendQueueSuite) TestEnqueue_Backpressure() {
+	startedCh := make(chan struct{})
+	s.mockResender.EXPECT().ResendWorkflowHistory(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, descriptor *ResendDescriptor) (*ResendResult, error) {
+			close(startedCh)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}).Times(1)
+
+	// the only worker is blocked by the first request, and the second request fills the queue
+	inFlightErrCh := make(chan error, 1)
+	s.NoError(s.queue.Enqueue(context.Background(), &ResendQueueRequest{
+		Descriptor: &ResendDescriptor{},
+		Callback: func(_ *ResendResult, err error) {
+			inFlightErrCh <- err
+		},
+	}))
+	<-startedCh
+	queuedErrCh := make(chan error, 1)
+	s.NoError(s.queue.Enqueue(context.Background(), &ResendQueueRequest{
+		Descriptor: &ResendDescriptor{},
+		Callback: func(_ *ResendResult, err error) {
+			queuedErrCh <- err
+		},
+	}))
+	s.Equal(1, s.queue.Depth())
+	s.Equal(1, s.queue.InFlight())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := s.queue.Enqueue(ctx, &ResendQueueRequest{Descriptor: &ResendDescriptor{}})
+	s.Equal(context.DeadlineExceeded, err)
+
+	s.queue.Stop()
+	s.Equal(context.Canceled, <-inFlightErrCh)
+	s.Equal(ErrResendQueueClosed, <-queuedErrCh)
+	s.Equal(0, s.queue.Depth())
+	s.Equal(0, s.queue.InFlight())
+
+	err = s.queue.Enqueue(context.Background(), &ResendQueueRequest{Descriptor: &ResendDescriptor{}})
+	s.Equal(ErrResendQueueClosed, err)
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/uber/cadence/common/backoff"
+	"github.com/uber/cadence/common/metrics"
+)
+
+type (
+	// ResendStats is the cumulative counters of the resends made by a resender, estimations are not counted
+	ResendStats struct {
+		// WorkflowCount is the number of runs fully resent
+		WorkflowCount int64
+		// BatchCount is the number of event batches successfully sent
+		BatchCount int64
+		// TotalBytes is the total size of the event batches successfully sent
+		TotalBytes int64
+		// SkipCount is the number of runs skipped since they do not exist in the source cluster
+		SkipCount int64
+		// FetchedBytes is the total size of the event batches received from the source cluster
+		FetchedBytes int64
+		// SentBytes is the total size of the event batches handed to the targets, including the retries
+		SentBytes int64
+		// RetryBudgetRemaining is the number of retries left in the budgets of the in-flight multi-run resends
+		RetryBudgetRemaining int64
+		// RetryBudgetExhaustedCount is the number of retryable failures not retried since the budget is exhausted
+		RetryBudgetExhaustedCount int64
+	}
+
+	// resendStatsRecorder accumulates the ResendStats of a resender,
+	// along with the retry budgets of its in-flight multi-run resends
+	resendStatsRecorder struct {
+		sync.Mutex
+		stats        ResendStats
+		retryBudgets map[*retryBudget]struct{}
+	}
+
+	// retryBudget is the number of retries shared by all the runs of a multi-run resend,
+	// so the retries are capped in total regardless of how many runs are failing
+	retryBudget struct {
+		remaining int64
+	}
+)
+
+func newResendStatsRecorder() *resendStatsRecorder {
+	return &resendStatsRecorder{
+		retryBudgets: make(map[*retryBudget]struct{}),
+	}
+}
+
+// snapshot returns the cumulative counters along with the retries left in the budgets registered
+func (r *resendStatsRecorder) snapshot() ResendStats {
+	r.Lock()
+	defer r.Unlock()
+
+	stats := r.stats
+	for budget := range r.retryBudgets {
+		stats.RetryBudgetRemaining += atomic.LoadInt64(&budget.remaining)
+	}
+	return stats
+}
+
+// record adds the work done by a resend to the counters
+func (r *resendStatsRecorder) record(
+	result *ResendResult,
+	err error,
+) {
+
+	if result == nil {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.stats.BatchCount += int64(result.BatchCount)
+	r.stats.TotalBytes += result.TotalBytes
+	r.stats.FetchedBytes += result.FetchedBytes
+	r.stats.SentBytes += result.SentBytes
+	switch {
+	case result.Skipped:
+		r.stats.SkipCount++
+	case err == nil:
+		r.stats.WorkflowCount++
+	}
+}
+
+// recordRetryBudgetExhausted counts a retryable failure not retried since the budget is exhausted
+func (r *resendStatsRecorder) recordRetryBudgetExhausted() {
+	r.Lock()
+	defer r.Unlock()
+
+	r.stats.RetryBudgetExhaustedCount++
+}
+
+// registerRetryBudget creates a retry budget of the size, the retries left in which are reported by snapshot
+// until it is unregistered
+func (r *resendStatsRecorder) registerRetryBudget(
+	size int64,
+) *retryBudget {
+
+	budget := &retryBudget{remaining: size}
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.retryBudgets[budget] = struct{}{}
+	return budget
+}
+
+func (r *resendStatsRecorder) unregisterRetryBudget(
+	budget *retryBudget,
+) {
+
+	r.Lock()
+	defer r.Unlock()
+
+	delete(r.retryBudgets, budget)
+}
+
+// tryAcquire takes a retry from the budget, it returns false if the budget is exhausted
+func (b *retryBudget) tryAcquire() bool {
+	for {
+		remaining := atomic.LoadInt64(&b.remaining)
+		if remaining <= 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&b.remaining, remaining, remaining-1) {
+			return true
+		}
+	}
+}
+
+// withRetryBudget returns the retry classifier which additionally takes a retry from the budget carried by the context,
+// if any, the retryable errors are not retried once the budget is exhausted
+func (n *NDCHistoryResenderImpl) withRetryBudget(
+	ctx context.Context,
+	isRetryable backoff.IsRetryable,
+) backoff.IsRetryable {
+
+	budget, ok := ctx.Value(retryBudgetKey).(*retryBudget)
+	if !ok {
+		return isRetryable
+	}
+	return func(err error) bool {
+		if !isRetryable(err) {
+			return false
+		}
+		if budget.tryAcquire() {
+			return true
+		}
+		n.stats.recordRetryBudgetExhausted()
+		n.metricsClient.IncCounter(metrics.NDCHistoryResenderScope, metrics.HistoryResendRetryBudgetExhaustedCounter)
+		return false
+	}
+}
+
+// startResendMetrics counts the resend and starts measuring its latency and traffic,
+// the returned function must be called to record them once the resend completes
+func (n *NDCHistoryResenderImpl) startResendMetrics(
+	ctx context.Context,
+	run *resendRun,
+) (context.Context, func()) {
+
+	run.scope.IncCounter(metrics.HistoryResendRequests)
+	sw := run.scope.StartTimer(metrics.HistoryResendLatency)
+	ctx, traffic := withResendTraffic(ctx)
+	return ctx, func() {
+		run.scope.RecordHistogramValue(metrics.HistoryResendBatchCount, float64(run.result.BatchCount))
+		run.result.FetchedBytes = atomic.LoadInt64(&traffic.fetchedBytes)
+		run.result.SentBytes = atomic.LoadInt64(&traffic.sentBytes)
+		run.scope.AddCounter(metrics.HistoryResendFetchedBytes, run.result.FetchedBytes)
+		run.scope.AddCounter(metrics.HistoryResendSentBytes, run.result.SentBytes)
+		sw.Stop()
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"go.uber.org/multierr"
+	"go.uber.org/yarpc"
+
+	"github.com/uber/cadence/.gen/go/admin"
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/backoff"
+	checks "github.com/uber/cadence/common/reconciliation/common"
+	"github.com/uber/cadence/common/service/dynamicconfig"
+)
+
+func (s *nDCHistoryResenderSuite) TestStats() {
+	workflowID := "some random workflow ID"
+	invariantMock := checks.NewMockInvariant(s.controller)
+	s.rereplicator.currentExecutionFixer.check = invariantMock
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob, blob},
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(3)
+	gomock.InOrder(
+		s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(2),
+		s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(&shared.EntityNotExistsError{}).Times(1),
+	)
+	invariantMock.EXPECT().Check(gomock.Any()).Return(checks.CheckResult{
+		CheckResultType: checks.CheckResultTypeHealthy,
+	}).Times(1)
+	s.Equal(ResendStats{}, s.resender.Stats())
+
+	err := s.resender.SendSingleWorkflowHistory(context.Background(), s.domainID, workflowID, uuid.New(), nil, nil, nil, nil)
+	s.NoError(err)
+	err = s.resender.SendSingleWorkflowHistory(context.Background(), s.domainID, workflowID, uuid.New(), nil, nil, nil, nil)
+	s.True(errors.Is(err, ErrSkipTask))
+	// the estimation is not counted
+	_, err = s.resender.EstimateResend(context.Background(), &ResendDescriptor{
+		DomainID:   s.domainID,
+		WorkflowID: workflowID,
+		RunID:      uuid.New(),
+	})
+	s.NoError(err)
+
+	s.Equal(ResendStats{
+		WorkflowCount: 1,
+		BatchCount:    2,
+		TotalBytes:    int64(2 * len(blob.Data)),
+		SkipCount:     1,
+		FetchedBytes:  int64(4 * len(blob.Data)),
+		SentBytes:     int64(3 * len(blob.Data)),
+	}, s.resender.Stats())
+}
+
+func (s *nDCHistoryResenderSuite) TestSendMultiWorkflowHistory_RetryBudget() {
+	workflowID := "some random workflow ID"
+	runCount := 20
+	retryBudget := 5
+	retryPolicy := backoff.NewExponentialRetryPolicy(time.Millisecond)
+	retryPolicy.SetMaximumInterval(time.Millisecond)
+	WithGetHistoryRetryPolicy(retryPolicy)(s.rereplicator)
+	WithResendConcurrency(func(domainID string) int { return runCount })(s.rereplicator)
+	WithMultiResendRetryBudget(func(opts ...dynamicconfig.FilterOption) int { return retryBudget })(s.rereplicator)
+
+	var callCount int64
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *admin.GetWorkflowExecutionRawHistoryV2Request, opts ...yarpc.CallOption) (*admin.GetWorkflowExecutionRawHistoryV2Response, error) {
+			atomic.AddInt64(&callCount, 1)
+			s.True(s.resender.Stats().RetryBudgetRemaining <= int64(retryBudget))
+			return nil, &shared.ServiceBusyError{}
+		}).AnyTimes()
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Times(0)
+
+	var descriptors []*ResendDescriptor
+	for i := 0; i < runCount; i++ {
+		descriptors = append(descriptors, &ResendDescriptor{DomainID: s.domainID, WorkflowID: workflowID, RunID: uuid.New()})
+	}
+	err := s.resender.SendMultiWorkflowHistory(context.Background(), descriptors)
+	s.Error(err)
+	s.Len(multierr.Errors(err), runCount)
+	// each run is fetched once, and the retries of all the runs are capped by the budget in total
+	s.Equal(int64(runCount+retryBudget), atomic.LoadInt64(&callCount))
+	stats := s.resender.Stats()
+	s.Equal(int64(runCount), stats.RetryBudgetExhaustedCount)
+	// the budget is released once the multi-run resend completes
+	s.Equal(int64(0), stats.RetryBudgetRemaining)
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+const (
+	resendSpanName          = "cadence-resend-workflow-history"
+	estimateResendSpanName  = "cadence-estimate-resend-workflow-history"
+	getHistorySpanName      = "cadence-resend-get-history"
+	replicateEventsSpanName = "cadence-resend-replicate-events"
+
+	spanTagDomainID     = "domainID"
+	spanTagWorkflowID   = "workflowID"
+	spanTagRunID        = "runID"
+	spanTagStartEventID = "startEventID"
+	spanTagEndEventID   = "endEventID"
+	spanTagFirstEventID = "firstEventID"
+	spanTagLastEventID  = "lastEventID"
+	spanTagPriority     = "priority"
+	spanTagShardID      = "shardID"
+)
+
+// startSpan starts a span as a child of the span carried by the given context, if any,
+// and returns a context carrying the new span
+func (n *NDCHistoryResenderImpl) startSpan(
+	ctx context.Context,
+	operationName string,
+) (opentracing.Span, context.Context) {
+
+	return opentracing.StartSpanFromContextWithTracer(ctx, n.tracer, operationName)
+}
+
+func finishSpan(
+	span opentracing.Span,
+	err error,
+) {
+
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("error", err.Error())
+	}
+	span.Finish()
+}
+
+// startResendSpan starts the span of the resend of the run, or of the estimation of the resend for the dry run
+func (n *NDCHistoryResenderImpl) startResendSpan(
+	ctx context.Context,
+	descriptor *ResendDescriptor,
+	dryRun bool,
+) (opentracing.Span, context.Context) {
+
+	operationName := resendSpanName
+	if dryRun {
+		operationName = estimateResendSpanName
+	}
+	span, ctx := n.startSpan(ctx, operationName)
+	span.SetTag(spanTagDomainID, descriptor.DomainID)
+	span.SetTag(spanTagWorkflowID, descriptor.WorkflowID)
+	span.SetTag(spanTagRunID, descriptor.RunID)
+	if descriptor.StartEventID != nil {
+		span.SetTag(spanTagStartEventID, *descriptor.StartEventID)
+	}
+	if descriptor.EndEventID != nil {
+		span.SetTag(spanTagEndEventID, *descriptor.EndEventID)
+	}
+	return span, ctx
+}
+
+// tagResendSpan tags the span of the resend with the priority and the shard carried by the context
+func tagResendSpan(
+	ctx context.Context,
+	span opentracing.Span,
+) {
+
+	span.SetTag(spanTagPriority, GetResendPriority(ctx).String())
+	if shardID, ok := GetResendShardID(ctx); ok {
+		span.SetTag(spanTagShardID, shardID)
+	}
+}
+
+// startReplicateEventsSpan starts the span of sending the batch of the events to the target cluster
+func (n *NDCHistoryResenderImpl) startReplicateEventsSpan(
+	ctx context.Context,
+	descriptor *ResendDescriptor,
+	firstEventID int64,
+	lastEventID int64,
+) (opentracing.Span, context.Context) {
+
+	span, ctx := n.startSpan(ctx, replicateEventsSpanName)
+	span.SetTag(spanTagDomainID, descriptor.DomainID)
+	span.SetTag(spanTagWorkflowID, descriptor.WorkflowID)
+	span.SetTag(spanTagRunID, descriptor.RunID)
+	span.SetTag(spanTagFirstEventID, firstEventID)
+	span.SetTag(spanTagLastEventID, lastEventID)
+	return span, ctx
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/pborman/uuid"
+
+	"github.com/uber/cadence/.gen/go/admin"
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+)
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_Tracing() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+		{
+			EventId:   common.Int64Ptr(3),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskStarted.Ptr(),
+		},
+	})
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(3),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(1)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	tracer := mocktracer.New()
+	WithTracer(tracer)(s.rereplicator)
+	parentSpan := tracer.StartSpan("parent")
+	ctx := opentracing.ContextWithSpan(context.Background(), parentSpan)
+
+	err := s.resender.SendSingleWorkflowHistory(
+		ctx,
+		s.domainID,
+		workflowID,
+		runID,
+		common.Int64Ptr(1),
+		common.Int64Ptr(123),
+		nil,
+		nil,
+	)
+	s.Nil(err)
+	parentSpan.Finish()
+
+	spans := make(map[string]*mocktracer.MockSpan)
+	for _, span := range tracer.FinishedSpans() {
+		spans[span.OperationName] = span
+	}
+	s.Len(spans, 4)
+	parent := spans["parent"]
+	resendSpan := spans[resendSpanName]
+	getHistorySpan := spans[getHistorySpanName]
+	replicateEventsSpan := spans[replicateEventsSpanName]
+	s.NotNil(parent)
+	s.NotNil(resendSpan)
+	s.NotNil(getHistorySpan)
+	s.NotNil(replicateEventsSpan)
+	s.Equal(parent.SpanContext.SpanID, resendSpan.ParentID)
+	s.Equal(resendSpan.SpanContext.SpanID, getHistorySpan.ParentID)
+	s.Equal(resendSpan.SpanContext.SpanID, replicateEventsSpan.ParentID)
+	s.Equal(s.domainID, resendSpan.Tag(spanTagDomainID))
+	s.Equal(workflowID, resendSpan.Tag(spanTagWorkflowID))
+	s.Equal(runID, resendSpan.Tag(spanTagRunID))
+	s.Equal(int64(1), resendSpan.Tag(spanTagStartEventID))
+	s.Equal(int64(2), replicateEventsSpan.Tag(spanTagFirstEventID))
+	s.Equal(int64(3), replicateEventsSpan.Tag(spanTagLastEventID))
+	s.Nil(resendSpan.Tag("error"))
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/cache"
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/metrics"
+	"github.com/uber/cadence/common/service/dynamicconfig"
+)
+
+type (
+	// skippedRunCache remembers the runs skipped for a terminal reason, so the resends of them fail with SkipTaskError
+	// without reaching the source cluster. the size and the TTL are read on each use, and the runs remembered are
+	// dropped once either of them is changed
+	skippedRunCache struct {
+		maxCount dynamicconfig.IntPropertyFn
+		ttl      dynamicconfig.DurationPropertyFn
+
+		sync.Mutex
+		runsMaxCount int
+		runsTTL      time.Duration
+		runs         cache.Cache
+	}
+)
+
+func newSkippedRunCache(
+	maxCount dynamicconfig.IntPropertyFn,
+	ttl dynamicconfig.DurationPropertyFn,
+) *skippedRunCache {
+
+	return &skippedRunCache{
+		maxCount: maxCount,
+		ttl:      ttl,
+	}
+}
+
+// get returns the error skipping the run if the run is skipped recently
+func (c *skippedRunCache) get(
+	key string,
+) *SkipTaskError {
+
+	runs := c.getRuns()
+	if runs == nil {
+		return nil
+	}
+	skipTaskErr, _ := runs.Get(key).(*SkipTaskError)
+	return skipTaskErr
+}
+
+// put remembers the skipped run if the reason is terminal, i.e. the run will be skipped again if resent
+func (c *skippedRunCache) put(
+	key string,
+	skipTaskErr *SkipTaskError,
+) {
+
+	if !skipTaskErr.Reason.isTerminal() {
+		return
+	}
+	if runs := c.getRuns(); runs != nil {
+		runs.Put(key, skipTaskErr)
+	}
+}
+
+// remove forgets the skipped run, e.g. once its current execution is fixed
+func (c *skippedRunCache) remove(
+	key string,
+) {
+
+	if runs := c.getRuns(); runs != nil {
+		runs.Delete(key)
+	}
+}
+
+// getRuns returns the runs remembered, or nil if the skipped runs are not remembered
+func (c *skippedRunCache) getRuns() cache.Cache {
+	if c.maxCount == nil || c.ttl == nil {
+		return nil
+	}
+	maxCount := c.maxCount()
+	ttl := c.ttl()
+
+	c.Lock()
+	defer c.Unlock()
+
+	if maxCount <= 0 || ttl <= 0 {
+		c.runs = nil
+		return nil
+	}
+	if c.runs == nil || c.runsMaxCount != maxCount || c.runsTTL != ttl {
+		c.runs = cache.New(&cache.Options{
+			TTL:      ttl,
+			MaxCount: maxCount,
+		})
+		c.runsMaxCount = maxCount
+		c.runsTTL = ttl
+	}
+	return c.runs
+}
+
+// getSkippedRunResult returns the result of the resend of the run skipped recently along with the error skipping it,
+// nil error is returned if the run is not skipped
+func (n *NDCHistoryResenderImpl) getSkippedRunResult(
+	descriptor *ResendDescriptor,
+) (*ResendResult, error) {
+
+	skipTaskErr := n.skippedRuns.get(getRunKey(descriptor.DomainID, descriptor.WorkflowID, descriptor.RunID))
+	if skipTaskErr == nil {
+		return nil, nil
+	}
+	n.metricsClient.IncCounter(metrics.NDCHistoryResenderScope, metrics.HistoryResendSkippedRunCacheHitCounter)
+	return &ResendResult{
+		FirstEventID: common.EmptyEventID,
+		LastEventID:  common.EmptyEventID,
+		Skipped:      true,
+		domainID:     descriptor.DomainID,
+		workflowID:   descriptor.WorkflowID,
+		runID:        descriptor.RunID,
+	}, skipTaskErr
+}
+
+// handleRunNotExistsInTarget checks the current execution in the target cluster once the target cannot apply
+// the events since the run does not exist in the target, the run is skipped and remembered if either
+// Case 1: the workflow pass the retention period
+// Case 2: the workflow is corrupted
+func (n *NDCHistoryResenderImpl) handleRunNotExistsInTarget(
+	ctx context.Context,
+	run *resendRun,
+	sendErr error,
+) error {
+
+	descriptor := run.descriptor
+	run.scope.IncCounter(metrics.HistoryResendEntityNotExistsCounter)
+	getErrorLogFn(ctx, run.logger, sendErr, run.logger.Warn)("workflow does not exist when replicating events",
+		tag.Error(sendErr))
+	reason, skipTask, fixErr := n.currentExecutionFixer.checkAndFix(
+		run.scope,
+		run.logger,
+		descriptor.DomainID,
+		run.targetDomainID,
+		descriptor.WorkflowID,
+		descriptor.RunID,
+	)
+	if fixErr != nil {
+		return fixErr
+	}
+	if !skipTask {
+		return sendErr
+	}
+	run.scope.IncCounter(metrics.HistoryResendSkipTaskCounter)
+	run.result.Skipped = true
+	skipTaskErr := &SkipTaskError{
+		Reason:     reason,
+		DomainID:   descriptor.DomainID,
+		WorkflowID: descriptor.WorkflowID,
+		RunID:      descriptor.RunID,
+	}
+	n.skippedRuns.put(getRunKey(descriptor.DomainID, descriptor.WorkflowID, descriptor.RunID), skipTaskErr)
+	return skipTaskErr
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package xdc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+
+	"github.com/uber/cadence/.gen/go/admin"
+	"github.com/uber/cadence/.gen/go/history"
+	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/persistence"
+	checks "github.com/uber/cadence/common/reconciliation/common"
+	"github.com/uber/cadence/common/service/dynamicconfig"
+)
+
+func (s *nDCHistoryResenderSuite) TestSendSingleWorkflowHistory_SkippedRunCache() {
+	workflowID := "some random workflow ID"
+	runID := uuid.New()
+	invariantMock := checks.NewMockInvariant(s.controller)
+	rereplicator, err := NewNDCHistoryResender(
+		s.mockDomainCache,
+		s.mockAdminClient,
+		func(ctx context.Context, request *history.ReplicateEventsV2Request) error {
+			return s.mockHistoryClient.ReplicateEventsV2(ctx, request)
+		},
+		persistence.NewPayloadSerializer(),
+		nil,
+		invariantMock,
+		s.metricsClient,
+		s.logger,
+		WithSkippedRunCache(dynamicconfig.GetIntPropertyFn(10), dynamicconfig.GetDurationPropertyFn(time.Minute)),
+	)
+	s.NoError(err)
+	s.useRereplicator(rereplicator)
+	blob := s.serializeEvents([]*shared.HistoryEvent{
+		{
+			EventId:   common.Int64Ptr(2),
+			Version:   common.Int64Ptr(123),
+			Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+			EventType: shared.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	})
+	s.mockAdminClient.EXPECT().GetWorkflowExecutionRawHistoryV2(gomock.Any(), gomock.Any()).
+		Return(&admin.GetWorkflowExecutionRawHistoryV2Response{
+			HistoryBatches: []*shared.DataBlob{blob},
+			VersionHistory: &shared.VersionHistory{
+				Items: []*shared.VersionHistoryItem{
+					{
+						EventID: common.Int64Ptr(2),
+						Version: common.Int64Ptr(123),
+					},
+				},
+			},
+		}, nil).Times(2)
+	s.mockHistoryClient.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).
+		Return(&shared.EntityNotExistsError{}).Times(2)
+	invariantMock.EXPECT().Check(gomock.Any()).Return(checks.CheckResult{
+		CheckResultType: checks.CheckResultTypeHealthy,
+	}).Times(2)
+	sendHistory := func(runID string) error {
+		return s.resender.SendSingleWorkflowHistory(
+			context.Background(),
+			s.domainID,
+			workflowID,
+			runID,
+			nil,
+			nil,
+			nil,
+			nil,
+		)
+	}
+
+	s.True(errors.Is(sendHistory(runID), ErrSkipTask))
+	// the skipped run is remembered, so the source cluster is not reached again
+	err = sendHistory(runID)
+	s.True(errors.Is(err, ErrSkipTask))
+	s.Equal(SkipTaskReasonRetentionExpired, GetSkipTaskReason(err))
+
+	s.True(errors.Is(sendHistory(uuid.New()), ErrSkipTask))
+}
+
+func (s *nDCHistoryResenderSuite) TestSkippedRunCache() {
+	maxCount := 10
+	skippedRuns := newSkippedRunCache(
+		func(opts ...dynamicconfig.FilterOption) int { return maxCount },
+		dynamicconfig.GetDurationPropertyFn(time.Minute),
+	)
+	newSkipTaskErr := func(reason SkipTaskReason) *SkipTaskError {
+		return &SkipTaskError{
+			Reason:     reason,
+			DomainID:   s.domainID,
+			WorkflowID: "some random workflow ID",
+			RunID:      uuid.New(),
+		}
+	}
+
+	// only the runs skipped again if resent are remembered
+	skippedRuns.put("up to date", newSkipTaskErr(SkipTaskReasonUpToDate))
+	s.Nil(skippedRuns.get("up to date"))
+	skippedRuns.put("corrupted", newSkipTaskErr(SkipTaskReasonCorrupted))
+	s.Nil(skippedRuns.get("corrupted"))
+	retentionExpired := newSkipTaskErr(SkipTaskReasonRetentionExpired)
+	skippedRuns.put("retention expired", retentionExpired)
+	s.Equal(retentionExpired, skippedRuns.get("retention expired"))
+
+	skippedRuns.remove("retention expired")
+	s.Nil(skippedRuns.get("retention expired"))
+
+	// the runs remembered are dropped once the size is changed, and not remembered once disabled
+	skippedRuns.put("retention expired", retentionExpired)
+	maxCount = 20
+	s.Nil(skippedRuns.get("retention expired"))
+	maxCount = 0
+	skippedRuns.put("retention expired", retentionExpired)
+	s.Nil(skippedRuns.get("retention expired"))
+}
@@ -26,6 +26,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/olivere/elastic"
@@ -73,6 +74,10 @@ type (
 		domainDLQHandler      domain.DLQMessageHandler
 		domainFailoverWatcher domain.FailoverWatcher
 		eventSerializder      persistence.PayloadSerializer
+
+		// resenders are the NDC history resenders of the remote clusters, created on the first resend from the cluster
+		resendersLock sync.Mutex
+		resenders     map[string]xdc.NDCHistoryResender
 	}
 
 	getWorkflowRawHistoryV2Token struct {
@@ -124,6 +129,7 @@ func NewAdminHandler(
 			resource.GetLogger(),
 		),
 		eventSerializder: persistence.NewPayloadSerializer(),
+		resenders:        make(map[string]xdc.NDCHistoryResender),
 	}
 }
 
@@ -146,6 +152,13 @@ func (adh *AdminHandler) Stop() {
 	// Calling stop if the queue does not start is ok
 	adh.Resource.GetDomainReplicationQueue().Stop()
 	adh.domainFailoverWatcher.Stop()
+
+	adh.resendersLock.Lock()
+	defer adh.resendersLock.Unlock()
+	for remoteCluster, resender := range adh.resenders {
+		resender.Close()
+		delete(adh.resenders, remoteCluster)
+	}
 }
 
 // AddSearchAttribute add search attribute to whitelist
@@ -1066,18 +1079,12 @@ func (adh *AdminHandler) ResendReplicationTasks(
 	if request == nil {
 		return adh.error(errRequestNotSet, scope)
 	}
-	resender := xdc.NewNDCHistoryResender(
-		adh.GetDomainCache(),
-		adh.GetRemoteAdminClient(request.GetRemoteCluster()),
-		func(ctx context.Context, request *h.ReplicateEventsV2Request) error {
-			return adh.GetHistoryClient().ReplicateEventsV2(ctx, request)
-		},
-		adh.eventSerializder,
-		nil,
-		nil,
-		adh.GetLogger(),
-	)
+	resender, err := adh.getNDCHistoryResender(request.GetRemoteCluster())
+	if err != nil {
+		return adh.error(err, scope)
+	}
 	return resender.SendSingleWorkflowHistory(
+		ctx,
 		request.GetDomainID(),
 		request.GetWorkflowID(),
 		request.GetRunID(),
@@ -1088,6 +1095,39 @@ func (adh *AdminHandler) ResendReplicationTasks(
 	)
 }
 
+// getNDCHistoryResender returns the NDC history resender of the remote cluster, which is created once
+// and shared by the resends from the cluster until the handler is stopped
+func (adh *AdminHandler) getNDCHistoryResender(
+	remoteCluster string,
+) (xdc.NDCHistoryResender, error) {
+
+	adh.resendersLock.Lock()
+	defer adh.resendersLock.Unlock()
+
+	if resender, ok := adh.resenders[remoteCluster]; ok {
+		return resender, nil
+	}
+	resender, err := xdc.NewNDCHistoryResender(
+		adh.GetDomainCache(),
+		adh.GetRemoteAdminClient(remoteCluster),
+		func(ctx context.Context, request *h.ReplicateEventsV2Request) error {
+			// the resender retries the service busy error by itself, so the raw client is used
+			return adh.GetHistoryRawClient().ReplicateEventsV2(ctx, request)
+		},
+		adh.eventSerializder,
+		nil,
+		nil,
+		adh.GetMetricsClient(),
+		adh.GetLogger(),
+		xdc.WithSourceCluster(remoteCluster),
+	)
+	if err != nil {
+		return nil, err
+	}
+	adh.resenders[remoteCluster] = resender
+	return resender, nil
+}
+
 func (adh *AdminHandler) validateGetWorkflowExecutionRawHistoryV2Request(
 	request *admin.GetWorkflowExecutionRawHistoryV2Request,
 ) error {
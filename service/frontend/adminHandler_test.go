@@ -582,3 +582,20 @@ func (s *adminHandlerSuite) Test_AddSearchAttribute_Permission() {
 		s.Equal(testCase.Expected, handler.AddSearchAttribute(ctx, testCase.Request))
 	}
 }
+
+func (s *adminHandlerSuite) Test_GetNDCHistoryResender_OncePerRemoteCluster() {
+	resender, err := s.handler.getNDCHistoryResender("cluster-a")
+	s.NoError(err)
+
+	sameResender, err := s.handler.getNDCHistoryResender("cluster-a")
+	s.NoError(err)
+	s.True(resender == sameResender)
+
+	otherResender, err := s.handler.getNDCHistoryResender("cluster-b")
+	s.NoError(err)
+	s.False(resender == otherResender)
+	s.Len(s.handler.resenders, 2)
+
+	s.handler.Stop()
+	s.Empty(s.handler.resenders)
+}
@@ -90,6 +90,45 @@ type Config struct {
 	StandbyTaskRedispatchInterval           dynamicconfig.DurationPropertyFn
 	TaskRedispatchIntervalJitterCoefficient dynamicconfig.FloatPropertyFn
 	StandbyTaskReReplicationContextTimeout  dynamicconfig.DurationPropertyFnWithDomainIDFilter
+	ReReplicationPageSize                   dynamicconfig.IntPropertyFnWithDomainIDFilter
+	ReReplicationGetHistoryRPS              dynamicconfig.IntPropertyFnWithDomainIDFilter
+	ReReplicationStrictValidation           dynamicconfig.BoolPropertyFnWithDomainIDFilter
+	ReReplicationTimeoutJitterCoefficient   dynamicconfig.FloatPropertyFnWithDomainIDFilter
+	ReReplicationMaxBytes                   dynamicconfig.IntPropertyFnWithDomainIDFilter
+	ReReplicationGetHistoryTimeout          dynamicconfig.DurationPropertyFnWithDomainIDFilter
+	ReReplicationReplicateEventsTimeout     dynamicconfig.DurationPropertyFnWithDomainIDFilter
+	ReReplicationPageBufferSize             dynamicconfig.IntPropertyFnWithDomainIDFilter
+	ReReplicationFetchConcurrency           dynamicconfig.IntPropertyFnWithDomainIDFilter
+	ReReplicationMaxDomainConcurrency       dynamicconfig.IntPropertyFnWithDomainIDFilter
+	ReReplicationConcurrencyWaitTimeout     dynamicconfig.DurationPropertyFnWithDomainIDFilter
+	ReReplicationValidateEventBatch         dynamicconfig.BoolPropertyFnWithDomainIDFilter
+	ReReplicationSkipInvalidEventBatch      dynamicconfig.BoolPropertyFnWithDomainIDFilter
+	ReReplicationSkipEmptyVersionHistory    dynamicconfig.BoolPropertyFnWithDomainIDFilter
+	ReReplicationCircuitBreakerThreshold    dynamicconfig.IntPropertyFnWithDomainIDFilter
+	ReReplicationCircuitBreakerCooldown     dynamicconfig.DurationPropertyFnWithDomainIDFilter
+	ReReplicationLargeBatchThreshold        dynamicconfig.IntPropertyFnWithDomainIDFilter
+	ReReplicationMaxBatchSize               dynamicconfig.IntPropertyFnWithDomainIDFilter
+	ReReplicationAsyncFixWorkerCount        dynamicconfig.IntPropertyFn
+	ReReplicationAsyncFixQueueSize          dynamicconfig.IntPropertyFn
+	ReReplicationArchivalFallback           dynamicconfig.BoolPropertyFnWithDomainIDFilter
+	ReReplicationSkippedRunCacheSize        dynamicconfig.IntPropertyFn
+	ReReplicationSkippedRunCacheTTL         dynamicconfig.DurationPropertyFn
+	ReReplicationEventBlobEncoding          dynamicconfig.StringPropertyFnWithDomainFilter
+	ReReplicationBatchDelay                 dynamicconfig.DurationPropertyFnWithDomainIDFilter
+	ReReplicationTrimBatchToEndEvent        dynamicconfig.BoolPropertyFnWithDomainIDFilter
+	ReReplicationPriority                   dynamicconfig.StringPropertyFnWithDomainFilter
+	ReReplicationMaxTimeoutOverride         dynamicconfig.DurationPropertyFnWithDomainIDFilter
+	ReReplicationFailOnHistoryGap           dynamicconfig.BoolPropertyFnWithDomainIDFilter
+	ReReplicationDisableCurrentExecutionFix dynamicconfig.BoolPropertyFnWithDomainIDFilter
+	ReReplicationMaxPages                   dynamicconfig.IntPropertyFnWithDomainIDFilter
+	ReReplicationMultiResendRetryBudget     dynamicconfig.IntPropertyFn
+	ReReplicationQuietExpectedErrors        dynamicconfig.BoolPropertyFnWithDomainIDFilter
+	ReReplicationTargetPageBytes            dynamicconfig.IntPropertyFnWithDomainIDFilter
+	ReReplicationMinAdaptivePageSize        dynamicconfig.IntPropertyFnWithDomainIDFilter
+	ReReplicationMaxAdaptivePageSize        dynamicconfig.IntPropertyFnWithDomainIDFilter
+	ReReplicationSplitBatchSize             dynamicconfig.IntPropertyFnWithDomainIDFilter
+	ReReplicationGlobalBytesPerSecond       dynamicconfig.IntPropertyFn
+	ReReplicationGlobalOpsPerSecond         dynamicconfig.IntPropertyFn
 	EnableDropStuckTaskByDomainID           dynamicconfig.BoolPropertyFnWithDomainIDFilter
 
 	// QueueProcessor settings
@@ -329,6 +368,45 @@ func New(dc *dynamicconfig.Collection, numberOfShards int, storeType string, isA
 		StandbyTaskRedispatchInterval:           dc.GetDurationProperty(dynamicconfig.StandbyTaskRedispatchInterval, 30*time.Second),
 		TaskRedispatchIntervalJitterCoefficient: dc.GetFloat64Property(dynamicconfig.TimerProcessorSplitQueueIntervalJitterCoefficient, 0.15),
 		StandbyTaskReReplicationContextTimeout:  dc.GetDurationPropertyFilteredByDomainID(dynamicconfig.StandbyTaskReReplicationContextTimeout, 3*time.Minute),
+		ReReplicationPageSize:                   dc.GetIntPropertyFilteredByDomainID(dynamicconfig.ReReplicationPageSize, 100),
+		ReReplicationGetHistoryRPS:              dc.GetIntPropertyFilteredByDomainID(dynamicconfig.ReReplicationGetHistoryRPS, 0),
+		ReReplicationStrictValidation:           dc.GetBoolPropertyFilteredByDomainID(dynamicconfig.ReReplicationStrictValidation, false),
+		ReReplicationTimeoutJitterCoefficient:   dc.GetFloat64PropertyFilteredByDomainID(dynamicconfig.ReReplicationTimeoutJitterCoefficient, 0),
+		ReReplicationMaxBytes:                   dc.GetIntPropertyFilteredByDomainID(dynamicconfig.ReReplicationMaxBytes, 0),
+		ReReplicationGetHistoryTimeout:          dc.GetDurationPropertyFilteredByDomainID(dynamicconfig.ReReplicationGetHistoryTimeout, 30*time.Second),
+		ReReplicationReplicateEventsTimeout:     dc.GetDurationPropertyFilteredByDomainID(dynamicconfig.ReReplicationReplicateEventsTimeout, 30*time.Second),
+		ReReplicationPageBufferSize:             dc.GetIntPropertyFilteredByDomainID(dynamicconfig.ReReplicationPageBufferSize, 1),
+		ReReplicationFetchConcurrency:           dc.GetIntPropertyFilteredByDomainID(dynamicconfig.ReReplicationFetchConcurrency, 1),
+		ReReplicationMaxDomainConcurrency:       dc.GetIntPropertyFilteredByDomainID(dynamicconfig.ReReplicationMaxDomainConcurrency, 0),
+		ReReplicationConcurrencyWaitTimeout:     dc.GetDurationPropertyFilteredByDomainID(dynamicconfig.ReReplicationConcurrencyWaitTimeout, 5*time.Second),
+		ReReplicationValidateEventBatch:         dc.GetBoolPropertyFilteredByDomainID(dynamicconfig.ReReplicationValidateEventBatch, false),
+		ReReplicationSkipInvalidEventBatch:      dc.GetBoolPropertyFilteredByDomainID(dynamicconfig.ReReplicationSkipInvalidEventBatch, false),
+		ReReplicationSkipEmptyVersionHistory:    dc.GetBoolPropertyFilteredByDomainID(dynamicconfig.ReReplicationSkipEmptyVersionHistory, false),
+		ReReplicationCircuitBreakerThreshold:    dc.GetIntPropertyFilteredByDomainID(dynamicconfig.ReReplicationCircuitBreakerThreshold, 0),
+		ReReplicationCircuitBreakerCooldown:     dc.GetDurationPropertyFilteredByDomainID(dynamicconfig.ReReplicationCircuitBreakerCooldown, 30*time.Second),
+		ReReplicationLargeBatchThreshold:        dc.GetIntPropertyFilteredByDomainID(dynamicconfig.ReReplicationLargeBatchThreshold, 0),
+		ReReplicationMaxBatchSize:               dc.GetIntPropertyFilteredByDomainID(dynamicconfig.ReReplicationMaxBatchSize, 0),
+		ReReplicationAsyncFixWorkerCount:        dc.GetIntProperty(dynamicconfig.ReReplicationAsyncFixWorkerCount, 0),
+		ReReplicationAsyncFixQueueSize:          dc.GetIntProperty(dynamicconfig.ReReplicationAsyncFixQueueSize, 1000),
+		ReReplicationArchivalFallback:           dc.GetBoolPropertyFilteredByDomainID(dynamicconfig.ReReplicationArchivalFallback, false),
+		ReReplicationSkippedRunCacheSize:        dc.GetIntProperty(dynamicconfig.ReReplicationSkippedRunCacheSize, 0),
+		ReReplicationSkippedRunCacheTTL:         dc.GetDurationProperty(dynamicconfig.ReReplicationSkippedRunCacheTTL, time.Minute),
+		ReReplicationEventBlobEncoding:          dc.GetStringPropertyFilteredByDomain(dynamicconfig.ReReplicationEventBlobEncoding, ""),
+		ReReplicationBatchDelay:                 dc.GetDurationPropertyFilteredByDomainID(dynamicconfig.ReReplicationBatchDelay, 0),
+		ReReplicationTrimBatchToEndEvent:        dc.GetBoolPropertyFilteredByDomainID(dynamicconfig.ReReplicationTrimBatchToEndEvent, false),
+		ReReplicationPriority:                   dc.GetStringPropertyFilteredByDomain(dynamicconfig.ReReplicationPriority, "normal"),
+		ReReplicationMaxTimeoutOverride:         dc.GetDurationPropertyFilteredByDomainID(dynamicconfig.ReReplicationMaxTimeoutOverride, 30*time.Minute),
+		ReReplicationFailOnHistoryGap:           dc.GetBoolPropertyFilteredByDomainID(dynamicconfig.ReReplicationFailOnHistoryGap, false),
+		ReReplicationDisableCurrentExecutionFix: dc.GetBoolPropertyFilteredByDomainID(dynamicconfig.ReReplicationDisableCurrentExecutionFix, false),
+		ReReplicationMaxPages:                   dc.GetIntPropertyFilteredByDomainID(dynamicconfig.ReReplicationMaxPages, 0),
+		ReReplicationMultiResendRetryBudget:     dc.GetIntProperty(dynamicconfig.ReReplicationMultiResendRetryBudget, 0),
+		ReReplicationQuietExpectedErrors:        dc.GetBoolPropertyFilteredByDomainID(dynamicconfig.ReReplicationQuietExpectedErrors, false),
+		ReReplicationTargetPageBytes:            dc.GetIntPropertyFilteredByDomainID(dynamicconfig.ReReplicationTargetPageBytes, 0),
+		ReReplicationMinAdaptivePageSize:        dc.GetIntPropertyFilteredByDomainID(dynamicconfig.ReReplicationMinAdaptivePageSize, 1),
+		ReReplicationMaxAdaptivePageSize:        dc.GetIntPropertyFilteredByDomainID(dynamicconfig.ReReplicationMaxAdaptivePageSize, 1000),
+		ReReplicationSplitBatchSize:             dc.GetIntPropertyFilteredByDomainID(dynamicconfig.ReReplicationSplitBatchSize, 0),
+		ReReplicationGlobalBytesPerSecond:       dc.GetIntProperty(dynamicconfig.ReReplicationGlobalBytesPerSecond, 0),
+		ReReplicationGlobalOpsPerSecond:         dc.GetIntProperty(dynamicconfig.ReReplicationGlobalOpsPerSecond, 0),
 		EnableDropStuckTaskByDomainID:           dc.GetBoolPropertyFilteredByDomainID(dynamicconfig.EnableDropStuckTaskByDomainID, false),
 
 		QueueProcessorEnableSplit:                          dc.GetBoolProperty(dynamicconfig.QueueProcessorEnableSplit, false),
@@ -64,6 +64,7 @@ import (
 	"github.com/uber/cadence/service/history/query"
 	"github.com/uber/cadence/service/history/queue"
 	"github.com/uber/cadence/service/history/replication"
+	"github.com/uber/cadence/service/history/resender"
 	"github.com/uber/cadence/service/history/reset"
 	"github.com/uber/cadence/service/history/shard"
 	"github.com/uber/cadence/service/history/task"
@@ -112,6 +113,7 @@ type (
 		workflowResetter          reset.WorkflowResetter
 		queueTaskProcessor        task.Processor
 		replicationTaskProcessors []replication.TaskProcessor
+		nDCHistoryResenders       []xdc.NDCHistoryResender
 		publicClient              workflowserviceclient.Interface
 		eventsReapplier           ndc.EventsReapplier
 		matchingClient            matching.Client
@@ -310,11 +312,12 @@ func NewEngineWithShardContext(
 	}
 
 	var replicationTaskProcessors []replication.TaskProcessor
+	var nDCHistoryResenders []xdc.NDCHistoryResender
 	replicationTaskExecutors := make(map[string]replication.TaskExecutor)
 	for _, replicationTaskFetcher := range replicationTaskFetchers.GetFetchers() {
 		sourceCluster := replicationTaskFetcher.GetSourceCluster()
 		// Intentionally use the raw client to create its own retry policy
-		adminClient := shard.GetService().GetClientBean().GetRemoteAdminClient(sourceCluster)
+		adminClient := resender.NewAdminClient(shard.GetService(), sourceCluster)
 		adminRetryableClient := admin.NewRetryableClient(
 			adminClient,
 			common.CreateReplicationServiceBusyRetryPolicy(),
@@ -327,17 +330,23 @@ func NewEngineWithShardContext(
 			common.CreateReplicationServiceBusyRetryPolicy(),
 			common.IsServiceBusyError,
 		)
-		nDCHistoryResender := xdc.NewNDCHistoryResender(
+		// the NDC history resender retries the calls by its own retry policies, so the raw clients are used
+		nDCHistoryResender, err := xdc.NewNDCHistoryResender(
 			shard.GetDomainCache(),
-			adminRetryableClient,
+			adminClient,
 			func(ctx context.Context, request *h.ReplicateEventsV2Request) error {
-				return historyRetryableClient.ReplicateEventsV2(ctx, request)
+				return historyClient.ReplicateEventsV2(ctx, request)
 			},
 			shard.GetService().GetPayloadSerializer(),
 			nil,
 			openExecutionCheck,
+			shard.GetMetricsClient(),
 			shard.GetLogger(),
+			resender.NewOptions(config, sourceCluster, shard.GetService())...,
 		)
+		if err != nil {
+			shard.GetLogger().Fatal("Creating NDC history resender failed", tag.Error(err))
+		}
 		historyRereplicator := xdc.NewHistoryRereplicator(
 			currentClusterName,
 			shard.GetDomainCache(),
@@ -371,8 +380,10 @@ func NewEngineWithShardContext(
 			replicationTaskExecutor,
 		)
 		replicationTaskProcessors = append(replicationTaskProcessors, replicationTaskProcessor)
+		nDCHistoryResenders = append(nDCHistoryResenders, nDCHistoryResender)
 	}
 	historyEngImpl.replicationTaskProcessors = replicationTaskProcessors
+	historyEngImpl.nDCHistoryResenders = nDCHistoryResenders
 	replicationMessageHandler := replication.NewDLQHandler(shard, replicationTaskExecutors)
 	historyEngImpl.replicationDLQHandler = replicationMessageHandler
 
@@ -424,6 +435,10 @@ func (e *historyEngineImpl) Stop() {
 	for _, replicationTaskProcessor := range e.replicationTaskProcessors {
 		replicationTaskProcessor.Stop()
 	}
+	// the resenders are closed after the processors using them are stopped
+	for _, nDCHistoryResender := range e.nDCHistoryResenders {
+		nDCHistoryResender.Close()
+	}
 
 	if e.queueTaskProcessor != nil {
 		e.queueTaskProcessor.StopShardProcessor(e.shard)
@@ -372,8 +372,9 @@ func deserializeBlob(
 		return nil, nil
 	}
 
-	return historySerializer.DeserializeBatchEvents(&persistence.DataBlob{
-		Encoding: common.EncodingTypeThriftRW,
-		Data:     blob.Data,
-	})
+	dataBlob, err := persistence.NewDataBlobFromThriftWithError(blob)
+	if err != nil {
+		return nil, err
+	}
+	return historySerializer.DeserializeBatchEvents(dataBlob)
 }
@@ -40,6 +40,7 @@ import (
 	"github.com/uber/cadence/service/history/config"
 	"github.com/uber/cadence/service/history/engine"
 	"github.com/uber/cadence/service/history/execution"
+	"github.com/uber/cadence/service/history/resender"
 	"github.com/uber/cadence/service/history/shard"
 	"github.com/uber/cadence/service/history/task"
 	"github.com/uber/cadence/service/worker/archiver"
@@ -68,6 +69,7 @@ type (
 		activeQueueProcessor   *timerQueueProcessorBase
 		standbyQueueProcessors map[string]*timerQueueProcessorBase
 		standbyQueueTimerGates map[string]RemoteTimerGate
+		nDCHistoryResenders    map[string]xdc.NDCHistoryResender
 	}
 )
 
@@ -106,6 +108,7 @@ func NewTimerQueueProcessor(
 
 	standbyQueueProcessors := make(map[string]*timerQueueProcessorBase)
 	standbyQueueTimerGates := make(map[string]RemoteTimerGate)
+	nDCHistoryResenders := make(map[string]xdc.NDCHistoryResender)
 	rereplicatorLogger := shard.GetLogger().WithTags(tag.ComponentHistoryResender)
 	resenderLogger := shard.GetLogger().WithTags(tag.ComponentHistoryResender)
 
@@ -126,17 +129,23 @@ func NewTimerQueueProcessor(
 			config.StandbyTaskReReplicationContextTimeout,
 			rereplicatorLogger,
 		)
-		nDCHistoryResender := xdc.NewNDCHistoryResender(
+		nDCHistoryResender, err := xdc.NewNDCHistoryResender(
 			shard.GetDomainCache(),
-			shard.GetService().GetClientBean().GetRemoteAdminClient(clusterName),
+			resender.NewAdminClient(shard.GetService(), clusterName),
 			func(ctx context.Context, request *h.ReplicateEventsV2Request) error {
 				return historyEngine.ReplicateEventsV2(ctx, request)
 			},
 			shard.GetService().GetPayloadSerializer(),
 			config.StandbyTaskReReplicationContextTimeout,
 			executionCheck,
+			shard.GetMetricsClient(),
 			resenderLogger,
+			resender.NewOptions(config, clusterName, shard.GetService())...,
 		)
+		if err != nil {
+			resenderLogger.Fatal("Creating NDC history resender failed", tag.Error(err))
+		}
+		nDCHistoryResenders[clusterName] = nDCHistoryResender
 		standbyTaskExecutor := task.NewTimerStandbyTaskExecutor(
 			shard,
 			archivalClient,
@@ -180,6 +189,7 @@ func NewTimerQueueProcessor(
 		activeQueueProcessor:   activeQueueProcessor,
 		standbyQueueProcessors: standbyQueueProcessors,
 		standbyQueueTimerGates: standbyQueueTimerGates,
+		nDCHistoryResenders:    nDCHistoryResenders,
 	}
 }
 
@@ -210,6 +220,10 @@ func (t *timerQueueProcessor) Stop() {
 			standbyQueueProcessor.Stop()
 		}
 	}
+	// the resenders are closed after the processors using them are stopped
+	for _, nDCHistoryResender := range t.nDCHistoryResenders {
+		nDCHistoryResender.Close()
+	}
 
 	close(t.shutdownChan)
 	common.AwaitWaitGroup(&t.shutdownWG, time.Minute)
@@ -42,6 +42,7 @@ import (
 	"github.com/uber/cadence/service/history/config"
 	"github.com/uber/cadence/service/history/engine"
 	"github.com/uber/cadence/service/history/execution"
+	"github.com/uber/cadence/service/history/resender"
 	"github.com/uber/cadence/service/history/reset"
 	"github.com/uber/cadence/service/history/shard"
 	"github.com/uber/cadence/service/history/task"
@@ -84,6 +85,7 @@ type (
 		activeTaskExecutor     task.Executor
 		activeQueueProcessor   *transferQueueProcessorBase
 		standbyQueueProcessors map[string]*transferQueueProcessorBase
+		nDCHistoryResenders    map[string]xdc.NDCHistoryResender
 	}
 )
 
@@ -124,6 +126,7 @@ func NewTransferQueueProcessor(
 	)
 
 	standbyQueueProcessors := make(map[string]*transferQueueProcessorBase)
+	nDCHistoryResenders := make(map[string]xdc.NDCHistoryResender)
 	rereplicatorLogger := shard.GetLogger().WithTags(tag.ComponentHistoryResender)
 	resenderLogger := shard.GetLogger().WithTags(tag.ComponentHistoryResender)
 	for clusterName, info := range shard.GetClusterMetadata().GetAllClusterInfo() {
@@ -143,17 +146,23 @@ func NewTransferQueueProcessor(
 			config.StandbyTaskReReplicationContextTimeout,
 			rereplicatorLogger,
 		)
-		nDCHistoryResender := xdc.NewNDCHistoryResender(
+		nDCHistoryResender, err := xdc.NewNDCHistoryResender(
 			shard.GetDomainCache(),
-			shard.GetService().GetClientBean().GetRemoteAdminClient(clusterName),
+			resender.NewAdminClient(shard.GetService(), clusterName),
 			func(ctx context.Context, request *h.ReplicateEventsV2Request) error {
 				return historyEngine.ReplicateEventsV2(ctx, request)
 			},
 			shard.GetService().GetPayloadSerializer(),
 			config.StandbyTaskReReplicationContextTimeout,
 			executionCheck,
+			shard.GetMetricsClient(),
 			resenderLogger,
+			resender.NewOptions(config, clusterName, shard.GetService())...,
 		)
+		if err != nil {
+			resenderLogger.Fatal("Creating NDC history resender failed", tag.Error(err))
+		}
+		nDCHistoryResenders[clusterName] = nDCHistoryResender
 		standbyTaskExecutor := task.NewTransferStandbyTaskExecutor(
 			shard,
 			archivalClient,
@@ -196,6 +205,7 @@ func NewTransferQueueProcessor(
 		activeTaskExecutor:     activeTaskExecutor,
 		activeQueueProcessor:   activeQueueProcessor,
 		standbyQueueProcessors: standbyQueueProcessors,
+		nDCHistoryResenders:    nDCHistoryResenders,
 	}
 }
 
@@ -226,6 +236,10 @@ func (t *transferQueueProcessor) Stop() {
 			standbyQueueProcessor.Stop()
 		}
 	}
+	// the resenders are closed after the processors using them are stopped
+	for _, nDCHistoryResender := range t.nDCHistoryResenders {
+		nDCHistoryResender.Close()
+	}
 
 	close(t.shutdownChan)
 	common.AwaitWaitGroup(&t.shutdownWG, time.Minute)
@@ -204,6 +204,7 @@ func (r *dlqHandlerImpl) MergeMessages(
 	lastMessageID = defaultBeginningMessageID
 	for _, task := range tasks {
 		if _, err := r.taskExecutors[sourceCluster].execute(
+			ctx,
 			task,
 			true,
 		); err != nil {
@@ -205,7 +205,7 @@ func (s *dlqHandlerSuite) TestMergeMessages_OK() {
 				replicationTask,
 			},
 		}, nil)
-	s.taskExecutor.EXPECT().execute(replicationTask, true).Return(0, nil).Times(1)
+	s.taskExecutor.EXPECT().execute(gomock.Any(), replicationTask, true).Return(0, nil).Times(1)
 	s.executionManager.On("RangeDeleteReplicationTaskFromDLQ",
 		&persistence.RangeDeleteReplicationTaskFromDLQRequest{
 			SourceClusterName:    s.sourceCluster,
@@ -24,6 +24,7 @@ package replication
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/uber/cadence/.gen/go/history"
@@ -42,7 +43,7 @@ import (
 type (
 	// TaskExecutor is the executor for replication task
 	TaskExecutor interface {
-		execute(replicationTask *r.ReplicationTask, forceApply bool) (int, error)
+		execute(ctx context.Context, replicationTask *r.ReplicationTask, forceApply bool) (int, error)
 	}
 
 	taskExecutorImpl struct {
@@ -87,6 +88,7 @@ func NewTaskExecutor(
 }
 
 func (e *taskExecutorImpl) execute(
+	ctx context.Context,
 	replicationTask *r.ReplicationTask,
 	forceApply bool,
 ) (int, error) {
@@ -99,16 +101,16 @@ func (e *taskExecutorImpl) execute(
 		scope = metrics.SyncShardTaskScope
 	case r.ReplicationTaskTypeSyncActivity:
 		scope = metrics.SyncActivityTaskScope
-		err = e.handleActivityTask(replicationTask, forceApply)
+		err = e.handleActivityTask(ctx, replicationTask, forceApply)
 	case r.ReplicationTaskTypeHistory:
 		scope = metrics.HistoryReplicationTaskScope
-		err = e.handleHistoryReplicationTask(replicationTask, forceApply)
+		err = e.handleHistoryReplicationTask(ctx, replicationTask, forceApply)
 	case r.ReplicationTaskTypeHistoryMetadata:
 		// Without kafka we should not have size limits so we don't necessary need this in the new replication scheme.
 		scope = metrics.HistoryMetadataReplicationTaskScope
 	case r.ReplicationTaskTypeHistoryV2:
 		scope = metrics.HistoryReplicationV2TaskScope
-		err = e.handleHistoryReplicationTaskV2(replicationTask, forceApply)
+		err = e.handleHistoryReplicationTaskV2(ctx, replicationTask, forceApply)
 	case r.ReplicationTaskTypeFailoverMarker:
 		scope = metrics.HistoryFailoverMarkerScope
 		err = e.handleFailoverReplicationTask(replicationTask)
@@ -122,6 +124,7 @@ func (e *taskExecutorImpl) execute(
 }
 
 func (e *taskExecutorImpl) handleActivityTask(
+	ctx context.Context,
 	task *r.ReplicationTask,
 	forceApply bool,
 ) error {
@@ -150,9 +153,9 @@ func (e *taskExecutorImpl) handleActivityTask(
 		LastWorkerIdentity: attr.LastWorkerIdentity,
 		VersionHistory:     attr.GetVersionHistory(),
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), replicationTimeout)
+	replicationCtx, cancel := context.WithTimeout(ctx, replicationTimeout)
 	defer cancel()
-	err = e.historyEngine.SyncActivity(ctx, request)
+	err = e.historyEngine.SyncActivity(replicationCtx, request)
 	// Handle resend error
 	retryV2Err, okV2 := e.convertRetryTaskV2Error(err)
 	//TODO: remove handling retry error v1 after 2DC deprecation
@@ -187,6 +190,7 @@ func (e *taskExecutorImpl) handleActivityTask(
 		defer stopwatch.Stop()
 
 		resendErr := e.nDCHistoryResender.SendSingleWorkflowHistory(
+			xdc.WithResendShardID(ctx, e.shard.GetShardID()),
 			retryV2Err.GetDomainId(),
 			retryV2Err.GetWorkflowId(),
 			retryV2Err.GetRunId(),
@@ -198,12 +202,14 @@ func (e *taskExecutorImpl) handleActivityTask(
 		switch {
 		case resendErr == nil:
 			break
-		case resendErr == xdc.ErrSkipTask:
+		case errors.Is(resendErr, xdc.ErrSkipTask):
+			e.metricsClient.IncCounter(metrics.HistoryRereplicationByActivityReplicationScope, xdc.GetSkipTaskReason(resendErr).MetricCounter())
 			e.logger.Error(
 				"skip replication sync activity task",
 				tag.WorkflowDomainID(retryV2Err.GetDomainId()),
 				tag.WorkflowID(retryV2Err.GetWorkflowId()),
 				tag.WorkflowRunID(retryV2Err.GetRunId()),
+				tag.Error(resendErr),
 			)
 			return nil
 		default:
@@ -219,11 +225,12 @@ func (e *taskExecutorImpl) handleActivityTask(
 		}
 	}
 	// should try again after back fill the history
-	return e.historyEngine.SyncActivity(ctx, request)
+	return e.historyEngine.SyncActivity(replicationCtx, request)
 }
 
 //TODO: remove this part after 2DC deprecation
 func (e *taskExecutorImpl) handleHistoryReplicationTask(
+	ctx context.Context,
 	task *r.ReplicationTask,
 	forceApply bool,
 ) error {
@@ -251,10 +258,10 @@ func (e *taskExecutorImpl) handleHistoryReplicationTask(
 		ResetWorkflow:     attr.ResetWorkflow,
 		NewRunNDC:         attr.NewRunNDC,
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), replicationTimeout)
+	replicationCtx, cancel := context.WithTimeout(ctx, replicationTimeout)
 	defer cancel()
 
-	err = e.historyEngine.ReplicateEvents(ctx, request)
+	err = e.historyEngine.ReplicateEvents(replicationCtx, request)
 	retryErr, ok := e.convertRetryTaskError(err)
 	if !ok || retryErr.GetRunId() == "" {
 		return err
@@ -278,10 +285,11 @@ func (e *taskExecutorImpl) handleHistoryReplicationTask(
 		return err
 	}
 
-	return e.historyEngine.ReplicateEvents(ctx, request)
+	return e.historyEngine.ReplicateEvents(replicationCtx, request)
 }
 
 func (e *taskExecutorImpl) handleHistoryReplicationTaskV2(
+	ctx context.Context,
 	task *r.ReplicationTask,
 	forceApply bool,
 ) error {
@@ -305,10 +313,10 @@ func (e *taskExecutorImpl) handleHistoryReplicationTaskV2(
 		// new run events does not need version history since there is no prior events
 		NewRunEvents: attr.NewRunEvents,
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), replicationTimeout)
+	replicationCtx, cancel := context.WithTimeout(ctx, replicationTimeout)
 	defer cancel()
 
-	err = e.historyEngine.ReplicateEventsV2(ctx, request)
+	err = e.historyEngine.ReplicateEventsV2(replicationCtx, request)
 	retryErr, ok := e.convertRetryTaskV2Error(err)
 	if !ok {
 		return err
@@ -318,6 +326,7 @@ func (e *taskExecutorImpl) handleHistoryReplicationTaskV2(
 	defer resendStopWatch.Stop()
 
 	resendErr := e.nDCHistoryResender.SendSingleWorkflowHistory(
+		xdc.WithResendShardID(ctx, e.shard.GetShardID()),
 		retryErr.GetDomainId(),
 		retryErr.GetWorkflowId(),
 		retryErr.GetRunId(),
@@ -329,12 +338,14 @@ func (e *taskExecutorImpl) handleHistoryReplicationTaskV2(
 	switch {
 	case resendErr == nil:
 		break
-	case resendErr == xdc.ErrSkipTask:
+	case errors.Is(resendErr, xdc.ErrSkipTask):
+		e.metricsClient.IncCounter(metrics.HistoryRereplicationByHistoryReplicationScope, xdc.GetSkipTaskReason(resendErr).MetricCounter())
 		e.logger.Error(
 			"skip replication history task",
 			tag.WorkflowDomainID(retryErr.GetDomainId()),
 			tag.WorkflowID(retryErr.GetWorkflowId()),
 			tag.WorkflowRunID(retryErr.GetRunId()),
+			tag.Error(resendErr),
 		)
 		return nil
 	default:
@@ -349,7 +360,7 @@ func (e *taskExecutorImpl) handleHistoryReplicationTaskV2(
 		return err
 	}
 
-	return e.historyEngine.ReplicateEventsV2(ctx, request)
+	return e.historyEngine.ReplicateEventsV2(replicationCtx, request)
 }
 
 func (e *taskExecutorImpl) handleFailoverReplicationTask(
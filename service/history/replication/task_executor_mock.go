@@ -28,6 +28,7 @@
 package replication
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -59,16 +60,16 @@ func (m *MockTaskExecutor) EXPECT() *MockTaskExecutorMockRecorder {
 }
 
 // execute mocks base method
-func (m *MockTaskExecutor) execute(replicationTask *replicator.ReplicationTask, forceApply bool) (int, error) {
+func (m *MockTaskExecutor) execute(ctx context.Context, replicationTask *replicator.ReplicationTask, forceApply bool) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "execute", replicationTask, forceApply)
+	ret := m.ctrl.Call(m, "execute", ctx, replicationTask, forceApply)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // execute indicates an expected call of execute
-func (mr *MockTaskExecutorMockRecorder) execute(replicationTask, forceApply interface{}) *gomock.Call {
+func (mr *MockTaskExecutorMockRecorder) execute(ctx, replicationTask, forceApply interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "execute", reflect.TypeOf((*MockTaskExecutor)(nil).execute), replicationTask, forceApply)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "execute", reflect.TypeOf((*MockTaskExecutor)(nil).execute), ctx, replicationTask, forceApply)
 }
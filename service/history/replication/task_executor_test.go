@@ -21,6 +21,7 @@
 package replication
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -214,7 +215,7 @@ func (s *taskExecutorSuite) TestProcessTaskOnce_SyncActivityReplicationTask() {
 	}
 
 	s.mockEngine.EXPECT().SyncActivity(gomock.Any(), request).Return(nil).Times(1)
-	_, err := s.taskHandler.execute(task, true)
+	_, err := s.taskHandler.execute(context.Background(), task, true)
 	s.NoError(err)
 }
 
@@ -241,7 +242,7 @@ func (s *taskExecutorSuite) TestProcessTaskOnce_HistoryReplicationTask() {
 	}
 
 	s.mockEngine.EXPECT().ReplicateEvents(gomock.Any(), request).Return(nil).Times(1)
-	_, err := s.taskHandler.execute(task, true)
+	_, err := s.taskHandler.execute(context.Background(), task, true)
 	s.NoError(err)
 }
 
@@ -266,6 +267,54 @@ func (s *taskExecutorSuite) TestProcess_HistoryV2ReplicationTask() {
 	}
 
 	s.mockEngine.EXPECT().ReplicateEventsV2(gomock.Any(), request).Return(nil).Times(1)
-	_, err := s.taskHandler.execute(task, true)
+	_, err := s.taskHandler.execute(context.Background(), task, true)
+	s.NoError(err)
+}
+
+func (s *taskExecutorSuite) TestProcess_HistoryV2ReplicationTask_ResendWithTaskContext() {
+	domainID := uuid.New()
+	workflowID := uuid.New()
+	runID := uuid.New()
+	task := &replicator.ReplicationTask{
+		TaskType: replicator.ReplicationTaskTypeHistoryV2.Ptr(),
+		HistoryTaskV2Attributes: &replicator.HistoryTaskV2Attributes{
+			DomainId:   common.StringPtr(domainID),
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+	}
+	retryErr := &shared.RetryTaskV2Error{
+		DomainId:          common.StringPtr(domainID),
+		WorkflowId:        common.StringPtr(workflowID),
+		RunId:             common.StringPtr(runID),
+		StartEventId:      common.Int64Ptr(1),
+		StartEventVersion: common.Int64Ptr(100),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	gomock.InOrder(
+		s.mockEngine.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(retryErr).Times(1),
+		s.nDCHistoryResender.EXPECT().SendSingleWorkflowHistory(
+			gomock.Any(),
+			domainID,
+			workflowID,
+			runID,
+			common.Int64Ptr(1),
+			common.Int64Ptr(100),
+			nil,
+			nil,
+		).DoAndReturn(func(resendCtx context.Context, _ string, _ string, _ string, _ *int64, _ *int64, _ *int64, _ *int64) error {
+			// the resend is interrupted once the task is cancelled
+			cancel()
+			s.Equal(context.Canceled, resendCtx.Err())
+			shardID, ok := xdc.GetResendShardID(resendCtx)
+			s.True(ok)
+			s.Equal(s.mockShard.GetShardID(), shardID)
+			return nil
+		}).Times(1),
+		s.mockEngine.EXPECT().ReplicateEventsV2(gomock.Any(), gomock.Any()).Return(nil).Times(1),
+	)
+	_, err := s.taskHandler.execute(ctx, task, true)
 	s.NoError(err)
 }
@@ -295,7 +295,7 @@ func (p *taskProcessorImpl) processResponse(response *r.ReplicationMessages) {
 		// TODO: move to MultiStageRateLimiter
 		_ = p.hostRateLimiter.Wait(ctx)
 		_ = p.shardRateLimiter.Wait(ctx)
-		err := p.processSingleTask(replicationTask)
+		err := p.processSingleTask(ctx, replicationTask)
 		if err != nil {
 			// Processor is shutdown. Exit without updating the checkpoint.
 			return
@@ -366,7 +366,7 @@ func (p *taskProcessorImpl) handleSyncShardStatus(
 	})
 }
 
-func (p *taskProcessorImpl) processSingleTask(replicationTask *r.ReplicationTask) error {
+func (p *taskProcessorImpl) processSingleTask(ctx context.Context, replicationTask *r.ReplicationTask) error {
 	retryTransientError := func() error {
 		return backoff.Retry(
 			func() error {
@@ -376,7 +376,7 @@ func (p *taskProcessorImpl) processSingleTask(replicationTask *r.ReplicationTask
 					// the ack level will not update and the new shard owner will retry the task.
 					return nil
 				default:
-					return p.processTaskOnce(replicationTask)
+					return p.processTaskOnce(ctx, replicationTask)
 				}
 			},
 			p.taskRetryPolicy,
@@ -407,9 +407,10 @@ func (p *taskProcessorImpl) processSingleTask(replicationTask *r.ReplicationTask
 	return nil
 }
 
-func (p *taskProcessorImpl) processTaskOnce(replicationTask *r.ReplicationTask) error {
+func (p *taskProcessorImpl) processTaskOnce(ctx context.Context, replicationTask *r.ReplicationTask) error {
 	startTime := time.Now()
 	scope, err := p.taskExecutor.execute(
+		ctx,
 		replicationTask,
 		false)
 
@@ -0,0 +1,71 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package resender
+
+import (
+	"github.com/uber/cadence/common/xdc"
+	"github.com/uber/cadence/service/history/config"
+	"github.com/uber/cadence/service/history/resource"
+)
+
+// NewOptions returns the options of the resender of the history events from the source cluster,
+// which are shared by all the resenders of the history service
+func NewOptions(
+	config *config.Config,
+	sourceCluster string,
+	historyResource resource.Resource,
+) []xdc.NDCHistoryResenderOption {
+
+	return []xdc.NDCHistoryResenderOption{
+		xdc.WithResendPageSize(config.ReReplicationPageSize),
+		xdc.WithSourceCluster(sourceCluster),
+		xdc.WithStrictVersionHistoryValidation(config.ReReplicationStrictValidation),
+		xdc.WithRereplicationTimeoutJitter(config.ReReplicationTimeoutJitterCoefficient),
+		xdc.WithMaxResendBytes(config.ReReplicationMaxBytes),
+		xdc.WithGetHistoryTimeout(config.ReReplicationGetHistoryTimeout),
+		xdc.WithReplicationTimeout(config.ReReplicationReplicateEventsTimeout),
+		xdc.WithResendPageBufferSize(config.ReReplicationPageBufferSize),
+		xdc.WithEventBatchValidation(config.ReReplicationValidateEventBatch),
+		xdc.WithSkipInvalidEventBatch(config.ReReplicationSkipInvalidEventBatch),
+		xdc.WithSkipEmptyVersionHistory(config.ReReplicationSkipEmptyVersionHistory),
+		xdc.WithCircuitBreaker(config.ReReplicationCircuitBreakerThreshold, config.ReReplicationCircuitBreakerCooldown),
+		xdc.WithAsyncCurrentExecutionFix(config.ReReplicationAsyncFixWorkerCount, config.ReReplicationAsyncFixQueueSize),
+		xdc.WithArchivalFallback(historyResource.GetArchiverProvider(), config.ReReplicationArchivalFallback),
+		xdc.WithSkippedRunCache(config.ReReplicationSkippedRunCacheSize, config.ReReplicationSkippedRunCacheTTL),
+		xdc.WithEventBlobEncoding(config.ReReplicationEventBlobEncoding),
+		xdc.WithBatchDelay(config.ReReplicationBatchDelay),
+		xdc.WithTrimBatchToEndEvent(config.ReReplicationTrimBatchToEndEvent),
+		xdc.WithResendFetchConcurrency(config.ReReplicationFetchConcurrency),
+		xdc.WithLargeBatchThreshold(config.ReReplicationLargeBatchThreshold),
+		xdc.WithMaxBatchSize(config.ReReplicationMaxBatchSize),
+		xdc.WithDomainPriority(config.ReReplicationPriority),
+		xdc.WithMaxTimeoutOverride(config.ReReplicationMaxTimeoutOverride),
+		xdc.WithFailOnHistoryGap(config.ReReplicationFailOnHistoryGap),
+		xdc.WithCurrentExecutionFixDisabled(config.ReReplicationDisableCurrentExecutionFix),
+		xdc.WithMaxPages(config.ReReplicationMaxPages),
+		xdc.WithMultiResendRetryBudget(config.ReReplicationMultiResendRetryBudget),
+		xdc.WithQuietExpectedErrors(config.ReReplicationQuietExpectedErrors),
+		xdc.WithTargetShardCount(config.NumberOfShards),
+		xdc.WithAdaptivePageSize(config.ReReplicationTargetPageBytes, config.ReReplicationMinAdaptivePageSize, config.ReReplicationMaxAdaptivePageSize),
+		xdc.WithSplitBatchSize(config.ReReplicationSplitBatchSize),
+		xdc.WithResendLimiter(historyResource.GetResendLimiter()),
+	}
+}
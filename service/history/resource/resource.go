@@ -27,6 +27,7 @@ import (
 	"github.com/uber/cadence/common/log/tag"
 	"github.com/uber/cadence/common/resource"
 	"github.com/uber/cadence/common/service"
+	"github.com/uber/cadence/common/xdc"
 	"github.com/uber/cadence/service/history/config"
 	"github.com/uber/cadence/service/history/events"
 )
@@ -35,13 +36,15 @@ import (
 type Resource interface {
 	resource.Resource
 	GetEventCache() events.Cache
+	GetResendLimiter() *xdc.ResendLimiter
 }
 
 type resourceImpl struct {
 	status int32
 
 	resource.Resource
-	eventCache events.Cache
+	eventCache    events.Cache
+	resendLimiter *xdc.ResendLimiter
 }
 
 // Start starts all resources
@@ -79,6 +82,11 @@ func (h *resourceImpl) GetEventCache() events.Cache {
 	return h.eventCache
 }
 
+// GetResendLimiter return the limiter of the history resends, shared by all the resenders of the host
+func (h *resourceImpl) GetResendLimiter() *xdc.ResendLimiter {
+	return h.resendLimiter
+}
+
 // New create a new resource containing common history dependencies
 func New(
 	params *service.BootstrapParams,
@@ -108,9 +116,18 @@ func New(
 		uint64(config.EventsCacheMaxSize()),
 	)
 
+	resendLimiter := xdc.NewResendLimiter(&xdc.ResendLimiterOptions{
+		GetHistoryRPS:                config.ReReplicationGetHistoryRPS,
+		MaxDomainConcurrency:         config.ReReplicationMaxDomainConcurrency,
+		DomainConcurrencyWaitTimeout: config.ReReplicationConcurrencyWaitTimeout,
+		GlobalBytesPerSecond:         config.ReReplicationGlobalBytesPerSecond,
+		GlobalOpsPerSecond:           config.ReReplicationGlobalOpsPerSecond,
+	})
+
 	historyResource = &resourceImpl{
-		Resource:   serviceResource,
-		eventCache: eventCache,
+		Resource:      serviceResource,
+		eventCache:    eventCache,
+		resendLimiter: resendLimiter,
 	}
 	return
 }
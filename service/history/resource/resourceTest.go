@@ -25,6 +25,7 @@ import (
 
 	"github.com/uber/cadence/common/metrics"
 	"github.com/uber/cadence/common/resource"
+	"github.com/uber/cadence/common/xdc"
 	"github.com/uber/cadence/service/history/events"
 )
 
@@ -32,7 +33,8 @@ type (
 	// Test is the test implementation used for testing
 	Test struct {
 		*resource.Test
-		EventCache *events.MockCache
+		EventCache    *events.MockCache
+		ResendLimiter *xdc.ResendLimiter
 	}
 )
 
@@ -44,8 +46,9 @@ func NewTest(
 	serviceMetricsIndex metrics.ServiceIdx,
 ) *Test {
 	return &Test{
-		Test:       resource.NewTest(controller, serviceMetricsIndex),
-		EventCache: events.NewMockCache(controller),
+		Test:          resource.NewTest(controller, serviceMetricsIndex),
+		EventCache:    events.NewMockCache(controller),
+		ResendLimiter: xdc.NewResendLimiter(&xdc.ResendLimiterOptions{}),
 	}
 }
 
@@ -53,3 +56,8 @@ func NewTest(
 func (s *Test) GetEventCache() events.Cache {
 	return s.EventCache
 }
+
+// GetResendLimiter for testing
+func (s *Test) GetResendLimiter() *xdc.ResendLimiter {
+	return s.ResendLimiter
+}
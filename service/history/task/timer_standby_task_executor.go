@@ -21,6 +21,7 @@
 package task
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
@@ -98,21 +99,23 @@ func (t *timerStandbyTaskExecutor) Execute(
 		return nil
 	}
 
+	// the task processing starts here, the context is passed down to the history resends
+	ctx := context.Background()
 	switch timerTask.TaskType {
 	case persistence.TaskTypeUserTimer:
-		return t.executeUserTimerTimeoutTask(timerTask)
+		return t.executeUserTimerTimeoutTask(ctx, timerTask)
 	case persistence.TaskTypeActivityTimeout:
-		return t.executeActivityTimeoutTask(timerTask)
+		return t.executeActivityTimeoutTask(ctx, timerTask)
 	case persistence.TaskTypeDecisionTimeout:
-		return t.executeDecisionTimeoutTask(timerTask)
+		return t.executeDecisionTimeoutTask(ctx, timerTask)
 	case persistence.TaskTypeWorkflowTimeout:
-		return t.executeWorkflowTimeoutTask(timerTask)
+		return t.executeWorkflowTimeoutTask(ctx, timerTask)
 	case persistence.TaskTypeActivityRetryTimer:
 		// retry backoff timer should not get created on passive cluster
 		// TODO: add error logs
 		return nil
 	case persistence.TaskTypeWorkflowBackoffTimer:
-		return t.executeWorkflowBackoffTimerTask(timerTask)
+		return t.executeWorkflowBackoffTimerTask(ctx, timerTask)
 	case persistence.TaskTypeDeleteHistoryEvent:
 		return t.executeDeleteHistoryEventTask(timerTask)
 	default:
@@ -121,6 +124,7 @@ func (t *timerStandbyTaskExecutor) Execute(
 }
 
 func (t *timerStandbyTaskExecutor) executeUserTimerTimeoutTask(
+	ctx context.Context,
 	timerTask *persistence.TimerTaskInfo,
 ) error {
 
@@ -152,6 +156,7 @@ func (t *timerStandbyTaskExecutor) executeUserTimerTimeoutTask(
 	}
 
 	return t.processTimer(
+		ctx,
 		timerTask,
 		actionFn,
 		getStandbyPostActionFn(
@@ -166,6 +171,7 @@ func (t *timerStandbyTaskExecutor) executeUserTimerTimeoutTask(
 }
 
 func (t *timerStandbyTaskExecutor) executeActivityTimeoutTask(
+	ctx context.Context,
 	timerTask *persistence.TimerTaskInfo,
 ) error {
 
@@ -254,6 +260,7 @@ func (t *timerStandbyTaskExecutor) executeActivityTimeoutTask(
 	}
 
 	return t.processTimer(
+		ctx,
 		timerTask,
 		actionFn,
 		getStandbyPostActionFn(
@@ -268,6 +275,7 @@ func (t *timerStandbyTaskExecutor) executeActivityTimeoutTask(
 }
 
 func (t *timerStandbyTaskExecutor) executeDecisionTimeoutTask(
+	ctx context.Context,
 	timerTask *persistence.TimerTaskInfo,
 ) error {
 
@@ -294,6 +302,7 @@ func (t *timerStandbyTaskExecutor) executeDecisionTimeoutTask(
 	}
 
 	return t.processTimer(
+		ctx,
 		timerTask,
 		actionFn,
 		getStandbyPostActionFn(
@@ -308,6 +317,7 @@ func (t *timerStandbyTaskExecutor) executeDecisionTimeoutTask(
 }
 
 func (t *timerStandbyTaskExecutor) executeWorkflowBackoffTimerTask(
+	ctx context.Context,
 	timerTask *persistence.TimerTaskInfo,
 ) error {
 
@@ -333,6 +343,7 @@ func (t *timerStandbyTaskExecutor) executeWorkflowBackoffTimerTask(
 	}
 
 	return t.processTimer(
+		ctx,
 		timerTask,
 		actionFn,
 		getStandbyPostActionFn(
@@ -347,6 +358,7 @@ func (t *timerStandbyTaskExecutor) executeWorkflowBackoffTimerTask(
 }
 
 func (t *timerStandbyTaskExecutor) executeWorkflowTimeoutTask(
+	ctx context.Context,
 	timerTask *persistence.TimerTaskInfo,
 ) error {
 
@@ -368,6 +380,7 @@ func (t *timerStandbyTaskExecutor) executeWorkflowTimeoutTask(
 	}
 
 	return t.processTimer(
+		ctx,
 		timerTask,
 		actionFn,
 		getStandbyPostActionFn(
@@ -398,6 +411,7 @@ func (t *timerStandbyTaskExecutor) getTimerSequence(
 }
 
 func (t *timerStandbyTaskExecutor) processTimer(
+	ctx context.Context,
 	timerTask *persistence.TimerTaskInfo,
 	actionFn standbyActionFn,
 	postActionFn standbyPostActionFn,
@@ -438,10 +452,11 @@ func (t *timerStandbyTaskExecutor) processTimer(
 	}
 
 	release(nil)
-	return postActionFn(timerTask, historyResendInfo, t.logger)
+	return postActionFn(ctx, timerTask, historyResendInfo, t.logger)
 }
 
 func (t *timerStandbyTaskExecutor) fetchHistoryFromRemote(
+	ctx context.Context,
 	taskInfo Info,
 	postActionInfo interface{},
 	log log.Logger,
@@ -461,6 +476,7 @@ func (t *timerStandbyTaskExecutor) fetchHistoryFromRemote(
 	var err error
 	if resendInfo.lastEventID != nil && resendInfo.lastEventVersion != nil {
 		err = t.nDCHistoryResender.SendSingleWorkflowHistory(
+			xdc.WithResendShardID(ctx, t.shard.GetShardID()),
 			timerTask.DomainID,
 			timerTask.WorkflowID,
 			timerTask.RunID,
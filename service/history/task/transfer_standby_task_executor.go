@@ -21,6 +21,7 @@
 package task
 
 import (
+	"context"
 	"time"
 
 	workflow "github.com/uber/cadence/.gen/go/shared"
@@ -89,33 +90,36 @@ func (t *transferStandbyTaskExecutor) Execute(
 		return nil
 	}
 
+	// the task processing starts here, the context is passed down to the history resends
+	ctx := context.Background()
 	switch transferTask.TaskType {
 	case persistence.TransferTaskTypeActivityTask:
-		return t.processActivityTask(transferTask)
+		return t.processActivityTask(ctx, transferTask)
 	case persistence.TransferTaskTypeDecisionTask:
-		return t.processDecisionTask(transferTask)
+		return t.processDecisionTask(ctx, transferTask)
 	case persistence.TransferTaskTypeCloseExecution:
-		return t.processCloseExecution(transferTask)
+		return t.processCloseExecution(ctx, transferTask)
 	case persistence.TransferTaskTypeCancelExecution:
-		return t.processCancelExecution(transferTask)
+		return t.processCancelExecution(ctx, transferTask)
 	case persistence.TransferTaskTypeSignalExecution:
-		return t.processSignalExecution(transferTask)
+		return t.processSignalExecution(ctx, transferTask)
 	case persistence.TransferTaskTypeStartChildExecution:
-		return t.processStartChildExecution(transferTask)
+		return t.processStartChildExecution(ctx, transferTask)
 	case persistence.TransferTaskTypeRecordWorkflowStarted:
-		return t.processRecordWorkflowStarted(transferTask)
+		return t.processRecordWorkflowStarted(ctx, transferTask)
 	case persistence.TransferTaskTypeResetWorkflow:
 		// no reset needed for standby
 		// TODO: add error logs
 		return nil
 	case persistence.TransferTaskTypeUpsertWorkflowSearchAttributes:
-		return t.processUpsertWorkflowSearchAttributes(transferTask)
+		return t.processUpsertWorkflowSearchAttributes(ctx, transferTask)
 	default:
 		return errUnknownTransferTask
 	}
 }
 
 func (t *transferStandbyTaskExecutor) processActivityTask(
+	ctx context.Context,
 	transferTask *persistence.TransferTaskInfo,
 ) error {
 
@@ -142,6 +146,7 @@ func (t *transferStandbyTaskExecutor) processActivityTask(
 	}
 
 	return t.processTransfer(
+		ctx,
 		processTaskIfClosed,
 		transferTask,
 		actionFn,
@@ -157,6 +162,7 @@ func (t *transferStandbyTaskExecutor) processActivityTask(
 }
 
 func (t *transferStandbyTaskExecutor) processDecisionTask(
+	ctx context.Context,
 	transferTask *persistence.TransferTaskInfo,
 ) error {
 
@@ -188,6 +194,7 @@ func (t *transferStandbyTaskExecutor) processDecisionTask(
 	}
 
 	return t.processTransfer(
+		ctx,
 		processTaskIfClosed,
 		transferTask,
 		actionFn,
@@ -203,6 +210,7 @@ func (t *transferStandbyTaskExecutor) processDecisionTask(
 }
 
 func (t *transferStandbyTaskExecutor) processCloseExecution(
+	ctx context.Context,
 	transferTask *persistence.TransferTaskInfo,
 ) error {
 
@@ -263,6 +271,7 @@ func (t *transferStandbyTaskExecutor) processCloseExecution(
 	}
 
 	return t.processTransfer(
+		ctx,
 		processTaskIfClosed,
 		transferTask,
 		actionFn,
@@ -271,6 +280,7 @@ func (t *transferStandbyTaskExecutor) processCloseExecution(
 }
 
 func (t *transferStandbyTaskExecutor) processCancelExecution(
+	ctx context.Context,
 	transferTask *persistence.TransferTaskInfo,
 ) error {
 
@@ -291,6 +301,7 @@ func (t *transferStandbyTaskExecutor) processCancelExecution(
 	}
 
 	return t.processTransfer(
+		ctx,
 		processTaskIfClosed,
 		transferTask,
 		actionFn,
@@ -306,6 +317,7 @@ func (t *transferStandbyTaskExecutor) processCancelExecution(
 }
 
 func (t *transferStandbyTaskExecutor) processSignalExecution(
+	ctx context.Context,
 	transferTask *persistence.TransferTaskInfo,
 ) error {
 
@@ -326,6 +338,7 @@ func (t *transferStandbyTaskExecutor) processSignalExecution(
 	}
 
 	return t.processTransfer(
+		ctx,
 		processTaskIfClosed,
 		transferTask,
 		actionFn,
@@ -341,6 +354,7 @@ func (t *transferStandbyTaskExecutor) processSignalExecution(
 }
 
 func (t *transferStandbyTaskExecutor) processStartChildExecution(
+	ctx context.Context,
 	transferTask *persistence.TransferTaskInfo,
 ) error {
 
@@ -365,6 +379,7 @@ func (t *transferStandbyTaskExecutor) processStartChildExecution(
 	}
 
 	return t.processTransfer(
+		ctx,
 		processTaskIfClosed,
 		transferTask,
 		actionFn,
@@ -380,11 +395,13 @@ func (t *transferStandbyTaskExecutor) processStartChildExecution(
 }
 
 func (t *transferStandbyTaskExecutor) processRecordWorkflowStarted(
+	ctx context.Context,
 	transferTask *persistence.TransferTaskInfo,
 ) error {
 
 	processTaskIfClosed := false
 	return t.processTransfer(
+		ctx,
 		processTaskIfClosed,
 		transferTask,
 		func(context execution.Context, mutableState execution.MutableState) (interface{}, error) {
@@ -395,11 +412,13 @@ func (t *transferStandbyTaskExecutor) processRecordWorkflowStarted(
 }
 
 func (t *transferStandbyTaskExecutor) processUpsertWorkflowSearchAttributes(
+	ctx context.Context,
 	transferTask *persistence.TransferTaskInfo,
 ) error {
 
 	processTaskIfClosed := false
 	return t.processTransfer(
+		ctx,
 		processTaskIfClosed,
 		transferTask,
 		func(context execution.Context, mutableState execution.MutableState) (interface{}, error) {
@@ -472,6 +491,7 @@ func (t *transferStandbyTaskExecutor) processRecordWorkflowStartedOrUpsertHelper
 }
 
 func (t *transferStandbyTaskExecutor) processTransfer(
+	ctx context.Context,
 	processTaskIfClosed bool,
 	taskInfo Info,
 	actionFn standbyActionFn,
@@ -511,10 +531,11 @@ func (t *transferStandbyTaskExecutor) processTransfer(
 	}
 
 	release(nil)
-	return postActionFn(taskInfo, historyResendInfo, t.logger)
+	return postActionFn(ctx, taskInfo, historyResendInfo, t.logger)
 }
 
 func (t *transferStandbyTaskExecutor) pushActivity(
+	ctx context.Context,
 	task Info,
 	postActionInfo interface{},
 	logger log.Logger,
@@ -533,6 +554,7 @@ func (t *transferStandbyTaskExecutor) pushActivity(
 }
 
 func (t *transferStandbyTaskExecutor) pushDecision(
+	ctx context.Context,
 	task Info,
 	postActionInfo interface{},
 	logger log.Logger,
@@ -552,6 +574,7 @@ func (t *transferStandbyTaskExecutor) pushDecision(
 }
 
 func (t *transferStandbyTaskExecutor) fetchHistoryFromRemote(
+	ctx context.Context,
 	taskInfo Info,
 	postActionInfo interface{},
 	log log.Logger,
@@ -571,6 +594,7 @@ func (t *transferStandbyTaskExecutor) fetchHistoryFromRemote(
 	var err error
 	if resendInfo.lastEventID != nil && resendInfo.lastEventVersion != nil {
 		err = t.nDCHistoryResender.SendSingleWorkflowHistory(
+			xdc.WithResendShardID(ctx, t.shard.GetShardID()),
 			transferTask.DomainID,
 			transferTask.WorkflowID,
 			transferTask.RunID,
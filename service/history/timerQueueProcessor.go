@@ -37,6 +37,7 @@ import (
 	"github.com/uber/cadence/common/xdc"
 	"github.com/uber/cadence/service/history/config"
 	"github.com/uber/cadence/service/history/queue"
+	"github.com/uber/cadence/service/history/resender"
 	"github.com/uber/cadence/service/history/shard"
 	"github.com/uber/cadence/service/history/task"
 )
@@ -62,6 +63,7 @@ type (
 		queueTaskProcessor     task.Processor
 		activeTimerProcessor   *timerQueueActiveProcessorImpl
 		standbyTimerProcessors map[string]*timerQueueStandbyProcessorImpl
+		nDCHistoryResenders    map[string]xdc.NDCHistoryResender
 	}
 )
 
@@ -80,6 +82,7 @@ func newTimerQueueProcessor(
 	taskAllocator := queue.NewTaskAllocator(shard)
 
 	standbyTimerProcessors := make(map[string]*timerQueueStandbyProcessorImpl)
+	nDCHistoryResenders := make(map[string]xdc.NDCHistoryResender)
 	for clusterName, info := range shard.GetService().GetClusterMetadata().GetAllClusterInfo() {
 		if !info.Enabled {
 			continue
@@ -98,17 +101,23 @@ func newTimerQueueProcessor(
 				config.StandbyTaskReReplicationContextTimeout,
 				logger,
 			)
-			nDCHistoryResender := xdc.NewNDCHistoryResender(
+			nDCHistoryResender, err := xdc.NewNDCHistoryResender(
 				shard.GetDomainCache(),
-				shard.GetService().GetClientBean().GetRemoteAdminClient(clusterName),
+				resender.NewAdminClient(shard.GetService(), clusterName),
 				func(ctx context.Context, request *h.ReplicateEventsV2Request) error {
 					return historyService.ReplicateEventsV2(ctx, request)
 				},
 				shard.GetService().GetPayloadSerializer(),
 				config.StandbyTaskReReplicationContextTimeout,
 				openExecutionCheck,
+				historyService.metricsClient,
 				logger,
+				resender.NewOptions(config, clusterName, shard.GetService())...,
 			)
+			if err != nil {
+				logger.Fatal("Creating NDC history resender failed", tag.Error(err))
+			}
+			nDCHistoryResenders[clusterName] = nDCHistoryResender
 			standbyTimerProcessors[clusterName] = newTimerQueueStandbyProcessor(
 				shard,
 				historyService,
@@ -144,6 +153,7 @@ func newTimerQueueProcessor(
 			logger,
 		),
 		standbyTimerProcessors: standbyTimerProcessors,
+		nDCHistoryResenders:    nDCHistoryResenders,
 	}
 }
 
@@ -170,6 +180,10 @@ func (t *timerQueueProcessorImpl) Stop() {
 			standbyTimerProcessor.Stop()
 		}
 	}
+	// the resenders are closed after the processors using them are stopped
+	for _, nDCHistoryResender := range t.nDCHistoryResenders {
+		nDCHistoryResender.Close()
+	}
 	close(t.shutdownChan)
 }
 
@@ -38,6 +38,7 @@ import (
 	"github.com/uber/cadence/common/xdc"
 	"github.com/uber/cadence/service/history/config"
 	"github.com/uber/cadence/service/history/queue"
+	"github.com/uber/cadence/service/history/resender"
 	"github.com/uber/cadence/service/history/shard"
 	"github.com/uber/cadence/service/history/task"
 )
@@ -62,6 +63,7 @@ type (
 		queueTaskProcessor    task.Processor
 		activeTaskProcessor   *transferQueueActiveProcessorImpl
 		standbyTaskProcessors map[string]*transferQueueStandbyProcessorImpl
+		nDCHistoryResenders   map[string]xdc.NDCHistoryResender
 	}
 )
 
@@ -82,6 +84,7 @@ func newTransferQueueProcessor(
 	taskAllocator := queue.NewTaskAllocator(shard)
 
 	standbyTaskProcessors := make(map[string]*transferQueueStandbyProcessorImpl)
+	nDCHistoryResenders := make(map[string]xdc.NDCHistoryResender)
 	rereplicatorLogger := shard.GetLogger().WithTags(tag.ComponentHistoryReplicator)
 	resenderLogger := shard.GetLogger().WithTags(tag.ComponentHistoryResender)
 	for clusterName, info := range shard.GetService().GetClusterMetadata().GetAllClusterInfo() {
@@ -102,17 +105,23 @@ func newTransferQueueProcessor(
 				config.StandbyTaskReReplicationContextTimeout,
 				rereplicatorLogger,
 			)
-			nDCHistoryResender := xdc.NewNDCHistoryResender(
+			nDCHistoryResender, err := xdc.NewNDCHistoryResender(
 				shard.GetDomainCache(),
-				shard.GetService().GetClientBean().GetRemoteAdminClient(clusterName),
+				resender.NewAdminClient(shard.GetService(), clusterName),
 				func(ctx context.Context, request *h.ReplicateEventsV2Request) error {
 					return historyService.ReplicateEventsV2(ctx, request)
 				},
 				shard.GetService().GetPayloadSerializer(),
 				config.StandbyTaskReReplicationContextTimeout,
 				openExecutionCheck,
+				historyService.metricsClient,
 				resenderLogger,
+				resender.NewOptions(config, clusterName, shard.GetService())...,
 			)
+			if err != nil {
+				resenderLogger.Fatal("Creating NDC history resender failed", tag.Error(err))
+			}
+			nDCHistoryResenders[clusterName] = nDCHistoryResender
 			standbyTaskProcessors[clusterName] = newTransferQueueStandbyProcessor(
 				clusterName,
 				shard,
@@ -154,6 +163,7 @@ func newTransferQueueProcessor(
 			logger,
 		),
 		standbyTaskProcessors: standbyTaskProcessors,
+		nDCHistoryResenders:   nDCHistoryResenders,
 	}
 }
 
@@ -181,6 +191,10 @@ func (t *transferQueueProcessorImpl) Stop() {
 			standbyTaskProcessor.Stop()
 		}
 	}
+	// the resenders are closed after the processors using them are stopped
+	for _, nDCHistoryResender := range t.nDCHistoryResenders {
+		nDCHistoryResender.Close()
+	}
 	close(t.shutdownChan)
 }
 
@@ -22,6 +22,7 @@ package replicator
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	h "github.com/uber/cadence/.gen/go/history"
@@ -355,6 +356,7 @@ func (t *activityReplicationTask) HandleErr(
 		defer stopwatch.Stop()
 
 		resendErr := t.nDCHistoryResender.SendSingleWorkflowHistory(
+			context.Background(),
 			retryV2Err.GetDomainId(),
 			retryV2Err.GetWorkflowId(),
 			retryV2Err.GetRunId(),
@@ -366,12 +368,14 @@ func (t *activityReplicationTask) HandleErr(
 		switch {
 		case resendErr == nil:
 			break
-		case resendErr == xdc.ErrSkipTask:
+		case errors.Is(resendErr, xdc.ErrSkipTask):
+			t.metricsClient.IncCounter(metrics.HistoryRereplicationByActivityReplicationScope, xdc.GetSkipTaskReason(resendErr).MetricCounter())
 			t.logger.Error(
 				"skip replication sync activity task",
 				tag.WorkflowDomainID(retryV2Err.GetDomainId()),
 				tag.WorkflowID(retryV2Err.GetWorkflowId()),
 				tag.WorkflowRunID(retryV2Err.GetRunId()),
+				tag.Error(resendErr),
 			)
 			return nil
 		default:
@@ -440,6 +444,7 @@ func (t *historyMetadataReplicationTask) Execute() error {
 
 	if t.version != nil {
 		return t.nDCHistoryResender.SendSingleWorkflowHistory(
+			context.Background(),
 			t.queueID.DomainID,
 			t.queueID.WorkflowID,
 			t.queueID.RunID,
@@ -506,6 +511,7 @@ func (t *historyReplicationV2Task) HandleErr(err error) error {
 	defer stopwatch.Stop()
 
 	resendErr := t.nDCHistoryResender.SendSingleWorkflowHistory(
+		context.Background(),
 		retryErr.GetDomainId(),
 		retryErr.GetWorkflowId(),
 		retryErr.GetRunId(),
@@ -517,12 +523,14 @@ func (t *historyReplicationV2Task) HandleErr(err error) error {
 	switch {
 	case resendErr == nil:
 		break
-	case resendErr == xdc.ErrSkipTask:
+	case errors.Is(resendErr, xdc.ErrSkipTask):
+		t.metricsClient.IncCounter(metrics.HistoryRereplicationByHistoryReplicationScope, xdc.GetSkipTaskReason(resendErr).MetricCounter())
 		t.logger.Error(
 			"skip replication history task",
 			tag.WorkflowDomainID(retryErr.GetDomainId()),
 			tag.WorkflowID(retryErr.GetWorkflowId()),
 			tag.WorkflowRunID(retryErr.GetRunId()),
+			tag.Error(resendErr),
 		)
 		return nil
 	default:
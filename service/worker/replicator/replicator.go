@@ -195,17 +195,23 @@ func (r *Replicator) createKafkaProcessors(currentClusterName string, clusterNam
 		r.config.ReReplicationContextTimeout,
 		r.logger,
 	)
-	nDCHistoryReplicator := xdc.NewNDCHistoryResender(
+	// the NDC history resender retries the calls by its own retry policies, so the raw clients are used
+	nDCHistoryReplicator, err := xdc.NewNDCHistoryResender(
 		r.domainCache,
-		adminRetryClient,
+		r.clientBean.GetRemoteAdminClient(clusterName),
 		func(ctx context.Context, request *h.ReplicateEventsV2Request) error {
-			return historyRetryClient.ReplicateEventsV2(ctx, request)
+			return r.historyClient.ReplicateEventsV2(ctx, request)
 		},
 		r.historySerializer,
 		r.config.ReReplicationContextTimeout,
 		nil,
+		r.metricsClient,
 		logger,
+		xdc.WithSourceCluster(clusterName),
 	)
+	if err != nil {
+		logger.Fatal("Creating NDC history resender failed", tag.Error(err))
+	}
 	r.processors = append(r.processors, newReplicationTaskProcessor(
 		currentClusterName,
 		clusterName,